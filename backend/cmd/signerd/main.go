@@ -0,0 +1,63 @@
+// Command signerd is the wallet signing daemon. It holds the wallet master key,
+// creates and stores wallet keys and signs digests for the API server, which
+// runs with SIGNER_BACKEND=remote and never decrypts keys itself.
+//
+// It reads the same .env as the server (MONGO_URI, WALLET_MASTER_KEY, ...) and
+// listens on SIGNER_ADDRESS, either "unix:/path/to/socket" or a loopback
+// "127.0.0.1:port". Set SIGNER_TOKEN on both sides to require a shared token.
+//
+//	go run ./cmd/signerd
+package main
+
+import (
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/signer"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using system environment variables")
+	}
+
+	if _, err := crypto.ActiveMasterKeyID(); err != nil {
+		log.Fatal("Invalid wallet master key configuration:", err)
+	}
+
+	address := os.Getenv("SIGNER_ADDRESS")
+	if address == "" {
+		log.Fatal("SIGNER_ADDRESS is not set")
+	}
+
+	// Connect to database
+	if err := database.Connect(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.Disconnect()
+
+	listener, err := signer.Listen(address)
+	if err != nil {
+		log.Fatal("Failed to listen:", err)
+	}
+
+	// Close the listener on shutdown so Serve returns and the socket is removed
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		listener.Close()
+	}()
+
+	log.Printf("🔏 Signer listening on %s", address)
+	if err := signer.Serve(listener, signer.NewLocalSigner(), os.Getenv("SIGNER_TOKEN")); err != nil {
+		log.Fatal("Signer stopped:", err)
+	}
+	log.Println("Signer stopped")
+}
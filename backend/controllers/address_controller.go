@@ -0,0 +1,415 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// masterKeyPath is the derivation path reported for inputs signed by the wallet's master key
+const masterKeyPath = "m"
+
+var errNotHDWallet = errors.New("wallet was created before HD support and cannot derive addresses")
+
+func getAddressCollection() *mongo.Collection {
+	return database.GetCollection("addresses")
+}
+
+// NewReceiveAddress derives a fresh receive address for the authenticated user's wallet
+func NewReceiveAddress(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	address, err := deriveNextAddress(ctx, &wallet, models.AddressChainReceive)
+	if err != nil {
+		if err == errNotHDWallet {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This wallet cannot derive new addresses. Restore it from a recovery phrase to enable them."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to derive address"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "New receive address created",
+		"address": address,
+	})
+}
+
+// GetMyAddresses lists every derived address of the authenticated user's wallet
+func GetMyAddresses(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	filter := bson.M{"walletId": wallet.WalletID}
+	if c.Query("includeChange") != "true" {
+		filter["chain"] = models.AddressChainReceive
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "chain", Value: 1}, {Key: "index", Value: 1}})
+	cursor, err := getAddressCollection().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch addresses"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var addresses []models.WalletAddress
+	if err := cursor.All(ctx, &addresses); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse addresses"})
+		return
+	}
+
+	if addresses == nil {
+		addresses = []models.WalletAddress{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"walletId":  wallet.WalletID,
+		"isHD":      wallet.IsHD(),
+		"addresses": addresses,
+		"count":     len(addresses),
+	})
+}
+
+// deriveNextAddress reserves the next unused index on a chain and stores the derived address
+func deriveNextAddress(ctx context.Context, wallet *models.Wallet, chain uint32) (*models.WalletAddress, error) {
+	if !wallet.IsHD() {
+		return nil, errNotHDWallet
+	}
+
+	counter := "nextReceiveIndex"
+	if chain == models.AddressChainChange {
+		counter = "nextChangeIndex"
+	}
+
+	// Reserve the index atomically so concurrent requests never share an address
+	var before models.Wallet
+	err := getWalletCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": wallet.ID},
+		bson.M{"$inc": bson.M{counter: 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil {
+		return nil, err
+	}
+
+	index := before.NextReceiveIndex
+	if chain == models.AddressChainChange {
+		index = before.NextChangeIndex
+	}
+
+	// The signer derives the key, so the master key never leaves it
+	path := crypto.AddressPath(chain, index)
+	publicKey, err := signer.Default().PublicKey(ctx, wallet.WalletID, path)
+	if err != nil {
+		return nil, err
+	}
+
+	address := models.WalletAddress{
+		ID:        primitive.NewObjectID(),
+		UserID:    wallet.UserID,
+		WalletID:  wallet.WalletID,
+		Address:   crypto.GenerateWalletID(publicKey),
+		PublicKey: publicKey,
+		Chain:     chain,
+		Index:     index,
+		Path:      path,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := getAddressCollection().InsertOne(ctx, address); err != nil {
+		return nil, err
+	}
+
+	return &address, nil
+}
+
+// addressGapLimit is how many unused addresses in a row a rescan derives on a chain
+// before it treats the rest of the chain as unused
+const addressGapLimit = 20
+
+// discoverWalletAddresses walks both address chains of an HD wallet after it was
+// restored. Every address up to the last one that ever received funds is stored,
+// and the wallet's index counters move past it so new addresses are never reused.
+// UTXOs recorded under another form of a derived address are moved to the address
+// as stored. It returns the number of UTXOs that were reassigned.
+func discoverWalletAddresses(ctx context.Context, wallet *models.Wallet) (int64, error) {
+	if !wallet.IsHD() {
+		return 0, nil
+	}
+
+	var recovered int64
+	for _, chain := range []uint32{models.AddressChainReceive, models.AddressChainChange} {
+		var derived []models.WalletAddress
+		var used uint32 // One past the last used index
+		for index := uint32(0); index < used+addressGapLimit; index++ {
+			path := crypto.AddressPath(chain, index)
+			publicKey, err := signer.Default().PublicKey(ctx, wallet.WalletID, path)
+			if err != nil {
+				return recovered, err
+			}
+			address := crypto.GenerateWalletID(publicKey)
+
+			// Spent UTXOs are kept, so any match means the address was handed out
+			result, err := getUTXOCollection().UpdateMany(ctx,
+				bson.M{"$or": []bson.M{
+					{"publicKey": publicKey},
+					{"walletId": bson.M{"$in": crypto.AddressAliases(address)}},
+				}},
+				bson.M{"$set": bson.M{"walletId": address}},
+			)
+			if err != nil {
+				return recovered, err
+			}
+			if result.MatchedCount > 0 {
+				used = index + 1
+				recovered += result.ModifiedCount
+			}
+
+			derived = append(derived, models.WalletAddress{
+				UserID:    wallet.UserID,
+				WalletID:  wallet.WalletID,
+				Address:   address,
+				PublicKey: publicKey,
+				Chain:     chain,
+				Index:     index,
+				Path:      path,
+				CreatedAt: time.Now(),
+			})
+		}
+
+		// Addresses that survived the restore are kept as they are
+		for _, address := range derived[:used] {
+			_, err := getAddressCollection().UpdateOne(ctx,
+				bson.M{"walletId": wallet.WalletID, "chain": address.Chain, "index": address.Index},
+				bson.M{"$setOnInsert": address},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return recovered, err
+			}
+		}
+
+		counter := "nextReceiveIndex"
+		if chain == models.AddressChainChange {
+			counter = "nextChangeIndex"
+		}
+		if _, err := getWalletCollection().UpdateOne(ctx,
+			bson.M{"_id": wallet.ID},
+			bson.M{"$max": bson.M{counter: used}},
+		); err != nil {
+			return recovered, err
+		}
+		if chain == models.AddressChainChange {
+			wallet.NextChangeIndex = max(wallet.NextChangeIndex, used)
+		} else {
+			wallet.NextReceiveIndex = max(wallet.NextReceiveIndex, used)
+		}
+	}
+
+	return recovered, nil
+}
+
+// walletAddressIDs returns the wallet's primary wallet ID followed by all of its
+// derived addresses, for queries that aggregate funds across the whole wallet
+func walletAddressIDs(ctx context.Context, wallet *models.Wallet) ([]string, error) {
+	addresses := []string{wallet.WalletID}
+	if !wallet.IsHD() {
+		return addresses, nil
+	}
+
+	cursor, err := getAddressCollection().Find(ctx, bson.M{"walletId": wallet.WalletID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var derived []models.WalletAddress
+	if err := cursor.All(ctx, &derived); err != nil {
+		return nil, err
+	}
+
+	for _, address := range derived {
+		addresses = append(addresses, address.Address)
+	}
+	return addresses, nil
+}
+
+// resolveAddress finds the wallet that owns an address, which is either a primary
+// wallet ID or a derived address, in its Base58Check or legacy hex form. The returned
+// WalletAddress holds the address as stored and the public key funds sent there are
+// locked to. It returns mongo.ErrNoDocuments for unknown addresses.
+//
+// A multisig address resolves to a Wallet holding only the multisig wallet's ID
+// and address. Funds sent there are locked to its redeem policy, so the returned
+// WalletAddress has no public key.
+func resolveAddress(ctx context.Context, address string) (*models.Wallet, *models.WalletAddress, error) {
+	if crypto.IsMultisigAddress(address) {
+		multisig, err := findMultisigWallet(ctx, address)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &models.Wallet{ID: multisig.ID, WalletID: multisig.WalletID}, &models.WalletAddress{
+			WalletID: multisig.WalletID,
+			Address:  multisig.WalletID,
+		}, nil
+	}
+
+	aliases := bson.M{"$in": crypto.AddressAliases(address)}
+
+	var wallet models.Wallet
+	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": aliases}).Decode(&wallet)
+	if err == nil {
+		return &wallet, &models.WalletAddress{
+			UserID:    wallet.UserID,
+			WalletID:  wallet.WalletID,
+			Address:   wallet.WalletID,
+			PublicKey: wallet.PublicKey,
+			Path:      masterKeyPath,
+		}, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, nil, err
+	}
+
+	var derived models.WalletAddress
+	if err := getAddressCollection().FindOne(ctx, bson.M{"address": aliases}).Decode(&derived); err != nil {
+		return nil, nil, err
+	}
+
+	if err := getWalletCollection().FindOne(ctx, bson.M{"walletId": derived.WalletID}).Decode(&wallet); err != nil {
+		return nil, nil, err
+	}
+	return &wallet, &derived, nil
+}
+
+// changeDestination returns where a wallet's transaction change should go: a fresh
+// internal address for HD wallets, or the primary wallet ID for older wallets
+func changeDestination(ctx context.Context, wallet *models.Wallet) (string, string, error) {
+	if !wallet.IsHD() {
+		return wallet.WalletID, wallet.PublicKey, nil
+	}
+
+	address, err := deriveNextAddress(ctx, wallet, models.AddressChainChange)
+	if err != nil {
+		return "", "", err
+	}
+	return address.Address, address.PublicKey, nil
+}
+
+// utxoKeyPaths returns the derivation path of the key that controls each UTXO
+func utxoKeyPaths(ctx context.Context, wallet *models.Wallet, utxos []models.UTXO) ([]string, error) {
+	var derivedAddresses []string
+	for _, utxo := range utxos {
+		if utxo.WalletID != wallet.WalletID {
+			derivedAddresses = append(derivedAddresses, utxo.WalletID)
+		}
+	}
+
+	pathByAddress := map[string]string{}
+	if len(derivedAddresses) > 0 {
+		cursor, err := getAddressCollection().Find(ctx, bson.M{
+			"walletId": wallet.WalletID,
+			"address":  bson.M{"$in": derivedAddresses},
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var derived []models.WalletAddress
+		if err := cursor.All(ctx, &derived); err != nil {
+			return nil, err
+		}
+		for _, address := range derived {
+			pathByAddress[address.Address] = address.Path
+		}
+	}
+
+	paths := make([]string, len(utxos))
+	for i, utxo := range utxos {
+		if utxo.WalletID == wallet.WalletID {
+			paths[i] = masterKeyPath
+			continue
+		}
+		path, ok := pathByAddress[utxo.WalletID]
+		if !ok {
+			return nil, errors.New("UTXO does not belong to this wallet: " + utxo.WalletID)
+		}
+		paths[i] = path
+	}
+
+	return paths, nil
+}
+
+// signUTXOInputs signs each input spending utxos with the key that controls it,
+// through the configured signer
+func signUTXOInputs(ctx context.Context, wallet *models.Wallet, utxos []models.UTXO, inputs []crypto.InputData, outputs []crypto.OutputData, hashType crypto.SigHashType) ([]string, error) {
+	paths, err := utxoKeyPaths(ctx, wallet, utxos)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]string, len(utxos))
+	for i, path := range paths {
+		digest, err := crypto.SigHashDigest(inputs, outputs, i, hashType)
+		if err != nil {
+			return nil, err
+		}
+		if signatures[i], err = signer.Default().SignDigest(ctx, wallet.WalletID, path, digest); err != nil {
+			return nil, err
+		}
+	}
+
+	return signatures, nil
+}
@@ -60,7 +60,7 @@ func GetSystemStats(c *gin.Context) {
 	pendingTx, _ := getTransactionCollection().CountDocuments(ctx, bson.M{"status": "pending"})
 
 	// Count blocks
-	blockCount, _ := getBlockCollection().CountDocuments(ctx, bson.M{})
+	blockCount, _ := getBlockCollection().CountDocuments(ctx, mainChain(bson.M{}))
 
 	// Count UTXOs
 	utxoCount, _ := getUTXOCollection().CountDocuments(ctx, bson.M{})
@@ -72,13 +72,13 @@ func GetSystemStats(c *gin.Context) {
 		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}},
 	}
 	cursor, err := getUTXOCollection().Aggregate(ctx, pipeline)
-	var totalBalance float64
+	var totalBalance models.Amount
 	if err == nil {
-		var results []bson.M
+		var results []struct {
+			Total models.Amount `bson:"total"`
+		}
 		if cursor.All(ctx, &results) == nil && len(results) > 0 {
-			if total, ok := results[0]["total"].(float64); ok {
-				totalBalance = total
-			}
+			totalBalance = results[0].Total
 		}
 	}
 
@@ -95,7 +95,7 @@ func GetSystemStats(c *gin.Context) {
 			"total":   txCount,
 			"pending": pendingTx,
 		},
-		"blocks":       blockCount,
+		"blocks": blockCount,
 		"utxos": gin.H{
 			"total":   utxoCount,
 			"unspent": unspentUtxos,
@@ -110,7 +110,7 @@ func GetAllTransactions(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := getTransactionCollection().Find(ctx, bson.M{}, 
+	cursor, err := getTransactionCollection().Find(ctx, bson.M{},
 		options.Find().SetSort(bson.M{"timestamp": -1}).SetLimit(100))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
@@ -135,7 +135,7 @@ func GetAllBlocks(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := getBlockCollection().Find(ctx, bson.M{}, 
+	cursor, err := getBlockCollection().Find(ctx, bson.M{},
 		options.Find().SetSort(bson.M{"index": -1}).SetLimit(100))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
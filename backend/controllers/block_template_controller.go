@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// External miners work from block templates: GET /template describes the next
+// block with a coinbase paying the caller, and POST /submit hands back the
+// nonce that solves it. The server keeps no template state. A submission names
+// the parent, timestamp, extra nonce and transactions it was mined with, and the
+// block is rebuilt from those and checked before it is saved. The parent may be
+// any known block, so miners can extend a side branch, but the block may only
+// spend outputs confirmed on that branch.
+
+// maxFutureBlockTime is how far ahead of the server's clock a submitted block's
+// timestamp may be
+const maxFutureBlockTime = 2 * time.Hour
+
+// GetBlockTemplate returns the next block for the caller to mine, with the
+// reward paid to their wallet. ?extraNonce picks the coinbase extra nonce, so
+// a miner that runs out of nonces can fetch a header with a new Merkle root.
+func GetBlockTemplate(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var extraNonce uint64
+	if value := c.Query("extraNonce"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid extraNonce"})
+			return
+		}
+		extraNonce = parsed
+	}
+
+	minerWallet, ok := loadMinerWallet(ctx, c)
+	if !ok {
+		return
+	}
+
+	lastBlock, err := loadChainTip(ctx)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Submitted timestamps have second precision, so the template's does too
+	timestamp := time.Unix(time.Now().Unix(), 0)
+	transactions, err := selectBlockTransactions(ctx, lastBlock.Index+1, timestamp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to select transactions"})
+		return
+	}
+	tpl, err := newBlockTemplate(ctx, lastBlock, transactions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build block template"})
+		return
+	}
+	tpl = tpl.payToWallet(minerWallet)
+
+	header, coinbaseTx := tpl.header(extraNonce, timestamp)
+	target, err := crypto.HeaderTarget(header)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid block target"})
+		return
+	}
+	data, err := crypto.SerializeBlockHeader(header)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to serialize block header"})
+		return
+	}
+
+	txIDs := make([]string, len(transactions))
+	for i, tx := range transactions {
+		txIDs[i] = tx.TransactionID
+	}
+	if transactions == nil {
+		transactions = []models.Transaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"template": models.BlockTemplate{
+			Version:        header.Version,
+			Index:          header.Index,
+			PreviousHash:   header.PreviousHash,
+			Timestamp:      timestamp.Unix(),
+			MinTimestamp:   lastBlock.Timestamp.Unix(),
+			MaxTimestamp:   time.Now().Add(maxFutureBlockTime).Unix(),
+			Bits:           header.Bits,
+			Target:         crypto.FormatTarget(target),
+			Difficulty:     header.Difficulty,
+			ExtraNonce:     extraNonce,
+			MerkleRoot:     header.MerkleRoot,
+			Coinbase:       coinbaseTx,
+			Transactions:   transactions,
+			TransactionIDs: txIDs,
+			MiningReward:   tpl.reward,
+			Header:         hex.EncodeToString(data),
+		},
+	})
+}
+
+// SubmitBlock saves a block mined from a template. The block is rebuilt from
+// the request and the caller's wallet, and its hash must meet the target. A
+// block that doesn't end up on the main chain is kept as a stale block and
+// reported with 202 Accepted.
+func SubmitBlock(c *gin.Context) {
+	var req models.SubmitBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	minerWallet, ok := loadMinerWallet(ctx, c)
+	if !ok {
+		return
+	}
+
+	parent, err := loadBlockByHash(ctx, req.PreviousHash)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	timestamp := time.Unix(req.Timestamp, 0)
+	if timestamp.Before(time.Unix(parent.Timestamp.Unix(), 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Block timestamp is before its parent's"})
+		return
+	}
+	if timestamp.After(time.Now().Add(maxFutureBlockTime)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Block timestamp is too far in the future"})
+		return
+	}
+
+	transactions, err := loadSubmittedTransactions(ctx, req.TransactionIDs, parent, timestamp)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tpl, err := newBlockTemplate(ctx, parent, transactions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build block"})
+		return
+	}
+	tpl = tpl.payToWallet(minerWallet)
+	header, coinbaseTx := tpl.header(req.ExtraNonce, timestamp)
+	header.Nonce = req.Nonce
+	header.Hash, err = crypto.HashBlock(header)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash block"})
+		return
+	}
+	if err := crypto.CheckProofOfWork(header); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid proof of work: " + err.Error(), "hash": header.Hash})
+		return
+	}
+
+	block, err := tpl.save(header, coinbaseTx)
+	if errors.Is(err, errNewTip) {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Block stored on a side branch with less work than the main chain",
+			"block":   block,
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Block accepted! 🎉",
+		"block":   block,
+		"reward":  tpl.reward,
+	})
+}
+
+// loadMinerWallet loads the caller's wallet, writing an error response if it
+// is missing
+func loadMinerWallet(ctx context.Context, c *gin.Context) (*models.Wallet, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+		return nil, false
+	}
+	return &wallet, true
+}
+
+// loadSubmittedTransactions loads the transactions a block mined on parent
+// includes, in the order given, checking each is pending, final at the block's
+// height and timestamp, not spending outputs of a pending transaction left out
+// of the block or placed after it, and not spending outputs confirmed off
+// parent's branch
+func loadSubmittedTransactions(ctx context.Context, txIDs []string, parent *models.Block, timestamp time.Time) ([]models.Transaction, error) {
+	height := parent.Index + 1
+	if len(txIDs) > models.DefaultBlockchainConfig.MaxTransactionsPerBlock {
+		return nil, badRequest("A block holds at most %d transactions", models.DefaultBlockchainConfig.MaxTransactionsPerBlock)
+	}
+	if len(txIDs) == 0 {
+		return nil, nil
+	}
+
+	position := make(map[string]int, len(txIDs))
+	for i, id := range txIDs {
+		if _, dup := position[id]; dup {
+			return nil, badRequest("Transaction %s is included twice", id)
+		}
+		position[id] = i
+	}
+
+	cursor, err := getTransactionCollection().Find(ctx, bson.M{
+		"transactionId": bson.M{"$in": txIDs},
+		"status":        models.TxStatusPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []models.Transaction
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	transactions := make([]models.Transaction, len(txIDs))
+	loaded := make([]bool, len(txIDs))
+	for _, tx := range found {
+		i := position[tx.TransactionID]
+		transactions[i] = tx
+		loaded[i] = true
+	}
+	for i, id := range txIDs {
+		if !loaded[i] {
+			return nil, &apiError{status: http.StatusConflict, message: fmt.Sprintf("Transaction %s is not pending", id)}
+		}
+		if !crypto.IsFinalTransaction(&transactions[i], height, timestamp.Unix()) {
+			return nil, badRequest("Transaction %s is not final at height %d", id, height)
+		}
+	}
+
+	// withoutUnminedParents filters in place, so give it a copy
+	ready, err := withoutUnminedParents(ctx, append([]models.Transaction(nil), transactions...))
+	if err != nil {
+		return nil, err
+	}
+	if len(ready) != len(transactions) {
+		return nil, badRequest("Block includes transactions before the pending transactions they spend")
+	}
+	if err := checkInputsOnBranch(ctx, transactions, parent); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// checkInputsOnBranch checks that the confirmed outputs transactions spend were
+// confirmed in parent or its ancestors. An output confirmed only on another
+// branch goes back to pending, or is removed, if the block's branch takes over,
+// so the block couldn't be connected.
+func checkInputsOnBranch(ctx context.Context, transactions []models.Transaction, parent *models.Block) error {
+	var sourceIDs []string
+	for _, tx := range transactions {
+		for _, input := range tx.Inputs {
+			sourceIDs = append(sourceIDs, input.TransactionID)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+
+	// Outputs the faucet creates are confirmed outside any block, so they are
+	// on every branch
+	cursor, err := getUTXOCollection().Find(ctx, bson.M{
+		"transactionId": bson.M{"$in": sourceIDs},
+		"isConfirmed":   true,
+		"blockHash":     bson.M{"$nin": bson.A{"", nil}},
+	}, options.Find().SetProjection(bson.M{"transactionId": 1, "blockHash": 1, "blockHeight": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var confirmed []models.UTXO
+	if err := cursor.All(ctx, &confirmed); err != nil {
+		return err
+	}
+
+	onBranch := map[string]bool{}
+	for _, utxo := range confirmed {
+		ok, checked := onBranch[utxo.BlockHash]
+		if !checked {
+			ok = utxo.BlockHeight <= parent.Index
+			if ok {
+				ancestor, err := blockAncestor(ctx, parent, utxo.BlockHeight)
+				if err != nil {
+					return err
+				}
+				ok = ancestor.Hash == utxo.BlockHash
+			}
+			onBranch[utxo.BlockHash] = ok
+		}
+		if !ok {
+			return badRequest("Block spends outputs of %s, which are confirmed in block %s, not on the branch of %s",
+				utxo.TransactionID, utxo.BlockHash, parent.Hash)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"crypto-wallet-backend/crypto"
 	"crypto-wallet-backend/database"
 	"crypto-wallet-backend/models"
+	"errors"
+	"math/big"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,6 +18,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// errNewTip cancels mining when another block extends the chain first
+var errNewTip = errors.New("chain tip changed")
+
 func getBlockCollection() *mongo.Collection {
 	return database.GetCollection("blocks")
 }
@@ -26,11 +31,11 @@ func GetBlockchainStats(c *gin.Context) {
 	defer cancel()
 
 	// Count blocks
-	totalBlocks, _ := getBlockCollection().CountDocuments(ctx, bson.M{})
+	totalBlocks, _ := getBlockCollection().CountDocuments(ctx, mainChain(bson.M{}))
 
 	// Count all transactions in blocks
 	var totalTransactions int64
-	cursor, err := getBlockCollection().Find(ctx, bson.M{})
+	cursor, err := getBlockCollection().Find(ctx, mainChain(bson.M{}))
 	if err == nil {
 		defer cursor.Close(ctx)
 		for cursor.Next(ctx) {
@@ -43,24 +48,31 @@ func GetBlockchainStats(c *gin.Context) {
 
 	// Get last block
 	var lastBlock models.Block
-	err = getBlockCollection().FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
-	
+	err = getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
+
 	lastBlockHash := ""
 	lastBlockTime := ""
 	currentDifficulty := models.DefaultBlockchainConfig.InitialDifficulty
-	
+	currentBits := crypto.TargetToCompact(crypto.LeadingZerosTarget(currentDifficulty))
+	chainWork := crypto.FormatChainWork(new(big.Int))
+
 	if err == nil {
 		lastBlockHash = lastBlock.Hash
 		lastBlockTime = lastBlock.Timestamp.Format(time.RFC3339)
 		currentDifficulty = lastBlock.Difficulty
+		header := lastBlock.Header()
+		currentBits = crypto.HeaderBits(&header)
+		if work, err := blockChainWork(ctx, &lastBlock); err == nil {
+			chainWork = crypto.FormatChainWork(work)
+		}
 	}
 
 	// Count pending transactions
 	pendingCount, _ := getTransactionCollection().CountDocuments(ctx, bson.M{"status": models.TxStatusPending})
 
 	// Calculate total mining rewards
-	var totalRewards float64
-	cursor2, err := getBlockCollection().Find(ctx, bson.M{})
+	var totalRewards models.Amount
+	cursor2, err := getBlockCollection().Find(ctx, mainChain(bson.M{}))
 	if err == nil {
 		defer cursor2.Close(ctx)
 		for cursor2.Next(ctx) {
@@ -78,6 +90,8 @@ func GetBlockchainStats(c *gin.Context) {
 			CurrentDifficulty:   currentDifficulty,
 			LastBlockHash:       lastBlockHash,
 			LastBlockTime:       lastBlockTime,
+			CurrentBits:         currentBits,
+			ChainWork:           chainWork,
 			TotalMiningRewards:  totalRewards,
 			PendingTransactions: int(pendingCount),
 		},
@@ -101,10 +115,10 @@ func GetBlocks(c *gin.Context) {
 	skip := (page - 1) * limit
 
 	// Get total count
-	total, _ := getBlockCollection().CountDocuments(ctx, bson.M{})
+	total, _ := getBlockCollection().CountDocuments(ctx, mainChain(bson.M{}))
 
 	// Get blocks sorted by index descending (newest first)
-	cursor, err := getBlockCollection().Find(ctx, bson.M{},
+	cursor, err := getBlockCollection().Find(ctx, mainChain(bson.M{}),
 		options.Find().
 			SetSort(bson.M{"index": -1}).
 			SetSkip(int64(skip)).
@@ -129,7 +143,8 @@ func GetBlocks(c *gin.Context) {
 	})
 }
 
-// GetBlock returns a specific block by hash or index
+// GetBlock returns a specific block by hash or index. An index names a block on
+// the main chain; a hash may name a stale block on a side branch as well.
 func GetBlock(c *gin.Context) {
 	identifier := c.Param("identifier")
 
@@ -141,7 +156,7 @@ func GetBlock(c *gin.Context) {
 
 	// Try to parse as index first
 	if index, parseErr := strconv.ParseInt(identifier, 10, 64); parseErr == nil {
-		err = getBlockCollection().FindOne(ctx, bson.M{"index": index}).Decode(&block)
+		err = getBlockCollection().FindOne(ctx, mainChain(bson.M{"index": index})).Decode(&block)
 	} else {
 		// Try as hash
 		err = getBlockCollection().FindOne(ctx, bson.M{"hash": identifier}).Decode(&block)
@@ -165,7 +180,7 @@ func GetLatestBlock(c *gin.Context) {
 	defer cancel()
 
 	var block models.Block
-	err := getBlockCollection().FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"index": -1})).Decode(&block)
+	err := getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&block)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "No blocks found. Genesis block not created yet."})
@@ -178,6 +193,54 @@ func GetLatestBlock(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"block": block})
 }
 
+// GetMerkleProof returns the header of the block containing a transaction and
+// the Merkle branch linking the transaction to it, so light clients can check
+// inclusion with crypto.VerifyMerkleProof. Coinbase transactions are only part
+// of the Merkle tree from header version 4.
+func GetMerkleProof(c *gin.Context) {
+	txID := c.Param("txId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var block models.Block
+	err := getBlockCollection().FindOne(ctx, mainChain(bson.M{"$or": []bson.M{
+		{"transactions.transactionId": txID},
+		{"coinbase.transactionId": txID},
+	}})).Decode(&block)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found in any block"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	proof, err := crypto.MerkleBranch(crypto.BlockMerkleLeaves(&block), txID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build Merkle proof", "details": err.Error()})
+		return
+	}
+	if proof.MerkleRoot != block.MerkleRoot {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Block transactions don't match its Merkle root"})
+		return
+	}
+
+	var lastBlock models.Block
+	err = getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blockHeader":   block.Header(),
+		"proof":         proof,
+		"confirmations": lastBlock.Index - block.Index + 1,
+	})
+}
+
 // CreateGenesisBlock creates the first block in the chain
 func CreateGenesisBlock(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -194,6 +257,10 @@ func CreateGenesisBlock(c *gin.Context) {
 	now := time.Now()
 	genesisHash := crypto.GetGenesisBlockHash()
 
+	difficulty := models.DefaultBlockchainConfig.InitialDifficulty
+	bits := crypto.TargetToCompact(crypto.LeadingZerosTarget(difficulty))
+	target, _ := crypto.CompactToTarget(bits)
+
 	genesis := models.Block{
 		Index:            0,
 		Hash:             genesisHash,
@@ -203,7 +270,9 @@ func CreateGenesisBlock(c *gin.Context) {
 		TransactionCount: 0,
 		MerkleRoot:       crypto.CalculateMerkleRoot([]string{}),
 		Nonce:            0,
-		Difficulty:       models.DefaultBlockchainConfig.InitialDifficulty,
+		Difficulty:       difficulty,
+		Bits:             bits,
+		ChainWork:        crypto.FormatChainWork(crypto.TargetWork(target)),
 		MinerWalletID:    "system",
 		MiningReward:     0,
 		Size:             0,
@@ -221,299 +290,425 @@ func CreateGenesisBlock(c *gin.Context) {
 	})
 }
 
-// MineBlock mines a new block with pending transactions
-func MineBlock(c *gin.Context) {
-	userID := c.GetString("userId")
+// blockTemplate is the next block being assembled on top of parent: everything
+// but the coinbase, timestamp and nonce, which vary while mining
+type blockTemplate struct {
+	parent        models.Block
+	index         int64
+	transactions  []models.Transaction
+	bits          uint32
+	difficulty    int
+	chainWork     *big.Int // Total work including this block
+	reward        models.Amount
+	minerWalletID string                     // Recorded as the block's miner
+	payouts       []models.TransactionOutput // Coinbase outputs, adding up to reward
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) // Longer timeout for mining
-	defer cancel()
+// loadChainTip returns the latest block of the main chain, as recorded in the
+// chain state document. Chains whose tip hasn't moved since the document was
+// introduced don't have one, and fall back to the highest main chain block.
+func loadChainTip(ctx context.Context) (*models.Block, error) {
+	var state struct {
+		TipHash string `bson:"tipHash"`
+	}
+	err := getChainStateCollection().FindOne(ctx, bson.M{"_id": chainStateID}).Decode(&state)
+	if err == nil {
+		return loadBlockByHash(ctx, state.TipHash)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
 
-	objID, err := primitive.ObjectIDFromHex(userID)
+	var lastBlock models.Block
+	err = getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+		if err == mongo.ErrNoDocuments {
+			return nil, badRequest("No genesis block. Please create genesis block first.")
+		}
+		return nil, err
 	}
+	return &lastBlock, nil
+}
 
-	// Get miner's wallet
-	var minerWallet models.Wallet
-	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&minerWallet)
+// newBlockTemplate prepares the block after parent with transactions,
+// retargeting every N blocks. Set who the reward is paid to with payTo or
+// payToWallet before mining it.
+func newBlockTemplate(ctx context.Context, parent *models.Block, transactions []models.Transaction) (*blockTemplate, error) {
+	bits, err := nextBlockBits(ctx, parent)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
-		return
+		return nil, err
+	}
+	target, err := crypto.CompactToTarget(bits)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get the latest block
-	var lastBlock models.Block
-	err = getBlockCollection().FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
+	parentWork, err := blockChainWork(ctx, parent)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No genesis block. Please create genesis block first."})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+		return nil, err
+	}
+
+	// The coinbase also collects the fees of the block's transactions
+	reward := models.DefaultBlockchainConfig.BlockReward
+	for _, tx := range transactions {
+		reward += tx.Fee
+	}
+
+	return &blockTemplate{
+		parent:       *parent,
+		index:        parent.Index + 1,
+		transactions: transactions,
+		bits:         bits,
+		difficulty:   crypto.TargetLeadingZeros(target),
+		chainWork:    new(big.Int).Add(parentWork, crypto.TargetWork(target)),
+		reward:       reward,
+	}, nil
+}
+
+// payTo returns a copy of the template whose coinbase pays payouts instead,
+// recording minerWalletID as the block's miner. The payouts must add up to the
+// reward.
+func (t *blockTemplate) payTo(minerWalletID string, payouts []models.TransactionOutput) *blockTemplate {
+	paid := *t
+	paid.minerWalletID = minerWalletID
+	paid.payouts = payouts
+	return &paid
+}
+
+// payToWallet returns a copy of the template paying the whole reward to wallet
+func (t *blockTemplate) payToWallet(wallet *models.Wallet) *blockTemplate {
+	return t.payTo(wallet.WalletID, []models.TransactionOutput{walletPayout(wallet, t.reward)})
+}
+
+// walletPayout returns a coinbase output paying amount to wallet
+func walletPayout(wallet *models.Wallet, amount models.Amount) models.TransactionOutput {
+	return models.TransactionOutput{
+		WalletID:      wallet.WalletID,
+		Amount:        amount,
+		PublicKey:     wallet.PublicKey,
+		LockingScript: outputLockingScript(wallet.WalletID),
 	}
+}
 
-	// Get pending transactions
-	cursor, err := getTransactionCollection().Find(ctx, bson.M{"status": models.TxStatusPending},
-		options.Find().SetLimit(int64(models.DefaultBlockchainConfig.MaxTransactionsPerBlock)))
+// header returns the header to mine for an extra nonce and timestamp along
+// with the coinbase it commits to as the first Merkle leaf
+func (t *blockTemplate) header(extraNonce uint64, timestamp time.Time) (*models.BlockHeader, models.Transaction) {
+	coinbaseTx := newCoinbaseTransaction(t.payouts, t.reward, t.index, extraNonce, timestamp)
+
+	txIDs := make([]string, 0, len(t.transactions)+1)
+	txIDs = append(txIDs, coinbaseTx.TransactionID)
+	for _, tx := range t.transactions {
+		txIDs = append(txIDs, tx.TransactionID)
+	}
+
+	return &models.BlockHeader{
+		Version:      crypto.BlockHeaderVersion,
+		Index:        t.index,
+		PreviousHash: t.parent.Hash,
+		Timestamp:    timestamp,
+		MerkleRoot:   crypto.CalculateMerkleRoot(txIDs),
+		Difficulty:   t.difficulty,
+		Bits:         t.bits,
+	}, coinbaseTx
+}
+
+// selectBlockTransactions returns the pending transactions ready for the block
+// at height mined at timestamp, oldest first
+func selectBlockTransactions(ctx context.Context, height int64, timestamp time.Time) ([]models.Transaction, error) {
+	// Get pending transactions whose lock time has passed
+	filter := lockReachedFilter("lockTime", height, timestamp)
+	filter["status"] = models.TxStatusPending
+	cursor, err := getTransactionCollection().Find(ctx, filter,
+		options.Find().SetSort(bson.M{"timestamp": 1}).SetLimit(int64(models.DefaultBlockchainConfig.MaxTransactionsPerBlock)))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending transactions"})
-		return
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
 	var pendingTxs []models.Transaction
 	if err := cursor.All(ctx, &pendingTxs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transactions"})
-		return
+		return nil, err
 	}
 
-	// Build transaction ID list for merkle root
-	txIDs := make([]string, len(pendingTxs))
-	for i, tx := range pendingTxs {
-		txIDs[i] = tx.TransactionID
-	}
+	return withoutUnminedParents(ctx, pendingTxs)
+}
 
-	// Calculate merkle root
-	merkleRoot := crypto.CalculateMerkleRoot(txIDs)
+// mineNextBlock mines the block after the current tip with the pending
+// transactions that are ready, paying the reward to minerWallet, and saves it.
+// started is called with the header template and transaction count once
+// mining begins. If another block extends the chain first, mining stops with
+// errNewTip and the caller may start again on the new tip. The returned miner
+// result is set whenever mining ran, even if it failed.
+func mineNextBlock(ctx context.Context, minerWallet *models.Wallet, miner *crypto.Miner, started func(header *models.BlockHeader, txCount int)) (*models.Block, *crypto.MinerResult, error) {
+	readCtx, cancelRead := context.WithTimeout(ctx, 30*time.Second)
+	defer cancelRead()
+
+	lastBlock, err := loadChainTip(readCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	pendingTxs, err := selectBlockTransactions(readCtx, lastBlock.Index+1, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	tpl, err := newBlockTemplate(readCtx, lastBlock, pendingTxs)
+	if err != nil {
+		return nil, nil, err
+	}
+	tpl = tpl.payToWallet(minerWallet)
+
+	// Each template gets a fresh timestamp and a coinbase committing to its
+	// extra nonce, which changes the merkle root
+	var coinbaseTx models.Transaction
+	template := func(extraNonce uint64) (*models.BlockHeader, error) {
+		var header *models.BlockHeader
+		header, coinbaseTx = tpl.header(extraNonce, time.Now())
+		if started != nil && extraNonce == 0 {
+			started(header, len(pendingTxs))
+		}
+		return header, nil
+	}
 
-	// Prepare new block
-	newIndex := lastBlock.Index + 1
-	timestamp := time.Now()
-	difficulty := lastBlock.Difficulty
+	// Mine the block on every core until a valid hash is found, ctx is done or
+	// another block becomes the tip
+	miningCtx, stopMining := context.WithCancelCause(ctx)
+	defer stopMining(nil)
+	go cancelOnNewTip(miningCtx, stopMining, lastBlock.Hash)
 
-	// Adjust difficulty every N blocks
-	if newIndex%int64(models.DefaultBlockchainConfig.DifficultyAdjustment) == 0 && newIndex > 0 {
-		// Simple difficulty adjustment - could be improved
-		difficulty = calculateNewDifficulty(ctx, difficulty)
+	result, err := miner.Mine(miningCtx, template)
+	if err != nil {
+		if context.Cause(miningCtx) == errNewTip {
+			return nil, result, errNewTip
+		}
+		return nil, result, err
 	}
+	stopMining(nil)
 
-	// Mine the block (find valid nonce)
-	maxIterations := int64(10000000) // 10 million attempts max
-	nonce, hash, found := crypto.MineBlock(newIndex, lastBlock.Hash, timestamp, merkleRoot, difficulty, maxIterations)
+	block, err := tpl.save(&result.Header, coinbaseTx)
+	if err != nil {
+		return nil, result, err
+	}
+	return block, result, nil
+}
 
-	if !found {
-		c.JSON(http.StatusRequestTimeout, gin.H{
-			"error":   "Mining timeout - could not find valid hash",
-			"message": "Try again or reduce difficulty",
-		})
-		return
+// save stores a solved block built from the template. If it becomes the chain
+// tip its transactions are confirmed and its coinbase outputs created; if it
+// loses to another block at its height it is kept as a stale block and returned
+// with errNewTip. The block is saved even if the caller's context has been
+// cancelled meanwhile.
+func (t *blockTemplate) save(header *models.BlockHeader, coinbaseTx models.Transaction) (*models.Block, error) {
+	hash := header.Hash
+	blockSize, err := crypto.BlockSize(header, append([]models.Transaction{coinbaseTx}, t.transactions...))
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the block
-	miningReward := models.DefaultBlockchainConfig.BlockReward
+	now := time.Now()
+	coinbaseTx.Status = models.TxStatusConfirmed
+	coinbaseTx.BlockHash = hash
+	coinbaseTx.BlockHeight = t.index
+	coinbaseTx.ConfirmedAt = &now
 	newBlock := models.Block{
-		Index:            newIndex,
+		Version:          header.Version,
+		Index:            t.index,
 		Hash:             hash,
-		PreviousHash:     lastBlock.Hash,
-		Timestamp:        timestamp,
-		Transactions:     pendingTxs,
-		TransactionCount: len(pendingTxs),
-		MerkleRoot:       merkleRoot,
-		Nonce:            nonce,
-		Difficulty:       difficulty,
-		MinerWalletID:    minerWallet.WalletID,
-		MiningReward:     miningReward,
-		Size:             int64(len(pendingTxs) * 500), // Approximate size
-	}
+		PreviousHash:     t.parent.Hash,
+		Timestamp:        header.Timestamp,
+		Transactions:     t.transactions,
+		Coinbase:         &coinbaseTx,
+		TransactionCount: len(t.transactions),
+		MerkleRoot:       header.MerkleRoot,
+		Nonce:            header.Nonce,
+		Difficulty:       t.difficulty,
+		Bits:             t.bits,
+		ChainWork:        crypto.FormatChainWork(t.chainWork),
+		MinerWalletID:    t.minerWalletID,
+		MiningReward:     t.reward,
+		Size:             blockSize,
+	}
+
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelSave()
 
 	// Start a session for atomic operations
 	session, err := database.GetClient().StartSession()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start database session"})
-		return
+		return nil, err
 	}
-	defer session.EndSession(ctx)
+	defer session.EndSession(saveCtx)
 
 	// Execute atomically
-	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Insert the block
-		_, err := getBlockCollection().InsertOne(sessCtx, newBlock)
-		if err != nil {
-			return nil, err
-		}
+	_, err = session.WithTransaction(saveCtx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, acceptBlock(sessCtx, &newBlock)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if newBlock.Stale {
+		return &newBlock, errNewTip
+	}
+	return &newBlock, nil
+}
 
-		// Update transactions to confirmed
-		now := time.Now()
-		for _, tx := range pendingTxs {
-			_, err := getTransactionCollection().UpdateOne(sessCtx,
-				bson.M{"transactionId": tx.TransactionID},
-				bson.M{"$set": bson.M{
-					"status":      models.TxStatusConfirmed,
-					"blockHash":   hash,
-					"blockHeight": newIndex,
-					"confirmedAt": now,
-				}})
-			if err != nil {
-				return nil, err
-			}
+// newCoinbaseTransaction builds the transaction paying the mining reward for
+// the block at height to payouts. Its coinbase data commits to the height and
+// extraNonce.
+func newCoinbaseTransaction(payouts []models.TransactionOutput, reward models.Amount, height int64, extraNonce uint64, timestamp time.Time) models.Transaction {
+	tx := models.Transaction{
+		Type:         models.TxTypeCoinbase,
+		SenderWallet: "",
+		Outputs:      payouts,
+		TotalInput:   0,
+		TotalOutput:  reward,
+		Fee:          0,
+		Message:      "Mining Reward",
+		Timestamp:    timestamp,
+		CoinbaseData: crypto.CoinbaseData(height, extraNonce),
+	}
+	tx.TransactionID = crypto.GenerateTransactionID(&tx)
+	return tx
+}
 
-			// If this is a zakat transaction, update the zakat_payments status
-			if tx.Type == models.TxTypeZakat {
-				_, err = database.GetCollection("zakat_payments").UpdateOne(sessCtx,
-					bson.M{"transactionId": tx.TransactionID},
-					bson.M{"$set": bson.M{
-						"status":      "confirmed",
-						"confirmedAt": now,
-					}})
-				if err != nil {
-					return nil, err
-				}
-			}
+// cancelOnNewTip polls the chain tip while ctx is live and cancels it with
+// errNewTip once a block other than tipHash is the latest, since a block mined
+// on the old tip would no longer extend the chain
+func cancelOnNewTip(ctx context.Context, cancel context.CancelCauseFunc, tipHash string) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 
-			// Mark UTXOs as confirmed
-			for _, output := range tx.Outputs {
-				_, err := getUTXOCollection().UpdateMany(sessCtx,
-					bson.M{"transactionId": tx.TransactionID, "walletId": output.WalletID},
-					bson.M{"$set": bson.M{
-						"isConfirmed": true,
-						"blockHash":   hash,
-					}})
-				if err != nil {
-					return nil, err
-				}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			latest, err := chainTipHash(ctx)
+			if err == nil && latest != tipHash {
+				cancel(errNewTip)
+				return
 			}
 		}
+	}
+}
 
-		// Create coinbase UTXO for mining reward
-		coinbaseTxID := crypto.GenerateTransactionID(minerWallet.WalletID, nil, []crypto.OutputData{{WalletID: minerWallet.WalletID, Amount: miningReward}}, timestamp.Unix())
-		coinbaseUTXO := models.UTXO{
-			TransactionID: coinbaseTxID,
-			OutputIndex:   0,
-			WalletID:      minerWallet.WalletID,
-			Amount:        miningReward,
-			PublicKey:     minerWallet.PublicKey,
-			IsSpent:       false,
-			IsConfirmed:   true,
-			BlockHash:     hash,
-			CreatedAt:     now,
-		}
-		_, err = getUTXOCollection().InsertOne(sessCtx, coinbaseUTXO)
-		if err != nil {
-			return nil, err
-		}
-
-		// Create coinbase Transaction record for mining reward (so it shows in transaction history)
-		coinbaseTx := models.Transaction{
-			TransactionID: coinbaseTxID,
-			Type:          models.TxTypeCoinbase,
-			SenderWallet:  "",
-			Outputs: []models.TransactionOutput{
-				{
-					WalletID:  minerWallet.WalletID,
-					Amount:    miningReward,
-					PublicKey: minerWallet.PublicKey,
-				},
-			},
-			TotalInput:  0,
-			TotalOutput: miningReward,
-			Fee:         0,
-			Status:      models.TxStatusConfirmed,
-			BlockHash:   hash,
-			Message:     "Mining Reward",
-			Timestamp:   now,
-			ConfirmedAt: &now,
-		}
-		_, err = getTransactionCollection().InsertOne(sessCtx, coinbaseTx)
-		if err != nil {
-			return nil, err
-		}
-
-		return nil, nil
-	})
-
+// chainTipHash returns the hash of the latest main chain block without loading
+// the rest of it
+func chainTipHash(ctx context.Context) (string, error) {
+	var latest models.Block
+	err := getBlockCollection().FindOne(ctx, mainChain(bson.M{}),
+		options.FindOne().SetSort(bson.M{"index": -1}).SetProjection(bson.M{"hash": 1})).Decode(&latest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save block", "details": err.Error()})
-		return
+		return "", err
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Block mined successfully! 🎉",
-		"block":        newBlock,
-		"miningReward": miningReward,
-		"nonce":        nonce,
-		"hash":         hash,
-	})
+	return latest.Hash, nil
 }
 
-// calculateNewDifficulty adjusts difficulty based on recent block times
-func calculateNewDifficulty(ctx context.Context, currentDifficulty int) int {
-	// Get last N blocks
-	cursor, err := getBlockCollection().Find(ctx, bson.M{},
-		options.Find().SetSort(bson.M{"index": -1}).SetLimit(int64(models.DefaultBlockchainConfig.DifficultyAdjustment)))
+// withoutUnminedParents drops transactions that spend outputs of pending
+// transactions left out of the block, such as ones still waiting for their
+// lock time. txs must be ordered so parents come before their children.
+func withoutUnminedParents(ctx context.Context, txs []models.Transaction) ([]models.Transaction, error) {
+	var sourceIDs []string
+	for _, tx := range txs {
+		for _, input := range tx.Inputs {
+			sourceIDs = append(sourceIDs, input.TransactionID)
+		}
+	}
+	if len(sourceIDs) == 0 {
+		return txs, nil
+	}
+
+	cursor, err := getTransactionCollection().Find(ctx, bson.M{
+		"transactionId": bson.M{"$in": sourceIDs},
+		"status":        models.TxStatusPending,
+	}, options.Find().SetProjection(bson.M{"transactionId": 1}))
 	if err != nil {
-		return currentDifficulty
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var blocks []models.Block
-	if err := cursor.All(ctx, &blocks); err != nil || len(blocks) < 2 {
-		return currentDifficulty
+	var pendingParents []models.Transaction
+	if err := cursor.All(ctx, &pendingParents); err != nil {
+		return nil, err
 	}
-
-	// Calculate average time between blocks
-	var totalTime time.Duration
-	for i := 0; i < len(blocks)-1; i++ {
-		diff := blocks[i].Timestamp.Sub(blocks[i+1].Timestamp)
-		totalTime += diff
+	pending := make(map[string]bool, len(pendingParents))
+	for _, tx := range pendingParents {
+		pending[tx.TransactionID] = true
 	}
-	avgTime := totalTime / time.Duration(len(blocks)-1)
-	targetTime := time.Duration(models.DefaultBlockchainConfig.TargetBlockTime) * time.Second
 
-	// Adjust difficulty
-	if avgTime < targetTime/2 {
-		return currentDifficulty + 1
-	} else if avgTime > targetTime*2 && currentDifficulty > 1 {
-		return currentDifficulty - 1
+	included := make(map[string]bool, len(txs))
+	selected := txs[:0]
+	for _, tx := range txs {
+		ready := true
+		for _, input := range tx.Inputs {
+			if pending[input.TransactionID] && !included[input.TransactionID] {
+				ready = false
+				break
+			}
+		}
+		if ready {
+			included[tx.TransactionID] = true
+			selected = append(selected, tx)
+		}
 	}
-
-	return currentDifficulty
+	return selected, nil
 }
 
-// ValidateBlockchain validates the entire blockchain
-func ValidateBlockchain(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// nextBlockBits returns the compact target for the block after lastBlock. Every
+// DifficultyAdjustment blocks the target is scaled by how long the last blocks
+// took compared with TargetBlockTime, so difficulty follows the hash rate in
+// proportion rather than in steps of 16.
+func nextBlockBits(ctx context.Context, lastBlock *models.Block) (uint32, error) {
+	header := lastBlock.Header()
+	bits := crypto.HeaderBits(&header)
 
-	cursor, err := getBlockCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"index": 1}))
+	interval := int64(models.DefaultBlockchainConfig.DifficultyAdjustment)
+	if (lastBlock.Index+1)%interval != 0 {
+		return bits, nil
+	}
+
+	// Get the first of the last N blocks on lastBlock's branch
+	first, err := blockAncestor(ctx, lastBlock, max(lastBlock.Index-interval+1, 0))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
-		return
+		return 0, err
+	}
+	if lastBlock.Index-first.Index < 1 {
+		return bits, nil
 	}
-	defer cursor.Close(ctx)
 
-	var blocks []models.Block
-	if err := cursor.All(ctx, &blocks); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse blocks"})
-		return
+	actual := lastBlock.Timestamp.Sub(first.Timestamp)
+	expected := time.Duration(lastBlock.Index-first.Index) * time.Duration(models.DefaultBlockchainConfig.TargetBlockTime) * time.Second
+	return crypto.RetargetBits(bits, actual, expected)
+}
+
+// blockChainWork returns the total work of the chain up to and including
+// block. Blocks mined before chain work was recorded have it summed from the
+// genesis block.
+func blockChainWork(ctx context.Context, block *models.Block) (*big.Int, error) {
+	if block.ChainWork != "" {
+		return crypto.ParseChainWork(block.ChainWork)
 	}
 
-	var errors []string
-	for i := 1; i < len(blocks); i++ {
-		// Check hash link
-		if blocks[i].PreviousHash != blocks[i-1].Hash {
-			errors = append(errors, "Block "+strconv.FormatInt(blocks[i].Index, 10)+" has invalid previous hash link")
-		}
+	cursor, err := getBlockCollection().Find(ctx, mainChain(bson.M{"index": bson.M{"$lte": block.Index}}),
+		options.Find().SetProjection(bson.M{"version": 1, "difficulty": 1, "bits": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
-		// Check index
-		if blocks[i].Index != blocks[i-1].Index+1 {
-			errors = append(errors, "Block "+strconv.FormatInt(blocks[i].Index, 10)+" has invalid index")
+	total := new(big.Int)
+	for cursor.Next(ctx) {
+		var b models.Block
+		if err := cursor.Decode(&b); err != nil {
+			return nil, err
 		}
-
-		// Verify hash meets difficulty
-		if !crypto.ValidateBlockHash(blocks[i].Hash, blocks[i].Difficulty) {
-			errors = append(errors, "Block "+strconv.FormatInt(blocks[i].Index, 10)+" hash doesn't meet difficulty")
+		header := b.Header()
+		work, err := crypto.HeaderWork(&header)
+		if err != nil {
+			return nil, err
 		}
+		total.Add(total, work)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"validation": models.ChainValidation{
-			IsValid:       len(errors) == 0,
-			BlocksChecked: int64(len(blocks)),
-			Errors:        errors,
-		},
-	})
+	return total, cursor.Err()
 }
 
 // GetMiningStatus returns current mining info
@@ -526,17 +721,22 @@ func GetMiningStatus(c *gin.Context) {
 
 	// Get latest block
 	var lastBlock models.Block
-	err := getBlockCollection().FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
+	err := getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
 
 	difficulty := models.DefaultBlockchainConfig.InitialDifficulty
+	bits := crypto.TargetToCompact(crypto.LeadingZerosTarget(difficulty))
 	lastBlockHash := ""
 	lastBlockIndex := int64(-1)
 
 	if err == nil {
-		difficulty = lastBlock.Difficulty
 		lastBlockHash = lastBlock.Hash
 		lastBlockIndex = lastBlock.Index
+		if next, err := nextBlockBits(ctx, &lastBlock); err == nil {
+			bits = next
+		}
 	}
+	target, _ := crypto.CompactToTarget(bits)
+	difficulty = crypto.TargetLeadingZeros(target)
 
 	c.JSON(http.StatusOK, gin.H{
 		"pendingTransactions": pendingCount,
@@ -545,6 +745,8 @@ func GetMiningStatus(c *gin.Context) {
 		"lastBlockHash":       lastBlockHash,
 		"lastBlockIndex":      lastBlockIndex,
 		"targetPrefix":        getTargetPrefix(difficulty),
+		"bits":                bits,
+		"target":              crypto.FormatTarget(target),
 	})
 }
 
@@ -577,8 +779,8 @@ func GetMyMinedBlocks(c *gin.Context) {
 		return
 	}
 
-	// Get blocks mined by this wallet
-	cursor, err := getBlockCollection().Find(ctx, bson.M{"minerWalletId": wallet.WalletID},
+	// Get blocks mined by this wallet that made it into the main chain
+	cursor, err := getBlockCollection().Find(ctx, mainChain(bson.M{"minerWalletId": wallet.WalletID}),
 		options.Find().SetSort(bson.M{"index": -1}))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
@@ -593,7 +795,7 @@ func GetMyMinedBlocks(c *gin.Context) {
 	}
 
 	// Calculate total rewards
-	var totalRewards float64
+	var totalRewards models.Amount
 	for _, block := range blocks {
 		totalRewards += block.MiningReward
 	}
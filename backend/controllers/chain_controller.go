@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Blocks are linked to their parent by PreviousHash, so they form a tree that
+// may branch when two blocks are mined on the same parent. The main chain is
+// the branch with the most work: its blocks are the ones whose transactions and
+// coinbase outputs count. Blocks on other branches are kept but marked stale.
+// When a side branch overtakes the main chain, the main chain's blocks back to
+// the fork are disconnected and the branch's blocks connected in their place,
+// all in one database transaction.
+//
+// Every transaction that moves the tip also writes the chain state document,
+// which is where the tip is read from. Two blocks accepted at once can't both
+// extend or replace the same tip: the second write conflicts, MongoDB aborts
+// that transaction and WithTransaction runs it again against the tip that won.
+
+// chainStateID is the _id of the chain state document
+const chainStateID = "tip"
+
+func getChainStateCollection() *mongo.Collection {
+	return database.GetCollection("chain_state")
+}
+
+// errLegacyReorg stops reorganizations that would disconnect blocks mined
+// before coinbases were stored with their block, which can't be connected again
+var errLegacyReorg = errors.New("reorganization would disconnect blocks without a stored coinbase")
+
+// mainChain restricts a block filter to the main chain. Blocks saved before
+// forks were tracked have no stale field and are all on the main chain.
+func mainChain(filter bson.M) bson.M {
+	filter["stale"] = bson.M{"$ne": true}
+	return filter
+}
+
+// loadBlockByHash returns the block with hash, on any branch
+func loadBlockByHash(ctx context.Context, hash string) (*models.Block, error) {
+	var block models.Block
+	err := getBlockCollection().FindOne(ctx, bson.M{"hash": hash}).Decode(&block)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, &apiError{status: http.StatusNotFound, message: "Block " + hash + " not found"}
+		}
+		return nil, err
+	}
+	return &block, nil
+}
+
+// blockAncestor returns the block at height on block's branch
+func blockAncestor(ctx context.Context, block *models.Block, height int64) (*models.Block, error) {
+	for block.Index > height {
+		if !block.Stale {
+			var ancestor models.Block
+			err := getBlockCollection().FindOne(ctx, mainChain(bson.M{"index": height})).Decode(&ancestor)
+			if err != nil {
+				return nil, err
+			}
+			return &ancestor, nil
+		}
+
+		parent, err := loadBlockByHash(ctx, block.PreviousHash)
+		if err != nil {
+			return nil, err
+		}
+		block = parent
+	}
+	return block, nil
+}
+
+// acceptBlock stores a new block. A block extending the chain tip is connected.
+// A block on another branch is stored as stale, unless its branch now has more
+// work than the main chain, in which case the chain is reorganized onto it;
+// ties go to the branch seen first. block.Stale reports the outcome. It must
+// run in a session transaction, so a branch that fails to connect leaves
+// nothing behind.
+func acceptBlock(ctx context.Context, block *models.Block) error {
+	count, err := getBlockCollection().CountDocuments(ctx, bson.M{"hash": block.Hash})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &apiError{status: http.StatusConflict, message: "Block " + block.Hash + " already exists"}
+	}
+
+	tip, err := loadChainTip(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Blocks are stored stale and become part of the main chain when connected
+	block.Stale = true
+	if block.PreviousHash == tip.Hash {
+		if _, err := getBlockCollection().InsertOne(ctx, block); err != nil {
+			return err
+		}
+		if err := connectBlock(ctx, block); err != nil {
+			return err
+		}
+		return setChainTip(ctx, block)
+	}
+
+	work, err := crypto.ParseChainWork(block.ChainWork)
+	if err != nil {
+		return err
+	}
+	tipWork, err := blockChainWork(ctx, tip)
+	if err != nil {
+		return err
+	}
+	if work.Cmp(tipWork) <= 0 {
+		_, err := getBlockCollection().InsertOne(ctx, block)
+		return err
+	}
+
+	disconnect, connect, err := reorganizationPlan(ctx, tip, block)
+	if errors.Is(err, errLegacyReorg) {
+		log.Printf("⚠️ [Chain] Keeping block %d (%s) stale: %v", block.Index, block.Hash, err)
+		_, err := getBlockCollection().InsertOne(ctx, block)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := getBlockCollection().InsertOne(ctx, block); err != nil {
+		return err
+	}
+	for i := range disconnect {
+		if err := disconnectBlock(ctx, &disconnect[i]); err != nil {
+			return err
+		}
+	}
+	for _, b := range connect {
+		if err := connectBlock(ctx, b); err != nil {
+			return err
+		}
+	}
+	if err := setChainTip(ctx, block); err != nil {
+		return err
+	}
+
+	log.Printf("🔀 [Chain] Reorganized at block %d: %d block(s) disconnected, %d connected, new tip %d (%s)",
+		connect[0].Index-1, len(disconnect), len(connect), block.Index, block.Hash)
+	return nil
+}
+
+// setChainTip records block as the chain tip in the chain state document. It
+// must run in the transaction that made block the tip.
+func setChainTip(ctx context.Context, block *models.Block) error {
+	_, err := getChainStateCollection().UpdateOne(ctx,
+		bson.M{"_id": chainStateID},
+		bson.M{"$set": bson.M{"tipHash": block.Hash, "tipIndex": block.Index, "updatedAt": time.Now()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// reorganizationPlan returns the main chain blocks to disconnect, tip first,
+// and the blocks of newTip's branch to connect, oldest first, to make newTip
+// the chain tip
+func reorganizationPlan(ctx context.Context, tip, newTip *models.Block) ([]models.Block, []*models.Block, error) {
+	connect, fork, err := branchFromFork(newTip, func(hash string) (*models.Block, error) {
+		return loadBlockByHash(ctx, hash)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursor, err := getBlockCollection().Find(ctx,
+		mainChain(bson.M{"index": bson.M{"$gt": fork.Index, "$lte": tip.Index}}),
+		options.Find().SetSort(bson.M{"index": -1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var disconnect []models.Block
+	if err := cursor.All(ctx, &disconnect); err != nil {
+		return nil, nil, err
+	}
+	for _, b := range disconnect {
+		if b.Coinbase == nil {
+			return nil, nil, errLegacyReorg
+		}
+	}
+	return disconnect, connect, nil
+}
+
+// branchFromFork walks back from newTip to the main chain block its branch
+// forks from. It returns the branch's stale blocks, oldest first and ending with
+// newTip, and the fork block. parent loads a block by hash.
+func branchFromFork(newTip *models.Block, parent func(hash string) (*models.Block, error)) ([]*models.Block, *models.Block, error) {
+	branch := []*models.Block{newTip}
+	fork := newTip
+	for {
+		block, err := parent(fork.PreviousHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		fork = block
+		if !fork.Stale {
+			break
+		}
+		branch = append(branch, fork)
+	}
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+	return branch, fork, nil
+}
+
+// connectBlock applies a stored block to the main chain: its transactions, which
+// must all be pending, are confirmed and its coinbase outputs created
+func connectBlock(ctx context.Context, block *models.Block) error {
+	now := time.Now()
+	for _, tx := range block.Transactions {
+		res, err := getTransactionCollection().UpdateOne(ctx,
+			bson.M{"transactionId": tx.TransactionID, "status": models.TxStatusPending},
+			bson.M{"$set": bson.M{
+				"status":      models.TxStatusConfirmed,
+				"blockHash":   block.Hash,
+				"blockHeight": block.Index,
+				"confirmedAt": now,
+			}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return &apiError{status: http.StatusConflict, message: "Transaction " + tx.TransactionID + " is no longer pending"}
+		}
+
+		// If this is a zakat transaction, update the zakat_payments status
+		if tx.Type == models.TxTypeZakat {
+			_, err = database.GetCollection("zakat_payments").UpdateOne(ctx,
+				bson.M{"transactionId": tx.TransactionID},
+				bson.M{"$set": bson.M{
+					"status":      "confirmed",
+					"confirmedAt": now,
+				}})
+			if err != nil {
+				return err
+			}
+		}
+
+		// Mark UTXOs as confirmed
+		_, err = getUTXOCollection().UpdateMany(ctx,
+			bson.M{"transactionId": tx.TransactionID},
+			bson.M{"$set": bson.M{
+				"isConfirmed": true,
+				"blockHash":   block.Hash,
+				"blockHeight": block.Index,
+			}})
+		if err != nil {
+			return err
+		}
+	}
+
+	if block.Coinbase != nil {
+		// Create a coinbase UTXO for each reward output
+		coinbaseTx := *block.Coinbase
+		for idx, output := range coinbaseTx.Outputs {
+			coinbaseUTXO := newOutputUTXO(coinbaseTx.TransactionID, idx, output, now)
+			coinbaseUTXO.IsConfirmed = true
+			coinbaseUTXO.BlockHash = block.Hash
+			coinbaseUTXO.BlockHeight = block.Index
+			if _, err := getUTXOCollection().InsertOne(ctx, coinbaseUTXO); err != nil {
+				return err
+			}
+		}
+
+		// Record the coinbase transaction for the mining reward (so it shows in transaction history)
+		if _, err := getTransactionCollection().InsertOne(ctx, coinbaseTx); err != nil {
+			return err
+		}
+	}
+
+	_, err := getBlockCollection().UpdateOne(ctx,
+		bson.M{"hash": block.Hash},
+		bson.M{"$unset": bson.M{"stale": ""}})
+	if err != nil {
+		return err
+	}
+	block.Stale = false
+	return nil
+}
+
+// disconnectBlock takes a block off the main chain, leaving it stored as stale.
+// Its transactions go back to pending. Its coinbase outputs are removed, and so
+// are transactions spending them, which can't be mined without them.
+func disconnectBlock(ctx context.Context, block *models.Block) error {
+	if block.Coinbase != nil {
+		coinbaseTxID := block.Coinbase.TransactionID
+		if err := evictSpenders(ctx, coinbaseTxID); err != nil {
+			return err
+		}
+		if _, err := getUTXOCollection().DeleteMany(ctx, bson.M{"transactionId": coinbaseTxID}); err != nil {
+			return err
+		}
+		if _, err := getTransactionCollection().DeleteOne(ctx, bson.M{"transactionId": coinbaseTxID}); err != nil {
+			return err
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		_, err := getTransactionCollection().UpdateOne(ctx,
+			bson.M{"transactionId": tx.TransactionID, "blockHash": block.Hash},
+			bson.M{
+				"$set": bson.M{
+					"status":      models.TxStatusPending,
+					"blockHash":   "",
+					"blockHeight": 0,
+					"confirmedAt": nil,
+				},
+			})
+		if err != nil {
+			return err
+		}
+
+		if tx.Type == models.TxTypeZakat {
+			_, err = database.GetCollection("zakat_payments").UpdateOne(ctx,
+				bson.M{"transactionId": tx.TransactionID},
+				bson.M{
+					"$set":   bson.M{"status": "pending"},
+					"$unset": bson.M{"confirmedAt": ""},
+				})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = getUTXOCollection().UpdateMany(ctx,
+			bson.M{"transactionId": tx.TransactionID},
+			bson.M{
+				"$set":   bson.M{"isConfirmed": false, "blockHash": ""},
+				"$unset": bson.M{"blockHeight": ""},
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := getBlockCollection().UpdateOne(ctx,
+		bson.M{"hash": block.Hash},
+		bson.M{"$set": bson.M{"stale": true}})
+	if err != nil {
+		return err
+	}
+	block.Stale = true
+	return nil
+}
+
+// evictSpenders fails the transactions spending outputs of txID
+func evictSpenders(ctx context.Context, txID string) error {
+	cursor, err := getUTXOCollection().Find(ctx, bson.M{"transactionId": txID, "isSpent": true},
+		options.Find().SetProjection(bson.M{"spentInTx": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var spent []models.UTXO
+	if err := cursor.All(ctx, &spent); err != nil {
+		return err
+	}
+	for _, utxo := range spent {
+		if err := evictTransaction(ctx, utxo.SpentInTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evictTransaction fails an unconfirmed transaction whose inputs no longer
+// exist, along with its descendants: its outputs are removed and the inputs it
+// still has are released for other transactions to spend
+func evictTransaction(ctx context.Context, txID string) error {
+	var tx models.Transaction
+	err := getTransactionCollection().FindOne(ctx, bson.M{
+		"transactionId": txID,
+		"status":        models.TxStatusPending,
+	}).Decode(&tx)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := evictSpenders(ctx, txID); err != nil {
+		return err
+	}
+	if _, err := getUTXOCollection().DeleteMany(ctx, bson.M{"transactionId": txID}); err != nil {
+		return err
+	}
+
+	for _, input := range tx.Inputs {
+		_, err := getUTXOCollection().UpdateOne(ctx,
+			bson.M{"transactionId": input.TransactionID, "outputIndex": input.OutputIndex, "spentInTx": txID},
+			bson.M{"$set": bson.M{"isSpent": false, "spentInTx": "", "spentAt": nil}})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = getTransactionCollection().UpdateOne(ctx,
+		bson.M{"transactionId": txID},
+		bson.M{"$set": bson.M{"status": models.TxStatusFailed}})
+	if err != nil {
+		return err
+	}
+	if tx.Type == models.TxTypeZakat {
+		_, err = database.GetCollection("zakat_payments").UpdateOne(ctx,
+			bson.M{"transactionId": txID},
+			bson.M{"$set": bson.M{"status": "failed"}})
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("⚠️ [Chain] Evicted transaction %s: it spends outputs of a disconnected block", txID)
+	return nil
+}
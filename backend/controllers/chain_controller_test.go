@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMainChainFilter(t *testing.T) {
+	filter := mainChain(bson.M{"index": 5})
+	want := bson.M{"index": 5, "stale": bson.M{"$ne": true}}
+	if !reflect.DeepEqual(filter, want) {
+		t.Fatalf("mainChain = %v, want %v", filter, want)
+	}
+}
+
+func TestBranchFromFork(t *testing.T) {
+	// genesis - m1 - m2 is the main chain; s1 - s2 - s3 branches off m1
+	blocks := map[string]*models.Block{}
+	add := func(hash, parent string, index int64, stale bool) *models.Block {
+		block := &models.Block{Hash: hash, PreviousHash: parent, Index: index, Stale: stale}
+		blocks[hash] = block
+		return block
+	}
+	add("genesis", "0", 0, false)
+	m1 := add("m1", "genesis", 1, false)
+	m2 := add("m2", "m1", 2, false)
+	s1 := add("s1", "m1", 2, true)
+	s2 := add("s2", "s1", 3, true)
+	// New tips aren't stored yet
+	m3 := &models.Block{Hash: "m3", PreviousHash: "m2", Index: 3, Stale: true}
+	s3 := &models.Block{Hash: "s3", PreviousHash: "s2", Index: 4, Stale: true}
+
+	load := func(hash string) (*models.Block, error) {
+		if block, ok := blocks[hash]; ok {
+			return block, nil
+		}
+		return nil, errors.New("block " + hash + " not found")
+	}
+
+	tests := []struct {
+		name       string
+		newTip     *models.Block
+		wantBranch []*models.Block
+		wantFork   *models.Block
+	}{
+		{"extends the tip", m3, []*models.Block{m3}, m2},
+		{"one block off the main chain", s1, []*models.Block{s1}, m1},
+		{"long branch", s3, []*models.Block{s1, s2, s3}, m1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			branch, fork, err := branchFromFork(tt.newTip, load)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(branch, tt.wantBranch) {
+				t.Errorf("branch = %v, want %v", blockHashes(branch), blockHashes(tt.wantBranch))
+			}
+			if fork != tt.wantFork {
+				t.Errorf("fork = %s, want %s", fork.Hash, tt.wantFork.Hash)
+			}
+		})
+	}
+
+	orphan := &models.Block{Hash: "o2", PreviousHash: "o1", Index: 2, Stale: true}
+	if _, _, err := branchFromFork(orphan, load); err == nil {
+		t.Error("found a fork for a block whose parent is unknown")
+	}
+}
+
+func blockHashes(blocks []*models.Block) []string {
+	hashes := make([]string, len(blocks))
+	for i, block := range blocks {
+		hashes[i] = block.Hash
+	}
+	return hashes
+}
+
+// withMockDatabase runs test with the controllers pointed at a mock
+// deployment. Each command takes the next response added with AddMockResponses.
+func withMockDatabase(t *testing.T, test func(t *testing.T, mt *mtest.T)) {
+	mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock)).Run("mock", func(mt *mtest.T) {
+		saved := database.DB
+		defer func() { database.DB = saved }()
+		database.DB = mt.DB
+		test(mt.T, mt)
+	})
+}
+
+// mockDocs converts values to documents for mock cursor responses
+func mockDocs(t *testing.T, values ...interface{}) []bson.D {
+	t.Helper()
+	docs := make([]bson.D, len(values))
+	for i, value := range values {
+		data, err := bson.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bson.Unmarshal(data, &docs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return docs
+}
+
+// mockFound is the response to a find that returns docs
+func mockFound(t *testing.T, collection string, values ...interface{}) bson.D {
+	return mtest.CreateCursorResponse(0, "crypto_wallet."+collection, mtest.FirstBatch, mockDocs(t, values...)...)
+}
+
+// mockWritten is the response to a write that changed n documents
+func mockWritten(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+// mockCommand is a command sent to the mock deployment
+type mockCommand struct {
+	name, collection string
+	filter           bson.Raw // Query of a find, or of the first update or delete
+	update           bson.Raw
+}
+
+func (c mockCommand) String() string {
+	return c.name + " " + c.collection
+}
+
+// filterString returns a string field of the command's filter
+func (c mockCommand) filterString(key string) string {
+	value, _ := c.filter.Lookup(key).StringValueOK()
+	return value
+}
+
+func mockCommands(mt *mtest.T) []mockCommand {
+	var commands []mockCommand
+	for _, e := range mt.GetAllStartedEvents() {
+		command := mockCommand{name: e.CommandName}
+		switch e.CommandName {
+		case "find":
+			command.filter = e.Command.Lookup("filter").Document()
+		case "update":
+			update := e.Command.Lookup("updates", "0").Document()
+			command.filter = update.Lookup("q").Document()
+			command.update = update.Lookup("u").Document()
+		case "delete":
+			command.filter = e.Command.Lookup("deletes", "0", "q").Document()
+		default:
+			continue
+		}
+		command.collection = e.Command.Lookup(e.CommandName).StringValue()
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func checkCommands(t *testing.T, commands []mockCommand, want ...string) {
+	t.Helper()
+	got := make([]string, len(commands))
+	for i, command := range commands {
+		got[i] = command.String()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestLoadChainTip(t *testing.T) {
+	tip := models.Block{Hash: "m2", PreviousHash: "m1", Index: 2}
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "crypto_wallet.chain_state", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: chainStateID}, {Key: "tipHash", Value: "m2"}, {Key: "tipIndex", Value: 2}}),
+			mockFound(t, "blocks", tip),
+		)
+		got, err := loadChainTip(context.Background())
+		if err != nil || got.Hash != "m2" {
+			t.Fatalf("loadChainTip = %+v, %v", got, err)
+		}
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "find chain_state", "find blocks")
+		if commands[0].filterString("_id") != chainStateID || commands[1].filterString("hash") != "m2" {
+			t.Errorf("queried %s and %s", commands[0].filter, commands[1].filter)
+		}
+	})
+
+	// Chains whose tip hasn't moved since the chain state was introduced
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mockFound(t, "chain_state"), mockFound(t, "blocks", tip))
+		got, err := loadChainTip(context.Background())
+		if err != nil || got.Hash != "m2" {
+			t.Fatalf("without a chain state: loadChainTip = %+v, %v", got, err)
+		}
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "find chain_state", "find blocks")
+		if _, err := commands[1].filter.LookupErr("stale"); err != nil {
+			t.Errorf("fallback query %s isn't restricted to the main chain", commands[1].filter)
+		}
+	})
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mockFound(t, "chain_state"), mockFound(t, "blocks"))
+		var apiErr *apiError
+		if _, err := loadChainTip(context.Background()); !errors.As(err, &apiErr) {
+			t.Fatalf("without blocks: err = %v, want an API error asking for a genesis block", err)
+		}
+	})
+}
+
+func TestReorganizationPlan(t *testing.T) {
+	// genesis - m1 - m2 - m3 is the main chain; s2 - s3 - s4 branches off m1
+	coinbase := &models.Transaction{TransactionID: "coinbase", Type: models.TxTypeCoinbase}
+	m1 := models.Block{Hash: "m1", PreviousHash: "genesis", Index: 1, Coinbase: coinbase}
+	m2 := models.Block{Hash: "m2", PreviousHash: "m1", Index: 2, Coinbase: coinbase}
+	m3 := models.Block{Hash: "m3", PreviousHash: "m2", Index: 3, Coinbase: coinbase}
+	s2 := models.Block{Hash: "s2", PreviousHash: "m1", Index: 2, Stale: true}
+	s3 := models.Block{Hash: "s3", PreviousHash: "s2", Index: 3, Stale: true}
+	s4 := &models.Block{Hash: "s4", PreviousHash: "s3", Index: 4, Stale: true}
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(
+			mockFound(t, "blocks", s3),
+			mockFound(t, "blocks", s2),
+			mockFound(t, "blocks", m1),
+			mockFound(t, "blocks", m3, m2),
+		)
+		disconnect, connect, err := reorganizationPlan(context.Background(), &m3, s4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := blockHashes(connect); !reflect.DeepEqual(got, []string{"s2", "s3", "s4"}) {
+			t.Errorf("connect = %v, want [s2 s3 s4]", got)
+		}
+		if len(disconnect) != 2 || disconnect[0].Hash != "m3" || disconnect[1].Hash != "m2" {
+			t.Errorf("disconnect = %+v, want m3 then m2", disconnect)
+		}
+
+		// The main chain blocks above the fork, up to the tip, are disconnected
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "find blocks", "find blocks", "find blocks", "find blocks")
+		index := commands[3].filter.Lookup("index").Document()
+		if index.Lookup("$gt").AsInt64() != 1 || index.Lookup("$lte").AsInt64() != 3 {
+			t.Errorf("disconnected blocks queried with %s, want index in (1, 3]", commands[3].filter)
+		}
+	})
+
+	// Blocks mined before coinbases were stored can't be connected again
+	legacy := m2
+	legacy.Coinbase = nil
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(
+			mockFound(t, "blocks", s3),
+			mockFound(t, "blocks", s2),
+			mockFound(t, "blocks", m1),
+			mockFound(t, "blocks", m3, legacy),
+		)
+		if _, _, err := reorganizationPlan(context.Background(), &m3, s4); !errors.Is(err, errLegacyReorg) {
+			t.Fatalf("legacy block: err = %v, want %v", err, errLegacyReorg)
+		}
+	})
+}
+
+func TestDisconnectBlockEvictsSpenders(t *testing.T) {
+	block := &models.Block{
+		Hash:         "m2",
+		Index:        2,
+		Coinbase:     &models.Transaction{TransactionID: "coinbase2", Type: models.TxTypeCoinbase},
+		Transactions: []models.Transaction{{TransactionID: "payment", Type: models.TxTypeTransfer}},
+	}
+	// child spends the coinbase and is still pending
+	child := models.Transaction{
+		TransactionID: "child",
+		Type:          models.TxTypeTransfer,
+		Status:        models.TxStatusPending,
+		Inputs:        []models.SignedInput{{TransactionID: "other", OutputIndex: 1}, {TransactionID: "coinbase2"}},
+	}
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(
+			mockFound(t, "utxos", models.UTXO{TransactionID: "coinbase2", IsSpent: true, SpentInTx: "child"}),
+			mockFound(t, "transactions", child),
+			mockFound(t, "utxos"), // Nothing spends child's outputs
+			mockWritten(1),        // Remove child's outputs
+			mockWritten(1),        // Release child's inputs
+			mockWritten(1),
+			mockWritten(1), // Fail child
+			mockWritten(1), // Remove the coinbase outputs
+			mockWritten(1), // and the coinbase
+			mockWritten(1), // Return payment to pending
+			mockWritten(1),
+			mockWritten(1), // Mark the block stale
+		)
+		if err := disconnectBlock(context.Background(), block); err != nil {
+			t.Fatal(err)
+		}
+		if !block.Stale {
+			t.Error("disconnected block isn't marked stale")
+		}
+
+		commands := mockCommands(mt)
+		checkCommands(t, commands,
+			"find utxos", "find transactions", "find utxos",
+			"delete utxos", "update utxos", "update utxos", "update transactions",
+			"delete utxos", "delete transactions",
+			"update transactions", "update utxos",
+			"update blocks")
+
+		for i, want := range map[int]string{0: "coinbase2", 1: "child", 2: "child", 3: "child", 6: "child", 7: "coinbase2", 8: "coinbase2", 9: "payment", 10: "payment"} {
+			if got := commands[i].filterString("transactionId"); got != want {
+				t.Errorf("command %d (%s) is for %q, want %q", i, commands[i], got, want)
+			}
+		}
+		// Only the outputs child spent are released
+		for i, input := range child.Inputs {
+			if filter := commands[4+i].filter; filter.Lookup("transactionId").StringValue() != input.TransactionID ||
+				filter.Lookup("spentInTx").StringValue() != "child" {
+				t.Errorf("input %d released with %s", i, filter)
+			}
+		}
+		if status := commands[6].update.Lookup("$set", "status").StringValue(); status != string(models.TxStatusFailed) {
+			t.Errorf("child set to %q, want %q", status, models.TxStatusFailed)
+		}
+		if status := commands[9].update.Lookup("$set", "status").StringValue(); status != string(models.TxStatusPending) {
+			t.Errorf("payment set to %q, want %q", status, models.TxStatusPending)
+		}
+		if commands[9].filterString("blockHash") != "m2" || commands[11].filterString("hash") != "m2" {
+			t.Errorf("payment and block updated with %s and %s", commands[9].filter, commands[11].filter)
+		}
+	})
+}
+
+func TestEvictTransactionSkipsSettledTransactions(t *testing.T) {
+	// A transaction that was mined or already failed is left alone
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mockFound(t, "transactions"))
+		if err := evictTransaction(context.Background(), "mined"); err != nil {
+			t.Fatal(err)
+		}
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "find transactions")
+		if commands[0].filterString("status") != string(models.TxStatusPending) {
+			t.Errorf("queried %s, want pending transactions only", commands[0].filter)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Helpers shared by the controllers that build, sign and record transactions
+
+// apiError is an error with the HTTP status and message to report to the client
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// respondError writes err as a JSON error, using its status when it is an apiError
+func respondError(c *gin.Context, err error) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		c.JSON(apiErr.status, gin.H{"error": apiErr.message})
+		return
+	}
+	if errors.Is(err, errInputsSpent) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process transaction", "details": err.Error()})
+}
+
+// outputLockingScript returns the hex pay-to-pubkey-hash script for an output to
+// address, or "" for addresses without one (multisig wallets, the zakat fund)
+func outputLockingScript(address string) string {
+	script, err := crypto.PayToAddressScript(address)
+	if err != nil {
+		return ""
+	}
+	return script.Hex()
+}
+
+// unlockingScriptFor returns the unlocking script of an input that spends utxo
+// with a single signature. Outputs locked to a bare public key need none.
+func unlockingScriptFor(utxo models.UTXO, signature string, hashType crypto.SigHashType) (string, error) {
+	if utxo.LockingScript == "" {
+		return "", nil
+	}
+	script, err := crypto.PayToPubKeyHashUnlockingScript(signature, hashType, utxo.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return script.Hex(), nil
+}
+
+// spendableUTXOFilter matches the unspent outputs at addresses that their key
+// alone can spend in the block at height, leaving out outputs locked by other
+// scripts or still time-locked
+func spendableUTXOFilter(addresses []string, height int64) bson.M {
+	filter := unlockedUTXOFilter(height, time.Now())
+	filter["walletId"] = bson.M{"$in": addresses}
+	filter["isSpent"] = false
+	filter["scriptClass"] = bson.M{"$in": []interface{}{nil, "", crypto.ScriptClassPubKeyHash}}
+	return filter
+}
+
+// newOutputUTXO returns the unspent output created by a transaction output
+func newOutputUTXO(txID string, index int, output models.TransactionOutput, now time.Time) models.UTXO {
+	return models.UTXO{
+		TransactionID: txID,
+		OutputIndex:   index,
+		WalletID:      output.WalletID,
+		Amount:        output.Amount,
+		PublicKey:     output.PublicKey,
+		LockingScript: output.LockingScript,
+		ScriptClass:   string(crypto.ClassifyScriptHex(output.LockingScript)),
+		LockUntil:     output.LockUntil,
+		RelativeLock:  output.RelativeLock,
+		IsSpent:       false,
+		IsConfirmed:   false,
+		CreatedAt:     now,
+	}
+}
+
+// nextBlockHeight returns the height of the next block to be mined, which time
+// locks are checked against
+func nextBlockHeight(ctx context.Context) (int64, error) {
+	var lastBlock models.Block
+	err := getBlockCollection().FindOne(ctx, mainChain(bson.M{}), options.FindOne().SetSort(bson.M{"index": -1})).Decode(&lastBlock)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastBlock.Index + 1, nil
+}
+
+// recordTransaction atomically spends utxos and stores tx with its new outputs,
+// running any extra writes in the same database transaction. It fails with
+// errInputsSpent if another transaction spent an input first.
+func recordTransaction(ctx context.Context, tx *models.Transaction, utxos []models.UTXO, extra ...func(sessCtx mongo.SessionContext) error) error {
+	session, err := database.GetClient().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		now := time.Now()
+		for _, utxo := range utxos {
+			result, err := getUTXOCollection().UpdateOne(sessCtx, bson.M{
+				"transactionId": utxo.TransactionID,
+				"outputIndex":   utxo.OutputIndex,
+				"isSpent":       false,
+			}, bson.M{
+				"$set": bson.M{
+					"isSpent":   true,
+					"spentInTx": tx.TransactionID,
+					"spentAt":   now,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errInputsSpent
+			}
+		}
+
+		for i, output := range tx.Outputs {
+			if _, err := getUTXOCollection().InsertOne(sessCtx, newOutputUTXO(tx.TransactionID, i, output, now)); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := getTransactionCollection().InsertOne(sessCtx, tx); err != nil {
+			return nil, err
+		}
+		for _, write := range extra {
+			if err := write(sessCtx); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// utxoSigHashData returns the sighash data of a transaction with lockTime
+// spending utxos into outputs
+func utxoSigHashData(utxos []models.UTXO, outputs []models.TransactionOutput, lockTime int64) ([]crypto.InputData, []crypto.OutputData) {
+	inputs := make([]crypto.InputData, len(utxos))
+	for i, utxo := range utxos {
+		inputs[i] = crypto.InputData{
+			TransactionID: utxo.TransactionID,
+			OutputIndex:   utxo.OutputIndex,
+			Amount:        utxo.Amount,
+			LockingScript: utxo.LockingScript,
+			LockTime:      lockTime,
+		}
+	}
+
+	outputData := make([]crypto.OutputData, len(outputs))
+	for i, output := range outputs {
+		outputData[i] = crypto.OutputData{
+			WalletID:      output.WalletID,
+			Amount:        output.Amount,
+			LockingScript: output.LockingScript,
+			LockUntil:     output.LockUntil,
+			RelativeLock:  output.RelativeLock,
+		}
+	}
+	return inputs, outputData
+}
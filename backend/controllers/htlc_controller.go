@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Hash time-locked contracts pay the recipient's key if it reveals a secret
+// before the expiry height, and the sender's key after it. The contract lives
+// entirely in the output's locking script (see crypto.HTLCScript); the records
+// kept here only let the parties find their contracts and the revealed secret.
+
+func getHTLCCollection() *mongo.Collection {
+	return database.GetCollection("htlcs")
+}
+
+// CreateHTLC locks an amount from the caller's wallet in a hash time-locked
+// contract with the recipient
+func CreateHTLC(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.CreateHTLCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashLock, _ := hex.DecodeString(req.HashLock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+		return
+	}
+	recipientWallet, _, err := resolveAddress(ctx, req.RecipientWalletID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if recipientWallet.ID == wallet.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot create an HTLC with your own wallet"})
+		return
+	}
+	// Multisig wallets have no single key to claim with, so the output would be unspendable
+	if recipientWallet.PublicKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multisig addresses can't receive HTLCs"})
+		return
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	if req.ExpiryHeight <= height {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Expiry height must be after the next block (%d)", height)})
+		return
+	}
+
+	// Both branches pay the wallets' primary keys, which the server signs with
+	script, err := crypto.HTLCScript(&crypto.HTLC{
+		HashLock:         hashLock,
+		RecipientKeyHash: crypto.PublicKeyHash(recipientWallet.PublicKey),
+		SenderKeyHash:    crypto.PublicKeyHash(wallet.PublicKey),
+		ExpiryHeight:     req.ExpiryHeight,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The output stays listed under the sender until it is claimed
+	tx, err := lockToScript(ctx, &wallet, script, wallet.WalletID, "", req.Amount, req.Message)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	now := time.Now()
+	htlc := models.HTLC{
+		TransactionID:     tx.TransactionID,
+		OutputIndex:       0,
+		SenderWalletID:    wallet.WalletID,
+		RecipientWalletID: recipientWallet.WalletID,
+		Amount:            req.Amount,
+		HashLock:          strings.ToLower(req.HashLock),
+		ExpiryHeight:      req.ExpiryHeight,
+		LockingScript:     script.Hex(),
+		Status:            models.HTLCStatusPending,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	result, err := getHTLCCollection().InsertOne(ctx, htlc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save HTLC"})
+		return
+	}
+	htlc.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"htlc":        htlc,
+		"transaction": tx,
+		"message":     "HTLC created",
+	})
+}
+
+// GetMyHTLCs lists the contracts the caller sent or received, optionally
+// filtered by ?status=pending|claimed|refunded
+func GetMyHTLCs(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	filter := bson.M{"$or": []bson.M{
+		{"senderWalletId": wallet.WalletID},
+		{"recipientWalletId": wallet.WalletID},
+	}}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	cursor, err := getHTLCCollection().Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch HTLCs"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	htlcs := []models.HTLC{}
+	if err := cursor.All(ctx, &htlcs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse HTLCs"})
+		return
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"htlcs":           htlcs,
+		"count":           len(htlcs),
+		"nextBlockHeight": height,
+	})
+}
+
+// GetHTLC returns one contract the caller is a party to
+func GetHTLC(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	wallet, htlc, ok := loadHTLCForUser(ctx, c)
+	if !ok {
+		return
+	}
+	if htlc.SenderWalletID != wallet.WalletID && htlc.RecipientWalletID != wallet.WalletID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "HTLC not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"htlc": htlc})
+}
+
+// ClaimHTLC pays a pending contract to its recipient, revealing the preimage of
+// the hash lock. Claims are accepted until the block before the expiry height.
+func ClaimHTLC(c *gin.Context) {
+	var req models.ClaimHTLCRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preimage, err := hex.DecodeString(req.Preimage)
+	if err != nil || len(preimage) > crypto.MaxScriptElementSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preimage"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	wallet, htlc, ok := loadHTLCForUser(ctx, c)
+	if !ok {
+		return
+	}
+	if htlc.RecipientWalletID != wallet.WalletID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the recipient can claim this HTLC"})
+		return
+	}
+	if htlc.Status != models.HTLCStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "HTLC is already " + string(htlc.Status)})
+		return
+	}
+
+	hash := sha256.Sum256(preimage)
+	hashLock, _ := hex.DecodeString(htlc.HashLock)
+	if !bytes.Equal(hash[:], hashLock) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Preimage does not match the hash lock"})
+		return
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	if height >= htlc.ExpiryHeight {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("HTLC expired at block %d; only the sender can refund it", htlc.ExpiryHeight)})
+		return
+	}
+
+	tx, err := settleHTLC(ctx, wallet, htlc, "HTLC claim", func(signature string) (crypto.Script, error) {
+		return crypto.HTLCClaimScript(signature, crypto.SigHashAll, wallet.PublicKey, preimage)
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	htlc.Status = models.HTLCStatusClaimed
+	htlc.Preimage = hex.EncodeToString(preimage)
+	htlc.SettlementTxID = tx.TransactionID
+	if err := saveHTLCSettlement(ctx, htlc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "HTLC claimed but failed to update its record", "transactionId": tx.TransactionID})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"htlc":        htlc,
+		"transaction": tx,
+		"message":     "HTLC claimed",
+	})
+}
+
+// RefundHTLC returns an expired, unclaimed contract to its sender
+func RefundHTLC(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	wallet, htlc, ok := loadHTLCForUser(ctx, c)
+	if !ok {
+		return
+	}
+	if htlc.SenderWalletID != wallet.WalletID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the sender can refund this HTLC"})
+		return
+	}
+	if htlc.Status != models.HTLCStatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "HTLC is already " + string(htlc.Status)})
+		return
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	if height < htlc.ExpiryHeight {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":           fmt.Sprintf("HTLC can be refunded from block %d", htlc.ExpiryHeight),
+			"nextBlockHeight": height,
+		})
+		return
+	}
+
+	tx, err := settleHTLC(ctx, wallet, htlc, "HTLC refund", func(signature string) (crypto.Script, error) {
+		return crypto.HTLCRefundScript(signature, crypto.SigHashAll, wallet.PublicKey)
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	htlc.Status = models.HTLCStatusRefunded
+	htlc.SettlementTxID = tx.TransactionID
+	if err := saveHTLCSettlement(ctx, htlc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "HTLC refunded but failed to update its record", "transactionId": tx.TransactionID})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"htlc":        htlc,
+		"transaction": tx,
+		"message":     "HTLC refunded",
+	})
+}
+
+// loadHTLCForUser loads the caller's wallet and the contract named by the :id
+// parameter, writing an error response if either is missing
+func loadHTLCForUser(ctx context.Context, c *gin.Context) (*models.Wallet, *models.HTLC, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, nil, false
+	}
+	htlcID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid HTLC ID"})
+		return nil, nil, false
+	}
+
+	var wallet models.Wallet
+	if err := getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return nil, nil, false
+	}
+
+	var htlc models.HTLC
+	if err := getHTLCCollection().FindOne(ctx, bson.M{"_id": htlcID}).Decode(&htlc); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "HTLC not found"})
+			return nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, nil, false
+	}
+	return &wallet, &htlc, true
+}
+
+// settleHTLC spends a contract's output to wallet. unlock builds the unlocking
+// script from the wallet's signature; it is checked against the contract script
+// before the transaction is recorded.
+func settleHTLC(ctx context.Context, wallet *models.Wallet, htlc *models.HTLC, message string, unlock func(signature string) (crypto.Script, error)) (*models.Transaction, error) {
+	tx, utxos, err := buildScriptSpend(ctx, wallet, &models.ScriptSpendRequest{
+		Inputs: []models.ScriptSpendInput{{
+			TransactionID: htlc.TransactionID,
+			OutputIndex:   htlc.OutputIndex,
+		}},
+		RecipientWalletID: wallet.WalletID,
+		Message:           message,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inputData, outputData := utxoSigHashData(utxos, tx.Outputs, tx.LockTime)
+	digest, err := crypto.SigHashDigest(inputData, outputData, 0, crypto.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := signer.Default().SignDigest(ctx, wallet.WalletID, masterKeyPath, digest)
+	if err != nil {
+		return nil, err
+	}
+	script, err := unlock(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = crypto.VerifyInputScript(script.Hex(), utxos[0].LockingScript, &crypto.ScriptContext{
+		Inputs:      inputData,
+		Outputs:     outputData,
+		InputIndex:  0,
+		BlockHeight: height,
+		BlockTime:   time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, badRequest("HTLC script failed: %v", err)
+	}
+	tx.Inputs[0].UnlockingScript = script.Hex()
+
+	if err := recordTransaction(ctx, tx, utxos); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// saveHTLCSettlement records how a pending contract was settled
+func saveHTLCSettlement(ctx context.Context, htlc *models.HTLC) error {
+	htlc.UpdatedAt = time.Now()
+	update := bson.M{
+		"status":         htlc.Status,
+		"settlementTxId": htlc.SettlementTxID,
+		"updatedAt":      htlc.UpdatedAt,
+	}
+	if htlc.Preimage != "" {
+		update["preimage"] = htlc.Preimage
+	}
+	_, err := getHTLCCollection().UpdateOne(ctx,
+		bson.M{"_id": htlc.ID, "status": models.HTLCStatusPending},
+		bson.M{"$set": update})
+	return err
+}
@@ -200,6 +200,16 @@ func GenerateTransactionReport(c *gin.Context) {
 		endDate = startDate.AddDate(0, 1, 0)
 	}
 
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	ownAddress := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		ownAddress[address] = true
+	}
+
 	// Query transactions
 	txFilter := bson.M{
 		"timestamp": bson.M{
@@ -208,7 +218,7 @@ func GenerateTransactionReport(c *gin.Context) {
 		},
 		"$or": []bson.M{
 			{"senderWallet": wallet.WalletID},
-			{"outputs.walletId": wallet.WalletID},
+			{"outputs.walletId": bson.M{"$in": addresses}},
 		},
 	}
 
@@ -226,16 +236,16 @@ func GenerateTransactionReport(c *gin.Context) {
 	}
 
 	// Process transactions
-	var totalSent, totalReceived, totalFees float64
+	var totalSent, totalReceived, totalFees models.Amount
 	var sentTxs, receivedTxs []models.TransactionSummary
 
 	for _, tx := range transactions {
 		if tx.SenderWallet == wallet.WalletID {
 			// Sent transaction
-			var sentAmount float64
+			var sentAmount models.Amount
 			var counterparty string
 			for _, output := range tx.Outputs {
-				if output.WalletID != wallet.WalletID {
+				if !ownAddress[output.WalletID] {
 					sentAmount += output.Amount
 					counterparty = output.WalletID
 				}
@@ -256,7 +266,7 @@ func GenerateTransactionReport(c *gin.Context) {
 
 		// Check if received
 		for _, output := range tx.Outputs {
-			if output.WalletID == wallet.WalletID && tx.SenderWallet != wallet.WalletID {
+			if ownAddress[output.WalletID] && tx.SenderWallet != wallet.WalletID {
 				totalReceived += output.Amount
 				receivedTxs = append(receivedTxs, models.TransactionSummary{
 					TransactionID: tx.TransactionID,
@@ -272,18 +282,18 @@ func GenerateTransactionReport(c *gin.Context) {
 	}
 
 	// Get mining rewards for period
-	blockFilter := bson.M{
+	blockFilter := mainChain(bson.M{
 		"minerWalletId": wallet.WalletID,
 		"timestamp": bson.M{
 			"$gte": startDate,
 			"$lt":  endDate,
 		},
-	}
+	})
 	blockCursor, _ := getBlockCollection().Find(ctx, blockFilter)
 	defer blockCursor.Close(ctx)
 
 	var blocksMined int
-	var miningRewards float64
+	var miningRewards models.Amount
 	for blockCursor.Next(ctx) {
 		var block models.Block
 		if blockCursor.Decode(&block) == nil {
@@ -303,7 +313,7 @@ func GenerateTransactionReport(c *gin.Context) {
 	zakatCursor, _ := getZakatPaymentCollection().Find(ctx, zakatFilter)
 	defer zakatCursor.Close(ctx)
 
-	var zakatPaid float64
+	var zakatPaid models.Amount
 	var zakatPayments int
 	for zakatCursor.Next(ctx) {
 		var payment models.ZakatPayment
@@ -359,21 +369,28 @@ func GenerateWalletReport(c *gin.Context) {
 
 	now := time.Now()
 
-	// Calculate balance
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	ownedUTXOs := bson.M{"$in": addresses}
+
+	// Calculate balance across all of the wallet's addresses
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"walletId": wallet.WalletID, "isSpent": false}}},
+		bson.D{{Key: "$match", Value: bson.M{"walletId": ownedUTXOs, "isSpent": false}}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}, "count": bson.M{"$sum": 1}}}},
 	}
 
 	cursor, _ := getUTXOCollection().Aggregate(ctx, pipeline)
 	defer cursor.Close(ctx)
 
-	var currentBalance float64
+	var currentBalance models.Amount
 	var unspentUTXOs int
 	if cursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
-			Count int     `bson:"count"`
+			Total models.Amount `bson:"total"`
+			Count int           `bson:"count"`
 		}
 		cursor.Decode(&result)
 		currentBalance = result.Total
@@ -381,21 +398,21 @@ func GenerateWalletReport(c *gin.Context) {
 	}
 
 	// Count total and spent UTXOs
-	totalUTXOs, _ := getUTXOCollection().CountDocuments(ctx, bson.M{"walletId": wallet.WalletID})
-	spentUTXOs, _ := getUTXOCollection().CountDocuments(ctx, bson.M{"walletId": wallet.WalletID, "isSpent": true})
+	totalUTXOs, _ := getUTXOCollection().CountDocuments(ctx, bson.M{"walletId": ownedUTXOs})
+	spentUTXOs, _ := getUTXOCollection().CountDocuments(ctx, bson.M{"walletId": ownedUTXOs, "isSpent": true})
 
 	// Pending balance (unconfirmed UTXOs)
 	pendingPipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"walletId": wallet.WalletID, "isSpent": false, "isConfirmed": false}}},
+		bson.D{{Key: "$match", Value: bson.M{"walletId": ownedUTXOs, "isSpent": false, "isConfirmed": false}}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
 	}
 	pendingCursor, _ := getUTXOCollection().Aggregate(ctx, pendingPipeline)
 	defer pendingCursor.Close(ctx)
 
-	var pendingBalance float64
+	var pendingBalance models.Amount
 	if pendingCursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		pendingCursor.Decode(&result)
 		pendingBalance = result.Total
@@ -403,10 +420,10 @@ func GenerateWalletReport(c *gin.Context) {
 
 	// Transaction stats
 	sentCount, _ := getTransactionCollection().CountDocuments(ctx, bson.M{"senderWallet": wallet.WalletID})
-	
+
 	// Received transactions (where wallet is in outputs but not sender)
 	receivedCount, _ := getTransactionCollection().CountDocuments(ctx, bson.M{
-		"outputs.walletId": wallet.WalletID,
+		"outputs.walletId": ownedUTXOs,
 		"senderWallet":     bson.M{"$ne": wallet.WalletID},
 	})
 
@@ -418,29 +435,29 @@ func GenerateWalletReport(c *gin.Context) {
 	sentCursor, _ := getTransactionCollection().Aggregate(ctx, sentPipeline)
 	defer sentCursor.Close(ctx)
 
-	var totalSentAmount float64
+	var totalSentAmount models.Amount
 	if sentCursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		sentCursor.Decode(&result)
 		totalSentAmount = result.Total
 	}
 
 	// Mining stats
-	blocksMined, _ := getBlockCollection().CountDocuments(ctx, bson.M{"minerWalletId": wallet.WalletID})
-	
+	blocksMined, _ := getBlockCollection().CountDocuments(ctx, mainChain(bson.M{"minerWalletId": wallet.WalletID}))
+
 	miningPipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"minerWalletId": wallet.WalletID}}},
+		bson.D{{Key: "$match", Value: mainChain(bson.M{"minerWalletId": wallet.WalletID})}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$miningReward"}}}},
 	}
 	miningCursor, _ := getBlockCollection().Aggregate(ctx, miningPipeline)
 	defer miningCursor.Close(ctx)
 
-	var totalMiningRewards float64
+	var totalMiningRewards models.Amount
 	if miningCursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		miningCursor.Decode(&result)
 		totalMiningRewards = result.Total
@@ -449,9 +466,9 @@ func GenerateWalletReport(c *gin.Context) {
 	// Zakat stats
 	nisab := models.DefaultZakatSettings.NisabInCoins
 	zakatEligible := currentBalance >= nisab
-	var zakatDue float64
+	var zakatDue models.Amount
 	if zakatEligible {
-		zakatDue = currentBalance * models.DefaultZakatSettings.ZakatRate
+		zakatDue = currentBalance.MulRate(models.DefaultZakatSettings.ZakatRate)
 	}
 
 	zakatPipeline := mongo.Pipeline{
@@ -461,10 +478,10 @@ func GenerateWalletReport(c *gin.Context) {
 	zakatCursor, _ := getZakatPaymentCollection().Aggregate(ctx, zakatPipeline)
 	defer zakatCursor.Close(ctx)
 
-	var totalZakatPaid float64
+	var totalZakatPaid models.Amount
 	if zakatCursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		zakatCursor.Decode(&result)
 		totalZakatPaid = result.Total
@@ -482,27 +499,27 @@ func GenerateWalletReport(c *gin.Context) {
 	}
 
 	report := models.WalletReport{
-		UserID:              objID,
-		WalletID:            wallet.WalletID,
-		GeneratedAt:         now,
-		CurrentBalance:      currentBalance,
-		AvailableBalance:    currentBalance - pendingBalance,
-		PendingBalance:      pendingBalance,
-		TotalUTXOs:          int(totalUTXOs),
-		SpentUTXOs:          int(spentUTXOs),
-		UnspentUTXOs:        unspentUTXOs,
-		TotalTransactions:   int(sentCount + receivedCount),
-		SentTransactions:    int(sentCount),
+		UserID:               objID,
+		WalletID:             wallet.WalletID,
+		GeneratedAt:          now,
+		CurrentBalance:       currentBalance,
+		AvailableBalance:     currentBalance - pendingBalance,
+		PendingBalance:       pendingBalance,
+		TotalUTXOs:           int(totalUTXOs),
+		SpentUTXOs:           int(spentUTXOs),
+		UnspentUTXOs:         unspentUTXOs,
+		TotalTransactions:    int(sentCount + receivedCount),
+		SentTransactions:     int(sentCount),
 		ReceivedTransactions: int(receivedCount),
-		TotalSentAmount:     totalSentAmount,
-		TotalReceivedAmount: currentBalance + totalSentAmount - totalMiningRewards, // Approximation
-		BlocksMined:         int(blocksMined),
-		TotalMiningRewards:  totalMiningRewards,
-		ZakatEligible:       zakatEligible,
-		ZakatDue:            zakatDue,
-		TotalZakatPaid:      totalZakatPaid,
-		LastActivity:        lastActivity,
-		WalletCreatedAt:     wallet.CreatedAt,
+		TotalSentAmount:      totalSentAmount,
+		TotalReceivedAmount:  currentBalance + totalSentAmount - totalMiningRewards, // Approximation
+		BlocksMined:          int(blocksMined),
+		TotalMiningRewards:   totalMiningRewards,
+		ZakatEligible:        zakatEligible,
+		ZakatDue:             zakatDue,
+		TotalZakatPaid:       totalZakatPaid,
+		LastActivity:         lastActivity,
+		WalletCreatedAt:      wallet.CreatedAt,
 	}
 
 	c.JSON(http.StatusOK, gin.H{"report": report})
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SignMessage signs a message with the key behind one of the caller's
+// addresses, so the caller can prove control of the address to others. The
+// signature is made over crypto.MessageDigest, which can't collide with a
+// transaction sighash.
+func SignMessage(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.SignMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	address := &models.WalletAddress{
+		WalletID:  wallet.WalletID,
+		Address:   wallet.WalletID,
+		PublicKey: wallet.PublicKey,
+		Path:      masterKeyPath,
+	}
+	if req.Address != "" {
+		if err := crypto.ValidateAddress(req.Address); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address: " + err.Error()})
+			return
+		}
+		owner, resolved, err := resolveAddress(ctx, req.Address)
+		if err != nil || owner.ID != wallet.ID || resolved.PublicKey == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Address does not belong to your wallet"})
+			return
+		}
+		address = resolved
+	}
+
+	signature, err := signer.Default().SignDigest(ctx, wallet.WalletID, address.Path, crypto.MessageDigest(req.Message))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign message"})
+		return
+	}
+
+	keyType, _ := crypto.KeyTypeOf(address.PublicKey)
+	c.JSON(http.StatusOK, gin.H{
+		"walletId":  address.Address,
+		"publicKey": address.PublicKey,
+		"keyType":   keyType,
+		"message":   req.Message,
+		"signature": signature,
+	})
+}
+
+// VerifyMessage checks that a message was signed by the key behind a wallet ID
+// or derived address (public endpoint)
+func VerifyMessage(c *gin.Context) {
+	var req models.VerifyMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := crypto.ValidateAddress(req.WalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": "Invalid wallet ID: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, address, err := resolveAddress(ctx, req.WalletID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if address.PublicKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": "Multisig addresses can't sign messages"})
+		return
+	}
+
+	valid, err := crypto.VerifyMessage(address.PublicKey, req.Message, req.Signature)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "walletId": address.Address, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":     valid,
+		"walletId":  address.Address,
+		"publicKey": address.PublicKey,
+	})
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mining runs in background jobs so requests return at once; clients poll the
+// job until it finishes. Job state lives in the mining_jobs collection, so any
+// server instance can report on or cancel a job. The instance running a job
+// refreshes its updatedAt every miningHeartbeat, and a job that stops doing so
+// is treated as failed.
+//
+// The per-user job limit is kept in the mining_job_slots collection, which has
+// a document per user counting their unfinished jobs. A job takes a slot with a
+// conditional increment before it is created and gives it back once it finishes
+// or is found stale, so the limit holds across instances.
+
+const (
+	miningHeartbeat = 2 * time.Second
+	miningJobStale  = 30 * time.Second
+)
+
+var (
+	// errJobCancelled stops a job cancelled by its owner
+	errJobCancelled = errors.New("mining job cancelled")
+
+	// runningJobs cancels the jobs running in this instance
+	runningJobs   = map[primitive.ObjectID]context.CancelCauseFunc{}
+	runningJobsMu sync.Mutex
+)
+
+func getMiningJobCollection() *mongo.Collection {
+	return database.GetCollection("mining_jobs")
+}
+
+func getMiningJobSlotCollection() *mongo.Collection {
+	return database.GetCollection("mining_job_slots")
+}
+
+// unfinishedMiningJobStatuses are the statuses of jobs that hold a slot
+var unfinishedMiningJobStatuses = []models.MiningJobStatus{models.MiningJobQueued, models.MiningJobRunning}
+
+// claimMiningJobSlot takes one of the user's limit job slots. When all are
+// taken the upsert collides with the user's slot document and it returns a
+// duplicate key error.
+func claimMiningJobSlot(ctx context.Context, userID primitive.ObjectID, limit int) error {
+	_, err := getMiningJobSlotCollection().UpdateOne(ctx,
+		bson.M{"_id": userID, "active": bson.M{"$lt": limit}},
+		bson.M{"$inc": bson.M{"active": 1}, "$set": bson.M{"updatedAt": time.Now()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// releaseMiningJobSlot gives back a slot taken by claimMiningJobSlot
+func releaseMiningJobSlot(ctx context.Context, userID primitive.ObjectID) {
+	_, err := getMiningJobSlotCollection().UpdateOne(ctx,
+		bson.M{"_id": userID, "active": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"active": -1}, "$set": bson.M{"updatedAt": time.Now()}})
+	if err != nil {
+		log.Printf("⚠️ [Mining] Failed to release a mining job slot of user %s: %v", userID.Hex(), err)
+	}
+}
+
+// failStaleMiningJobs marks the user's jobs that stopped sending heartbeats as
+// failed, giving back their slots
+func failStaleMiningJobs(ctx context.Context, userID primitive.ObjectID) error {
+	cursor, err := getMiningJobCollection().Find(ctx, bson.M{
+		"userId":    userID,
+		"status":    bson.M{"$in": unfinishedMiningJobStatuses},
+		"updatedAt": bson.M{"$lt": time.Now().Add(-miningJobStale)},
+	}, options.Find().SetProjection(bson.M{"result": 0}))
+	if err != nil {
+		return err
+	}
+	var jobs []models.MiningJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return err
+	}
+	for i := range jobs {
+		markStaleMiningJob(ctx, &jobs[i])
+	}
+	return nil
+}
+
+// MineBlock starts a background job mining the next block for the caller and
+// returns its ID at once. Poll GET /mining-jobs/:id for the result.
+func MineBlock(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Get miner's wallet
+	var minerWallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&minerWallet)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+		return
+	}
+
+	count, err := getBlockCollection().CountDocuments(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No genesis block. Please create genesis block first."})
+		return
+	}
+
+	if err := failStaleMiningJobs(ctx, objID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	limit := models.DefaultBlockchainConfig.MaxMiningJobsPerUser
+	if err := claimMiningJobSlot(ctx, objID, limit); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": fmt.Sprintf("You can run at most %d mining job(s) at a time", limit),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	now := time.Now()
+	job := models.MiningJob{
+		UserID:        objID,
+		MinerWalletID: minerWallet.WalletID,
+		Status:        models.MiningJobQueued,
+		MiningReward:  models.DefaultBlockchainConfig.BlockReward,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	result, err := getMiningJobCollection().InsertOne(ctx, job)
+	if err != nil {
+		releaseMiningJobSlot(ctx, objID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create mining job"})
+		return
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+
+	timeout := time.Duration(models.DefaultBlockchainConfig.MiningJobTimeout) * time.Second
+	jobCtx, cancelJob := context.WithCancelCause(context.Background())
+	jobCtx, cancelTimeout := context.WithTimeout(jobCtx, timeout)
+
+	runningJobsMu.Lock()
+	runningJobs[job.ID] = cancelJob
+	runningJobsMu.Unlock()
+
+	go func() {
+		defer cancelTimeout()
+		defer func() {
+			runningJobsMu.Lock()
+			delete(runningJobs, job.ID)
+			runningJobsMu.Unlock()
+			cancelJob(nil)
+		}()
+		runMiningJob(jobCtx, cancelJob, job.ID, &minerWallet)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Mining job started",
+		"jobId":   job.ID.Hex(),
+		"job":     job,
+	})
+}
+
+// runMiningJob mines until a block is saved, the job is cancelled or ctx
+// times out, then records the outcome. When another block becomes the tip
+// mining starts again on top of it.
+func runMiningJob(ctx context.Context, cancel context.CancelCauseFunc, jobID primitive.ObjectID, minerWallet *models.Wallet) {
+	miner := &crypto.Miner{}
+	startedAt := time.Now()
+	updateMiningJob(jobID, bson.M{"status": models.MiningJobRunning, "startedAt": startedAt})
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go miningJobHeartbeat(heartbeatCtx, cancel, jobID, miner, startedAt)
+
+	started := func(header *models.BlockHeader, txCount int) {
+		updateMiningJob(jobID, bson.M{
+			"blockIndex":       header.Index,
+			"previousHash":     header.PreviousHash,
+			"transactionCount": txCount,
+			"merkleRoot":       header.MerkleRoot,
+			"difficulty":       header.Difficulty,
+			"bits":             header.Bits,
+			"targetPrefix":     getTargetPrefix(header.Difficulty),
+		})
+	}
+
+	var (
+		block *models.Block
+		mined *crypto.MinerResult
+		err   error
+	)
+	for {
+		block, mined, err = mineNextBlock(ctx, minerWallet, miner, started)
+		if !errors.Is(err, errNewTip) || ctx.Err() != nil {
+			break
+		}
+	}
+	stopHeartbeat()
+
+	finishedAt := time.Now()
+	elapsed := finishedAt.Sub(startedAt)
+	result := &models.MiningResult{MiningTime: elapsed.Seconds()}
+	status := models.MiningJobFailed
+	switch {
+	case err == nil:
+		status = models.MiningJobSucceeded
+		result.Success = true
+		result.Block = block
+		result.Message = "Block mined successfully! 🎉"
+		result.Nonce = block.Nonce
+		result.Hash = block.Hash
+		result.ExtraNonce = mined.ExtraNonce
+	case context.Cause(ctx) == errJobCancelled:
+		status = models.MiningJobCancelled
+		result.Message = "Mining job cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Message = "Mining timeout - could not find valid hash"
+	default:
+		result.Message = "Mining failed: " + err.Error()
+	}
+
+	hashes := miner.Hashes()
+	update := bson.M{
+		"status":     status,
+		"result":     result,
+		"hashes":     int64(hashes),
+		"finishedAt": finishedAt,
+	}
+	if elapsed > 0 {
+		update["hashrate"] = float64(hashes) / elapsed.Seconds()
+	}
+	finishMiningJob(jobID, update)
+}
+
+// miningJobHeartbeat records a running job's progress every miningHeartbeat
+// and cancels it once its owner has asked for that, which may have happened on
+// another instance
+func miningJobHeartbeat(ctx context.Context, cancel context.CancelCauseFunc, jobID primitive.ObjectID, miner *crypto.Miner, startedAt time.Time) {
+	ticker := time.NewTicker(miningHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hashes := miner.Hashes()
+			job, err := updateMiningJob(jobID, bson.M{
+				"hashes":   int64(hashes),
+				"hashrate": float64(hashes) / time.Since(startedAt).Seconds(),
+			})
+			if err == nil && job.CancelRequested {
+				cancel(errJobCancelled)
+				return
+			}
+		}
+	}
+}
+
+// updateMiningJob sets fields of a job along with its updatedAt and returns the
+// updated job
+func updateMiningJob(jobID primitive.ObjectID, fields bson.M) (*models.MiningJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fields["updatedAt"] = time.Now()
+	var job models.MiningJob
+	err := getMiningJobCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": jobID},
+		bson.M{"$set": fields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finishMiningJob records the outcome of a job and gives back its slot, unless
+// the job was already marked stale, which gave it back then
+func finishMiningJob(jobID primitive.ObjectID, fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fields["updatedAt"] = time.Now()
+	var job models.MiningJob
+	err := getMiningJobCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": jobID},
+		bson.M{"$set": fields},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&job)
+	if err != nil {
+		log.Printf("⚠️ [Mining] Failed to record the outcome of mining job %s: %v", jobID.Hex(), err)
+		return
+	}
+	if !job.IsFinished() {
+		releaseMiningJobSlot(ctx, job.UserID)
+	}
+}
+
+// GetMyMiningJobs lists the caller's mining jobs, newest first, optionally
+// filtered by ?status=queued|running|succeeded|failed|cancelled
+func GetMyMiningJobs(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	filter := bson.M{"userId": objID}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	// Results carry whole blocks, so the list leaves them out
+	cursor, err := getMiningJobCollection().Find(ctx, filter,
+		options.Find().
+			SetSort(bson.M{"createdAt": -1}).
+			SetLimit(50).
+			SetProjection(bson.M{"result.block": 0}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mining jobs"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []models.MiningJob{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse mining jobs"})
+		return
+	}
+	for i := range jobs {
+		markStaleMiningJob(ctx, &jobs[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+// GetMiningJob returns one of the caller's mining jobs
+func GetMiningJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job, ok := loadMiningJobForUser(ctx, c)
+	if !ok {
+		return
+	}
+	markStaleMiningJob(ctx, job)
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// CancelMiningJob stops one of the caller's unfinished mining jobs. A job
+// running in another instance stops at its next heartbeat.
+func CancelMiningJob(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	job, ok := loadMiningJobForUser(ctx, c)
+	if !ok {
+		return
+	}
+	markStaleMiningJob(ctx, job)
+	if job.IsFinished() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Mining job already " + string(job.Status)})
+		return
+	}
+
+	_, err := getMiningJobCollection().UpdateOne(ctx,
+		bson.M{"_id": job.ID},
+		bson.M{"$set": bson.M{"cancelRequested": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel mining job"})
+		return
+	}
+	job.CancelRequested = true
+
+	runningJobsMu.Lock()
+	if cancelJob, ok := runningJobs[job.ID]; ok {
+		cancelJob(errJobCancelled)
+	}
+	runningJobsMu.Unlock()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Mining job is being cancelled",
+		"job":     job,
+	})
+}
+
+// loadMiningJobForUser loads the caller's job named by the :id parameter,
+// writing an error response if it is missing
+func loadMiningJobForUser(ctx context.Context, c *gin.Context) (*models.MiningJob, bool) {
+	objID, err := primitive.ObjectIDFromHex(c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, false
+	}
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mining job ID"})
+		return nil, false
+	}
+
+	var job models.MiningJob
+	err = getMiningJobCollection().FindOne(ctx, bson.M{"_id": jobID, "userId": objID}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mining job not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// markStaleMiningJob fails an unfinished job whose instance stopped sending
+// heartbeats, for example because the server restarted, and gives back its slot
+func markStaleMiningJob(ctx context.Context, job *models.MiningJob) {
+	if job.IsFinished() || time.Since(job.UpdatedAt) < miningJobStale {
+		return
+	}
+
+	now := time.Now()
+	job.Status = models.MiningJobFailed
+	job.Result = &models.MiningResult{Message: "Mining job stopped responding"}
+	job.FinishedAt = &now
+	result, err := getMiningJobCollection().UpdateOne(ctx,
+		bson.M{"_id": job.ID, "status": bson.M{"$in": unfinishedMiningJobStatuses}},
+		bson.M{"$set": bson.M{
+			"status":     job.Status,
+			"result":     job.Result,
+			"finishedAt": now,
+		}})
+	// Only the update that finished the job gives back its slot
+	if err == nil && result.ModifiedCount == 1 {
+		releaseMiningJobSlot(ctx, job.UserID)
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/models"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestClaimMiningJobSlot(t *testing.T) {
+	userID := primitive.NewObjectID()
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mockWritten(1))
+		if err := claimMiningJobSlot(context.Background(), userID, 2); err != nil {
+			t.Fatal(err)
+		}
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "update mining_job_slots")
+		if got := commands[0].filter.Lookup("active", "$lt").AsInt64(); got != 2 {
+			t.Errorf("claimed a slot while %d jobs are active, want fewer than 2", got)
+		}
+		if got := commands[0].update.Lookup("$inc", "active").AsInt64(); got != 1 {
+			t.Errorf("active incremented by %d, want 1", got)
+		}
+	})
+
+	// Every slot is taken, so the upsert collides with the slot document
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}))
+		if err := claimMiningJobSlot(context.Background(), userID, 1); !mongo.IsDuplicateKeyError(err) {
+			t.Fatalf("err = %v, want a duplicate key error", err)
+		}
+	})
+}
+
+func TestMarkStaleMiningJobReleasesSlotOnce(t *testing.T) {
+	stale := func() *models.MiningJob {
+		return &models.MiningJob{
+			ID:        primitive.NewObjectID(),
+			UserID:    primitive.NewObjectID(),
+			Status:    models.MiningJobRunning,
+			UpdatedAt: time.Now().Add(-2 * miningJobStale),
+		}
+	}
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		job := stale()
+		mt.AddMockResponses(mockWritten(1), mockWritten(1))
+		markStaleMiningJob(context.Background(), job)
+		if job.Status != models.MiningJobFailed {
+			t.Errorf("status = %s, want %s", job.Status, models.MiningJobFailed)
+		}
+		commands := mockCommands(mt)
+		checkCommands(t, commands, "update mining_jobs", "update mining_job_slots")
+		if commands[1].filter.Lookup("_id").ObjectID() != job.UserID {
+			t.Errorf("released a slot with %s, want the job owner's", commands[1].filter)
+		}
+	})
+
+	// The job finished, or another request marked it stale, first
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		mt.AddMockResponses(mockWritten(0))
+		markStaleMiningJob(context.Background(), stale())
+		checkCommands(t, mockCommands(mt), "update mining_jobs")
+	})
+
+	withMockDatabase(t, func(t *testing.T, mt *mtest.T) {
+		job := stale()
+		job.UpdatedAt = time.Now()
+		markStaleMiningJob(context.Background(), job)
+		if commands := mockCommands(mt); len(commands) != 0 {
+			t.Errorf("job with a recent heartbeat sent %v", commands)
+		}
+	})
+}
@@ -0,0 +1,779 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Multisig wallets are not owned by a single user. Each participant takes part
+// with the key of their own wallet: any participant can propose a spend, the
+// proposal is shown to every participant, and it is broadcast as soon as the
+// wallet's threshold of participants have signed it.
+
+var (
+	errSpendNotPending = errors.New("spend is no longer pending")
+	errInputsSpent     = errors.New("one or more inputs were already spent by another transaction")
+	errSpendRejected   = errors.New("collected signatures do not satisfy the redeem policy")
+)
+
+func getMultisigWalletCollection() *mongo.Collection {
+	return database.GetCollection("multisig_wallets")
+}
+
+func getMultisigSpendCollection() *mongo.Collection {
+	return database.GetCollection("multisig_spends")
+}
+
+// findMultisigWallet looks up a multisig wallet by its address
+func findMultisigWallet(ctx context.Context, address string) (*models.MultisigWallet, error) {
+	var multisig models.MultisigWallet
+	err := getMultisigWalletCollection().FindOne(ctx, bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(address)}}).Decode(&multisig)
+	if err != nil {
+		return nil, err
+	}
+	return &multisig, nil
+}
+
+// CreateMultisigWallet creates an m-of-n wallet from participant public keys.
+// The caller's wallet key must be one of the participants.
+func CreateMultisigWallet(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.CreateMultisigWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	policy, err := crypto.NewMultisigPolicy(req.Threshold, req.PublicKeys)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multisig policy: " + err.Error()})
+		return
+	}
+	if !policy.HasKey(wallet.PublicKey) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your wallet's public key must be one of the participants"})
+		return
+	}
+
+	// The address is determined by the policy, so the same policy can only be registered once
+	address := policy.Address()
+	if existing, err := findMultisigWallet(ctx, address); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This multisig wallet already exists", "walletId": existing.WalletID})
+		return
+	} else if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	multisig := models.MultisigWallet{
+		ID:           primitive.NewObjectID(),
+		WalletID:     address,
+		Threshold:    policy.Threshold,
+		PublicKeys:   policy.PublicKeys,
+		RedeemPolicy: policy.Hex(),
+		CreatedBy:    objID,
+		CreatedAt:    time.Now(),
+	}
+	if _, err := getMultisigWalletCollection().InsertOne(ctx, multisig); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create multisig wallet"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("%d-of-%d multisig wallet created", policy.Threshold, len(policy.PublicKeys)),
+		"wallet":  multisig,
+	})
+}
+
+// GetMyMultisigWallets lists the multisig wallets the user participates in, with their balances
+func GetMyMultisigWallets(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	cursor, err := getMultisigWalletCollection().Find(ctx, bson.M{"publicKeys": wallet.PublicKey},
+		options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch multisig wallets"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var multisigs []models.MultisigWallet
+	if err := cursor.All(ctx, &multisigs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse multisig wallets"})
+		return
+	}
+
+	// Balances are summed from unspent outputs, as for single-key wallets
+	addresses := make([]string, len(multisigs))
+	for i, multisig := range multisigs {
+		addresses[i] = multisig.WalletID
+	}
+	balances := map[string]models.Amount{}
+	if len(addresses) > 0 {
+		pipeline := mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"walletId": bson.M{"$in": addresses}, "isSpent": false}}},
+			bson.D{{Key: "$group", Value: bson.M{"_id": "$walletId", "total": bson.M{"$sum": "$amount"}}}},
+		}
+		balanceCursor, err := getUTXOCollection().Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balances"})
+			return
+		}
+		defer balanceCursor.Close(ctx)
+
+		var totals []struct {
+			WalletID string        `bson:"_id"`
+			Total    models.Amount `bson:"total"`
+		}
+		if err := balanceCursor.All(ctx, &totals); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balances"})
+			return
+		}
+		for _, total := range totals {
+			balances[total.WalletID] = total.Total
+		}
+	}
+
+	response := make([]models.MultisigWalletResponse, len(multisigs))
+	for i, multisig := range multisigs {
+		response[i] = models.MultisigWalletResponse{MultisigWallet: multisig, Balance: balances[multisig.WalletID]}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wallets": response,
+		"count":   len(response),
+	})
+}
+
+// ProposeMultisigSpend builds an unsigned transaction from a multisig wallet and
+// stores it for the participants to sign. Change returns to the multisig address.
+func ProposeMultisigSpend(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ProposeMultisigSpendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	multisig, err := findMultisigWallet(ctx, req.MultisigWalletID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Multisig wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	policy, err := crypto.ParseMultisigPolicy(multisig.RedeemPolicy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Multisig wallet has an invalid redeem policy"})
+		return
+	}
+	if !policy.HasKey(wallet.PublicKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a participant of this multisig wallet"})
+		return
+	}
+
+	// Catch typos offline before looking the address up
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+		return
+	}
+	_, recipient, err := resolveAddress(ctx, req.RecipientWalletID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if recipient.Address == multisig.WalletID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send to the same multisig wallet"})
+		return
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	cursor, err := getUTXOCollection().Find(ctx, spendableUTXOFilter([]string{multisig.WalletID}, height), utxoSelectionOrder())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch UTXOs"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var utxos []models.UTXO
+	if err := cursor.All(ctx, &utxos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse UTXOs"})
+		return
+	}
+
+	// Select UTXOs for transaction (greedy algorithm)
+	var selectedUTXOs []models.UTXO
+	var totalInput models.Amount
+	for _, utxo := range utxos {
+		selectedUTXOs = append(selectedUTXOs, utxo)
+		totalInput += utxo.Amount
+		if totalInput >= req.Amount {
+			break
+		}
+	}
+	if totalInput < req.Amount {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Insufficient balance",
+			"available": totalInput,
+			"requested": req.Amount,
+		})
+		return
+	}
+	change := totalInput - req.Amount
+
+	var inputs []models.SignedInput
+	for _, utxo := range selectedUTXOs {
+		inputs = append(inputs, models.SignedInput{
+			TransactionID: utxo.TransactionID,
+			OutputIndex:   utxo.OutputIndex,
+			Amount:        utxo.Amount,
+			SigHashType:   uint8(crypto.SigHashAll),
+			RedeemPolicy:  multisig.RedeemPolicy,
+		})
+	}
+
+	outputs := []models.TransactionOutput{{
+		WalletID:      recipient.Address,
+		Amount:        req.Amount,
+		PublicKey:     recipient.PublicKey,
+		LockingScript: outputLockingScript(recipient.Address),
+	}}
+	if change > 0 {
+		outputs = append(outputs, models.TransactionOutput{
+			WalletID: multisig.WalletID,
+			Amount:   change,
+		})
+	}
+
+	transaction := models.Transaction{
+		Type:         models.TxTypeTransfer,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		TotalInput:   totalInput,
+		TotalOutput:  req.Amount + change,
+		Fee:          0,
+		SenderWallet: multisig.WalletID,
+		Status:       models.TxStatusPending,
+		Timestamp:    time.Now(),
+		Message:      req.Message,
+	}
+	transaction.TransactionID = crypto.GenerateTransactionID(&transaction)
+
+	inputData, outputData := transactionSigHashData(&transaction)
+	dataToSign := make([]string, len(inputs))
+	for i := range inputs {
+		if dataToSign[i], err = crypto.CalculateSigHash(inputData, outputData, i, crypto.SigHashAll); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	spend := models.MultisigSpend{
+		ID:               primitive.NewObjectID(),
+		MultisigWalletID: multisig.WalletID,
+		Threshold:        multisig.Threshold,
+		Transaction:      transaction,
+		DataToSign:       dataToSign,
+		Signers:          []string{},
+		ProposedBy:       wallet.WalletID,
+		Status:           models.MultisigSpendPending,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if _, err := getMultisigSpendCollection().InsertOne(ctx, spend); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save spend"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": fmt.Sprintf("Spend proposed. It needs signatures from %d participants.", multisig.Threshold),
+		"spend":   spend,
+	})
+}
+
+// GetMyMultisigSpends lists spends of the multisig wallets the user participates
+// in, newest first. Filter with ?status=pending|broadcast|failed.
+func GetMyMultisigSpends(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	addresses, err := getMultisigWalletCollection().Distinct(ctx, "walletId", bson.M{"publicKeys": wallet.PublicKey})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch multisig wallets"})
+		return
+	}
+
+	filter := bson.M{"multisigWalletId": bson.M{"$in": addresses}}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	spends := []models.MultisigSpend{}
+	if len(addresses) > 0 {
+		cursor, err := getMultisigSpendCollection().Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch spends"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		if err := cursor.All(ctx, &spends); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse spends"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"spends": spends,
+		"count":  len(spends),
+	})
+}
+
+// GetMultisigSpend returns one spend of a multisig wallet the user participates in
+func GetMultisigSpend(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	spendID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid spend ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var spend models.MultisigSpend
+	err = getMultisigSpendCollection().FindOne(ctx, bson.M{"_id": spendID}).Decode(&spend)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Spend not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	multisig, err := findMultisigWallet(ctx, spend.MultisigWalletID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Multisig wallet not found"})
+		return
+	}
+	policy, err := crypto.ParseMultisigPolicy(multisig.RedeemPolicy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Multisig wallet has an invalid redeem policy"})
+		return
+	}
+	if !policy.HasKey(wallet.PublicKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a participant of this multisig wallet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"spend": spend})
+}
+
+// SignMultisigSpend adds the caller's signature to every input of a pending spend
+// and broadcasts the transaction once the threshold is reached
+func SignMultisigSpend(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.SignMultisigSpendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	spendID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid spend ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var spend models.MultisigSpend
+	err = getMultisigSpendCollection().FindOne(ctx, bson.M{"_id": spendID}).Decode(&spend)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Spend not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if spend.Status != models.MultisigSpendPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Spend is not pending", "status": spend.Status})
+		return
+	}
+
+	multisig, err := findMultisigWallet(ctx, spend.MultisigWalletID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Multisig wallet not found"})
+		return
+	}
+	policy, err := crypto.ParseMultisigPolicy(multisig.RedeemPolicy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Multisig wallet has an invalid redeem policy"})
+		return
+	}
+	if !policy.HasKey(wallet.PublicKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not a participant of this multisig wallet"})
+		return
+	}
+
+	inputData, outputData := transactionSigHashData(&spend.Transaction)
+	signatures := make([]string, len(spend.Transaction.Inputs))
+	if len(req.Signatures) == 0 {
+		// Sign with the wallet's own key through the configured signer
+		for i := range signatures {
+			digest, err := crypto.SigHashDigest(inputData, outputData, i, crypto.SigHashAll)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if signatures[i], err = signer.Default().SignDigest(ctx, wallet.WalletID, masterKeyPath, digest); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign spend"})
+				return
+			}
+		}
+	} else {
+		// Client-side signatures over each input's DataToSign
+		for _, sig := range req.Signatures {
+			if sig.InputIndex < 0 || sig.InputIndex >= len(signatures) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Input index out of range", "inputIndex": sig.InputIndex})
+				return
+			}
+			if sig.SigHashType != 0 && crypto.SigHashType(sig.SigHashType) != crypto.SigHashAll {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Multisig inputs must be signed with SIGHASH_ALL", "inputIndex": sig.InputIndex})
+				return
+			}
+			signatures[sig.InputIndex] = sig.Signature
+		}
+		for i, signature := range signatures {
+			if signature == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Missing signature for input", "inputIndex": i})
+				return
+			}
+			valid, err := crypto.VerifyInputSignature(wallet.PublicKey, inputData, outputData, i, crypto.SigHashAll, signature)
+			if err != nil || !valid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature", "inputIndex": i})
+				return
+			}
+		}
+	}
+
+	// Add the signatures in one update, so concurrent signers can't overwrite each other
+	push := bson.M{}
+	for i, signature := range signatures {
+		push["transaction.inputs."+strconv.Itoa(i)+".cosignatures"] = models.CosignerSignature{
+			PublicKey: wallet.PublicKey,
+			Signature: signature,
+		}
+	}
+	var updated models.MultisigSpend
+	err = getMultisigSpendCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": spend.ID, "status": models.MultisigSpendPending, "signers": bson.M{"$ne": wallet.PublicKey}},
+		bson.M{
+			"$push":     push,
+			"$addToSet": bson.M{"signers": wallet.PublicKey},
+			"$set":      bson.M{"updatedAt": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already signed this spend or it is no longer pending"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save signature"})
+		return
+	}
+
+	if len(updated.Signers) < updated.Threshold {
+		c.JSON(http.StatusOK, gin.H{
+			"message": fmt.Sprintf("Signature added. %d of %d signatures collected.", len(updated.Signers), updated.Threshold),
+			"spend":   updated,
+		})
+		return
+	}
+
+	if err := broadcastMultisigSpend(ctx, &updated); err != nil {
+		switch {
+		case err == errSpendNotPending:
+			c.JSON(http.StatusConflict, gin.H{"error": "Spend was already broadcast"})
+		case err == errInputsSpent, errors.Is(err, errSpendRejected):
+			c.JSON(http.StatusConflict, gin.H{"error": "Spend failed: " + err.Error(), "spend": updated})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to broadcast spend", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Threshold reached. Transaction broadcast successfully!",
+		"spend":       updated,
+		"transaction": updated.Transaction,
+	})
+}
+
+// broadcastMultisigSpend verifies every input's signatures against the redeem
+// policy and records the transaction, spending its inputs. A spend whose
+// signatures don't satisfy the policy, or whose inputs were taken by another
+// transaction, is marked failed.
+func broadcastMultisigSpend(ctx context.Context, spend *models.MultisigSpend) error {
+	tx := &spend.Transaction
+
+	// Further signatures can't replace a bad one, so the spend would stay pending forever
+	inputData, outputData := transactionSigHashData(tx)
+	for i, input := range tx.Inputs {
+		if err := crypto.VerifyMultisigInput(spend.MultisigWalletID, input.RedeemPolicy, input.Cosignatures, inputData, outputData, i, crypto.SigHashAll); err != nil {
+			return failMultisigSpend(ctx, spend, fmt.Errorf("%w: input %d: %v", errSpendRejected, i, err))
+		}
+	}
+
+	session, err := database.GetClient().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	now := time.Now()
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// Only one signer may move the spend out of pending
+		result, err := getMultisigSpendCollection().UpdateOne(sessCtx,
+			bson.M{"_id": spend.ID, "status": models.MultisigSpendPending},
+			bson.M{"$set": bson.M{"status": models.MultisigSpendBroadcast, "updatedAt": now}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.ModifiedCount == 0 {
+			return nil, errSpendNotPending
+		}
+
+		// Mark input UTXOs as spent
+		for _, input := range tx.Inputs {
+			result, err := getUTXOCollection().UpdateOne(sessCtx, bson.M{
+				"transactionId": input.TransactionID,
+				"outputIndex":   input.OutputIndex,
+				"walletId":      spend.MultisigWalletID,
+				"isSpent":       false,
+			}, bson.M{
+				"$set": bson.M{
+					"isSpent":   true,
+					"spentInTx": tx.TransactionID,
+					"spentAt":   now,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errInputsSpent
+			}
+		}
+
+		// Create new UTXOs for outputs
+		for i, output := range tx.Outputs {
+			if _, err := getUTXOCollection().InsertOne(sessCtx, newOutputUTXO(tx.TransactionID, i, output, now)); err != nil {
+				return nil, err
+			}
+		}
+
+		// Save the transaction
+		if _, err := getTransactionCollection().InsertOne(sessCtx, tx); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	if err == errInputsSpent {
+		return failMultisigSpend(ctx, spend, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	spend.Status = models.MultisigSpendBroadcast
+	return nil
+}
+
+// failMultisigSpend moves a pending spend to failed, recording reason, and
+// returns reason
+func failMultisigSpend(ctx context.Context, spend *models.MultisigSpend, reason error) error {
+	_, err := getMultisigSpendCollection().UpdateOne(ctx,
+		bson.M{"_id": spend.ID, "status": models.MultisigSpendPending},
+		bson.M{"$set": bson.M{"status": models.MultisigSpendFailed, "error": reason.Error(), "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+
+	spend.Status = models.MultisigSpendFailed
+	spend.Error = reason.Error()
+	return reason
+}
+
+// transactionSigHashData extracts the input and output data that sighashes cover
+func transactionSigHashData(tx *models.Transaction) ([]crypto.InputData, []crypto.OutputData) {
+	inputs := make([]crypto.InputData, len(tx.Inputs))
+	for i, input := range tx.Inputs {
+		inputs[i] = crypto.InputData{
+			TransactionID: input.TransactionID,
+			OutputIndex:   input.OutputIndex,
+			Amount:        input.Amount,
+			LockTime:      tx.LockTime,
+		}
+	}
+
+	outputs := make([]crypto.OutputData, len(tx.Outputs))
+	for i, output := range tx.Outputs {
+		outputs[i] = crypto.OutputData{
+			WalletID:      output.WalletID,
+			Amount:        output.Amount,
+			LockingScript: output.LockingScript,
+			LockUntil:     output.LockUntil,
+			RelativeLock:  output.RelativeLock,
+		}
+	}
+	return inputs, outputs
+}
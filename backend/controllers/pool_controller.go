@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// poolStatsWindow is how far back pool hashrates and share counts look
+const poolStatsWindow = 10 * time.Minute
+
+func getPoolShareCollection() *mongo.Collection {
+	return database.GetCollection("pool_shares")
+}
+
+// pplnsSplit splits reward between the wallets of the last PoolPPLNSWindow
+// shares in proportion to their difficulty. It returns each wallet's part,
+// largest first, along with the coinbase outputs paying them. Rounding leftovers
+// go to the largest part. Both are empty while the pool has no shares.
+func pplnsSplit(ctx context.Context, reward models.Amount) ([]models.PoolPayout, []models.TransactionOutput, error) {
+	cursor, err := getPoolShareCollection().Find(ctx, bson.M{},
+		options.Find().
+			SetSort(bson.M{"createdAt": -1}).
+			SetLimit(int64(models.DefaultBlockchainConfig.PoolPPLNSWindow)).
+			SetProjection(bson.M{"walletId": 1, "difficulty": 1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var shares []models.PoolShare
+	if err := cursor.All(ctx, &shares); err != nil {
+		return nil, nil, err
+	}
+
+	parts := map[string]*models.PoolPayout{}
+	var walletIDs []string
+	for _, share := range shares {
+		part, ok := parts[share.WalletID]
+		if !ok {
+			part = &models.PoolPayout{WalletID: share.WalletID}
+			parts[share.WalletID] = part
+			walletIDs = append(walletIDs, share.WalletID)
+		}
+		part.Shares++
+		part.Weight += share.Difficulty
+	}
+	if len(walletIDs) == 0 {
+		return []models.PoolPayout{}, nil, nil
+	}
+
+	// Shares of wallets that no longer exist are left out
+	walletCursor, err := getWalletCollection().Find(ctx, bson.M{"walletId": bson.M{"$in": walletIDs}})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer walletCursor.Close(ctx)
+
+	var wallets []models.Wallet
+	if err := walletCursor.All(ctx, &wallets); err != nil {
+		return nil, nil, err
+	}
+
+	payouts := make([]models.PoolPayout, 0, len(wallets))
+	byWallet := make(map[string]*models.Wallet, len(wallets))
+	var totalWeight int64
+	for i := range wallets {
+		byWallet[wallets[i].WalletID] = &wallets[i]
+		payouts = append(payouts, *parts[wallets[i].WalletID])
+		totalWeight += parts[wallets[i].WalletID].Weight
+	}
+	if totalWeight == 0 {
+		return []models.PoolPayout{}, nil, nil
+	}
+	sort.Slice(payouts, func(i, j int) bool {
+		if payouts[i].Weight != payouts[j].Weight {
+			return payouts[i].Weight > payouts[j].Weight
+		}
+		return payouts[i].WalletID < payouts[j].WalletID
+	})
+
+	var paid models.Amount
+	total := big.NewInt(totalWeight)
+	for i := range payouts {
+		amount := new(big.Int).Mul(big.NewInt(int64(reward)), big.NewInt(payouts[i].Weight))
+		payouts[i].Amount = models.Amount(amount.Div(amount, total).Int64())
+		payouts[i].Percent = float64(payouts[i].Weight) / float64(totalWeight) * 100
+		paid += payouts[i].Amount
+	}
+	payouts[0].Amount += reward - paid
+
+	outputs := make([]models.TransactionOutput, 0, len(payouts))
+	for _, payout := range payouts {
+		if payout.Amount > 0 {
+			outputs = append(outputs, walletPayout(byWallet[payout.WalletID], payout.Amount))
+		}
+	}
+	return payouts, outputs, nil
+}
+
+// poolHashrate estimates the hashes per second behind shares of total
+// difficulty found over window
+func poolHashrate(difficulty int64, window time.Duration) float64 {
+	work := new(big.Float).SetInt(crypto.TargetWork(crypto.ShareTarget(1)))
+	hashes, _ := work.Mul(work, big.NewFloat(float64(difficulty))).Float64()
+	return hashes / window.Seconds()
+}
+
+// GetPoolStats returns the mining pool's hashrate, blocks found, current job
+// and the split the next block's reward would have
+func GetPoolStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	difficulty := models.DefaultBlockchainConfig.PoolShareDifficulty
+	stats := models.PoolStats{
+		Enabled:         pool != nil,
+		ShareDifficulty: difficulty,
+		ShareTarget:     crypto.FormatTarget(crypto.ShareTarget(difficulty)),
+		PPLNSWindow:     models.DefaultBlockchainConfig.PoolPPLNSWindow,
+	}
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"createdAt": bson.M{"$gte": time.Now().Add(-poolStatsWindow)}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":        nil,
+			"shares":     bson.M{"$sum": 1},
+			"difficulty": bson.M{"$sum": "$difficulty"},
+		}}},
+	}
+	cursor, err := getPoolShareCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pool shares"})
+		return
+	}
+	defer cursor.Close(ctx)
+	if cursor.Next(ctx) {
+		var recent struct {
+			Shares     int64 `bson:"shares"`
+			Difficulty int64 `bson:"difficulty"`
+		}
+		cursor.Decode(&recent)
+		stats.RecentShares = recent.Shares
+		stats.Hashrate = poolHashrate(recent.Difficulty, poolStatsWindow)
+	}
+
+	stats.BlocksFound, err = getPoolShareCollection().CountDocuments(ctx, bson.M{"blockHash": bson.M{"$exists": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	stats.Payouts, _, err = pplnsSplit(ctx, models.DefaultBlockchainConfig.BlockReward)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute payouts"})
+		return
+	}
+
+	if pool != nil {
+		pool.mu.Lock()
+		job := pool.job
+		for conn := range pool.conns {
+			conn.mu.Lock()
+			stats.ConnectedWorkers += len(conn.workers)
+			conn.mu.Unlock()
+		}
+		pool.mu.Unlock()
+
+		if job != nil {
+			stats.CurrentJob = &models.PoolJob{
+				ID:               job.id,
+				BlockIndex:       job.tpl.index,
+				PreviousHash:     job.tpl.parent.Hash,
+				TransactionCount: len(job.tpl.transactions),
+				Bits:             job.tpl.bits,
+				Difficulty:       job.tpl.difficulty,
+				CreatedAt:        job.createdAt,
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
+
+// GetPoolWorkers lists the pool's workers: those connected now and those that
+// submitted shares recently
+func GetPoolWorkers(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"createdAt": bson.M{"$gte": time.Now().Add(-poolStatsWindow)}}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":         bson.M{"workerName": "$workerName", "walletId": "$walletId"},
+			"shares":      bson.M{"$sum": 1},
+			"difficulty":  bson.M{"$sum": "$difficulty"},
+			"lastShareAt": bson.M{"$max": "$createdAt"},
+		}}},
+	}
+	cursor, err := getPoolShareCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pool shares"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var recent []struct {
+		ID struct {
+			WorkerName string `bson:"workerName"`
+			WalletID   string `bson:"walletId"`
+		} `bson:"_id"`
+		Shares      int64     `bson:"shares"`
+		Difficulty  int64     `bson:"difficulty"`
+		LastShareAt time.Time `bson:"lastShareAt"`
+	}
+	if err := cursor.All(ctx, &recent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse pool shares"})
+		return
+	}
+
+	workers := map[string]*models.PoolWorkerStats{}
+	for _, r := range recent {
+		lastShareAt := r.LastShareAt
+		workers[r.ID.WorkerName] = &models.PoolWorkerStats{
+			WorkerName:     r.ID.WorkerName,
+			WalletID:       r.ID.WalletID,
+			AcceptedShares: r.Shares,
+			Hashrate:       poolHashrate(r.Difficulty, poolStatsWindow),
+			LastShareAt:    &lastShareAt,
+		}
+	}
+
+	if pool != nil {
+		pool.mu.Lock()
+		conns := make([]*stratumConn, 0, len(pool.conns))
+		for conn := range pool.conns {
+			conns = append(conns, conn)
+		}
+		pool.mu.Unlock()
+
+		for _, conn := range conns {
+			conn.mu.Lock()
+			for name, worker := range conn.workers {
+				stats, ok := workers[name]
+				if !ok {
+					stats = &models.PoolWorkerStats{WorkerName: name, WalletID: worker.wallet.WalletID}
+					workers[name] = stats
+				}
+				stats.Online = true
+				stats.RejectedShares += worker.rejected
+			}
+			conn.mu.Unlock()
+		}
+	}
+
+	list := make([]models.PoolWorkerStats, 0, len(workers))
+	for _, worker := range workers {
+		list = append(list, *worker)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].WorkerName < list[j].WorkerName
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"workers": list,
+		"count":   len(list),
+	})
+}
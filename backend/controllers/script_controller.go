@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Outputs to ordinary addresses are locked with a pay-to-pubkey-hash script and
+// spent by the usual transfer endpoints. The handlers here lock funds to any
+// other script (escrow, hash locks, time locks) and spend such outputs by
+// supplying unlocking scripts, which are run through the script interpreter.
+
+// LockToScript pays an amount from the caller's wallet to an output locked by a
+// custom script. The output is listed under walletId (the caller by default),
+// or under the address a pay-to-pubkey-hash script pays.
+func LockToScript(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ScriptLockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found. Please generate a wallet first."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	script, err := crypto.ParseScriptAsm(req.LockingScript)
+	if err != nil || len(script) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locking script", "details": fmt.Sprint(err)})
+		return
+	}
+
+	label, labelPublicKey := wallet.WalletID, ""
+	if pubKeyHash, ok := crypto.ExtractPubKeyHash(script); ok {
+		label = crypto.EncodeAddress(crypto.ActiveAddressVersion(), pubKeyHash)
+		if _, owner, err := resolveAddress(ctx, label); err == nil {
+			label, labelPublicKey = owner.Address, owner.PublicKey
+		}
+	} else if req.WalletID != "" {
+		if err := crypto.ValidateAddress(req.WalletID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet address: " + err.Error()})
+			return
+		}
+		_, owner, err := resolveAddress(ctx, req.WalletID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+			return
+		}
+		label = owner.Address
+	}
+
+	tx, err := lockToScript(ctx, &wallet, script, label, labelPublicKey, req.Amount, req.Message)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction": tx,
+		"scriptClass": crypto.ClassifyScript(script),
+		"message":     "Funds locked to script",
+	})
+}
+
+// lockToScript sends amount from wallet to an output locked by script, signing
+// the inputs through the configured signer. Change returns to the wallet.
+func lockToScript(ctx context.Context, wallet *models.Wallet, script crypto.Script, walletID, publicKey string, amount models.Amount, message string) (*models.Transaction, error) {
+	utxos, totalInput, err := SelectUTXOsForAmount(wallet, amount)
+	if err != nil {
+		return nil, badRequest("Insufficient confirmed balance: %v", err)
+	}
+
+	outputs := []models.TransactionOutput{{
+		WalletID:      walletID,
+		Amount:        amount,
+		PublicKey:     publicKey,
+		LockingScript: script.Hex(),
+	}}
+	if change := totalInput - amount; change > 0 {
+		changeAddress, changePublicKey, err := changeDestination(ctx, wallet)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, models.TransactionOutput{
+			WalletID:      changeAddress,
+			Amount:        change,
+			PublicKey:     changePublicKey,
+			LockingScript: outputLockingScript(changeAddress),
+		})
+	}
+
+	inputs := make([]models.SignedInput, len(utxos))
+	for i, utxo := range utxos {
+		inputs[i] = models.SignedInput{
+			TransactionID: utxo.TransactionID,
+			OutputIndex:   utxo.OutputIndex,
+			Amount:        utxo.Amount,
+			PublicKey:     utxo.PublicKey,
+			SigHashType:   uint8(crypto.SigHashAll),
+			KeyType:       inputKeyType(utxo.PublicKey),
+		}
+	}
+
+	tx := &models.Transaction{
+		Type:         models.TxTypeTransfer,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		TotalInput:   totalInput,
+		TotalOutput:  totalInput,
+		Fee:          0,
+		SenderWallet: wallet.WalletID,
+		Status:       models.TxStatusPending,
+		Timestamp:    time.Now(),
+		Message:      message,
+	}
+
+	inputData, outputData := utxoSigHashData(utxos, outputs, tx.LockTime)
+	signatures, err := signUTXOInputs(ctx, wallet, utxos, inputData, outputData, crypto.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+	for i, utxo := range utxos {
+		tx.Inputs[i].Signature = signatures[i]
+		if tx.Inputs[i].UnlockingScript, err = unlockingScriptFor(utxo, signatures[i], crypto.SigHashAll); err != nil {
+			return nil, err
+		}
+	}
+
+	tx.TransactionID = crypto.GenerateTransactionID(tx)
+	if err := recordTransaction(ctx, tx, utxos); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// PreviewScriptSpend builds the transaction spending script-locked outputs and
+// returns the sighash of each input, for clients that sign unlocking scripts themselves
+func PreviewScriptSpend(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ScriptSpendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	tx, utxos, err := buildScriptSpend(ctx, &wallet, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	inputData, outputData := utxoSigHashData(utxos, tx.Outputs, tx.LockTime)
+	dataToSign := make([]string, len(utxos))
+	for i := range utxos {
+		if dataToSign[i], err = crypto.CalculateSigHash(inputData, outputData, i, crypto.SigHashAll); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactionId": tx.TransactionID,
+		"inputs":        tx.Inputs,
+		"outputs":       tx.Outputs,
+		"dataToSign":    dataToSign,
+		"timestamp":     tx.Timestamp.Unix(),
+		"message":       "Sign each input's sighash and append the sighash type byte (01) to use it in an unlocking script",
+	})
+}
+
+// SpendScriptOutputs spends script-locked outputs to a recipient. Each input's
+// unlocking script is run against the locking script of the output it spends.
+func SpendScriptOutputs(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ScriptSpendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var wallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+		return
+	}
+
+	tx, utxos, err := buildScriptSpend(ctx, &wallet, &req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	asm := make([]string, len(req.Inputs))
+	for i, input := range req.Inputs {
+		asm[i] = input.UnlockingScript
+	}
+	if err := unlockScriptSpend(ctx, &wallet, tx, utxos, asm); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := recordTransaction(ctx, tx, utxos); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction": tx,
+		"message":     "Transaction broadcast successfully!",
+	})
+}
+
+// buildScriptSpend validates the referenced outputs and builds the unsigned
+// transaction paying their total to the recipient
+func buildScriptSpend(ctx context.Context, wallet *models.Wallet, req *models.ScriptSpendRequest) (*models.Transaction, []models.UTXO, error) {
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		return nil, nil, badRequest("Invalid recipient address: %v", err)
+	}
+	_, recipient, err := resolveAddress(ctx, req.RecipientWalletID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, &apiError{status: http.StatusNotFound, message: "Recipient wallet not found"}
+		}
+		return nil, nil, err
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now().Unix()
+
+	utxos := make([]models.UTXO, len(req.Inputs))
+	inputs := make([]models.SignedInput, len(req.Inputs))
+	seen := map[string]bool{}
+	var totalInput models.Amount
+	for i, input := range req.Inputs {
+		outpoint := fmt.Sprintf("%s:%d", input.TransactionID, input.OutputIndex)
+		if seen[outpoint] {
+			return nil, nil, badRequest("Output %s is spent twice", outpoint)
+		}
+		seen[outpoint] = true
+
+		err := getUTXOCollection().FindOne(ctx, bson.M{
+			"transactionId": input.TransactionID,
+			"outputIndex":   input.OutputIndex,
+			"isSpent":       false,
+		}).Decode(&utxos[i])
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, nil, &apiError{status: http.StatusNotFound, message: "Unspent output not found: " + outpoint}
+			}
+			return nil, nil, err
+		}
+		if utxos[i].LockingScript == "" {
+			return nil, nil, badRequest("Output %s is not locked by a script", outpoint)
+		}
+		if !crypto.UTXOUnlocked(&utxos[i], height, now) {
+			return nil, nil, badRequest("Output %s is time-locked", outpoint)
+		}
+
+		inputs[i] = models.SignedInput{
+			TransactionID: utxos[i].TransactionID,
+			OutputIndex:   utxos[i].OutputIndex,
+			Amount:        utxos[i].Amount,
+			SigHashType:   uint8(crypto.SigHashAll),
+		}
+		totalInput += utxos[i].Amount
+	}
+
+	timestamp := time.Now()
+	if req.Timestamp != 0 {
+		timestamp = time.Unix(req.Timestamp, 0)
+	}
+
+	tx := &models.Transaction{
+		Type:   models.TxTypeTransfer,
+		Inputs: inputs,
+		Outputs: []models.TransactionOutput{{
+			WalletID:      recipient.Address,
+			Amount:        totalInput,
+			PublicKey:     recipient.PublicKey,
+			LockingScript: outputLockingScript(recipient.Address),
+		}},
+		TotalInput:   totalInput,
+		TotalOutput:  totalInput,
+		Fee:          0,
+		SenderWallet: wallet.WalletID,
+		Status:       models.TxStatusPending,
+		Timestamp:    timestamp,
+		Message:      req.Message,
+	}
+	tx.TransactionID = crypto.GenerateTransactionID(tx)
+	return tx, utxos, nil
+}
+
+// unlockScriptSpend assembles each input's unlocking script, filling in the SIG
+// and PUBKEY placeholders with the wallet's signature and public key, and runs it
+// against the locking script of the output the input spends
+func unlockScriptSpend(ctx context.Context, wallet *models.Wallet, tx *models.Transaction, utxos []models.UTXO, unlockingAsm []string) error {
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		return err
+	}
+
+	inputData, outputData := utxoSigHashData(utxos, tx.Outputs, tx.LockTime)
+	for i, asm := range unlockingAsm {
+		tokens := strings.Fields(asm)
+		for j, token := range tokens {
+			switch strings.ToUpper(token) {
+			case "SIG":
+				digest, err := crypto.SigHashDigest(inputData, outputData, i, crypto.SigHashAll)
+				if err != nil {
+					return err
+				}
+				signature, err := signer.Default().SignDigest(ctx, wallet.WalletID, masterKeyPath, digest)
+				if err != nil {
+					return err
+				}
+				scriptSignature, err := crypto.ScriptSignature(signature, crypto.SigHashAll)
+				if err != nil {
+					return err
+				}
+				tokens[j] = "0x" + hex.EncodeToString(scriptSignature)
+			case "PUBKEY":
+				tokens[j] = "0x" + wallet.PublicKey
+			}
+		}
+
+		script, err := crypto.ParseScriptAsm(strings.Join(tokens, " "))
+		if err != nil {
+			return badRequest("Invalid unlocking script for input %d: %v", i, err)
+		}
+		err = crypto.VerifyInputScript(script.Hex(), utxos[i].LockingScript, &crypto.ScriptContext{
+			Inputs:      inputData,
+			Outputs:     outputData,
+			InputIndex:  i,
+			BlockHeight: height,
+			BlockTime:   time.Now().Unix(),
+		})
+		if err != nil {
+			return badRequest("Script for input %d failed: %v", i, err)
+		}
+		tx.Inputs[i].UnlockingScript = script.Hex()
+	}
+	return nil
+}
+
+// DecodeScript converts a script given as assembly or hex into both forms and
+// reports its class
+func DecodeScript(c *gin.Context) {
+	var req models.DecodeScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	script, err := crypto.ParseScriptHex(req.Script)
+	if err != nil {
+		if script, err = crypto.ParseScriptAsm(req.Script); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid script", "details": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hex":   script.Hex(),
+		"asm":   script.String(),
+		"class": crypto.ClassifyScript(script),
+		"size":  len(script),
+	})
+}
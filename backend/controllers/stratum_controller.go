@@ -0,0 +1,628 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// The mining pool speaks a subset of Stratum v1: JSON-RPC messages, one per
+// line, over TCP. A miner calls mining.subscribe, then mining.authorize for
+// each worker, named after the wallet it is paid to ("walletId" or
+// "walletId.rig"). The pool answers with mining.set_difficulty and sends a
+// mining.notify whenever there is new work.
+//
+// Unlike Bitcoin's Stratum, the pool builds each connection's coinbase itself,
+// committing to the connection's extranonce1, so a job carries the finished
+// header instead of coinbase parts and a Merkle branch. The miner varies the
+// 8-byte nonce at the end of the header and calls mining.submit with
+// [workerName, jobId, nonce as 16 hex digits] for every hash that meets the
+// share target or the block's bits.
+//
+// A block found by the pool pays its reward to the wallets of the last
+// PoolPPLNSWindow shares (pay per last N shares), in proportion to their
+// difficulty, through one coinbase output each. The split is fixed when a job
+// is made, since the coinbase is part of the header being mined.
+
+const (
+	// stratumMaxLine bounds the length of a message from a miner
+	stratumMaxLine = 16 * 1024
+
+	// stratumIdleTimeout closes connections that send nothing for this long
+	stratumIdleTimeout = 10 * time.Minute
+
+	stratumWriteTimeout = 10 * time.Second
+
+	// stratumSendQueue is how many messages may wait for a slow miner before
+	// the pool drops the connection
+	stratumSendQueue = 64
+
+	// poolJobRefresh is how often the pool makes a new job on the same tip, to
+	// pick up new transactions and shares
+	poolJobRefresh = 30 * time.Second
+
+	// poolJobsKept is how many of a connection's latest jobs shares are
+	// accepted for, as long as they build on the chain tip
+	poolJobsKept = 4
+)
+
+// Stratum error codes
+const (
+	stratumErrOther         = 20
+	stratumErrStaleJob      = 21
+	stratumErrDuplicate     = 22
+	stratumErrLowDifficulty = 23
+	stratumErrUnauthorized  = 24
+	stratumErrNotSubscribed = 25
+)
+
+// stratumError is reported to the miner as [code, message, null]
+type stratumError struct {
+	code    int
+	message string
+}
+
+func (e *stratumError) Error() string {
+	return e.message
+}
+
+var errStaleShare = &stratumError{stratumErrStaleJob, "Job not found or stale"}
+
+var errSendQueueFull = errors.New("miner is not reading its messages")
+
+type stratumRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+type stratumNotification struct {
+	ID     interface{}   `json:"id"` // Always null
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// miningPool hands out work to the connected miners and collects their shares
+type miningPool struct {
+	shareDifficulty int64
+	shareTarget     *big.Int
+
+	// refreshMu serializes making jobs
+	refreshMu sync.Mutex
+
+	mu             sync.Mutex
+	job            *poolJob
+	jobSeq         uint64
+	conns          map[*stratumConn]struct{}
+	nextExtraNonce uint32
+}
+
+// pool is the running mining pool, nil unless StartMiningPool was called
+var pool *miningPool
+
+// poolJob is a block template handed out to every connection. The coinbase
+// pays outputs, or the connection's first worker while the PPLNS window is
+// empty.
+type poolJob struct {
+	id        string
+	seq       uint64
+	tpl       *blockTemplate
+	outputs   []models.TransactionOutput
+	timestamp time.Time
+	createdAt time.Time
+}
+
+// stratumConn is a connected miner
+type stratumConn struct {
+	pool       *miningPool
+	conn       net.Conn
+	extraNonce uint32
+
+	// send queues messages for writeLoop, so a slow miner never holds up the
+	// pool or a lock; done is closed when the connection is
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu         sync.Mutex
+	subscribed bool
+	working    bool
+	workers    map[string]*stratumWorker
+	payee      *models.Wallet // First authorized worker's wallet
+	jobs       map[string]*stratumJob
+	lastJobSeq uint64
+}
+
+// stratumWorker is a worker authorized on a connection
+type stratumWorker struct {
+	name     string
+	wallet   *models.Wallet
+	rejected int64
+}
+
+// stratumJob is a pool job as sent to one connection
+type stratumJob struct {
+	job      *poolJob
+	tpl      *blockTemplate
+	header   *models.BlockHeader
+	coinbase models.Transaction
+	nonces   map[uint64]bool
+}
+
+// StartMiningPool starts the mining pool's Stratum server on address. It runs
+// until the process exits.
+func StartMiningPool(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	difficulty := models.DefaultBlockchainConfig.PoolShareDifficulty
+	pool = &miningPool{
+		shareDifficulty: difficulty,
+		shareTarget:     crypto.ShareTarget(difficulty),
+		conns:           map[*stratumConn]struct{}{},
+		nextExtraNonce:  rand.Uint32(),
+	}
+	go pool.watchChain()
+	go pool.accept(listener)
+	return nil
+}
+
+func (p *miningPool) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("❌ [Pool] Stopped accepting connections: %v", err)
+			return
+		}
+
+		p.mu.Lock()
+		p.nextExtraNonce++
+		c := &stratumConn{
+			pool:       p,
+			conn:       conn,
+			extraNonce: p.nextExtraNonce,
+			workers:    map[string]*stratumWorker{},
+			jobs:       map[string]*stratumJob{},
+			send:       make(chan []byte, stratumSendQueue),
+			done:       make(chan struct{}),
+		}
+		p.conns[c] = struct{}{}
+		p.mu.Unlock()
+
+		go c.writeLoop()
+		go c.serve()
+	}
+}
+
+// watchChain makes a new job whenever the chain tip changes, and every
+// poolJobRefresh otherwise
+func (p *miningPool) watchChain() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		tipHash, err := chainTipHash(ctx)
+		cancel()
+		if err != nil {
+			continue
+		}
+
+		p.mu.Lock()
+		job := p.job
+		p.mu.Unlock()
+
+		switch {
+		case job == nil || job.tpl.parent.Hash != tipHash:
+			p.newJob(true)
+		case time.Since(job.createdAt) >= poolJobRefresh:
+			p.newJob(false)
+		}
+	}
+}
+
+// newJob makes a job on the chain tip and sends it to every miner. With clean
+// set, miners drop their earlier jobs.
+func (p *miningPool) newJob(clean bool) {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tip, err := loadChainTip(ctx)
+	if err != nil {
+		return
+	}
+	timestamp := time.Unix(time.Now().Unix(), 0)
+	transactions, err := selectBlockTransactions(ctx, tip.Index+1, timestamp)
+	if err != nil {
+		log.Printf("❌ [Pool] Failed to select transactions: %v", err)
+		return
+	}
+	tpl, err := newBlockTemplate(ctx, tip, transactions)
+	if err != nil {
+		log.Printf("❌ [Pool] Failed to build block template: %v", err)
+		return
+	}
+	_, outputs, err := pplnsSplit(ctx, tpl.reward)
+	if err != nil {
+		log.Printf("❌ [Pool] Failed to split reward: %v", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.jobSeq++
+	job := &poolJob{
+		id:        fmt.Sprintf("%x", p.jobSeq),
+		seq:       p.jobSeq,
+		tpl:       tpl,
+		outputs:   outputs,
+		timestamp: timestamp,
+		createdAt: time.Now(),
+	}
+	p.job = job
+	conns := make([]*stratumConn, 0, len(p.conns))
+	for c := range p.conns {
+		conns = append(conns, c)
+	}
+	p.mu.Unlock()
+
+	for _, c := range conns {
+		c.sendJob(job, clean)
+	}
+}
+
+// currentJob returns the job miners should be working on
+func (p *miningPool) currentJob() *poolJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.job
+}
+
+// serve answers the miner's requests until it disconnects
+func (c *stratumConn) serve() {
+	defer func() {
+		c.pool.mu.Lock()
+		delete(c.pool.conns, c)
+		c.pool.mu.Unlock()
+		c.close()
+	}()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 4096), stratumMaxLine)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+		if !scanner.Scan() {
+			return
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req stratumRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			return
+		}
+
+		result, err := c.handle(&req)
+		resp := stratumResponse{ID: req.ID, Result: result}
+		if err != nil {
+			resp.Result = nil
+			var stratumErr *stratumError
+			if !errors.As(err, &stratumErr) {
+				stratumErr = &stratumError{stratumErrOther, err.Error()}
+			}
+			resp.Error = []interface{}{stratumErr.code, stratumErr.message, nil}
+		}
+		if err := c.write(resp); err != nil {
+			return
+		}
+
+		// Work is sent once the miner has subscribed and authorized a worker,
+		// in either order
+		if err == nil && (req.Method == "mining.subscribe" || req.Method == "mining.authorize") {
+			c.startWork()
+		}
+	}
+}
+
+func (c *stratumConn) handle(req *stratumRequest) (interface{}, error) {
+	switch req.Method {
+	case "mining.subscribe":
+		return c.subscribe()
+	case "mining.authorize":
+		return c.authorize(req.Params)
+	case "mining.submit":
+		return c.submit(req.Params)
+	default:
+		return nil, &stratumError{stratumErrOther, "Unsupported method " + req.Method}
+	}
+}
+
+// subscribe returns the subscription details: the notifications the miner will
+// get, its extranonce1 and an extranonce2 size of 0, since the pool builds the
+// coinbase
+func (c *stratumConn) subscribe() (interface{}, error) {
+	c.mu.Lock()
+	c.subscribed = true
+	c.mu.Unlock()
+
+	id := fmt.Sprintf("%08x", c.extraNonce)
+	return []interface{}{
+		[][]string{{"mining.set_difficulty", id}, {"mining.notify", id}},
+		id,
+		0,
+	}, nil
+}
+
+// authorize adds a worker paid to the wallet its name starts with. The
+// password is ignored.
+func (c *stratumConn) authorize(params []json.RawMessage) (interface{}, error) {
+	var name string
+	if len(params) < 1 || json.Unmarshal(params[0], &name) != nil || name == "" {
+		return nil, &stratumError{stratumErrOther, "Expected [workerName, password]"}
+	}
+	walletID, _, _ := strings.Cut(name, ".")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wallet models.Wallet
+	if err := getWalletCollection().FindOne(ctx, bson.M{"walletId": walletID}).Decode(&wallet); err != nil {
+		return nil, &stratumError{stratumErrUnauthorized, "Unknown wallet " + walletID}
+	}
+
+	c.mu.Lock()
+	c.workers[name] = &stratumWorker{name: name, wallet: &wallet}
+	if c.payee == nil {
+		c.payee = &wallet
+	}
+	c.mu.Unlock()
+	return true, nil
+}
+
+// startWork sends the share difficulty and the current job to a miner that has
+// just become ready for work
+func (c *stratumConn) startWork() {
+	c.mu.Lock()
+	ready := c.subscribed && c.payee != nil && !c.working
+	c.working = c.working || ready
+	c.mu.Unlock()
+	if !ready {
+		return
+	}
+
+	c.notify("mining.set_difficulty", c.pool.shareDifficulty)
+	if job := c.pool.currentJob(); job != nil {
+		c.sendJob(job, true)
+	}
+}
+
+// sendJob builds the connection's header for job and notifies the miner. Jobs
+// older than the last one sent are ignored.
+func (c *stratumConn) sendJob(job *poolJob, clean bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.working || job.seq <= c.lastJobSeq {
+		return
+	}
+
+	tpl := job.tpl.payToWallet(c.payee)
+	if len(job.outputs) > 0 {
+		tpl = job.tpl.payTo(c.payee.WalletID, job.outputs)
+	}
+	header, coinbase := tpl.header(uint64(c.extraNonce), job.timestamp)
+	data, err := crypto.SerializeBlockHeader(header)
+	if err != nil {
+		return
+	}
+
+	if clean {
+		c.jobs = map[string]*stratumJob{}
+	}
+	for id, old := range c.jobs {
+		if old.job.seq+poolJobsKept <= job.seq {
+			delete(c.jobs, id)
+		}
+	}
+	c.jobs[job.id] = &stratumJob{
+		job:      job,
+		tpl:      tpl,
+		header:   header,
+		coinbase: coinbase,
+		nonces:   map[uint64]bool{},
+	}
+	c.lastJobSeq = job.seq
+
+	// Queued while holding mu so miners get jobs in order. Queuing never
+	// blocks, so a slow miner can't stall the pool here.
+	c.notify("mining.notify",
+		job.id,
+		header.PreviousHash,
+		hex.EncodeToString(data),
+		fmt.Sprintf("%08x", header.Version),
+		fmt.Sprintf("%08x", header.Bits),
+		fmt.Sprintf("%08x", job.timestamp.Unix()),
+		clean)
+}
+
+// submit checks a share and records it, saving the block when the share solves
+// it
+func (c *stratumConn) submit(params []json.RawMessage) (interface{}, error) {
+	var workerName, jobID, nonceHex string
+	if len(params) < 3 ||
+		json.Unmarshal(params[0], &workerName) != nil ||
+		json.Unmarshal(params[1], &jobID) != nil ||
+		json.Unmarshal(params[2], &nonceHex) != nil {
+		return nil, &stratumError{stratumErrOther, "Expected [workerName, jobId, nonce]"}
+	}
+
+	c.mu.Lock()
+	subscribed := c.subscribed
+	worker := c.workers[workerName]
+	sj := c.jobs[jobID]
+	c.mu.Unlock()
+
+	if !subscribed {
+		return nil, &stratumError{stratumErrNotSubscribed, "Not subscribed"}
+	}
+	if worker == nil {
+		return nil, &stratumError{stratumErrUnauthorized, "Unauthorized worker"}
+	}
+	share, err := c.checkShare(worker, sj, nonceHex)
+	if err != nil {
+		c.mu.Lock()
+		worker.rejected++
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := getPoolShareCollection().InsertOne(ctx, share); err != nil {
+		return nil, &stratumError{stratumErrOther, "Failed to record share"}
+	}
+	return true, nil
+}
+
+// checkShare validates a nonce for a job and returns the share it earns. A
+// share that solves the block saves it before being returned.
+func (c *stratumConn) checkShare(worker *stratumWorker, sj *stratumJob, nonceHex string) (*models.PoolShare, error) {
+	if sj == nil {
+		return nil, errStaleShare
+	}
+
+	// A share only counts while its job builds on the chain tip
+	current := c.pool.currentJob()
+	if current == nil || current.tpl.parent.Hash != sj.job.tpl.parent.Hash {
+		return nil, errStaleShare
+	}
+
+	nonceBytes, err := hex.DecodeString(nonceHex)
+	if err != nil || len(nonceBytes) != 8 {
+		return nil, &stratumError{stratumErrOther, "Nonce must be 16 hex digits"}
+	}
+	nonce := binary.BigEndian.Uint64(nonceBytes)
+
+	c.mu.Lock()
+	duplicate := sj.nonces[nonce]
+	sj.nonces[nonce] = true
+	c.mu.Unlock()
+	if duplicate {
+		return nil, &stratumError{stratumErrDuplicate, "Duplicate share"}
+	}
+
+	header := *sj.header
+	header.Nonce = int64(nonce)
+	header.Hash, err = crypto.HashBlock(&header)
+	if err != nil {
+		return nil, err
+	}
+	solvesBlock := crypto.CheckProofOfWork(&header) == nil
+	if !solvesBlock && !crypto.HashMeetsTarget(header.Hash, c.pool.shareTarget) {
+		return nil, &stratumError{stratumErrLowDifficulty, "Low difficulty share"}
+	}
+
+	share := &models.PoolShare{
+		WorkerName: worker.name,
+		WalletID:   worker.wallet.WalletID,
+		JobID:      sj.job.id,
+		BlockIndex: header.Index,
+		Difficulty: c.pool.shareDifficulty,
+		Hash:       header.Hash,
+		CreatedAt:  time.Now(),
+	}
+	if !solvesBlock {
+		return share, nil
+	}
+
+	// The block is credited to the worker that found it; the coinbase still
+	// pays the PPLNS split the job was made with
+	block, err := sj.tpl.payTo(worker.wallet.WalletID, sj.tpl.payouts).save(&header, sj.coinbase)
+	if err != nil {
+		if errors.Is(err, errNewTip) {
+			return nil, errStaleShare
+		}
+		return nil, &stratumError{stratumErrOther, "Block rejected: " + err.Error()}
+	}
+	log.Printf("⛏️ [Pool] Block %d found by %s: %s", block.Index, worker.name, block.Hash)
+	share.BlockHash = block.Hash
+	go c.pool.newJob(true)
+	return share, nil
+}
+
+// notify sends a notification to the miner
+func (c *stratumConn) notify(method string, params ...interface{}) {
+	c.write(stratumNotification{Method: method, Params: params})
+}
+
+// write queues a message for the miner without blocking. A miner whose queue
+// is full is disconnected rather than waited for.
+func (c *stratumConn) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case c.send <- append(data, '\n'):
+		return nil
+	case <-c.done:
+		return net.ErrClosed
+	default:
+		log.Printf("⛏️ [Pool] Dropping miner %s: %d messages unsent", c.conn.RemoteAddr(), stratumSendQueue)
+		c.close()
+		return errSendQueueFull
+	}
+}
+
+// writeLoop writes queued messages to the miner until the connection closes
+func (c *stratumConn) writeLoop() {
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+			if _, err := c.conn.Write(data); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close disconnects the miner, which ends serve and writeLoop
+func (c *stratumConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
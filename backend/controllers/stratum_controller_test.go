@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func testStratumConn(conn net.Conn) *stratumConn {
+	c := &stratumConn{
+		conn: conn,
+		send: make(chan []byte, stratumSendQueue),
+		done: make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+func TestStratumWritesInOrder(t *testing.T) {
+	server, miner := net.Pipe()
+	defer miner.Close()
+	c := testStratumConn(server)
+	defer c.close()
+
+	for i := 0; i < 3; i++ {
+		if err := c.write(stratumNotification{Method: "mining.notify", Params: []interface{}{i}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := bufio.NewScanner(miner)
+	for i := 0; i < 3; i++ {
+		if !scanner.Scan() {
+			t.Fatalf("message %d: %v", i, scanner.Err())
+		}
+		var notification struct {
+			Params []int `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+			t.Fatal(err)
+		}
+		if len(notification.Params) != 1 || notification.Params[0] != i {
+			t.Fatalf("message %d has params %v", i, notification.Params)
+		}
+	}
+}
+
+func TestStratumDropsSlowMiner(t *testing.T) {
+	// Nothing reads from the miner's end, so every write blocks
+	server, miner := net.Pipe()
+	defer miner.Close()
+	c := testStratumConn(server)
+
+	var err error
+	for i := 0; i <= stratumSendQueue+1 && err == nil; i++ {
+		err = c.write(stratumNotification{Method: "mining.notify"})
+	}
+	if err != errSendQueueFull {
+		t.Fatalf("err = %v, want %v", err, errSendQueueFull)
+	}
+
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection was not closed")
+	}
+	if err := c.write(stratumNotification{Method: "mining.notify"}); err == nil {
+		t.Fatal("queued a message on a closed connection")
+	}
+}
@@ -19,6 +19,16 @@ func getTransactionCollection() *mongo.Collection {
 	return database.GetCollection("transactions")
 }
 
+// utxoSelectionOrder sorts UTXOs largest first with a stable tie-break, so that
+// previewing and broadcasting the same payment select the same inputs
+func utxoSelectionOrder() *options.FindOptions {
+	return options.Find().SetSort(bson.D{
+		{Key: "amount", Value: -1},
+		{Key: "transactionId", Value: 1},
+		{Key: "outputIndex", Value: 1},
+	})
+}
+
 // CreateTransaction creates a new unsigned transaction (preview)
 func CreateTransaction(c *gin.Context) {
 	userID := c.GetString("userId")
@@ -29,6 +39,17 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
+	hashType, err := crypto.ParseSigHashType(req.SigHashType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := crypto.ValidateTimeLocks(req.LockTime, req.LockUntil, req.RelativeLock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -50,9 +71,14 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
-	// Validate recipient wallet exists
-	var recipientWallet models.Wallet
-	err = getWalletCollection().FindOne(ctx, bson.M{"walletId": req.RecipientWalletID}).Decode(&recipientWallet)
+	// Catch typos offline before looking the address up
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+		return
+	}
+
+	// Validate recipient address exists (a primary wallet ID or a derived address)
+	recipientWallet, recipient, err := resolveAddress(ctx, req.RecipientWalletID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient wallet not found"})
@@ -61,18 +87,29 @@ func CreateTransaction(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	recipientPublicKey := recipient.PublicKey
+
+	// Use the address as stored so legacy and encoded forms share the same UTXOs
+	req.RecipientWalletID = recipient.Address
 
 	// Cannot send to yourself
-	if senderWallet.WalletID == req.RecipientWalletID {
+	if recipientWallet.ID == senderWallet.ID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send to your own wallet"})
 		return
 	}
 
-	// Get sender's UTXOs (unspent only)
-	cursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId": senderWallet.WalletID,
-		"isSpent":  false,
-	}, options.Find().SetSort(bson.M{"amount": -1})) // Sort by amount descending
+	// Get sender's UTXOs (unspent and unlocked) across all of the wallet's addresses
+	addresses, err := walletAddressIDs(ctx, &senderWallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	cursor, err := getUTXOCollection().Find(ctx, spendableUTXOFilter(addresses, height), utxoSelectionOrder()) // Sort by amount descending
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch UTXOs"})
 		return
@@ -86,7 +123,7 @@ func CreateTransaction(c *gin.Context) {
 	}
 
 	// Calculate total available balance
-	var totalAvailable float64
+	var totalAvailable models.Amount
 	for _, utxo := range utxos {
 		totalAvailable += utxo.Amount
 	}
@@ -102,7 +139,7 @@ func CreateTransaction(c *gin.Context) {
 
 	// Select UTXOs for transaction (greedy algorithm)
 	var selectedUTXOs []models.UTXO
-	var totalInput float64
+	var totalInput models.Amount
 	for _, utxo := range utxos {
 		selectedUTXOs = append(selectedUTXOs, utxo)
 		totalInput += utxo.Amount
@@ -113,7 +150,24 @@ func CreateTransaction(c *gin.Context) {
 
 	// Calculate change
 	change := totalInput - req.Amount
-	fee := 0.0 // For now, no transaction fees
+	fee := models.Amount(0) // For now, no transaction fees
+
+	// Change goes to a fresh internal address
+	var changeAddress, changePublicKey string
+	if change > 0 {
+		changeAddress, changePublicKey, err = changeDestination(ctx, &senderWallet)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to derive change address"})
+			return
+		}
+	}
+
+	// Tell the client which key signs each input
+	keyPaths, err := utxoKeyPaths(ctx, &senderWallet, selectedUTXOs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve input keys"})
+		return
+	}
 
 	// Build transaction inputs
 	var inputs []models.SignedInput
@@ -123,13 +177,16 @@ func CreateTransaction(c *gin.Context) {
 			TransactionID: utxo.TransactionID,
 			OutputIndex:   utxo.OutputIndex,
 			Amount:        utxo.Amount,
-			PublicKey:     senderWallet.PublicKey,
+			PublicKey:     utxo.PublicKey,
 			Signature:     "", // Will be filled after signing
+			KeyType:       inputKeyType(utxo.PublicKey),
 		})
 		inputDataForHash = append(inputDataForHash, crypto.InputData{
 			TransactionID: utxo.TransactionID,
 			OutputIndex:   utxo.OutputIndex,
 			Amount:        utxo.Amount,
+			LockingScript: utxo.LockingScript,
+			LockTime:      req.LockTime,
 		})
 	}
 
@@ -139,38 +196,57 @@ func CreateTransaction(c *gin.Context) {
 
 	// Output to recipient
 	outputs = append(outputs, models.TransactionOutput{
-		WalletID:  req.RecipientWalletID,
-		Amount:    req.Amount,
-		PublicKey: recipientWallet.PublicKey,
-	})
-	outputDataForHash = append(outputDataForHash, crypto.OutputData{
-		WalletID: req.RecipientWalletID,
-		Amount:   req.Amount,
+		WalletID:      req.RecipientWalletID,
+		Amount:        req.Amount,
+		PublicKey:     recipientPublicKey,
+		LockingScript: outputLockingScript(req.RecipientWalletID),
+		LockUntil:     req.LockUntil,
+		RelativeLock:  req.RelativeLock,
 	})
 
 	// Change output (if any)
 	if change > 0 {
 		outputs = append(outputs, models.TransactionOutput{
-			WalletID:  senderWallet.WalletID,
-			Amount:    change,
-			PublicKey: senderWallet.PublicKey,
+			WalletID:      changeAddress,
+			Amount:        change,
+			PublicKey:     changePublicKey,
+			LockingScript: outputLockingScript(changeAddress),
 		})
+	}
+
+	for _, output := range outputs {
 		outputDataForHash = append(outputDataForHash, crypto.OutputData{
-			WalletID: senderWallet.WalletID,
-			Amount:   change,
+			WalletID:      output.WalletID,
+			Amount:        output.Amount,
+			LockingScript: output.LockingScript,
+			LockUntil:     output.LockUntil,
+			RelativeLock:  output.RelativeLock,
 		})
 	}
 
 	// Generate transaction ID
 	timestamp := time.Now()
-	txID := crypto.GenerateTransactionID(senderWallet.WalletID, inputDataForHash, outputDataForHash, timestamp.Unix())
+	txID := crypto.GenerateTransactionID(&models.Transaction{
+		Type:         models.TxTypeTransfer,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		SenderWallet: senderWallet.WalletID,
+		Timestamp:    timestamp,
+		Message:      req.Message,
+		LockTime:     req.LockTime,
+	})
 
-	// Generate data to sign for each input
+	// Generate the sighash each input signature must cover
 	var dataToSign []string
-	for i, input := range inputs {
-		signData := crypto.CreateInputSignatureData(txID, input.TransactionID, input.OutputIndex, input.Amount)
-		dataToSign = append(dataToSign, signData)
+	for i := range inputs {
+		sigHash, err := crypto.CalculateSigHash(inputDataForHash, outputDataForHash, i, hashType)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dataToSign = append(dataToSign, sigHash)
 		inputs[i].Signature = "" // Placeholder for signature
+		inputs[i].SigHashType = uint8(hashType)
 	}
 
 	// Create transaction preview
@@ -182,27 +258,35 @@ func CreateTransaction(c *gin.Context) {
 		TotalOutput:       req.Amount + change,
 		Fee:               fee,
 		DataToSign:        dataToSign,
+		KeyPaths:          keyPaths,
 		RecipientWalletID: req.RecipientWalletID,
 		Amount:            req.Amount,
 		Change:            change,
+		ChangeAddress:     changeAddress,
+		Timestamp:         timestamp.Unix(),
+		LockTime:          req.LockTime,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"preview": preview,
-		"message": "Transaction created. Please sign each input with your private key.",
+		"message": "Transaction created. Please sign each input's sighash with your private key.",
 	})
 }
 
-// SignAndBroadcastTransaction signs and broadcasts a transaction
+// SignAndBroadcastTransaction verifies client-side input signatures and broadcasts a transaction.
+// Each signature is checked against the sighash of the inputs and outputs rebuilt here.
 func SignAndBroadcastTransaction(c *gin.Context) {
 	userID := c.GetString("userId")
 
 	var req struct {
-		TransactionID     string                    `json:"transactionId" binding:"required"`
-		RecipientWalletID string                    `json:"recipientWalletId" binding:"required"`
-		Amount            float64                   `json:"amount" binding:"required"`
-		Signatures        []models.InputSignature   `json:"signatures" binding:"required"`
-		Message           string                    `json:"message"`
+		TransactionID       string                  `json:"transactionId" binding:"required"`
+		RecipientWalletID   string                  `json:"recipientWalletId" binding:"required"`
+		Amount              models.Amount           `json:"amount" binding:"required,gt=0"`
+		Signatures          []models.InputSignature `json:"signatures" binding:"required"`
+		Message             string                  `json:"message"`
+		ChangeAddress       string                  `json:"changeAddress"`                // From the transaction preview
+		Timestamp           int64                   `json:"timestamp" binding:"required"` // From the transaction preview
+		models.PaymentLocks                         // As given when creating the preview
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -210,6 +294,11 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 		return
 	}
 
+	if err := crypto.ValidateTimeLocks(req.LockTime, req.LockUntil, req.RelativeLock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -228,18 +317,46 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 	}
 
 	// Get recipient's wallet
-	var recipientWallet models.Wallet
-	err = getWalletCollection().FindOne(ctx, bson.M{"walletId": req.RecipientWalletID}).Decode(&recipientWallet)
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+		return
+	}
+	recipientWallet, recipient, err := resolveAddress(ctx, req.RecipientWalletID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipient wallet not found"})
 		return
 	}
+	recipientPublicKey := recipient.PublicKey
+	req.RecipientWalletID = recipient.Address
+	if recipientWallet.ID == senderWallet.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send to your own wallet"})
+		return
+	}
 
-	// Get sender's UTXOs
-	cursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId": senderWallet.WalletID,
-		"isSpent":  false,
-	}, options.Find().SetSort(bson.M{"amount": -1}))
+	// Change must go to the address given in the preview, which has to belong to the sender
+	changeAddress, changePublicKey := senderWallet.WalletID, senderWallet.PublicKey
+	if req.ChangeAddress != "" {
+		changeWallet, change, err := resolveAddress(ctx, req.ChangeAddress)
+		if err != nil || changeWallet.ID != senderWallet.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Change address does not belong to your wallet"})
+			return
+		}
+		changeAddress, changePublicKey = change.Address, change.PublicKey
+	}
+
+	// Get sender's UTXOs across all of the wallet's addresses. Time locks and
+	// locking scripts are checked against the next block.
+	addresses, err := walletAddressIDs(ctx, &senderWallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	cursor, err := getUTXOCollection().Find(ctx, spendableUTXOFilter(addresses, height), utxoSelectionOrder())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch UTXOs"})
 		return
@@ -254,7 +371,7 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 
 	// Select UTXOs for transaction
 	var selectedUTXOs []models.UTXO
-	var totalInput float64
+	var totalInput models.Amount
 	for _, utxo := range utxos {
 		selectedUTXOs = append(selectedUTXOs, utxo)
 		totalInput += utxo.Amount
@@ -287,13 +404,17 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 
 	// Add output data for hash
 	outputDataForHash = append(outputDataForHash, crypto.OutputData{
-		WalletID: req.RecipientWalletID,
-		Amount:   req.Amount,
+		WalletID:      req.RecipientWalletID,
+		Amount:        req.Amount,
+		LockingScript: outputLockingScript(req.RecipientWalletID),
+		LockUntil:     req.LockUntil,
+		RelativeLock:  req.RelativeLock,
 	})
 	if change > 0 {
 		outputDataForHash = append(outputDataForHash, crypto.OutputData{
-			WalletID: senderWallet.WalletID,
-			Amount:   change,
+			WalletID:      changeAddress,
+			Amount:        change,
+			LockingScript: outputLockingScript(changeAddress),
 		})
 	}
 
@@ -302,21 +423,28 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 			TransactionID: utxo.TransactionID,
 			OutputIndex:   utxo.OutputIndex,
 			Amount:        utxo.Amount,
+			LockingScript: utxo.LockingScript,
+			LockTime:      req.LockTime,
 		})
 	}
 
-	// Use the provided transaction ID
-	txID := req.TransactionID
+	if err := checkOutputAmounts(totalInput, outputDataForHash); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	// Verify each signature
+	// Verify each signature against the sighash of the inputs and outputs built above,
+	// so a signature can't be replayed against a different recipient or amount
 	for i, utxo := range selectedUTXOs {
-		signData := crypto.CreateInputSignatureData(txID, utxo.TransactionID, utxo.OutputIndex, utxo.Amount)
-		
 		// Find the signature for this input
 		var signature string
+		var sigHashByte uint8
+		var sigKeyType models.KeyType
 		for _, sig := range req.Signatures {
 			if sig.InputIndex == i {
 				signature = sig.Signature
+				sigHashByte = sig.SigHashType
+				sigKeyType = sig.KeyType
 				break
 			}
 		}
@@ -326,115 +454,108 @@ func SignAndBroadcastTransaction(c *gin.Context) {
 			return
 		}
 
-		// Verify the signature
-		valid, err := crypto.VerifySignature(senderWallet.PublicKey, signData, signature)
+		hashType, err := crypto.ParseSigHashType(sigHashByte)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to verify signature", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "inputIndex": i})
 			return
 		}
-		if !valid {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature", "inputIndex": i})
+
+		// A client that names its key type must have signed with the UTXO's key
+		keyType := inputKeyType(utxo.PublicKey)
+		if sigKeyType != "" && sigKeyType != keyType {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Signature key type does not match the input's key", "inputIndex": i})
 			return
 		}
 
+		unlockingScript, err := unlockingScriptFor(utxo, signature, hashType)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature encoding", "inputIndex": i})
+			return
+		}
+
+		if utxo.LockingScript != "" {
+			// Run the output's locking script against the signature and key
+			err := crypto.VerifyInputScript(unlockingScript, utxo.LockingScript, &crypto.ScriptContext{
+				Inputs:      inputDataForHash,
+				Outputs:     outputDataForHash,
+				InputIndex:  i,
+				BlockHeight: height,
+				BlockTime:   time.Now().Unix(),
+			})
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature", "inputIndex": i, "details": err.Error()})
+				return
+			}
+		} else {
+			// Verify the signature against the key the UTXO is locked to
+			valid, err := crypto.VerifyInputSignature(utxo.PublicKey, inputDataForHash, outputDataForHash, i, hashType, signature)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to verify signature", "details": err.Error()})
+				return
+			}
+			if !valid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature", "inputIndex": i})
+				return
+			}
+		}
+
 		inputs = append(inputs, models.SignedInput{
-			TransactionID: utxo.TransactionID,
-			OutputIndex:   utxo.OutputIndex,
-			Amount:        utxo.Amount,
-			PublicKey:     senderWallet.PublicKey,
-			Signature:     signature,
+			TransactionID:   utxo.TransactionID,
+			OutputIndex:     utxo.OutputIndex,
+			Amount:          utxo.Amount,
+			PublicKey:       utxo.PublicKey,
+			Signature:       signature,
+			SigHashType:     uint8(hashType),
+			KeyType:         keyType,
+			UnlockingScript: unlockingScript,
 		})
 	}
 
 	// Build outputs
 	var outputs []models.TransactionOutput
 	outputs = append(outputs, models.TransactionOutput{
-		WalletID:  req.RecipientWalletID,
-		Amount:    req.Amount,
-		PublicKey: recipientWallet.PublicKey,
+		WalletID:      req.RecipientWalletID,
+		Amount:        req.Amount,
+		PublicKey:     recipientPublicKey,
+		LockingScript: outputLockingScript(req.RecipientWalletID),
+		LockUntil:     req.LockUntil,
+		RelativeLock:  req.RelativeLock,
 	})
 	if change > 0 {
 		outputs = append(outputs, models.TransactionOutput{
-			WalletID:  senderWallet.WalletID,
-			Amount:    change,
-			PublicKey: senderWallet.PublicKey,
+			WalletID:      changeAddress,
+			Amount:        change,
+			PublicKey:     changePublicKey,
+			LockingScript: outputLockingScript(changeAddress),
 		})
 	}
 
 	// Create the transaction
-	timestamp := time.Now()
 	transaction := models.Transaction{
-		TransactionID: txID,
-		Type:          models.TxTypeTransfer,
-		Inputs:        inputs,
-		Outputs:       outputs,
-		TotalInput:    totalInput,
-		TotalOutput:   req.Amount + change,
-		Fee:           0,
-		SenderWallet:  senderWallet.WalletID,
-		Status:        models.TxStatusPending,
-		Timestamp:     timestamp,
-		Message:       req.Message,
-	}
-
-	// Start a session for atomic operations
-	session, err := database.GetClient().StartSession()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start database session"})
-		return
-	}
-	defer session.EndSession(ctx)
-
-	// Execute transaction atomically
-	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Mark input UTXOs as spent
-		now := time.Now()
-		for _, utxo := range selectedUTXOs {
-			_, err := getUTXOCollection().UpdateOne(sessCtx, bson.M{
-				"transactionId": utxo.TransactionID,
-				"outputIndex":   utxo.OutputIndex,
-				"isSpent":       false,
-			}, bson.M{
-				"$set": bson.M{
-					"isSpent":   true,
-					"spentInTx": txID,
-					"spentAt":   now,
-				},
-			})
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Create new UTXOs for outputs
-		for i, output := range outputs {
-			newUTXO := models.UTXO{
-				TransactionID: txID,
-				OutputIndex:   i,
-				WalletID:      output.WalletID,
-				Amount:        output.Amount,
-				PublicKey:     output.PublicKey,
-				IsSpent:       false,
-				IsConfirmed:   false, // Will be confirmed when included in a block
-				CreatedAt:     now,
-			}
-			_, err := getUTXOCollection().InsertOne(sessCtx, newUTXO)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Save the transaction
-		_, err := getTransactionCollection().InsertOne(sessCtx, transaction)
-		if err != nil {
-			return nil, err
-		}
+		Type:         models.TxTypeTransfer,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		TotalInput:   totalInput,
+		TotalOutput:  req.Amount + change,
+		Fee:          0,
+		SenderWallet: senderWallet.WalletID,
+		Status:       models.TxStatusPending,
+		Timestamp:    time.Unix(req.Timestamp, 0),
+		Message:      req.Message,
+		LockTime:     req.LockTime,
+	}
 
-		return nil, nil
-	})
+	// The transaction ID is derived from the contents, so it must match the preview
+	txID := crypto.GenerateTransactionID(&transaction)
+	if txID != req.TransactionID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction ID does not match transaction contents"})
+		return
+	}
+	transaction.TransactionID = txID
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process transaction", "details": err.Error()})
+	// Spend the inputs and record the transaction atomically
+	if err := recordTransaction(ctx, &transaction, selectedUTXOs); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -469,11 +590,21 @@ func GetMyTransactions(c *gin.Context) {
 		return
 	}
 
-	// Find transactions where user is sender or receiver
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	ownAddress := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		ownAddress[address] = true
+	}
+
+	// Find transactions where user is sender or receiver on any of their addresses
 	cursor, err := getTransactionCollection().Find(ctx, bson.M{
 		"$or": []bson.M{
 			{"senderWallet": wallet.WalletID},
-			{"outputs.walletId": wallet.WalletID},
+			{"outputs.walletId": bson.M{"$in": addresses}},
 		},
 	}, options.Find().SetSort(bson.M{"timestamp": -1}))
 	if err != nil {
@@ -505,7 +636,7 @@ func GetMyTransactions(c *gin.Context) {
 			item.Direction = "sent"
 			// Find the recipient (first output that's not change)
 			for _, output := range tx.Outputs {
-				if output.WalletID != wallet.WalletID {
+				if !ownAddress[output.WalletID] {
 					item.Counterparty = output.WalletID
 					item.Amount = output.Amount
 					break
@@ -517,9 +648,8 @@ func GetMyTransactions(c *gin.Context) {
 			item.Counterparty = tx.SenderWallet
 			// Find how much user received
 			for _, output := range tx.Outputs {
-				if output.WalletID == wallet.WalletID {
-					item.Amount = output.Amount
-					break
+				if ownAddress[output.WalletID] {
+					item.Amount += output.Amount
 				}
 			}
 		}
@@ -581,9 +711,10 @@ func SendTransaction(c *gin.Context) {
 	userID := c.GetString("userId")
 
 	var req struct {
-		RecipientWalletID string  `json:"recipientWalletId" binding:"required"`
-		Amount            float64 `json:"amount" binding:"required,gt=0"`
-		Message           string  `json:"message"`
+		RecipientWalletID string        `json:"recipientWalletId" binding:"required"`
+		Amount            models.Amount `json:"amount" binding:"required,gt=0"`
+		Message           string        `json:"message"`
+		models.PaymentLocks
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -591,6 +722,11 @@ func SendTransaction(c *gin.Context) {
 		return
 	}
 
+	if err := crypto.ValidateTimeLocks(req.LockTime, req.LockUntil, req.RelativeLock); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -612,9 +748,14 @@ func SendTransaction(c *gin.Context) {
 		return
 	}
 
-	// Validate recipient wallet exists
-	var recipientWallet models.Wallet
-	err = getWalletCollection().FindOne(ctx, bson.M{"walletId": req.RecipientWalletID}).Decode(&recipientWallet)
+	// Catch typos offline before looking the address up
+	if err := crypto.ValidateAddress(req.RecipientWalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+		return
+	}
+
+	// Validate recipient address exists (a primary wallet ID or a derived address)
+	recipientWallet, recipient, err := resolveAddress(ctx, req.RecipientWalletID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient wallet not found"})
@@ -623,18 +764,29 @@ func SendTransaction(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	recipientPublicKey := recipient.PublicKey
+
+	// Use the address as stored so legacy and encoded forms share the same UTXOs
+	req.RecipientWalletID = recipient.Address
 
 	// Cannot send to yourself
-	if senderWallet.WalletID == req.RecipientWalletID {
+	if recipientWallet.ID == senderWallet.ID {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot send to your own wallet"})
 		return
 	}
 
-	// Get sender's UTXOs (unspent only)
-	cursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId": senderWallet.WalletID,
-		"isSpent":  false,
-	}, options.Find().SetSort(bson.M{"amount": -1}))
+	// Get sender's UTXOs (unspent and unlocked) across all of the wallet's addresses
+	addresses, err := walletAddressIDs(ctx, &senderWallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	cursor, err := getUTXOCollection().Find(ctx, spendableUTXOFilter(addresses, height), utxoSelectionOrder())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch UTXOs"})
 		return
@@ -648,7 +800,7 @@ func SendTransaction(c *gin.Context) {
 	}
 
 	// Calculate total available balance
-	var totalAvailable float64
+	var totalAvailable models.Amount
 	for _, utxo := range utxos {
 		totalAvailable += utxo.Amount
 	}
@@ -664,7 +816,7 @@ func SendTransaction(c *gin.Context) {
 
 	// Select UTXOs for transaction (greedy algorithm)
 	var selectedUTXOs []models.UTXO
-	var totalInput float64
+	var totalInput models.Amount
 	for _, utxo := range utxos {
 		selectedUTXOs = append(selectedUTXOs, utxo)
 		totalInput += utxo.Amount
@@ -675,14 +827,17 @@ func SendTransaction(c *gin.Context) {
 
 	change := totalInput - req.Amount
 
-	// Decrypt sender's private key for signing
-	privateKeyHex, err := crypto.DecryptPrivateKey(senderWallet.PrivateKey)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt private key"})
-		return
+	// Change goes to a fresh internal address
+	var changeAddress, changePublicKey string
+	if change > 0 {
+		changeAddress, changePublicKey, err = changeDestination(ctx, &senderWallet)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to derive change address"})
+			return
+		}
 	}
 
-	// Build inputs and outputs for transaction ID generation
+	// Build inputs and outputs for signing
 	var inputDataForHash []crypto.InputData
 	var outputDataForHash []crypto.OutputData
 
@@ -691,131 +846,99 @@ func SendTransaction(c *gin.Context) {
 			TransactionID: utxo.TransactionID,
 			OutputIndex:   utxo.OutputIndex,
 			Amount:        utxo.Amount,
+			LockingScript: utxo.LockingScript,
+			LockTime:      req.LockTime,
 		})
 	}
 
 	outputDataForHash = append(outputDataForHash, crypto.OutputData{
-		WalletID: req.RecipientWalletID,
-		Amount:   req.Amount,
+		WalletID:      req.RecipientWalletID,
+		Amount:        req.Amount,
+		LockingScript: outputLockingScript(req.RecipientWalletID),
+		LockUntil:     req.LockUntil,
+		RelativeLock:  req.RelativeLock,
 	})
 	if change > 0 {
 		outputDataForHash = append(outputDataForHash, crypto.OutputData{
-			WalletID: senderWallet.WalletID,
-			Amount:   change,
+			WalletID:      changeAddress,
+			Amount:        change,
+			LockingScript: outputLockingScript(changeAddress),
 		})
 	}
 
-	// Generate transaction ID
-	timestamp := time.Now()
-	txID := crypto.GenerateTransactionID(senderWallet.WalletID, inputDataForHash, outputDataForHash, timestamp.Unix())
+	if err := checkOutputAmounts(totalInput, outputDataForHash); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	// Build and sign inputs
+	// Sign each input with the key that controls it
+	signatures, err := signUTXOInputs(ctx, &senderWallet, selectedUTXOs, inputDataForHash, outputDataForHash, crypto.SigHashAll)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign transaction"})
+		return
+	}
+
+	// Build inputs
 	var inputs []models.SignedInput
-	for _, utxo := range selectedUTXOs {
-		signData := crypto.CreateInputSignatureData(txID, utxo.TransactionID, utxo.OutputIndex, utxo.Amount)
-		signature, err := crypto.SignData(privateKeyHex, signData)
+	for i, utxo := range selectedUTXOs {
+		unlockingScript, err := unlockingScriptFor(utxo, signatures[i], crypto.SigHashAll)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign transaction"})
 			return
 		}
-
 		inputs = append(inputs, models.SignedInput{
-			TransactionID: utxo.TransactionID,
-			OutputIndex:   utxo.OutputIndex,
-			Amount:        utxo.Amount,
-			PublicKey:     senderWallet.PublicKey,
-			Signature:     signature,
+			TransactionID:   utxo.TransactionID,
+			OutputIndex:     utxo.OutputIndex,
+			Amount:          utxo.Amount,
+			PublicKey:       utxo.PublicKey,
+			Signature:       signatures[i],
+			SigHashType:     uint8(crypto.SigHashAll),
+			KeyType:         inputKeyType(utxo.PublicKey),
+			UnlockingScript: unlockingScript,
 		})
 	}
 
 	// Build outputs
 	var outputs []models.TransactionOutput
 	outputs = append(outputs, models.TransactionOutput{
-		WalletID:  req.RecipientWalletID,
-		Amount:    req.Amount,
-		PublicKey: recipientWallet.PublicKey,
+		WalletID:      req.RecipientWalletID,
+		Amount:        req.Amount,
+		PublicKey:     recipientPublicKey,
+		LockingScript: outputLockingScript(req.RecipientWalletID),
+		LockUntil:     req.LockUntil,
+		RelativeLock:  req.RelativeLock,
 	})
 	if change > 0 {
 		outputs = append(outputs, models.TransactionOutput{
-			WalletID:  senderWallet.WalletID,
-			Amount:    change,
-			PublicKey: senderWallet.PublicKey,
+			WalletID:      changeAddress,
+			Amount:        change,
+			PublicKey:     changePublicKey,
+			LockingScript: outputLockingScript(changeAddress),
 		})
 	}
 
 	// Create the transaction
 	transaction := models.Transaction{
-		TransactionID: txID,
-		Type:          models.TxTypeTransfer,
-		Inputs:        inputs,
-		Outputs:       outputs,
-		TotalInput:    totalInput,
-		TotalOutput:   req.Amount + change,
-		Fee:           0,
-		SenderWallet:  senderWallet.WalletID,
-		Status:        models.TxStatusPending,
-		Timestamp:     timestamp,
-		Message:       req.Message,
-	}
-
-	// Start a session for atomic operations
-	session, err := database.GetClient().StartSession()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start database session"})
-		return
-	}
-	defer session.EndSession(ctx)
-
-	// Execute transaction atomically
-	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Mark input UTXOs as spent
-		now := time.Now()
-		for _, utxo := range selectedUTXOs {
-			_, err := getUTXOCollection().UpdateOne(sessCtx, bson.M{
-				"transactionId": utxo.TransactionID,
-				"outputIndex":   utxo.OutputIndex,
-				"isSpent":       false,
-			}, bson.M{
-				"$set": bson.M{
-					"isSpent":   true,
-					"spentInTx": txID,
-					"spentAt":   now,
-				},
-			})
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Create new UTXOs for outputs
-		for i, output := range outputs {
-			newUTXO := models.UTXO{
-				TransactionID: txID,
-				OutputIndex:   i,
-				WalletID:      output.WalletID,
-				Amount:        output.Amount,
-				PublicKey:     output.PublicKey,
-				IsSpent:       false,
-				IsConfirmed:   false,
-				CreatedAt:     now,
-			}
-			_, err := getUTXOCollection().InsertOne(sessCtx, newUTXO)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Save the transaction
-		_, err := getTransactionCollection().InsertOne(sessCtx, transaction)
-		if err != nil {
-			return nil, err
-		}
+		Type:         models.TxTypeTransfer,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		TotalInput:   totalInput,
+		TotalOutput:  req.Amount + change,
+		Fee:          0,
+		SenderWallet: senderWallet.WalletID,
+		Status:       models.TxStatusPending,
+		Timestamp:    time.Now(),
+		Message:      req.Message,
+		LockTime:     req.LockTime,
+	}
 
-		return nil, nil
-	})
+	// Generate transaction ID
+	txID := crypto.GenerateTransactionID(&transaction)
+	transaction.TransactionID = txID
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process transaction", "details": err.Error()})
+	// Spend the inputs and record the transaction atomically
+	if err := recordTransaction(ctx, &transaction, selectedUTXOs); err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -824,3 +947,29 @@ func SendTransaction(c *gin.Context) {
 		"message":     "Transaction sent successfully!",
 	})
 }
+
+// checkOutputAmounts rejects outputs that aren't positive or that add up to
+// more than totalInput
+func checkOutputAmounts(totalInput models.Amount, outputs []crypto.OutputData) error {
+	var totalOutput models.Amount
+	for i, output := range outputs {
+		if output.Amount <= 0 {
+			return badRequest("Output %d has a non-positive amount", i)
+		}
+		totalOutput += output.Amount
+	}
+	if totalOutput > totalInput {
+		return badRequest("Outputs total %s, more than the %s the inputs hold", totalOutput, totalInput)
+	}
+	return nil
+}
+
+// inputKeyType returns the key type of the key an input is signed with, or an
+// empty type if the public key isn't recognised
+func inputKeyType(publicKeyHex string) models.KeyType {
+	keyType, err := crypto.KeyTypeOf(publicKeyHex)
+	if err != nil {
+		return ""
+	}
+	return keyType
+}
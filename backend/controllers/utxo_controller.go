@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"crypto-wallet-backend/crypto"
 	"crypto-wallet-backend/database"
 	"crypto-wallet-backend/models"
 	"fmt"
@@ -27,9 +28,9 @@ func GetBalance(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Validate wallet exists
+	// Validate wallet exists (the ID may be given in legacy hex or Base58Check form)
 	var wallet models.Wallet
-	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": walletID}).Decode(&wallet)
+	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(walletID)}}).Decode(&wallet)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid Wallet ID"})
@@ -40,6 +41,7 @@ func GetBalance(c *gin.Context) {
 	}
 
 	// Get all unspent UTXOs for this wallet
+	walletID = wallet.WalletID
 	cursor, err := getUTXOCollection().Find(ctx, bson.M{
 		"walletId": walletID,
 		"isSpent":  false,
@@ -57,7 +59,7 @@ func GetBalance(c *gin.Context) {
 	}
 
 	// Calculate balances
-	var totalBalance, confirmedBalance, pendingBalance float64
+	var totalBalance, confirmedBalance, pendingBalance models.Amount
 	for _, utxo := range utxos {
 		totalBalance += utxo.Amount
 		if utxo.IsConfirmed {
@@ -103,9 +105,15 @@ func GetMyBalance(c *gin.Context) {
 		return
 	}
 
-	// Get all unspent UTXOs for this wallet
+	// Get all unspent UTXOs across the wallet's addresses
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+
 	cursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId": wallet.WalletID,
+		"walletId": bson.M{"$in": addresses},
 		"isSpent":  false,
 	})
 	if err != nil {
@@ -121,7 +129,7 @@ func GetMyBalance(c *gin.Context) {
 	}
 
 	// Calculate balances
-	var totalBalance, confirmedBalance, pendingBalance float64
+	var totalBalance, confirmedBalance, pendingBalance models.Amount
 	for _, utxo := range utxos {
 		totalBalance += utxo.Amount
 		if utxo.IsConfirmed {
@@ -158,7 +166,7 @@ func GetUTXOs(c *gin.Context) {
 	defer cancel()
 
 	// Build filter
-	filter := bson.M{"walletId": walletID}
+	filter := bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(walletID)}}
 	if !includeSpent {
 		filter["isSpent"] = false
 	}
@@ -183,7 +191,7 @@ func GetUTXOs(c *gin.Context) {
 	}
 
 	// Calculate total
-	var total float64
+	var total models.Amount
 	for _, utxo := range utxos {
 		if !utxo.IsSpent {
 			total += utxo.Amount
@@ -223,8 +231,14 @@ func GetMyUTXOs(c *gin.Context) {
 		return
 	}
 
-	// Build filter
-	filter := bson.M{"walletId": wallet.WalletID}
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+
+	// Build filter across all of the wallet's addresses
+	filter := bson.M{"walletId": bson.M{"$in": addresses}}
 	if !includeSpent {
 		filter["isSpent"] = false
 	}
@@ -248,14 +262,29 @@ func GetMyUTXOs(c *gin.Context) {
 		utxos = []models.UTXO{}
 	}
 
+	// Time locks are checked against the next block
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
+	}
+	now := time.Now().Unix()
+
 	// Calculate totals
-	var totalBalance, confirmedBalance float64
-	for _, utxo := range utxos {
+	var totalBalance, confirmedBalance, spendableBalance, lockedBalance models.Amount
+	for i := range utxos {
+		utxo := &utxos[i]
 		if !utxo.IsSpent {
 			totalBalance += utxo.Amount
 			if utxo.IsConfirmed {
 				confirmedBalance += utxo.Amount
 			}
+			if crypto.UTXOUnlocked(utxo, height, now) {
+				spendableBalance += utxo.Amount
+			} else {
+				utxo.Locked = true
+				lockedBalance += utxo.Amount
+			}
 		}
 	}
 
@@ -264,6 +293,9 @@ func GetMyUTXOs(c *gin.Context) {
 		"count":            len(utxos),
 		"totalBalance":     totalBalance,
 		"confirmedBalance": confirmedBalance,
+		"spendableBalance": spendableBalance,
+		"lockedBalance":    lockedBalance,
+		"nextBlockHeight":  height,
 	})
 }
 
@@ -286,7 +318,7 @@ func CreateCoinbaseUTXO(c *gin.Context) {
 
 	// Validate wallet exists
 	var wallet models.Wallet
-	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": req.WalletID}).Decode(&wallet)
+	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(req.WalletID)}}).Decode(&wallet)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid Wallet ID"})
@@ -304,9 +336,11 @@ func CreateCoinbaseUTXO(c *gin.Context) {
 		ID:            primitive.NewObjectID(),
 		TransactionID: txID,
 		OutputIndex:   0,
-		WalletID:      req.WalletID,
+		WalletID:      wallet.WalletID,
 		Amount:        req.Amount,
 		PublicKey:     wallet.PublicKey,
+		LockingScript: outputLockingScript(wallet.WalletID),
+		ScriptClass:   string(crypto.ScriptClassPubKeyHash),
 		IsSpent:       false,
 		IsConfirmed:   true, // Coinbase UTXOs are immediately confirmed
 		CreatedAt:     time.Now(),
@@ -335,19 +369,55 @@ func CreateCoinbaseUTXO(c *gin.Context) {
 	})
 }
 
+// lockReachedFilter matches documents whose lock value in field is unset or
+// allows inclusion in the block at height mined at now
+func lockReachedFilter(field string, height int64, now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{field: bson.M{"$in": []interface{}{nil, 0}}},
+		{field: bson.M{"$lt": crypto.LockTimeThreshold, "$lte": height}},
+		{field: bson.M{"$gte": crypto.LockTimeThreshold, "$lte": now.Unix()}},
+	}}
+}
+
+// unlockedUTXOFilter matches UTXOs whose time locks allow spending them in the
+// block at height. It mirrors crypto.UTXOUnlocked.
+func unlockedUTXOFilter(height int64, now time.Time) bson.M {
+	return bson.M{"$and": []bson.M{
+		lockReachedFilter("lockUntil", height, now),
+		{"$or": []bson.M{
+			{"relativeLock": bson.M{"$in": []interface{}{nil, 0}}},
+			{
+				"isConfirmed": true,
+				"$expr": bson.M{"$lte": bson.A{
+					bson.M{"$add": bson.A{"$blockHeight", "$relativeLock"}},
+					height,
+				}},
+			},
+		}},
+	}}
+}
+
 // SelectUTXOsForAmount selects optimal UTXOs to cover a specific amount
+// from any of the wallet's addresses, skipping time-locked ones.
 // Uses a greedy algorithm to minimize the number of inputs
-func SelectUTXOsForAmount(walletID string, amount float64) ([]models.UTXO, float64, error) {
+func SelectUTXOsForAmount(wallet *models.Wallet, amount models.Amount) ([]models.UTXO, models.Amount, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	addresses, err := walletAddressIDs(ctx, wallet)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	// Get all unspent, confirmed UTXOs for the wallet, sorted by amount descending
-	opts := options.Find().SetSort(bson.D{{Key: "amount", Value: -1}})
-	cursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId":    walletID,
-		"isSpent":     false,
-		"isConfirmed": true,
-	}, opts)
+	filter := spendableUTXOFilter(addresses, height)
+	filter["isConfirmed"] = true
+	cursor, err := getUTXOCollection().Find(ctx, filter, utxoSelectionOrder())
 	if err != nil {
 		return nil, 0, err
 	}
@@ -360,7 +430,7 @@ func SelectUTXOsForAmount(walletID string, amount float64) ([]models.UTXO, float
 
 	// Greedy selection
 	var selectedUTXOs []models.UTXO
-	var totalSelected float64
+	var totalSelected models.Amount
 
 	for _, utxo := range allUTXOs {
 		if totalSelected >= amount {
@@ -371,7 +441,7 @@ func SelectUTXOsForAmount(walletID string, amount float64) ([]models.UTXO, float
 	}
 
 	if totalSelected < amount {
-		return nil, totalSelected, fmt.Errorf("insufficient balance: have %.8f, need %.8f", totalSelected, amount)
+		return nil, totalSelected, fmt.Errorf("insufficient balance: have %s, need %s", totalSelected, amount)
 	}
 
 	return selectedUTXOs, totalSelected, nil
@@ -445,17 +515,17 @@ func GetUTXOStats(c *gin.Context) {
 	}
 	defer cursor.Close(ctx)
 
-	var result []bson.M
+	var result []struct {
+		Total models.Amount `bson:"total"`
+	}
 	if err := cursor.All(ctx, &result); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse result"})
 		return
 	}
 
-	var totalValue float64
+	var totalValue models.Amount
 	if len(result) > 0 {
-		if val, ok := result[0]["total"].(float64); ok {
-			totalValue = val
-		}
+		totalValue = result[0].Total
 	}
 
 	c.JSON(http.StatusOK, gin.H{
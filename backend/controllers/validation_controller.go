@@ -0,0 +1,540 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The chain is validated by replaying it: main chain blocks are read in order
+// from a cursor and each is checked against the UTXO set the blocks before it
+// leave, which is rebuilt in memory from the genesis block. Only outputs the
+// faucet creates outside any block are taken from the database. Spent outputs
+// leave the set but keep a small record of what spent them, which is needed to
+// report double spends and to check the stored outputs at the end, so memory
+// still grows with every output the chain has created. At the end the rebuilt
+// set and the spent records are compared with the stored outputs.
+
+// maxValidationErrors caps how many errors a validation report lists
+const maxValidationErrors = 100
+
+// outpoint identifies a transaction output
+type outpoint struct {
+	txID  string
+	index int
+}
+
+// replayedOutput is an output in the UTXO set rebuilt by a chain replay
+type replayedOutput struct {
+	utxo   models.UTXO
+	stored bool // Found in the stored UTXO set
+}
+
+// spentOutput is what a chain replay keeps of an output once it is spent
+type spentOutput struct {
+	spentIn     string // Transaction that spent it
+	blockHeight int64  // Block that created it, zero for faucet outputs
+	blockHash   string
+	stored      bool // Found in the stored UTXO set
+}
+
+// chainReplay validates main chain blocks in order, keeping the UTXO set they
+// leave behind
+type chainReplay struct {
+	utxos  map[outpoint]*replayedOutput
+	spent  map[outpoint]*spentOutput
+	prev   *models.Block
+	work   *big.Int
+	result models.ChainValidation
+}
+
+// ValidateBlockchain replays the main chain from the genesis block, checking
+// every block's links, hash, proof of work, Merkle root and coinbase, and every
+// transaction's signatures and the outputs it spends
+func ValidateBlockchain(c *gin.Context) {
+	// Replaying takes longer as the chain grows, so it runs for as long as the
+	// client waits rather than under a fixed timeout
+	ctx := c.Request.Context()
+
+	replay, err := newChainReplay(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load faucet outputs"})
+		return
+	}
+
+	cursor, err := getBlockCollection().Find(ctx, mainChain(bson.M{}), options.Find().SetSort(bson.M{"index": 1}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var block models.Block
+		if err := cursor.Decode(&block); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse blocks"})
+			return
+		}
+		if err := replay.checkBlock(ctx, &block); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate block", "details": err.Error()})
+			return
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocks"})
+		return
+	}
+
+	if err := replay.checkStoredUTXOs(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare UTXO set", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"validation": replay.report()})
+}
+
+// newChainReplay starts a replay from an empty chain, with the faucet's
+// outputs, which no block creates, already in the UTXO set
+func newChainReplay(ctx context.Context) (*chainReplay, error) {
+	r := newEmptyChainReplay()
+
+	cursor, err := getUTXOCollection().Find(ctx, bson.M{"transactionId": bson.M{"$regex": "^coinbase_"}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var utxo models.UTXO
+		if err := cursor.Decode(&utxo); err != nil {
+			return nil, err
+		}
+		utxo.IsSpent = false
+		utxo.SpentInTx = ""
+		r.utxos[outpoint{utxo.TransactionID, utxo.OutputIndex}] = &replayedOutput{utxo: utxo}
+	}
+	return r, cursor.Err()
+}
+
+// newEmptyChainReplay starts a replay with an empty UTXO set
+func newEmptyChainReplay() *chainReplay {
+	return &chainReplay{
+		utxos: map[outpoint]*replayedOutput{},
+		spent: map[outpoint]*spentOutput{},
+		work:  new(big.Int),
+	}
+}
+
+// fail records an error in block, or in one of its transactions when txID is set
+func (r *chainReplay) fail(block *models.Block, txID string, format string, args ...interface{}) {
+	r.result.ErrorCount++
+	if len(r.result.Errors) < maxValidationErrors {
+		r.result.Errors = append(r.result.Errors, models.ChainValidationError{
+			BlockIndex:    block.Index,
+			BlockHash:     block.Hash,
+			TransactionID: txID,
+			Message:       fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+// report returns the outcome of the replay
+func (r *chainReplay) report() models.ChainValidation {
+	result := r.result
+	for _, out := range r.utxos {
+		result.UTXOCount++
+		result.TotalSupply += out.utxo.Amount
+	}
+	result.IsValid = result.ErrorCount == 0
+	return result
+}
+
+// checkBlock validates the next main chain block and applies it to the UTXO
+// set. Rule violations are recorded; the error is only for failed queries.
+func (r *chainReplay) checkBlock(ctx context.Context, block *models.Block) error {
+	r.result.BlocksChecked++
+	header := block.Header()
+
+	if r.prev == nil {
+		if block.Index != 0 {
+			r.fail(block, "", "Chain starts at block %d instead of the genesis block", block.Index)
+		} else if block.Hash != crypto.GetGenesisBlockHash() {
+			r.fail(block, "", "Genesis block hash is %s, expected %s", block.Hash, crypto.GetGenesisBlockHash())
+		}
+		if len(block.Transactions) > 0 || block.Coinbase != nil {
+			r.fail(block, "", "Genesis block has transactions")
+		}
+	} else {
+		r.checkHeader(ctx, block, &header)
+	}
+
+	work, err := crypto.HeaderWork(&header)
+	if err != nil {
+		r.fail(block, "", "Invalid target: %v", err)
+	} else {
+		r.work.Add(r.work, work)
+	}
+	if block.ChainWork != "" && block.ChainWork != crypto.FormatChainWork(r.work) {
+		r.fail(block, "", "Chain work is %s, expected %s", block.ChainWork, crypto.FormatChainWork(r.work))
+	}
+
+	if crypto.CommitsToCoinbase(&header) && block.Coinbase == nil {
+		r.fail(block, "", "Block has no coinbase")
+	}
+	if root := crypto.CalculateMerkleRoot(crypto.BlockMerkleLeaves(block)); root != block.MerkleRoot {
+		r.fail(block, "", "Merkle root is %s, but the block's transactions give %s", block.MerkleRoot, root)
+	}
+	if block.TransactionCount != len(block.Transactions) {
+		r.fail(block, "", "Transaction count is %d, but the block holds %d", block.TransactionCount, len(block.Transactions))
+	}
+	if len(block.Transactions) > models.DefaultBlockchainConfig.MaxTransactionsPerBlock {
+		r.fail(block, "", "Block holds %d transactions, more than the limit of %d",
+			len(block.Transactions), models.DefaultBlockchainConfig.MaxTransactionsPerBlock)
+	}
+
+	var fees models.Amount
+	for i := range block.Transactions {
+		fees += r.checkTransaction(block, &header, &block.Transactions[i])
+	}
+	if err := r.checkCoinbase(ctx, block, &header, fees); err != nil {
+		return err
+	}
+
+	r.prev = block
+	return nil
+}
+
+// checkHeader checks a block's header against the block before it
+func (r *chainReplay) checkHeader(ctx context.Context, block *models.Block, header *models.BlockHeader) {
+	if block.Index != r.prev.Index+1 {
+		r.fail(block, "", "Block follows block %d", r.prev.Index)
+	}
+	if block.PreviousHash != r.prev.Hash {
+		r.fail(block, "", "Previous hash is %s, but block %d's hash is %s", block.PreviousHash, r.prev.Index, r.prev.Hash)
+	}
+	if block.Timestamp.Unix() < r.prev.Timestamp.Unix() {
+		r.fail(block, "", "Timestamp is before the previous block's")
+	}
+	if block.Timestamp.After(time.Now().Add(maxFutureBlockTime)) {
+		r.fail(block, "", "Timestamp is too far in the future")
+	}
+
+	hash, err := crypto.HashBlock(header)
+	if err != nil {
+		r.fail(block, "", "Header can't be hashed: %v", err)
+	} else if hash != block.Hash {
+		r.fail(block, "", "Hash is %s, but the header hashes to %s", block.Hash, hash)
+	}
+	if err := crypto.CheckProofOfWork(header); err != nil {
+		r.fail(block, "", "Invalid proof of work: %v", err)
+	}
+
+	// Bits are only set by the retargeting rule once both blocks use them
+	prevHeader := r.prev.Header()
+	if crypto.HasCompactTarget(header) && crypto.HasCompactTarget(&prevHeader) {
+		bits, err := nextBlockBits(ctx, r.prev)
+		if err != nil {
+			r.fail(block, "", "Expected bits can't be computed: %v", err)
+		} else if block.Bits != bits {
+			r.fail(block, "", "Bits are %08x, expected %08x", block.Bits, bits)
+		}
+	}
+}
+
+// checkTransaction validates a transaction of block against the UTXO set and
+// applies it, returning its fee. Invalid transactions are applied too, so one
+// bad transaction isn't reported again through everything spending from it.
+func (r *chainReplay) checkTransaction(block *models.Block, header *models.BlockHeader, tx *models.Transaction) models.Amount {
+	r.result.TransactionsChecked++
+	fail := func(format string, args ...interface{}) {
+		r.fail(block, tx.TransactionID, format, args...)
+	}
+
+	if tx.Type == models.TxTypeCoinbase {
+		fail("Coinbase transaction listed among the block's transactions")
+	}
+	if len(tx.Inputs) == 0 {
+		fail("Transaction has no inputs")
+	}
+	if !crypto.IsFinalTransaction(tx, block.Index, block.Timestamp.Unix()) {
+		fail("Lock time %d isn't reached at this block", tx.LockTime)
+	}
+	legacy := crypto.IsLegacyHeader(header)
+	if !legacy {
+		if id := crypto.GenerateTransactionID(tx); id != tx.TransactionID {
+			fail("Transaction ID doesn't match its contents, which hash to %s", id)
+		}
+	}
+
+	// Look up the outputs the inputs spend
+	spent := make([]*replayedOutput, 0, len(tx.Inputs))
+	var totalInput models.Amount
+	for i, input := range tx.Inputs {
+		key := outpoint{input.TransactionID, input.OutputIndex}
+		out, ok := r.utxos[key]
+		if !ok {
+			if prior, ok := r.spent[key]; ok {
+				fail("Input %d spends %s:%d, already spent by %s", i, input.TransactionID, input.OutputIndex, prior.spentIn)
+			} else {
+				fail("Input %d spends %s:%d, which doesn't exist", i, input.TransactionID, input.OutputIndex)
+			}
+			continue
+		}
+		r.spend(key, out, tx.TransactionID)
+
+		switch {
+		case input.Amount != out.utxo.Amount:
+			fail("Input %d claims %s, but %s:%d holds %s", i, input.Amount, input.TransactionID, input.OutputIndex, out.utxo.Amount)
+		case !crypto.UTXOUnlocked(&out.utxo, block.Index, block.Timestamp.Unix()):
+			fail("Input %d spends %s:%d before its time lock", i, input.TransactionID, input.OutputIndex)
+		}
+		spent = append(spent, out)
+		totalInput += out.utxo.Amount
+	}
+	complete := len(spent) == len(tx.Inputs)
+
+	if complete && !legacy {
+		utxos := make([]models.UTXO, len(spent))
+		for i, out := range spent {
+			utxos[i] = out.utxo
+		}
+		inputData, outputData := utxoSigHashData(utxos, tx.Outputs, tx.LockTime)
+		for i := range tx.Inputs {
+			if err := verifyReplayedInput(block, tx, i, &utxos[i], inputData, outputData); err != nil {
+				fail("Input %d: %v", i, err)
+			}
+		}
+	}
+
+	var totalOutput models.Amount
+	for i, output := range tx.Outputs {
+		if output.Amount <= 0 {
+			fail("Output %d has a non-positive amount of %s", i, output.Amount)
+		}
+		totalOutput += output.Amount
+	}
+
+	var fee models.Amount
+	if complete {
+		if totalOutput > totalInput {
+			fail("Outputs total %s, more than the %s its inputs hold", totalOutput, totalInput)
+		} else {
+			fee = totalInput - totalOutput
+		}
+		if tx.Fee != fee {
+			fail("Fee is recorded as %s, but inputs minus outputs is %s", tx.Fee, fee)
+		}
+	}
+
+	r.addOutputs(block, tx)
+	return fee
+}
+
+// spend moves an output from the UTXO set to the spent outputs. The record is
+// kept for the rest of the replay, so every spent output stays in memory.
+func (r *chainReplay) spend(key outpoint, out *replayedOutput, txID string) {
+	delete(r.utxos, key)
+	r.spent[key] = &spentOutput{
+		spentIn:     txID,
+		blockHeight: out.utxo.BlockHeight,
+		blockHash:   out.utxo.BlockHash,
+	}
+}
+
+// verifyReplayedInput checks input i of tx, spending utxo, the way it was
+// checked when broadcast: against the multisig policy, the locking script, or
+// for bare outputs the key the output is locked to
+func verifyReplayedInput(block *models.Block, tx *models.Transaction, i int, utxo *models.UTXO, inputData []crypto.InputData, outputData []crypto.OutputData) error {
+	input := tx.Inputs[i]
+	if input.RedeemPolicy != "" {
+		return crypto.VerifyMultisigInput(utxo.WalletID, input.RedeemPolicy, input.Cosignatures, inputData, outputData, i, crypto.SigHashAll)
+	}
+	if utxo.LockingScript != "" {
+		return crypto.VerifyInputScript(input.UnlockingScript, utxo.LockingScript, &crypto.ScriptContext{
+			Inputs:      inputData,
+			Outputs:     outputData,
+			InputIndex:  i,
+			BlockHeight: block.Index,
+			BlockTime:   block.Timestamp.Unix(),
+		})
+	}
+
+	hashType, err := crypto.ParseSigHashType(input.SigHashType)
+	if err != nil {
+		return err
+	}
+	valid, err := crypto.VerifyInputSignature(utxo.PublicKey, inputData, outputData, i, hashType, input.Signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// checkCoinbase validates block's coinbase, which must pay the block reward
+// plus fees, and adds its outputs to the UTXO set
+func (r *chainReplay) checkCoinbase(ctx context.Context, block *models.Block, header *models.BlockHeader, fees models.Amount) error {
+	// The genesis block pays no reward
+	if block.Index == 0 {
+		return nil
+	}
+
+	coinbase := block.Coinbase
+	if coinbase == nil {
+		if crypto.CommitsToCoinbase(header) {
+			return nil
+		}
+
+		// Blocks from before coinbases were stored with their block only have a
+		// transaction record pointing back at them
+		var recorded models.Transaction
+		err := getTransactionCollection().FindOne(ctx, bson.M{
+			"blockHash": block.Hash,
+			"type":      models.TxTypeCoinbase,
+		}).Decode(&recorded)
+		if err == mongo.ErrNoDocuments {
+			r.fail(block, "", "Block has no coinbase transaction")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		coinbase = &recorded
+	} else {
+		if id := crypto.GenerateTransactionID(coinbase); id != coinbase.TransactionID {
+			r.fail(block, coinbase.TransactionID, "Coinbase ID doesn't match its contents, which hash to %s", id)
+		}
+		heightData := crypto.CoinbaseData(block.Index, 0)[:16]
+		if len(coinbase.CoinbaseData) < 16 || coinbase.CoinbaseData[:16] != heightData {
+			r.fail(block, coinbase.TransactionID, "Coinbase data doesn't commit to the block height")
+		}
+	}
+
+	if coinbase.Type != models.TxTypeCoinbase {
+		r.fail(block, coinbase.TransactionID, "Coinbase has type %q", coinbase.Type)
+	}
+	if len(coinbase.Inputs) > 0 {
+		r.fail(block, coinbase.TransactionID, "Coinbase has inputs")
+	}
+
+	var total models.Amount
+	for i, output := range coinbase.Outputs {
+		if output.Amount <= 0 {
+			r.fail(block, coinbase.TransactionID, "Coinbase output %d has a non-positive amount of %s", i, output.Amount)
+		}
+		total += output.Amount
+	}
+	if reward := models.DefaultBlockchainConfig.BlockReward + fees; total != reward {
+		r.fail(block, coinbase.TransactionID, "Coinbase pays %s, expected the %s block reward plus %s in fees",
+			total, models.DefaultBlockchainConfig.BlockReward, fees)
+	}
+
+	r.addOutputs(block, coinbase)
+	return nil
+}
+
+// addOutputs adds the outputs of tx, confirmed in block, to the UTXO set
+func (r *chainReplay) addOutputs(block *models.Block, tx *models.Transaction) {
+	for i, output := range tx.Outputs {
+		key := outpoint{tx.TransactionID, i}
+		if _, exists := r.utxos[key]; exists || r.spent[key] != nil {
+			r.fail(block, tx.TransactionID, "Output %d already exists", i)
+			continue
+		}
+
+		utxo := newOutputUTXO(tx.TransactionID, i, output, block.Timestamp)
+		utxo.IsConfirmed = true
+		utxo.BlockHash = block.Hash
+		utxo.BlockHeight = block.Index
+		r.utxos[key] = &replayedOutput{utxo: utxo}
+	}
+}
+
+// checkStoredUTXOs compares the stored outputs confirmed in blocks with the
+// rebuilt UTXO set. Stored outputs may be spent by pending transactions, but
+// outputs the chain spends must be stored as spent by the same transaction.
+func (r *chainReplay) checkStoredUTXOs(ctx context.Context) error {
+	cursor, err := getUTXOCollection().Find(ctx, bson.M{"isConfirmed": true})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var stored models.UTXO
+		if err := cursor.Decode(&stored); err != nil {
+			return err
+		}
+		block := &models.Block{Index: stored.BlockHeight, Hash: stored.BlockHash}
+		key := outpoint{stored.TransactionID, stored.OutputIndex}
+
+		if spent, ok := r.spent[key]; ok {
+			spent.stored = true
+			if stored.SpentInTx != spent.spentIn {
+				r.fail(block, stored.TransactionID, "Stored output %d isn't marked spent by %s, which spends it on the chain",
+					stored.OutputIndex, spent.spentIn)
+			}
+			continue
+		}
+
+		out, ok := r.utxos[key]
+		if !ok {
+			if stored.BlockHash != "" {
+				r.fail(block, stored.TransactionID, "Stored output %d isn't created by any main chain block", stored.OutputIndex)
+			}
+			continue
+		}
+		out.stored = true
+
+		if stored.Amount != out.utxo.Amount || stored.WalletID != out.utxo.WalletID {
+			r.fail(block, stored.TransactionID, "Stored output %d pays %s to %s, but the chain pays %s to %s",
+				stored.OutputIndex, stored.Amount, stored.WalletID, out.utxo.Amount, out.utxo.WalletID)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	type missingOutput struct {
+		outpoint
+		block *models.Block
+	}
+	var missing []missingOutput
+	for key, out := range r.utxos {
+		if !out.stored {
+			missing = append(missing, missingOutput{key, &models.Block{Index: out.utxo.BlockHeight, Hash: out.utxo.BlockHash}})
+		}
+	}
+	for key, out := range r.spent {
+		if !out.stored {
+			missing = append(missing, missingOutput{key, &models.Block{Index: out.blockHeight, Hash: out.blockHash}})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		a, b := missing[i], missing[j]
+		if a.block.Index != b.block.Index {
+			return a.block.Index < b.block.Index
+		}
+		if a.txID != b.txID {
+			return a.txID < b.txID
+		}
+		return a.index < b.index
+	})
+	for _, out := range missing {
+		r.fail(out.block, out.txID, "Output %d is missing from the stored UTXO set", out.index)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChain builds main chain blocks for a replay. Blocks after the genesis
+// block commit to their coinbase, so the replay never falls back to the database.
+type testChain struct {
+	t      *testing.T
+	miner  *crypto.KeyPair
+	blocks []*models.Block
+}
+
+func newTestChain(t *testing.T) *testChain {
+	t.Helper()
+	masterKey, err := crypto.GenerateMasterKey(models.KeyTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	miner, err := masterKey.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := &models.Block{
+		Index:        0,
+		Hash:         crypto.GetGenesisBlockHash(),
+		PreviousHash: "0",
+		Timestamp:    time.Unix(1700000000, 0),
+		MerkleRoot:   crypto.CalculateMerkleRoot(nil),
+		Difficulty:   1,
+	}
+	return &testChain{t: t, miner: miner, blocks: []*models.Block{genesis}}
+}
+
+// addBlock mines a block holding txs on the tip, with a coinbase paying the
+// block reward and their fees to the miner
+func (tc *testChain) addBlock(txs ...models.Transaction) *models.Block {
+	tc.t.Helper()
+	prev := tc.blocks[len(tc.blocks)-1]
+	height := prev.Index + 1
+
+	reward := models.DefaultBlockchainConfig.BlockReward
+	for _, tx := range txs {
+		reward += tx.Fee
+	}
+	coinbase := &models.Transaction{
+		Type:         models.TxTypeCoinbase,
+		Outputs:      []models.TransactionOutput{{WalletID: "miner", Amount: reward, PublicKey: tc.miner.PublicKeyHex}},
+		TotalOutput:  reward,
+		CoinbaseData: crypto.CoinbaseData(height, 0),
+	}
+	coinbase.TransactionID = crypto.GenerateTransactionID(coinbase)
+
+	block := &models.Block{
+		Version:          4,
+		Index:            height,
+		PreviousHash:     prev.Hash,
+		Timestamp:        prev.Timestamp.Add(10 * time.Minute),
+		Transactions:     txs,
+		Coinbase:         coinbase,
+		TransactionCount: len(txs),
+		Bits:             crypto.PowLimitBits,
+	}
+	block.MerkleRoot = crypto.CalculateMerkleRoot(crypto.BlockMerkleLeaves(block))
+	for {
+		header := block.Header()
+		hash, err := crypto.HashBlock(&header)
+		if err != nil {
+			tc.t.Fatal(err)
+		}
+		header.Hash = hash
+		if crypto.CheckProofOfWork(&header) == nil {
+			block.Hash = hash
+			break
+		}
+		block.Nonce++
+	}
+
+	tc.blocks = append(tc.blocks, block)
+	return block
+}
+
+// spend returns a transaction signed by the miner, spending the coinbase
+// outputs of blocks into one output of amount to recipient
+func (tc *testChain) spend(recipient string, amount models.Amount, blocks ...*models.Block) models.Transaction {
+	tc.t.Helper()
+	tx := models.Transaction{
+		Type:    models.TxTypeTransfer,
+		Outputs: []models.TransactionOutput{{WalletID: recipient, Amount: amount}},
+	}
+	utxos := make([]models.UTXO, len(blocks))
+	for i, block := range blocks {
+		output := block.Coinbase.Outputs[0]
+		utxos[i] = newOutputUTXO(block.Coinbase.TransactionID, 0, output, block.Timestamp)
+		tx.Inputs = append(tx.Inputs, models.SignedInput{
+			TransactionID: block.Coinbase.TransactionID,
+			Amount:        output.Amount,
+			PublicKey:     tc.miner.PublicKeyHex,
+			SigHashType:   uint8(crypto.SigHashAll),
+			KeyType:       tc.miner.KeyType,
+		})
+		tx.TotalInput += output.Amount
+	}
+	tx.TotalOutput = amount
+	tx.Fee = tx.TotalInput - amount
+
+	inputData, outputData := utxoSigHashData(utxos, tx.Outputs, tx.LockTime)
+	for i := range tx.Inputs {
+		signature, err := crypto.SignInput(tc.miner.KeyType, tc.miner.PrivateKeyHex, inputData, outputData, i, crypto.SigHashAll)
+		if err != nil {
+			tc.t.Fatal(err)
+		}
+		tx.Inputs[i].Signature = signature
+	}
+	tx.TransactionID = crypto.GenerateTransactionID(&tx)
+	return tx
+}
+
+// replay runs the chain through a replay with no faucet outputs
+func (tc *testChain) replay() *chainReplay {
+	tc.t.Helper()
+	r := newEmptyChainReplay()
+	for _, block := range tc.blocks {
+		if err := r.checkBlock(context.Background(), block); err != nil {
+			tc.t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func validationMessages(result models.ChainValidation) []string {
+	messages := make([]string, len(result.Errors))
+	for i, e := range result.Errors {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+func TestChainReplayValidChain(t *testing.T) {
+	tc := newTestChain(t)
+	first := tc.addBlock()
+	second := tc.addBlock()
+	const fee = 1000
+	payment := tc.spend("recipient", 2*models.DefaultBlockchainConfig.BlockReward-fee, first, second)
+	third := tc.addBlock(payment)
+
+	r := tc.replay()
+	result := r.report()
+	if !result.IsValid {
+		t.Fatalf("valid chain reported errors: %q", validationMessages(result))
+	}
+	if result.BlocksChecked != 4 || result.TransactionsChecked != 1 {
+		t.Errorf("checked %d blocks and %d transactions, want 4 and 1", result.BlocksChecked, result.TransactionsChecked)
+	}
+	// The payment and the last coinbase are left; the coinbases it spends are gone
+	if result.UTXOCount != 2 || result.TotalSupply != 3*models.DefaultBlockchainConfig.BlockReward {
+		t.Errorf("%d outputs holding %s left, want 2 holding %s",
+			result.UTXOCount, result.TotalSupply, 3*models.DefaultBlockchainConfig.BlockReward)
+	}
+	if third.Coinbase.Outputs[0].Amount != models.DefaultBlockchainConfig.BlockReward+fee {
+		t.Errorf("coinbase pays %s, want the reward plus the fee", third.Coinbase.Outputs[0].Amount)
+	}
+
+	for _, block := range []*models.Block{first, second} {
+		key := outpoint{block.Coinbase.TransactionID, 0}
+		if _, ok := r.utxos[key]; ok {
+			t.Errorf("block %d's spent coinbase is still in the UTXO set", block.Index)
+		}
+		if spent := r.spent[key]; spent == nil || spent.spentIn != payment.TransactionID || spent.blockHeight != block.Index {
+			t.Errorf("block %d's coinbase is recorded as spent by %+v, want %s", block.Index, spent, payment.TransactionID)
+		}
+	}
+}
+
+func TestChainReplayDoubleSpends(t *testing.T) {
+	tc := newTestChain(t)
+	first := tc.addBlock()
+	payment := tc.spend("recipient", models.DefaultBlockchainConfig.BlockReward, first)
+	tc.addBlock(payment)
+	again := tc.spend("other", models.DefaultBlockchainConfig.BlockReward, first)
+	tc.addBlock(again)
+	twice := tc.spend("other", 2*models.DefaultBlockchainConfig.BlockReward, tc.blocks[2], tc.blocks[2])
+	tc.addBlock(twice)
+
+	result := tc.replay().report()
+	messages := validationMessages(result)
+	want := []string{
+		"Input 0 spends " + first.Coinbase.TransactionID + ":0, already spent by " + payment.TransactionID,
+		"Input 1 spends " + tc.blocks[2].Coinbase.TransactionID + ":0, already spent by " + twice.TransactionID,
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("errors = %q, want %q", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("error %d = %q, want %q", i, messages[i], want[i])
+		}
+	}
+}
+
+func TestChainReplayRejectsBadBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(block *models.Block)
+		want   string
+	}{
+		{"coinbase overpays", func(b *models.Block) { b.Coinbase.Outputs[0].Amount++ }, "Coinbase pays"},
+		{"coinbase ID changed", func(b *models.Block) { b.Coinbase.CoinbaseData = crypto.CoinbaseData(b.Index, 1) }, "Coinbase ID doesn't match"},
+		{"coinbase for another height", func(b *models.Block) {
+			b.Coinbase.CoinbaseData = crypto.CoinbaseData(b.Index+1, 0)
+		}, "Coinbase data doesn't commit to the block height"},
+		{"no coinbase", func(b *models.Block) { b.Coinbase = nil }, "Block has no coinbase"},
+		{"wrong parent", func(b *models.Block) { b.PreviousHash = strings.Repeat("0", 64) }, "Previous hash is"},
+		{"hash not of the header", func(b *models.Block) { b.Nonce++ }, "but the header hashes to"},
+		{"wrong transaction count", func(b *models.Block) { b.TransactionCount = 2 }, "Transaction count is 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestChain(t)
+			tt.modify(tc.addBlock())
+
+			result := tc.replay().report()
+			if result.IsValid {
+				t.Fatal("tampered block passed validation")
+			}
+			messages := validationMessages(result)
+			for _, message := range messages {
+				if strings.Contains(message, tt.want) {
+					return
+				}
+			}
+			t.Fatalf("errors = %q, want one containing %q", messages, tt.want)
+		})
+	}
+}
@@ -5,6 +5,12 @@ import (
 	"crypto-wallet-backend/crypto"
 	"crypto-wallet-backend/database"
 	"crypto-wallet-backend/models"
+	"crypto-wallet-backend/signer"
+	"crypto-wallet-backend/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -22,10 +28,19 @@ func getBeneficiaryCollection() *mongo.Collection {
 	return database.GetCollection("beneficiaries")
 }
 
-// GenerateWallet creates a new wallet with key pair for a user
+// GenerateWallet creates a new wallet with key pair for a user.
+// If requested, the key is derived from a fresh BIP-39 mnemonic which is
+// returned once and never stored.
 func GenerateWallet(c *gin.Context) {
 	userID := c.GetString("userId")
-	
+
+	// The request body is optional
+	var req models.GenerateWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -47,76 +62,367 @@ func GenerateWallet(c *gin.Context) {
 		return
 	}
 
-	// Generate key pair
-	keyPair, err := crypto.GenerateKeyPair()
+	// The signer generates the HD master key, from a new mnemonic if requested
+	spec := signer.KeySpec{KeyType: req.KeyType}
+	if req.UseMnemonic {
+		wordCount := req.WordCount
+		if wordCount == 0 {
+			wordCount = 12
+		}
+		if wordCount != 12 && wordCount != 24 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wordCount must be 12 or 24"})
+			return
+		}
+		spec.WordCount = wordCount
+		spec.Passphrase = req.Passphrase
+	}
+
+	wallet, key, err := createWalletForUser(ctx, objID, spec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key pair"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wallet"})
 		return
 	}
 
-	// Generate wallet ID from public key
-	walletID := crypto.GenerateWalletID(keyPair.PublicKeyHex)
+	response := gin.H{
+		"message": "Wallet created successfully",
+		"wallet":  walletResponse(wallet),
+	}
+	if key.Mnemonic != "" {
+		response["mnemonic"] = key.Mnemonic
+		response["warning"] = "Write down your recovery phrase and keep it offline. It will not be shown again."
+	}
 
-	// Encrypt private key before storing
-	encryptedPrivateKey, err := crypto.EncryptPrivateKey(keyPair.PrivateKeyHex)
+	c.JSON(http.StatusCreated, response)
+}
+
+// RestoreWallet rebuilds a wallet from its mnemonic phrase and optional passphrase.
+// The derived key always produces the same wallet ID, so a user who lost access
+// to their stored key can recover the same address and funds.
+func RestoreWallet(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.RestoreWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt private key"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	// Create wallet
-	wallet := models.Wallet{
-		ID:         primitive.NewObjectID(),
-		UserID:     objID,
-		WalletID:   walletID,
-		PublicKey:  keyPair.PublicKeyHex,
-		PrivateKey: encryptedPrivateKey,
-		Balance:    0,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	spec := signer.KeySpec{KeyType: req.KeyType, Mnemonic: req.Mnemonic, Passphrase: req.Passphrase}
+	key, err := signer.Default().DescribeKey(ctx, spec)
+	if err != nil {
+		if errors.Is(err, signer.ErrInvalidKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mnemonic phrase: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to derive key pair"})
+		return
+	}
+
+	restoreWalletForUser(ctx, c, objID, spec, key)
+}
+
+// ImportPrivateKey creates a wallet from an existing private key of the requested
+// type (P-256 by default) and claims any UTXOs already locked to its public key
+func ImportPrivateKey(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ImportPrivateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	// Check if user already has a wallet
+	var existingWallet models.Wallet
+	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&existingWallet)
+	if err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wallet already exists for this user"})
+		return
+	} else if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
 	}
 
-	// Insert wallet into database
-	_, err = getWalletCollection().InsertOne(ctx, wallet)
+	// An imported key has no chain code, so the wallet can't derive addresses
+	spec := signer.KeySpec{KeyType: req.KeyType, PrivateKey: req.PrivateKey}
+	key, err := signer.Default().DescribeKey(ctx, spec)
+	if err != nil {
+		if errors.Is(err, signer.ErrInvalidKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid private key: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read private key"})
+		return
+	}
+
+	// Reject keys that are already registered as a wallet
+	walletID := crypto.GenerateWalletID(key.PublicKey)
+	count, err := getWalletCollection().CountDocuments(ctx, bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(walletID)}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This key is already registered as a wallet"})
+		return
+	}
+
+	wallet, _, err := createWalletForUser(ctx, objID, spec)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wallet"})
 		return
 	}
 
+	recovered, err := rescanWalletUTXOs(ctx, wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Wallet imported but UTXO scan failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Wallet imported successfully",
+		"wallet":         walletResponse(wallet),
+		"recoveredUtxos": recovered,
+	})
+}
+
+// rescanWalletUTXOs attaches UTXOs that are locked to the wallet's public key, or
+// stored under another form of its ID, to the wallet, rediscovers the derived
+// addresses of HD wallets and refreshes the cached balance across all of them.
+// It returns the number of UTXOs that were reassigned.
+func rescanWalletUTXOs(ctx context.Context, wallet *models.Wallet) (int64, error) {
+	discovered, err := discoverWalletAddresses(ctx, wallet)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := getUTXOCollection().UpdateMany(ctx,
+		bson.M{
+			"$or": []bson.M{
+				{"publicKey": wallet.PublicKey},
+				{"walletId": bson.M{"$in": crypto.AddressAliases(wallet.WalletID)}},
+			},
+			"walletId": bson.M{"$ne": wallet.WalletID},
+		},
+		bson.M{"$set": bson.M{"walletId": wallet.WalletID}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	addresses, err := walletAddressIDs(ctx, wallet)
+	if err != nil {
+		return 0, err
+	}
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"walletId": bson.M{"$in": addresses}, "isSpent": false}}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
+	}
+	cursor, err := getUTXOCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Total models.Amount `bson:"total"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) > 0 {
+		wallet.Balance = totals[0].Total
+	}
+
+	_, err = getWalletCollection().UpdateOne(ctx,
+		bson.M{"_id": wallet.ID},
+		bson.M{"$set": bson.M{"balance": wallet.Balance, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount + discovered, nil
+}
+
+// restoreWalletForUser installs a recovered key, described by key, as the user's
+// wallet and writes the response. A user keeps a single wallet, so restoring is
+// only allowed into an empty account or over the same wallet. Keys without a
+// chain code (wallets from before HD support) restore as non-HD wallets.
+func restoreWalletForUser(ctx context.Context, c *gin.Context, objID primitive.ObjectID, spec signer.KeySpec, key *signer.KeyInfo) {
+	walletID := crypto.GenerateWalletID(key.PublicKey)
+
+	var existingWallet models.Wallet
+	err := getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&existingWallet)
+	if err == nil {
+		if !crypto.AddressesEqual(existingWallet.WalletID, walletID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A different wallet already exists for this user"})
+			return
+		}
+
+		// Same wallet: the signer replaces the stored key, and the chain code when
+		// the recovered key has one
+		if _, err := signer.Default().StoreKey(ctx, existingWallet.ID, spec); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore wallet"})
+			return
+		}
+		_, err = getWalletCollection().UpdateOne(ctx,
+			bson.M{"_id": existingWallet.ID},
+			bson.M{"$set": bson.M{"updatedAt": time.Now()}},
+		)
+		if err == nil {
+			err = getWalletCollection().FindOne(ctx, bson.M{"_id": existingWallet.ID}).Decode(&existingWallet)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore wallet"})
+			return
+		}
+
+		// A recovery phrase can bring back addresses the stored key had lost
+		recovered, err := rescanWalletUTXOs(ctx, &existingWallet)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Wallet restored but UTXO scan failed"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Wallet restored successfully",
+			"wallet":         walletResponse(&existingWallet),
+			"recoveredUtxos": recovered,
+		})
+		return
+	} else if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// The wallet must not already belong to someone else
+	count, err := getWalletCollection().CountDocuments(ctx, bson.M{"walletId": bson.M{"$in": crypto.AddressAliases(walletID)}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This wallet is registered to another account"})
+		return
+	}
+
+	wallet, _, err := createWalletForUser(ctx, objID, spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create wallet"})
+		return
+	}
+
+	// Funds may still be recorded under the wallet's key or its legacy ID
+	recovered, err := rescanWalletUTXOs(ctx, wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Wallet restored but UTXO scan failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Wallet restored successfully",
+		"wallet":         walletResponse(wallet),
+		"recoveredUtxos": recovered,
+	})
+}
+
+// createWalletForUser has the signer make and store the key spec describes,
+// then completes the wallet around it and links it to the user. The master
+// key's own address is the wallet's primary wallet ID. A key without a chain
+// code is stored as a non-HD wallet. The key's public details are returned
+// with the wallet.
+func createWalletForUser(ctx context.Context, userID primitive.ObjectID, spec signer.KeySpec) (*models.Wallet, *signer.KeyInfo, error) {
+	// The signer writes the encrypted key first, to a document no user owns yet
+	docID := primitive.NewObjectID()
+	key, err := signer.Default().StoreKey(ctx, docID, spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store key: %w", err)
+	}
+
+	// Generate wallet ID from public key
+	walletID := crypto.GenerateWalletID(key.PublicKey)
+
+	now := time.Now()
+	_, err = getWalletCollection().UpdateOne(ctx,
+		bson.M{"_id": docID},
+		bson.M{"$set": bson.M{
+			"userId":           userID,
+			"walletId":         walletID,
+			"publicKey":        key.PublicKey,
+			"balance":          models.Amount(0),
+			"nextReceiveIndex": 0,
+			"nextChangeIndex":  0,
+			"createdAt":        now,
+			"updatedAt":        now,
+		}},
+	)
+	if err != nil {
+		getWalletCollection().DeleteOne(ctx, bson.M{"_id": docID})
+		return nil, nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	var wallet models.Wallet
+	if err := getWalletCollection().FindOne(ctx, bson.M{"_id": docID}).Decode(&wallet); err != nil {
+		return nil, nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
 	// Update user with wallet ID and public key
 	_, err = getUserCollection().UpdateOne(
 		ctx,
-		bson.M{"_id": objID},
+		bson.M{"_id": userID},
 		bson.M{
 			"$set": bson.M{
 				"walletId":  walletID,
-				"publicKey": keyPair.PublicKeyHex,
+				"publicKey": key.PublicKey,
 				"updatedAt": time.Now(),
 			},
 		},
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		return
+		return nil, nil, fmt.Errorf("failed to update user: %w", err)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Wallet created successfully",
-		"wallet": models.WalletResponse{
-			ID:        wallet.ID,
-			UserID:    wallet.UserID,
-			WalletID:  wallet.WalletID,
-			PublicKey: wallet.PublicKey,
-			Balance:   wallet.Balance,
-			CreatedAt: wallet.CreatedAt,
-		},
-	})
+	return &wallet, key, nil
+}
+
+// walletResponse strips the private key from a wallet before it is sent to the client
+func walletResponse(wallet *models.Wallet) models.WalletResponse {
+	return models.WalletResponse{
+		ID:        wallet.ID,
+		UserID:    wallet.UserID,
+		WalletID:  wallet.WalletID,
+		PublicKey: wallet.PublicKey,
+		Balance:   wallet.Balance,
+		KeyType:   wallet.KeyType.OrDefault(),
+		IsHD:      wallet.IsHD(),
+		CreatedAt: wallet.CreatedAt,
+	}
 }
 
 // GetWallet returns the wallet for the authenticated user
 func GetWallet(c *gin.Context) {
 	userID := c.GetString("userId")
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -138,26 +444,19 @@ func GetWallet(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"wallet": models.WalletResponse{
-			ID:        wallet.ID,
-			UserID:    wallet.UserID,
-			WalletID:  wallet.WalletID,
-			PublicKey: wallet.PublicKey,
-			Balance:   wallet.Balance,
-			CreatedAt: wallet.CreatedAt,
-		},
+		"wallet": walletResponse(&wallet),
 	})
 }
 
 // GetWalletByID returns wallet info by wallet ID (public endpoint)
 func GetWalletByID(c *gin.Context) {
 	walletID := c.Param("walletId")
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var wallet models.Wallet
-	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": walletID}).Decode(&wallet)
+	// The ID may be a primary wallet ID or an address derived from one
+	wallet, address, err := resolveAddress(ctx, walletID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid Wallet ID"})
@@ -169,8 +468,8 @@ func GetWalletByID(c *gin.Context) {
 
 	// Return only public info
 	c.JSON(http.StatusOK, gin.H{
-		"walletId":  wallet.WalletID,
-		"publicKey": wallet.PublicKey,
+		"walletId":  address.Address,
+		"publicKey": address.PublicKey,
 		"balance":   wallet.Balance,
 	})
 }
@@ -178,12 +477,18 @@ func GetWalletByID(c *gin.Context) {
 // ValidateWalletID checks if a wallet ID exists
 func ValidateWalletID(c *gin.Context) {
 	walletID := c.Param("walletId")
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	var wallet models.Wallet
-	err := getWalletCollection().FindOne(ctx, bson.M{"walletId": walletID}).Decode(&wallet)
+	// Reject malformed IDs and typos without touching the database
+	if err := crypto.ValidateAddress(walletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": err.Error()})
+		return
+	}
+
+	// Derived addresses are valid destinations too
+	_, address, err := resolveAddress(ctx, walletID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"valid": false, "error": "Invalid Wallet ID"})
@@ -193,13 +498,20 @@ func ValidateWalletID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"valid": true, "walletId": wallet.WalletID})
+	c.JSON(http.StatusOK, gin.H{"valid": true, "walletId": address.Address})
 }
 
-// ExportPrivateKey returns the decrypted private key (use with caution!)
-func ExportPrivateKey(c *gin.Context) {
+// ExportKeystore returns the wallet's keys as a keystore file encrypted with a
+// passphrase chosen by the user. The account password must be re-entered first.
+func ExportKeystore(c *gin.Context) {
 	userID := c.GetString("userId")
-	
+
+	var req models.ExportKeystoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -209,6 +521,22 @@ func ExportPrivateKey(c *gin.Context) {
 		return
 	}
 
+	// Confirm the account password before releasing any key material
+	var user models.User
+	err = getUserCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Password == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Keystore export requires an account password. Accounts that sign in with Google cannot export keys."})
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+		return
+	}
+
 	var wallet models.Wallet
 	err = getWalletCollection().FindOne(ctx, bson.M{"userId": objID}).Decode(&wallet)
 	if err != nil {
@@ -220,23 +548,94 @@ func ExportPrivateKey(c *gin.Context) {
 		return
 	}
 
-	// Decrypt private key
+	// Decrypt private key and, for HD wallets, the chain code
 	privateKeyHex, err := crypto.DecryptPrivateKey(wallet.PrivateKey)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt private key"})
 		return
 	}
+	var chainCodeHex string
+	if wallet.IsHD() {
+		chainCodeHex, err = crypto.DecryptPrivateKey(wallet.ChainCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt private key"})
+			return
+		}
+	}
+
+	keystore, err := crypto.EncryptKeystore(wallet.WalletID, crypto.KeystoreKey{
+		KeyType:    wallet.KeyType.OrDefault(),
+		PrivateKey: privateKeyHex,
+		ChainCode:  chainCodeHex,
+	}, req.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create keystore"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"warning":    "Keep your private key secure! Never share it with anyone.",
-		"privateKey": privateKeyHex,
+		"warning":  "Store this file and its passphrase separately. The passphrase cannot be recovered.",
+		"keystore": keystore,
 	})
 }
 
+// ImportKeystore decrypts a keystore file and creates or restores the wallet it holds
+func ImportKeystore(c *gin.Context) {
+	userID := c.GetString("userId")
+
+	var req models.ImportKeystoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var keystore crypto.Keystore
+	if err := json.Unmarshal(req.Keystore, &keystore); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keystore file"})
+		return
+	}
+
+	key, err := crypto.DecryptKeystore(&keystore, req.Passphrase)
+	if err != nil {
+		if err == crypto.ErrKeystorePassphrase {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Incorrect keystore passphrase"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keystore file: " + err.Error()})
+		return
+	}
+
+	spec := signer.KeySpec{KeyType: key.KeyType, PrivateKeyHex: key.PrivateKey, ChainCodeHex: key.ChainCode}
+	info, err := signer.Default().DescribeKey(ctx, spec)
+	if err != nil {
+		if errors.Is(err, signer.ErrInvalidKey) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Keystore contains an invalid key"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read keystore key"})
+		return
+	}
+	if !crypto.AddressesEqual(keystore.WalletID, crypto.GenerateWalletID(info.PublicKey)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Keystore wallet ID does not match its key"})
+		return
+	}
+
+	restoreWalletForUser(ctx, c, objID, spec, info)
+}
+
 // AddBeneficiary adds a new beneficiary to the user's list
 func AddBeneficiary(c *gin.Context) {
 	userID := c.GetString("userId")
-	
+
 	var req models.AddBeneficiaryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -252,9 +651,14 @@ func AddBeneficiary(c *gin.Context) {
 		return
 	}
 
+	// Validate the wallet ID's format and checksum before looking it up
+	if err := crypto.ValidateAddress(req.WalletID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Wallet ID - " + err.Error()})
+		return
+	}
+
 	// Validate wallet ID exists
-	var wallet models.Wallet
-	err = getWalletCollection().FindOne(ctx, bson.M{"walletId": req.WalletID}).Decode(&wallet)
+	_, address, err := resolveAddress(ctx, req.WalletID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Wallet ID - wallet does not exist"})
@@ -263,6 +667,7 @@ func AddBeneficiary(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
+	req.WalletID = address.Address
 
 	// Check if beneficiary already exists
 	var existingBeneficiary models.Beneficiary
@@ -299,7 +704,7 @@ func AddBeneficiary(c *gin.Context) {
 // GetBeneficiaries returns all beneficiaries for the user
 func GetBeneficiaries(c *gin.Context) {
 	userID := c.GetString("userId")
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -335,7 +740,7 @@ func GetBeneficiaries(c *gin.Context) {
 func DeleteBeneficiary(c *gin.Context) {
 	userID := c.GetString("userId")
 	beneficiaryID := c.Param("id")
-	
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"crypto-wallet-backend/crypto"
 	"crypto-wallet-backend/database"
 	"crypto-wallet-backend/models"
 	"net/http"
@@ -54,9 +55,15 @@ func GetZakatSummary(c *gin.Context) {
 		return
 	}
 
-	// Calculate current balance
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+
+	// Calculate current balance across all of the wallet's addresses
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"walletId": wallet.WalletID, "isSpent": false}}},
+		bson.D{{Key: "$match", Value: bson.M{"walletId": bson.M{"$in": addresses}, "isSpent": false}}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
 	}
 
@@ -67,10 +74,10 @@ func GetZakatSummary(c *gin.Context) {
 	}
 	defer cursor.Close(ctx)
 
-	var balance float64
+	var balance models.Amount
 	if cursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		cursor.Decode(&result)
 		balance = result.Total
@@ -96,12 +103,12 @@ func GetZakatSummary(c *gin.Context) {
 	cursor2, _ := getZakatPaymentCollection().Aggregate(ctx, pipeline2)
 	defer cursor2.Close(ctx)
 
-	var totalPaid float64
+	var totalPaid models.Amount
 	var paymentCount int
 	if cursor2.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
-			Count int     `bson:"count"`
+			Total models.Amount `bson:"total"`
+			Count int           `bson:"count"`
 		}
 		cursor2.Decode(&result)
 		totalPaid = result.Total
@@ -111,9 +118,9 @@ func GetZakatSummary(c *gin.Context) {
 	// Calculate zakat eligibility and amount
 	nisab := models.DefaultZakatSettings.NisabInCoins
 	isEligible := balance >= nisab
-	var zakatDue float64
+	var zakatDue models.Amount
 	if isEligible {
-		zakatDue = balance * models.DefaultZakatSettings.ZakatRate
+		zakatDue = balance.MulRate(models.DefaultZakatSettings.ZakatRate)
 	}
 
 	// Calculate next due date (1 lunar year from last calculation)
@@ -157,9 +164,15 @@ func CalculateZakat(c *gin.Context) {
 		return
 	}
 
-	// Calculate current balance
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+
+	// Calculate current balance across all of the wallet's addresses
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"walletId": wallet.WalletID, "isSpent": false}}},
+		bson.D{{Key: "$match", Value: bson.M{"walletId": bson.M{"$in": addresses}, "isSpent": false}}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
 	}
 
@@ -170,10 +183,10 @@ func CalculateZakat(c *gin.Context) {
 	}
 	defer cursor.Close(ctx)
 
-	var balance float64
+	var balance models.Amount
 	if cursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		cursor.Decode(&result)
 		balance = result.Total
@@ -184,9 +197,9 @@ func CalculateZakat(c *gin.Context) {
 	rate := models.DefaultZakatSettings.ZakatRate
 	isEligible := balance >= nisab
 
-	var zakatAmount float64
+	var zakatAmount models.Amount
 	if isEligible {
-		zakatAmount = balance * rate
+		zakatAmount = balance.MulRate(rate)
 	}
 
 	now := time.Now()
@@ -286,28 +299,44 @@ func PayZakat(c *gin.Context) {
 	}
 
 	// Check if recipient wallet exists (unless it's the default fund)
+	var recipientPublicKey string
 	if recipientWallet != models.DefaultZakatSettings.ZakatFundWallet {
-		var recipientWalletDoc models.Wallet
-		err = getWalletCollection().FindOne(ctx, bson.M{"walletId": recipientWallet}).Decode(&recipientWalletDoc)
+		if err := crypto.ValidateAddress(recipientWallet); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipient address: " + err.Error()})
+			return
+		}
+		_, recipient, err := resolveAddress(ctx, recipientWallet)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Recipient wallet not found"})
 			return
 		}
+		recipientWallet, recipientPublicKey = recipient.Address, recipient.PublicKey
+	}
+
+	addresses, err := walletAddressIDs(ctx, &wallet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch wallet addresses"})
+		return
+	}
+	height, err := nextBlockHeight(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chain height"})
+		return
 	}
 
-	// Check sender has sufficient balance
+	// Check sender has sufficient unlocked balance
 	pipeline := mongo.Pipeline{
-		bson.D{{Key: "$match", Value: bson.M{"walletId": wallet.WalletID, "isSpent": false}}},
+		bson.D{{Key: "$match", Value: spendableUTXOFilter(addresses, height)}},
 		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
 	}
 
 	cursor, _ := getUTXOCollection().Aggregate(ctx, pipeline)
 	defer cursor.Close(ctx)
 
-	var balance float64
+	var balance models.Amount
 	if cursor.Next(ctx) {
 		var result struct {
-			Total float64 `bson:"total"`
+			Total models.Amount `bson:"total"`
 		}
 		cursor.Decode(&result)
 		balance = result.Total
@@ -320,10 +349,7 @@ func PayZakat(c *gin.Context) {
 
 	// Create the zakat payment transaction using existing transaction logic
 	// First, get UTXOs to spend
-	utxoCursor, err := getUTXOCollection().Find(ctx, bson.M{
-		"walletId": wallet.WalletID,
-		"isSpent":  false,
-	}, options.Find().SetSort(bson.M{"amount": -1}))
+	utxoCursor, err := getUTXOCollection().Find(ctx, spendableUTXOFilter(addresses, height), options.Find().SetSort(bson.M{"amount": -1}))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch UTXOs"})
 		return
@@ -338,7 +364,7 @@ func PayZakat(c *gin.Context) {
 
 	// Select UTXOs to cover the amount
 	var selectedUTXOs []models.UTXO
-	var totalInput float64
+	var totalInput models.Amount
 	for _, utxo := range utxos {
 		if totalInput >= req.Amount {
 			break
@@ -352,105 +378,98 @@ func PayZakat(c *gin.Context) {
 		return
 	}
 
-	// Create transaction
 	now := time.Now()
-	txID := primitive.NewObjectID().Hex()
-
-	// Build inputs
-	var inputs []models.SignedInput
-	for _, utxo := range selectedUTXOs {
-		inputs = append(inputs, models.SignedInput{
-			TransactionID: utxo.TransactionID,
-			OutputIndex:   utxo.OutputIndex,
-			Amount:        utxo.Amount,
-			PublicKey:     wallet.PublicKey,
-			Signature:     "ZAKAT_PAYMENT_" + txID, // Internal transaction
-		})
-	}
 
 	// Build outputs
 	change := totalInput - req.Amount
 	outputs := []models.TransactionOutput{
 		{
-			WalletID: recipientWallet,
-			Amount:   req.Amount,
+			WalletID:      recipientWallet,
+			Amount:        req.Amount,
+			PublicKey:     recipientPublicKey,
+			LockingScript: outputLockingScript(recipientWallet),
 		},
 	}
 
+	// Change goes to a fresh internal address
 	if change > 0 {
+		changeAddress, changePublicKey, err := changeDestination(ctx, &wallet)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to derive change address"})
+			return
+		}
 		outputs = append(outputs, models.TransactionOutput{
-			WalletID: wallet.WalletID,
-			Amount:   change,
+			WalletID:      changeAddress,
+			Amount:        change,
+			PublicKey:     changePublicKey,
+			LockingScript: outputLockingScript(changeAddress),
 		})
 	}
 
-	transaction := models.Transaction{
-		TransactionID: txID,
-		Type:          models.TxTypeZakat,
-		SenderWallet:  wallet.WalletID,
-		Inputs:        inputs,
-		Outputs:       outputs,
-		TotalInput:    totalInput,
-		TotalOutput:   req.Amount + change,
-		Fee:           0, // No fee for zakat
-		Status:        models.TxStatusPending,
-		Message:       "Zakat Payment",
-		Timestamp:     now,
-	}
-
-	// Start atomic transaction
-	session, err := database.GetClient().StartSession()
+	var inputDataForHash []crypto.InputData
+	for _, utxo := range selectedUTXOs {
+		inputDataForHash = append(inputDataForHash, crypto.InputData{
+			TransactionID: utxo.TransactionID,
+			OutputIndex:   utxo.OutputIndex,
+			Amount:        utxo.Amount,
+			LockingScript: utxo.LockingScript,
+		})
+	}
+
+	var outputDataForHash []crypto.OutputData
+	for _, output := range outputs {
+		outputDataForHash = append(outputDataForHash, crypto.OutputData{
+			WalletID:      output.WalletID,
+			Amount:        output.Amount,
+			LockingScript: output.LockingScript,
+		})
+	}
+
+	// Sign each input over the full transaction like a regular transfer
+	signatures, err := signUTXOInputs(ctx, &wallet, selectedUTXOs, inputDataForHash, outputDataForHash, crypto.SigHashAll)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign zakat payment"})
 		return
 	}
-	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Insert transaction
-		_, err := getTransactionCollection().InsertOne(sessCtx, transaction)
+	// Build inputs
+	var inputs []models.SignedInput
+	for i, utxo := range selectedUTXOs {
+		unlockingScript, err := unlockingScriptFor(utxo, signatures[i], crypto.SigHashAll)
 		if err != nil {
-			return nil, err
-		}
-
-		// Mark UTXOs as spent
-		for _, utxo := range selectedUTXOs {
-			_, err := getUTXOCollection().UpdateOne(sessCtx,
-				bson.M{"transactionId": utxo.TransactionID, "outputIndex": utxo.OutputIndex},
-				bson.M{"$set": bson.M{"isSpent": true, "spentInTx": txID, "spentAt": now}})
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Create new UTXOs for outputs
-		for idx, output := range outputs {
-			newUTXO := models.UTXO{
-				TransactionID: txID,
-				OutputIndex:   idx,
-				WalletID:      output.WalletID,
-				Amount:        output.Amount,
-				IsSpent:       false,
-				IsConfirmed:   false,
-				CreatedAt:     now,
-			}
-
-			// Get public key for recipient
-			if output.WalletID == wallet.WalletID {
-				newUTXO.PublicKey = wallet.PublicKey
-			} else if output.WalletID != models.DefaultZakatSettings.ZakatFundWallet {
-				var recipientWalletDoc models.Wallet
-				getWalletCollection().FindOne(sessCtx, bson.M{"walletId": output.WalletID}).Decode(&recipientWalletDoc)
-				newUTXO.PublicKey = recipientWalletDoc.PublicKey
-			}
-
-			_, err := getUTXOCollection().InsertOne(sessCtx, newUTXO)
-			if err != nil {
-				return nil, err
-			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign zakat payment"})
+			return
 		}
+		inputs = append(inputs, models.SignedInput{
+			TransactionID:   utxo.TransactionID,
+			OutputIndex:     utxo.OutputIndex,
+			Amount:          utxo.Amount,
+			PublicKey:       utxo.PublicKey,
+			Signature:       signatures[i],
+			SigHashType:     uint8(crypto.SigHashAll),
+			KeyType:         inputKeyType(utxo.PublicKey),
+			UnlockingScript: unlockingScript,
+		})
+	}
 
-		// Create zakat payment record
+	// Create transaction
+	transaction := models.Transaction{
+		Type:         models.TxTypeZakat,
+		SenderWallet: wallet.WalletID,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		TotalInput:   totalInput,
+		TotalOutput:  req.Amount + change,
+		Fee:          0, // No fee for zakat
+		Status:       models.TxStatusPending,
+		Message:      "Zakat Payment",
+		Timestamp:    now,
+	}
+	txID := crypto.GenerateTransactionID(&transaction)
+	transaction.TransactionID = txID
+
+	// Spend the inputs, record the transaction and the payment atomically
+	err = recordTransaction(ctx, &transaction, selectedUTXOs, func(sessCtx mongo.SessionContext) error {
 		payment := models.ZakatPayment{
 			UserID:          objID,
 			WalletID:        wallet.WalletID,
@@ -461,26 +480,21 @@ func PayZakat(c *gin.Context) {
 			Status:          "pending",
 			PaidAt:          now,
 		}
-		_, err = getZakatPaymentCollection().InsertOne(sessCtx, payment)
-		if err != nil {
-			return nil, err
+		if _, err := getZakatPaymentCollection().InsertOne(sessCtx, payment); err != nil {
+			return err
 		}
 
 		// Mark calculation as paid if provided
 		if req.CalculationID != "" {
-			_, err = getZakatCalculationCollection().UpdateOne(sessCtx,
+			_, err := getZakatCalculationCollection().UpdateOne(sessCtx,
 				bson.M{"_id": calcID},
 				bson.M{"$set": bson.M{"isPaid": true, "paidAt": now, "paymentTxId": txID}})
-			if err != nil {
-				return nil, err
-			}
+			return err
 		}
-
-		return nil, nil
+		return nil
 	})
-
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process zakat payment", "details": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -0,0 +1,242 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Wallet addresses are Base58Check encoded:
+//
+//	base58(version byte | 20-byte public key hash | 4-byte checksum)
+//
+// where the checksum is the first 4 bytes of SHA-256(SHA-256(version | hash)).
+// The version byte identifies the network, so a testnet address can't be pasted
+// into a mainnet wallet, and the checksum catches typos without a database lookup.
+//
+// Wallets created before this format hold a bare 40-character hex ID of the same
+// 20-byte hash. Both forms are accepted while existing wallets are migrated.
+//
+// Multisig wallets use their own version bytes, with the hash of the wallet's
+// redeem policy in place of the public key hash (see multisig.go). They have
+// no legacy hex form.
+
+// Address version bytes
+const (
+	MainNetAddressVersion         byte = 0x1c // Addresses start with "C"
+	TestNetAddressVersion         byte = 0x6f // Addresses start with "m" or "n"
+	MainNetMultisigAddressVersion byte = 0x32 // Addresses start with "M"
+	TestNetMultisigAddressVersion byte = 0xc4 // Addresses start with "2"
+)
+
+const (
+	addressHashLength     = 20
+	addressChecksumLength = 4
+	legacyAddressLength   = addressHashLength * 2
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var (
+	ErrInvalidAddress         = errors.New("invalid wallet address")
+	ErrAddressChecksum        = errors.New("wallet address checksum mismatch, check it for typos")
+	ErrAddressNetworkMismatch = errors.New("wallet address belongs to a different network")
+)
+
+var base58Indexes = buildBase58Indexes()
+
+func buildBase58Indexes() [256]int {
+	var indexes [256]int
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		indexes[base58Alphabet[i]] = i
+	}
+	return indexes
+}
+
+// ActiveAddressVersion returns the version byte for the configured network.
+// Set WALLET_NETWORK=testnet to issue testnet addresses; mainnet is the default.
+func ActiveAddressVersion() byte {
+	if strings.EqualFold(os.Getenv("WALLET_NETWORK"), "testnet") {
+		return TestNetAddressVersion
+	}
+	return MainNetAddressVersion
+}
+
+// ActiveMultisigAddressVersion returns the multisig version byte for the configured network
+func ActiveMultisigAddressVersion() byte {
+	if ActiveAddressVersion() == TestNetAddressVersion {
+		return TestNetMultisigAddressVersion
+	}
+	return MainNetMultisigAddressVersion
+}
+
+// PublicKeyHash returns the 20-byte hash that identifies a public key's wallet
+func PublicKeyHash(publicKeyHex string) []byte {
+	// First SHA-256 hash
+	hash := sha256.Sum256([]byte(publicKeyHex))
+
+	// Second SHA-256 hash (double hashing for extra security)
+	hash2 := sha256.Sum256(hash[:])
+
+	// Take first 20 bytes
+	return hash2[:addressHashLength]
+}
+
+// EncodeAddress encodes a public key hash as a Base58Check address
+func EncodeAddress(version byte, hash []byte) string {
+	payload := make([]byte, 0, 1+len(hash)+addressChecksumLength)
+	payload = append(payload, version)
+	payload = append(payload, hash...)
+	payload = append(payload, addressChecksum(payload)...)
+	return base58Encode(payload)
+}
+
+// DecodeAddress decodes a Base58Check address and verifies its checksum
+func DecodeAddress(address string) (byte, []byte, error) {
+	payload, err := base58Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) != 1+addressHashLength+addressChecksumLength {
+		return 0, nil, fmt.Errorf("%w: wrong length", ErrInvalidAddress)
+	}
+
+	body := payload[:len(payload)-addressChecksumLength]
+	checksum := payload[len(payload)-addressChecksumLength:]
+	if !bytes.Equal(checksum, addressChecksum(body)) {
+		return 0, nil, ErrAddressChecksum
+	}
+
+	return body[0], body[1:], nil
+}
+
+// ValidateAddress checks an address offline. It accepts Base58Check addresses
+// for the active network, multisig addresses included, and legacy 40-character
+// hex wallet IDs.
+func ValidateAddress(address string) error {
+	_, _, err := addressHash(address)
+	return err
+}
+
+// IsMultisigAddress reports whether address is a valid multisig address for the active network
+func IsMultisigAddress(address string) bool {
+	version, _, err := addressHash(address)
+	return err == nil && version == ActiveMultisigAddressVersion()
+}
+
+// LegacyWalletID returns the pre-Base58Check hex form of an address
+func LegacyWalletID(address string) (string, error) {
+	_, hash, err := addressHash(address)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// AddressAliases returns every stored form an address may have: the Base58Check
+// encoding and the legacy hex ID. Use it to look up wallets during the transition.
+func AddressAliases(address string) []string {
+	version, hash, err := addressHash(address)
+	if err != nil {
+		return []string{address}
+	}
+	if version == ActiveMultisigAddressVersion() {
+		return []string{EncodeAddress(version, hash)}
+	}
+	return []string{EncodeAddress(version, hash), hex.EncodeToString(hash)}
+}
+
+// AddressesEqual reports whether two addresses, in either form, identify the same wallet
+func AddressesEqual(a, b string) bool {
+	versionA, hashA, errA := addressHash(a)
+	versionB, hashB, errB := addressHash(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return versionA == versionB && bytes.Equal(hashA, hashB)
+}
+
+// addressHash extracts the version byte and hash from either address form.
+// Legacy hex IDs are reported with the active single-key version.
+func addressHash(address string) (byte, []byte, error) {
+	address = strings.TrimSpace(address)
+
+	if len(address) == legacyAddressLength {
+		if hash, err := hex.DecodeString(address); err == nil {
+			return ActiveAddressVersion(), hash, nil
+		}
+	}
+
+	version, hash, err := DecodeAddress(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version != ActiveAddressVersion() && version != ActiveMultisigAddressVersion() {
+		return 0, nil, ErrAddressNetworkMismatch
+	}
+	return version, hash, nil
+}
+
+func addressChecksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:addressChecksumLength]
+}
+
+// base58Encode encodes data with the Bitcoin Base58 alphabet. Leading zero
+// bytes are kept as leading '1' characters.
+func base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// Digits were produced least significant first
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	if s == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidAddress)
+	}
+
+	value := new(big.Int)
+	radix := big.NewInt(58)
+	for i := 0; i < len(s); i++ {
+		digit := base58Indexes[s[i]]
+		if digit < 0 {
+			return nil, fmt.Errorf("%w: invalid character %q", ErrInvalidAddress, s[i])
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	leadingZeros := 0
+	for leadingZeros < len(s) && s[leadingZeros] == base58Alphabet[0] {
+		leadingZeros++
+	}
+
+	return append(make([]byte, leadingZeros), value.Bytes()...), nil
+}
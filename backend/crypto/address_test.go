@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBase58Vectors(t *testing.T) {
+	// From Bitcoin Core's base58_encode_decode.json
+	tests := []struct{ data, encoded string }{
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"636363", "aPEr"},
+		{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+		{"572e4794", "3EFU7m"},
+		{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+		{"10c8511e", "Rt5zm"},
+		{"00000000000000000000", "1111111111"},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.data)
+		if got := base58Encode(data); got != tt.encoded {
+			t.Errorf("base58Encode(%s) = %q, want %q", tt.data, got, tt.encoded)
+		}
+		decoded, err := base58Decode(tt.encoded)
+		if err != nil || hex.EncodeToString(decoded) != tt.data {
+			t.Errorf("base58Decode(%q) = %x, %v, want %s", tt.encoded, decoded, err, tt.data)
+		}
+	}
+	if got := base58Encode(nil); got != "" {
+		t.Errorf("base58Encode(nil) = %q, want an empty string", got)
+	}
+}
+
+func TestBase58CheckAddress(t *testing.T) {
+	// The address paid by Bitcoin's genesis block: version 0x00 and the key's
+	// 20-byte hash
+	const address = "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"
+	hash, _ := hex.DecodeString("62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+
+	if got := EncodeAddress(0x00, hash); got != address {
+		t.Fatalf("EncodeAddress = %q, want %q", got, address)
+	}
+	version, decoded, err := DecodeAddress(address)
+	if err != nil || version != 0x00 || hex.EncodeToString(decoded) != hex.EncodeToString(hash) {
+		t.Fatalf("DecodeAddress = %#x, %x, %v", version, decoded, err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		want    error
+	}{
+		{"typo", strings.Replace(address, "QGef", "QGeg", 1), ErrAddressChecksum},
+		{"swapped characters", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DifvNa", ErrAddressChecksum},
+		{"character outside the alphabet", strings.Replace(address, "1", "0", 1), ErrInvalidAddress},
+		{"too short", address[:len(address)-2], ErrInvalidAddress},
+		{"empty", "", ErrInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := DecodeAddress(tt.address); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalletAddressNetworks(t *testing.T) {
+	hash := PublicKeyHash("02" + strings.Repeat("11", 32))
+	legacy := hex.EncodeToString(hash)
+
+	t.Setenv("WALLET_NETWORK", "")
+	mainnet := EncodeAddress(ActiveAddressVersion(), hash)
+	if !strings.HasPrefix(mainnet, "C") {
+		t.Errorf("mainnet address %q doesn't start with C", mainnet)
+	}
+	if err := ValidateAddress(mainnet); err != nil {
+		t.Errorf("ValidateAddress(%q): %v", mainnet, err)
+	}
+	if !AddressesEqual(mainnet, legacy) || !AddressesEqual(" "+legacy, mainnet) {
+		t.Errorf("%q and its legacy ID %q aren't the same wallet", mainnet, legacy)
+	}
+	if aliases := AddressAliases(legacy); len(aliases) != 2 || aliases[0] != mainnet || aliases[1] != legacy {
+		t.Errorf("AddressAliases(%q) = %q", legacy, aliases)
+	}
+
+	t.Setenv("WALLET_NETWORK", "testnet")
+	testnet := EncodeAddress(ActiveAddressVersion(), hash)
+	if !strings.HasPrefix(testnet, "m") && !strings.HasPrefix(testnet, "n") {
+		t.Errorf("testnet address %q doesn't start with m or n", testnet)
+	}
+	if err := ValidateAddress(mainnet); !errors.Is(err, ErrAddressNetworkMismatch) {
+		t.Errorf("mainnet address on testnet: err = %v, want %v", err, ErrAddressNetworkMismatch)
+	}
+	if AddressesEqual(mainnet, testnet) {
+		t.Error("addresses on different networks compare equal")
+	}
+}
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"crypto-wallet-backend/models"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -8,14 +9,44 @@ import (
 	"time"
 )
 
-// HashBlock creates a SHA-256 hash of block data
-func HashBlock(index int64, previousHash string, timestamp time.Time, merkleRoot string, nonce int64, difficulty int) string {
-	data := fmt.Sprintf("%d%s%d%s%d%d", index, previousHash, timestamp.Unix(), merkleRoot, nonce, difficulty)
+// HashBlock creates a double SHA-256 hash of the serialized block header.
+// Version 2 headers were hashed with a single SHA-256 and older ones from a
+// concatenated decimal string; those forms are kept so existing chains still
+// validate.
+func HashBlock(header *models.BlockHeader) (string, error) {
+	if IsLegacyHeader(header) {
+		return hashBlockLegacy(header), nil
+	}
+
+	data, err := SerializeBlockHeader(header)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+	if header.Version >= compactTargetVersion {
+		hash = sha256.Sum256(hash[:])
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// IsLegacyHeader reports whether a header predates the binary header encoding.
+// Transactions in such blocks were identified and signed under the original
+// string encoding, so their IDs and signatures can't be recomputed.
+func IsLegacyHeader(header *models.BlockHeader) bool {
+	return header.Version < 2
+}
+
+// hashBlockLegacy reproduces the original string-based block hash
+func hashBlockLegacy(header *models.BlockHeader) string {
+	data := fmt.Sprintf("%d%s%d%s%d%d", header.Index, header.PreviousHash, header.Timestamp.Unix(), header.MerkleRoot, header.Nonce, header.Difficulty)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
-// CalculateMerkleRoot calculates the Merkle root from transaction IDs
+// CalculateMerkleRoot calculates the Merkle root from transaction IDs.
+// Leaves are the SHA-256 of each ID and parents the SHA-256 of their children's
+// hex hashes; a level with an odd number of nodes pairs the last one with itself.
 func CalculateMerkleRoot(transactionIDs []string) string {
 	if len(transactionIDs) == 0 {
 		// Empty block - hash of empty string
@@ -23,16 +54,10 @@ func CalculateMerkleRoot(transactionIDs []string) string {
 		return hex.EncodeToString(hash[:])
 	}
 
-	if len(transactionIDs) == 1 {
-		hash := sha256.Sum256([]byte(transactionIDs[0]))
-		return hex.EncodeToString(hash[:])
-	}
-
 	// Build Merkle tree
 	hashes := make([]string, len(transactionIDs))
 	for i, txID := range transactionIDs {
-		hash := sha256.Sum256([]byte(txID))
-		hashes[i] = hex.EncodeToString(hash[:])
+		hashes[i] = merkleLeaf(txID)
 	}
 
 	// Keep hashing pairs until we have one root
@@ -40,15 +65,12 @@ func CalculateMerkleRoot(transactionIDs []string) string {
 		var newLevel []string
 
 		for i := 0; i < len(hashes); i += 2 {
-			var combined string
 			if i+1 < len(hashes) {
-				combined = hashes[i] + hashes[i+1]
+				newLevel = append(newLevel, merkleParent(hashes[i], hashes[i+1]))
 			} else {
 				// Odd number - duplicate the last hash
-				combined = hashes[i] + hashes[i]
+				newLevel = append(newLevel, merkleParent(hashes[i], hashes[i]))
 			}
-			hash := sha256.Sum256([]byte(combined))
-			newLevel = append(newLevel, hex.EncodeToString(hash[:]))
 		}
 
 		hashes = newLevel
@@ -57,27 +79,13 @@ func CalculateMerkleRoot(transactionIDs []string) string {
 	return hashes[0]
 }
 
-// ValidateBlockHash checks if a hash meets the difficulty requirement
+// ValidateBlockHash checks if a hash meets a leading-zero difficulty, the
+// proof-of-work rule for blocks from before compact targets
 func ValidateBlockHash(hash string, difficulty int) bool {
 	prefix := strings.Repeat("0", difficulty)
 	return strings.HasPrefix(hash, prefix)
 }
 
-// MineBlock attempts to find a valid nonce for the block
-// Returns the nonce and hash if successful
-func MineBlock(index int64, previousHash string, timestamp time.Time, merkleRoot string, difficulty int, maxIterations int64) (int64, string, bool) {
-	prefix := strings.Repeat("0", difficulty)
-
-	for nonce := int64(0); nonce < maxIterations; nonce++ {
-		hash := HashBlock(index, previousHash, timestamp, merkleRoot, nonce, difficulty)
-		if strings.HasPrefix(hash, prefix) {
-			return nonce, hash, true
-		}
-	}
-
-	return 0, "", false
-}
-
 // GetGenesisBlockHash returns the hash for the genesis block
 func GetGenesisBlockHash() string {
 	genesisData := "Genesis Block - Crypto Wallet Blockchain - 2025"
@@ -6,85 +6,310 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
-// getEncryptionKey derives a 32-byte key from JWT_SECRET
+// Wallet secrets are stored with envelope encryption. Each secret is encrypted
+// with its own random data key, and the data key is wrapped (encrypted) by a
+// master key. The stored value names the master key that wrapped it:
+//
+//	env1:<master key ID>:<base64 wrapped data key>:<base64 nonce|ciphertext>
+//
+// so the master key can be rotated by re-wrapping data keys (see
+// scripts/rewrap_keys) without touching the ciphertexts or the JWT secret.
+//
+// The master key comes from WALLET_MASTER_KEY (32 bytes, hex or base64) and is
+// required to encrypt anything. Keys being rotated out are listed in
+// WALLET_PREVIOUS_MASTER_KEYS (comma-separated) and are only used to unwrap.
+// The key derived from JWT_SECRET, which encrypted wallets before
+// WALLET_MASTER_KEY existed, is likewise only used to read what it wrote: values
+// in the older format (plain base64 of nonce|ciphertext) and envelopes it
+// wrapped, until scripts/rewrap_keys moves them to the master key.
+
+const (
+	envelopePrefix  = "env1"
+	jwtMasterKeyID  = "jwt"
+	masterKeyLength = 32
+)
+
+var (
+	ErrNoMasterKey        = errors.New("WALLET_MASTER_KEY is not set")
+	ErrUnknownMasterKey   = errors.New("encrypted value is wrapped by an unknown master key")
+	ErrInvalidMasterKey   = errors.New("master key must be 32 bytes, hex or base64 encoded")
+	ErrMalformedEncrypted = errors.New("malformed encrypted value")
+)
+
+// masterKeyring holds the key used to wrap new data keys and every key that
+// may still unwrap existing ones, by key ID. activeID is empty when
+// WALLET_MASTER_KEY isn't set, and nothing can then be wrapped.
+type masterKeyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// getEncryptionKey derives a 32-byte key from JWT_SECRET. It encrypted every
+// wallet before WALLET_MASTER_KEY existed and is now only used to decrypt them.
 func getEncryptionKey() []byte {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
 		secret = "default-encryption-key-change-in-production"
 	}
-	
+
 	// Use SHA-256 to derive a 32-byte key
 	hash := sha256.Sum256([]byte(secret))
 	return hash[:]
 }
 
-// EncryptPrivateKey encrypts the private key using AES-GCM
-func EncryptPrivateKey(privateKeyHex string) (string, error) {
-	key := getEncryptionKey()
-	plaintext := []byte(privateKeyHex)
+// loadMasterKeys reads the master keys from the environment
+func loadMasterKeys() (*masterKeyring, error) {
+	ring := &masterKeyring{
+		keys: map[string][]byte{jwtMasterKeyID: getEncryptionKey()},
+	}
 
-	block, err := aes.NewCipher(key)
+	for _, encoded := range strings.Split(os.Getenv("WALLET_PREVIOUS_MASTER_KEYS"), ",") {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		key, err := parseMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("WALLET_PREVIOUS_MASTER_KEYS: %w", err)
+		}
+		ring.keys[masterKeyID(key)] = key
+	}
+
+	if encoded := os.Getenv("WALLET_MASTER_KEY"); encoded != "" {
+		key, err := parseMasterKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("WALLET_MASTER_KEY: %w", err)
+		}
+		ring.activeID = masterKeyID(key)
+		ring.keys[ring.activeID] = key
+	}
+
+	return ring, nil
+}
+
+func parseMasterKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == masterKeyLength {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == masterKeyLength {
+		return key, nil
+	}
+	return nil, ErrInvalidMasterKey
+}
+
+// masterKeyID identifies a master key by a fingerprint, so the ID can't drift
+// from the key it names
+func masterKeyID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:8])
+}
+
+// ActiveMasterKeyID returns the ID of the master key that wraps new data keys.
+// It fails with ErrNoMasterKey when WALLET_MASTER_KEY isn't set.
+func ActiveMasterKeyID() (string, error) {
+	ring, err := loadMasterKeys()
 	if err != nil {
 		return "", err
 	}
+	if ring.activeID == "" {
+		return "", ErrNoMasterKey
+	}
+	return ring.activeID, nil
+}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+// EncryptPrivateKey encrypts the private key under a fresh data key wrapped by
+// the active master key
+func EncryptPrivateKey(privateKeyHex string) (string, error) {
+	ring, err := loadMasterKeys()
 	if err != nil {
 		return "", err
 	}
 
-	// Generate random nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
 		return "", err
 	}
 
-	// Encrypt and append nonce
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	
-	// Return as base64 string
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	ciphertext, err := sealAESGCM(dataKey, []byte(privateKeyHex), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return wrapEnvelope(ring, dataKey, ciphertext)
 }
 
-// DecryptPrivateKey decrypts the encrypted private key
+// DecryptPrivateKey decrypts a value written by EncryptPrivateKey, or by the
+// older scheme that encrypted directly under the JWT_SECRET key
 func DecryptPrivateKey(encryptedKey string) (string, error) {
-	key := getEncryptionKey()
-	
+	ring, err := loadMasterKeys()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(encryptedKey, envelopePrefix+":") {
+		return decryptLegacy(encryptedKey)
+	}
+
+	_, dataKey, ciphertext, err := openEnvelope(ring, encryptedKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := openAESGCM(dataKey, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// RewrapPrivateKey re-wraps the data key of an encrypted value under the active
+// master key, leaving the ciphertext as it is. Values in the older format are
+// re-encrypted as envelopes. It reports whether the value changed.
+func RewrapPrivateKey(encryptedKey string) (string, bool, error) {
+	ring, err := loadMasterKeys()
+	if err != nil {
+		return "", false, err
+	}
+	if ring.activeID == "" {
+		return "", false, ErrNoMasterKey
+	}
+
+	if !strings.HasPrefix(encryptedKey, envelopePrefix+":") {
+		plaintext, err := decryptLegacy(encryptedKey)
+		if err != nil {
+			return "", false, err
+		}
+		rewrapped, err := EncryptPrivateKey(plaintext)
+		return rewrapped, err == nil, err
+	}
+
+	keyID, dataKey, ciphertext, err := openEnvelope(ring, encryptedKey)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == ring.activeID {
+		return encryptedKey, false, nil
+	}
+
+	rewrapped, err := wrapEnvelope(ring, dataKey, ciphertext)
+	return rewrapped, err == nil, err
+}
+
+// wrapEnvelope wraps the data key with the active master key and formats the envelope
+func wrapEnvelope(ring *masterKeyring, dataKey, ciphertext []byte) (string, error) {
+	if ring.activeID == "" {
+		return "", ErrNoMasterKey
+	}
+
+	// The key ID is authenticated so an envelope can't be relabelled
+	wrappedKey, err := sealAESGCM(ring.keys[ring.activeID], dataKey, []byte(ring.activeID))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		envelopePrefix,
+		ring.activeID,
+		base64.StdEncoding.EncodeToString(wrappedKey),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":"), nil
+}
+
+// openEnvelope parses an envelope and unwraps its data key
+func openEnvelope(ring *masterKeyring, envelope string) (string, []byte, []byte, error) {
+	parts := strings.Split(envelope, ":")
+	if len(parts) != 4 {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+	keyID := parts[1]
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", nil, nil, ErrMalformedEncrypted
+	}
+
+	masterKey, ok := ring.keys[keyID]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, keyID)
+	}
+	dataKey, err := openAESGCM(masterKey, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return keyID, dataKey, ciphertext, nil
+}
+
+// decryptLegacy decrypts a value encrypted directly with the JWT_SECRET key
+func decryptLegacy(encryptedKey string) (string, error) {
 	// Decode base64
 	ciphertext, err := base64.StdEncoding.DecodeString(encryptedKey)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	plaintext, err := openAESGCM(getEncryptionKey(), ciphertext, nil)
 	if err != nil {
 		return "", err
 	}
+	return string(plaintext), nil
+}
 
+// sealAESGCM encrypts with AES-GCM and returns nonce|ciphertext
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create GCM mode
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// Generate random nonce
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	// Encrypt and prepend nonce
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts nonce|ciphertext produced by sealAESGCM
+func openAESGCM(key, ciphertext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
 	}
 
 	// Extract nonce
 	nonceSize := gcm.NonceSize()
 	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	
-	// Decrypt
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", err
-	}
 
-	return string(plaintext), nil
+	// Decrypt
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
 }
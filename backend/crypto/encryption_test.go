@@ -0,0 +1,179 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testMasterKey   = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testPrivateKey  = "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"
+	testJWTSecret   = "test-jwt-secret"
+	testOtherSecret = "rotated-jwt-secret"
+)
+
+// legacyEncrypt encrypts the way wallets were before envelope encryption
+func legacyEncrypt(t *testing.T, plaintext string) string {
+	t.Helper()
+	sealed, err := sealAESGCM(getEncryptionKey(), []byte(plaintext), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sealed)
+}
+
+func TestEncryptionRequiresMasterKey(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("WALLET_MASTER_KEY", "")
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", "")
+
+	if _, err := ActiveMasterKeyID(); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("ActiveMasterKeyID: err = %v, want %v", err, ErrNoMasterKey)
+	}
+	if _, err := EncryptPrivateKey(testPrivateKey); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("EncryptPrivateKey: err = %v, want %v", err, ErrNoMasterKey)
+	}
+
+	// Values from before the master key still decrypt, but aren't rewrapped
+	// under the JWT_SECRET key
+	legacy := legacyEncrypt(t, testPrivateKey)
+	if got, err := DecryptPrivateKey(legacy); err != nil || got != testPrivateKey {
+		t.Errorf("DecryptPrivateKey = %q, %v", got, err)
+	}
+	if _, _, err := RewrapPrivateKey(legacy); !errors.Is(err, ErrNoMasterKey) {
+		t.Errorf("RewrapPrivateKey: err = %v, want %v", err, ErrNoMasterKey)
+	}
+
+	t.Setenv("WALLET_MASTER_KEY", "too short")
+	if _, err := ActiveMasterKeyID(); !errors.Is(err, ErrInvalidMasterKey) {
+		t.Errorf("malformed key: err = %v, want %v", err, ErrInvalidMasterKey)
+	}
+}
+
+func TestEncryptionIgnoresJWTSecretOnceWrapped(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("WALLET_MASTER_KEY", testMasterKey)
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", "")
+
+	encrypted, err := EncryptPrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(encrypted, ":"+jwtMasterKeyID+":") {
+		t.Fatalf("new value %q is wrapped by the JWT_SECRET key", encrypted)
+	}
+
+	t.Setenv("JWT_SECRET", testOtherSecret)
+	if got, err := DecryptPrivateKey(encrypted); err != nil || got != testPrivateKey {
+		t.Fatalf("after rotating JWT_SECRET: DecryptPrivateKey = %q, %v", got, err)
+	}
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("WALLET_MASTER_KEY", testMasterKey)
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", "")
+
+	encrypted, err := EncryptPrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeID, err := ActiveMasterKeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parts := strings.Split(encrypted, ":"); len(parts) != 4 || parts[0] != envelopePrefix || parts[1] != activeID {
+		t.Fatalf("envelope %q isn't env1:%s:<key>:<ciphertext>", encrypted, activeID)
+	}
+	if got, err := DecryptPrivateKey(encrypted); err != nil || got != testPrivateKey {
+		t.Fatalf("DecryptPrivateKey = %q, %v", got, err)
+	}
+
+	// Every value gets its own data key
+	again, err := EncryptPrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Split(again, ":")[2] == strings.Split(encrypted, ":")[2] {
+		t.Error("two values share a wrapped data key")
+	}
+
+	// The key ID is authenticated, so an envelope can't be relabelled
+	parts := strings.Split(encrypted, ":")
+	parts[1] = jwtMasterKeyID
+	if _, err := DecryptPrivateKey(strings.Join(parts, ":")); err == nil {
+		t.Error("decrypted an envelope relabelled with another key ID")
+	}
+	if _, err := DecryptPrivateKey(envelopePrefix + ":" + activeID + ":!!"); !errors.Is(err, ErrMalformedEncrypted) {
+		t.Errorf("malformed envelope: err = %v, want %v", err, ErrMalformedEncrypted)
+	}
+}
+
+func TestRewrapRotatesMasterKey(t *testing.T) {
+	const newMasterKey = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("WALLET_MASTER_KEY", testMasterKey)
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", "")
+
+	legacy := legacyEncrypt(t, testPrivateKey)
+	encrypted, err := EncryptPrivateKey(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Envelopes wrapped by the JWT_SECRET key, as written before the master key was required
+	jwtRing := &masterKeyring{activeID: jwtMasterKeyID, keys: map[string][]byte{jwtMasterKeyID: getEncryptionKey()}}
+	dataKey := make([]byte, 32)
+	ciphertext, err := sealAESGCM(dataKey, []byte(testPrivateKey), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtWrapped, err := wrapEnvelope(jwtRing, dataKey, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: the old key can only unwrap
+	t.Setenv("WALLET_MASTER_KEY", newMasterKey)
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", " ,"+testMasterKey)
+	activeID, err := ActiveMasterKeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rewrapped []string
+	for _, value := range []string{encrypted, legacy, jwtWrapped} {
+		if got, err := DecryptPrivateKey(value); err != nil || got != testPrivateKey {
+			t.Fatalf("before rewrapping %q: DecryptPrivateKey = %q, %v", value, got, err)
+		}
+		updated, changed, err := RewrapPrivateKey(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !changed || !strings.HasPrefix(updated, envelopePrefix+":"+activeID+":") {
+			t.Fatalf("rewrapped %q to %q, changed %v", value, updated, changed)
+		}
+		if same, changed, err := RewrapPrivateKey(updated); err != nil || changed || same != updated {
+			t.Fatalf("rewrapping again changed %q to %q (%v)", updated, same, err)
+		}
+		rewrapped = append(rewrapped, updated)
+	}
+
+	// Only the wrapped data key changes, not the ciphertext
+	if strings.Split(rewrapped[0], ":")[3] != strings.Split(encrypted, ":")[3] {
+		t.Error("rewrapping re-encrypted the value")
+	}
+
+	// Once the old key and JWT_SECRET are gone, rewrapped values still decrypt
+	t.Setenv("WALLET_PREVIOUS_MASTER_KEYS", "")
+	t.Setenv("JWT_SECRET", testOtherSecret)
+	for _, value := range rewrapped {
+		if got, err := DecryptPrivateKey(value); err != nil || got != testPrivateKey {
+			t.Errorf("after dropping the old keys: DecryptPrivateKey = %q, %v", got, err)
+		}
+	}
+	if _, err := DecryptPrivateKey(encrypted); !errors.Is(err, ErrUnknownMasterKey) {
+		t.Errorf("value under a dropped key: err = %v, want %v", err, ErrUnknownMasterKey)
+	}
+}
@@ -0,0 +1,279 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Hierarchical deterministic key derivation following SLIP-0010, the variant of
+// BIP-32 defined for the NIST P-256 curve used by this wallet. Every wallet has a
+// master key and chain code; receive and change addresses are derived from it as
+//
+//	m/0/i   receive address i
+//	m/1/i   change address i
+//
+// secp256k1 keys follow BIP-32 itself. SLIP-0010 only allows hardened derivation
+// for Ed25519, which can't produce the m/0/i and m/1/i chains, so Ed25519 wallets
+// keep their master key without a chain code and use a single address.
+
+// HardenedKeyStart is the first hardened child index (written as i' or iH in paths)
+const HardenedKeyStart uint32 = 0x80000000
+
+// HMAC keys SLIP-0010 uses to derive a master key for each curve
+const (
+	p256SeedKey      = "Nist256p1 seed"
+	secp256k1SeedKey = "Bitcoin seed"
+	ed25519SeedKey   = "ed25519 seed"
+)
+
+var ErrInvalidDerivationPath = errors.New("invalid derivation path")
+
+// ExtendedKey is a private key together with the chain code needed to derive its
+// children. ECDSA keys (P-256 and secp256k1) are held in PrivateKey, Ed25519 keys
+// in Ed25519Key.
+type ExtendedKey struct {
+	KeyType     models.KeyType
+	PrivateKey  *ecdsa.PrivateKey
+	Ed25519Key  ed25519.PrivateKey
+	ChainCode   []byte
+	Depth       uint8
+	ChildNumber uint32
+}
+
+// NewMasterKey derives the master extended key of the given type from a seed
+// (16-64 bytes). An empty key type means P-256.
+func NewMasterKey(seed []byte, keyType models.KeyType) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 16 and 64 bytes")
+	}
+
+	switch keyType = keyType.OrDefault(); keyType {
+	case models.KeyTypeEd25519:
+		// Every 32-byte string is a valid Ed25519 seed, so no retry is needed
+		sum := hmacSHA512([]byte(ed25519SeedKey), seed)
+		return &ExtendedKey{
+			KeyType:    keyType,
+			Ed25519Key: ed25519.NewKeyFromSeed(sum[:32]),
+		}, nil
+	case models.KeyTypeP256:
+		return newECDSAMasterKey(keyType, elliptic.P256(), p256SeedKey, seed), nil
+	case models.KeyTypeSecp256k1:
+		return newECDSAMasterKey(keyType, secp256k1.S256(), secp256k1SeedKey, seed), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
+	}
+}
+
+func newECDSAMasterKey(keyType models.KeyType, curve elliptic.Curve, hmacKey string, seed []byte) *ExtendedKey {
+	n := curve.Params().N
+
+	sum := hmacSHA512([]byte(hmacKey), seed)
+
+	// Retry with the previous output in the (astronomically unlikely) case the
+	// left half isn't a valid scalar
+	d := new(big.Int).SetBytes(sum[:32])
+	for d.Sign() == 0 || d.Cmp(n) >= 0 {
+		sum = hmacSHA512([]byte(hmacKey), sum)
+		d.SetBytes(sum[:32])
+	}
+
+	return &ExtendedKey{
+		KeyType:    keyType,
+		PrivateKey: privateKeyFromScalar(curve, d),
+		ChainCode:  sum[32:],
+	}
+}
+
+// GenerateMasterKey creates a master key from a fresh random seed, for wallets
+// created without a mnemonic
+func GenerateMasterKey(keyType models.KeyType) (*ExtendedKey, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return NewMasterKey(seed, keyType)
+}
+
+// NewExtendedKey rebuilds an extended key from a stored private key and chain code.
+// An empty chain code gives a key that can sign but not derive children, as held
+// by wallets created before HD support and by Ed25519 wallets.
+func NewExtendedKey(keyType models.KeyType, privateKeyHex, chainCodeHex string) (*ExtendedKey, error) {
+	key, err := extendedKeyFromHex(keyType.OrDefault(), privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	if chainCodeHex == "" {
+		return key, nil
+	}
+	if key.KeyType == models.KeyTypeEd25519 {
+		return nil, errors.New("ed25519 keys do not have a chain code")
+	}
+
+	chainCode, err := hex.DecodeString(chainCodeHex)
+	if err != nil || len(chainCode) != 32 {
+		return nil, errors.New("invalid chain code")
+	}
+	key.ChainCode = chainCode
+
+	return key, nil
+}
+
+// extendedKeyFromHex parses a private key in the hex encoding of its key type
+func extendedKeyFromHex(keyType models.KeyType, privateKeyHex string) (*ExtendedKey, error) {
+	switch keyType {
+	case models.KeyTypeP256:
+		privateKey, err := PrivateKeyFromHex(privateKeyHex)
+		if err != nil {
+			return nil, err
+		}
+		return &ExtendedKey{KeyType: keyType, PrivateKey: privateKey}, nil
+	case models.KeyTypeSecp256k1:
+		scalar, err := hex.DecodeString(privateKeyHex)
+		if err != nil || len(scalar) != 32 {
+			return nil, errors.New("secp256k1 private key must be 32 bytes of hex")
+		}
+		curve := secp256k1.S256()
+		d := new(big.Int).SetBytes(scalar)
+		if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+			return nil, errors.New("secp256k1 private key is out of range")
+		}
+		return &ExtendedKey{KeyType: keyType, PrivateKey: privateKeyFromScalar(curve, d)}, nil
+	case models.KeyTypeEd25519:
+		seed, err := hex.DecodeString(privateKeyHex)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("Ed25519 private key must be a 32-byte hex seed")
+		}
+		return &ExtendedKey{KeyType: keyType, Ed25519Key: ed25519.NewKeyFromSeed(seed)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyType, keyType)
+	}
+}
+
+// Child derives the child key at index. Indexes from HardenedKeyStart upwards
+// are hardened: their derivation uses the private key rather than the public key.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.Depth == 255 {
+		return nil, errors.New("maximum derivation depth reached")
+	}
+	if len(k.ChainCode) == 0 || k.PrivateKey == nil {
+		return nil, errors.New("key has no chain code and cannot derive children")
+	}
+
+	curve := k.PrivateKey.Curve
+	n := curve.Params().N
+
+	data := make([]byte, 0, 37)
+	if index >= HardenedKeyStart {
+		data = append(data, 0x00)
+		data = append(data, k.PrivateKey.D.FillBytes(make([]byte, 32))...)
+	} else {
+		data = append(data, elliptic.MarshalCompressed(curve, k.PrivateKey.X, k.PrivateKey.Y)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	for {
+		sum := hmacSHA512(k.ChainCode, data)
+
+		il := new(big.Int).SetBytes(sum[:32])
+		d := new(big.Int).Add(il, k.PrivateKey.D)
+		d.Mod(d, n)
+
+		if il.Cmp(n) < 0 && d.Sign() != 0 {
+			return &ExtendedKey{
+				KeyType:     k.KeyType,
+				PrivateKey:  privateKeyFromScalar(curve, d),
+				ChainCode:   sum[32:],
+				Depth:       k.Depth + 1,
+				ChildNumber: index,
+			}, nil
+		}
+
+		// Invalid key: SLIP-0010 retries with 0x01 || IR || index
+		data = append([]byte{0x01}, sum[32:]...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// Derive walks a path such as "m/0/5" or "m/44'/0'/0'" from this key
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// KeyPair returns the extended key's private and public keys in the usual hex encodings
+func (k *ExtendedKey) KeyPair() (*KeyPair, error) {
+	if k.Ed25519Key != nil {
+		return NewEd25519KeyPair(k.Ed25519Key), nil
+	}
+	return NewKeyPair(k.PrivateKey)
+}
+
+// ChainCodeHex returns the chain code as a hex string for storage
+func (k *ExtendedKey) ChainCodeHex() string {
+	return hex.EncodeToString(k.ChainCode)
+}
+
+// ParseDerivationPath converts a path such as "m/0'/1/2" into child indexes.
+// Hardened indexes may be marked with ' or H.
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("%w: must start with m", ErrInvalidDerivationPath)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+
+		value, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(value) >= HardenedKeyStart {
+			return nil, fmt.Errorf("%w: bad index %q", ErrInvalidDerivationPath, part)
+		}
+
+		index := uint32(value)
+		if hardened {
+			index += HardenedKeyStart
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// AddressPath returns the derivation path of address index on the given chain
+func AddressPath(chain, index uint32) string {
+	return fmt.Sprintf("m/%d/%d", chain, index)
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
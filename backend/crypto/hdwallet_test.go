@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"crypto/elliptic"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// hdVector is one key of a SLIP-0010 or BIP-32 test vector chain
+type hdVector struct {
+	path      string
+	chainCode string
+	private   string
+	public    string // Compressed
+}
+
+func checkHDVectors(t *testing.T, keyType models.KeyType, seedHex string, vectors []hdVector) {
+	t.Helper()
+	seed, _ := hex.DecodeString(seedHex)
+	master, err := NewMasterKey(seed, keyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		key, err := master.Derive(v.path)
+		if err != nil {
+			t.Fatalf("%s: %v", v.path, err)
+		}
+		private := hex.EncodeToString(key.PrivateKey.D.FillBytes(make([]byte, 32)))
+		public := hex.EncodeToString(elliptic.MarshalCompressed(key.PrivateKey.Curve, key.PrivateKey.X, key.PrivateKey.Y))
+		if key.ChainCodeHex() != v.chainCode || private != v.private || public != v.public {
+			t.Errorf("%s: chain code %s, private key %s, public key %s; want %s, %s, %s",
+				v.path, key.ChainCodeHex(), private, public, v.chainCode, v.private, v.public)
+		}
+	}
+}
+
+func TestBIP32Vectors(t *testing.T) {
+	// BIP-32 test vector 1
+	checkHDVectors(t, models.KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", []hdVector{
+		{"m", "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+			"e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+			"0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2"},
+		{"m/0'", "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+			"edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+			"035a784662a4a20a65bf6aab9ae98a6c068a81c52e4b032c0fb5400c706cfccc56"},
+		{"m/0'/1", "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19",
+			"3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+			"03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c"},
+		{"m/0'/1/2'", "04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f",
+			"cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca",
+			"0357bfe1e341d01c69fe5654309956cbea516822fba8a601743a012a7896ee8dc2"},
+		{"m/0'/1/2'/2", "cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd",
+			"0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4",
+			"02e8445082a72f29b75ca48748a914df60622a609cacfce8ed0e35804560741d29"},
+		{"m/0'/1/2'/2/1000000000", "c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e",
+			"471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+			"022a471424da5e657499d1ff51cb43c47481a03b1e77f951fe64cec9f5a48f7011"},
+	})
+}
+
+func TestSLIP10P256Vectors(t *testing.T) {
+	// SLIP-0010 test vector 1 for nist256p1
+	checkHDVectors(t, models.KeyTypeP256, "000102030405060708090a0b0c0d0e0f", []hdVector{
+		{"m", "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			"0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8"},
+		{"m/0'", "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			"0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c"},
+		{"m/0'/1", "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			"03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844"},
+		{"m/0'/1/2'", "98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+			"694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7",
+			"0359cf160040778a4b14c5f4d7b76e327ccc8c4a6086dd9451b7482b5a4972dda0"},
+		{"m/0'/1/2'/2", "ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0",
+			"5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa",
+			"029f871f4cb9e1c97f9f4de9ccd0d4a2f2a171110c61178f84430062230833ff20"},
+	})
+}
+
+func TestSLIP10Ed25519MasterKey(t *testing.T) {
+	// SLIP-0010 test vector 1 for ed25519. Ed25519 wallets keep only the master
+	// key, so its chain code isn't stored.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed, models.KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := master.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyPair.PrivateKeyHex != "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7" ||
+		keyPair.PublicKeyHex != "a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed" {
+		t.Fatalf("master key = %s, %s", keyPair.PrivateKeyHex, keyPair.PublicKeyHex)
+	}
+	if _, err := master.Child(HardenedKeyStart); err == nil {
+		t.Error("derived a child of an Ed25519 key")
+	}
+}
+
+func TestParseDerivationPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []uint32
+	}{
+		{"m", []uint32{}},
+		{"m/0/5", []uint32{0, 5}},
+		{"m/44'/0H/2147483647'", []uint32{HardenedKeyStart + 44, HardenedKeyStart, HardenedKeyStart + 2147483647}},
+	}
+	for _, tt := range tests {
+		got, err := ParseDerivationPath(tt.path)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDerivationPath(%q) = %v, %v, want %v", tt.path, got, err, tt.want)
+		}
+	}
+
+	for _, path := range []string{"", "0/1", "m/", "m/-1", "m/2147483648", "m/1''", "m/x"} {
+		if _, err := ParseDerivationPath(path); !errors.Is(err, ErrInvalidDerivationPath) {
+			t.Errorf("ParseDerivationPath(%q): err = %v, want %v", path, err, ErrInvalidDerivationPath)
+		}
+	}
+}
@@ -1,24 +1,41 @@
 package crypto
 
 import (
+	"crypto-wallet-backend/models"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
-// KeyPair represents a public/private key pair
+// KeyPair represents a public/private key pair. The hex encodings depend on the
+// key type:
+//
+//	p256       SEC 1 DER private key, PKIX DER public key
+//	secp256k1  32-byte private scalar, 33-byte compressed public key
+//	ed25519    32-byte private seed, 32-byte public key
+//
+// so the type of a public key can be told from its encoding (see KeyTypeOf).
 type KeyPair struct {
-	PrivateKey    *ecdsa.PrivateKey
-	PublicKey     *ecdsa.PublicKey
+	KeyType       models.KeyType
+	PrivateKey    *ecdsa.PrivateKey  // P-256 and secp256k1
+	PublicKey     *ecdsa.PublicKey   // P-256 and secp256k1
+	Ed25519Key    ed25519.PrivateKey // Ed25519
 	PrivateKeyHex string
 	PublicKeyHex  string
 }
 
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
 // GenerateKeyPair generates a new ECDSA key pair using P-256 curve
 func GenerateKeyPair() (*KeyPair, error) {
 	// Generate private key using P-256 curve (secp256r1)
@@ -27,9 +44,40 @@ func GenerateKeyPair() (*KeyPair, error) {
 		return nil, err
 	}
 
+	return NewKeyPair(privateKey)
+}
+
+// KeyPairFromSeed deterministically derives a P-256 key pair from a BIP-39 seed.
+// The key is the wallet's HD master key, so the same mnemonic and passphrase
+// always restore the same wallet.
+func KeyPairFromSeed(seed []byte) (*KeyPair, error) {
+	master, err := NewMasterKey(seed, models.KeyTypeP256)
+	if err != nil {
+		return nil, err
+	}
+
+	return master.KeyPair()
+}
+
+// NewKeyPair wraps an existing ECDSA private key with its hex encodings. The key
+// must be on P-256 or secp256k1.
+func NewKeyPair(privateKey *ecdsa.PrivateKey) (*KeyPair, error) {
 	// Get public key
 	publicKey := &privateKey.PublicKey
 
+	if privateKey.Curve == secp256k1.S256() {
+		return &KeyPair{
+			KeyType:       models.KeyTypeSecp256k1,
+			PrivateKey:    privateKey,
+			PublicKey:     publicKey,
+			PrivateKeyHex: hex.EncodeToString(privateKey.D.FillBytes(make([]byte, 32))),
+			PublicKeyHex:  hex.EncodeToString(elliptic.MarshalCompressed(privateKey.Curve, publicKey.X, publicKey.Y)),
+		}, nil
+	}
+	if privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKeyType, privateKey.Curve.Params().Name)
+	}
+
 	// Convert private key to hex
 	privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey)
 	if err != nil {
@@ -45,6 +93,7 @@ func GenerateKeyPair() (*KeyPair, error) {
 	publicKeyHex := hex.EncodeToString(publicKeyBytes)
 
 	return &KeyPair{
+		KeyType:       models.KeyTypeP256,
 		PrivateKey:    privateKey,
 		PublicKey:     publicKey,
 		PrivateKeyHex: privateKeyHex,
@@ -52,18 +101,59 @@ func GenerateKeyPair() (*KeyPair, error) {
 	}, nil
 }
 
-// GenerateWalletID creates a wallet ID by hashing the public key
+// NewEd25519KeyPair wraps an Ed25519 private key with its hex encodings
+func NewEd25519KeyPair(privateKey ed25519.PrivateKey) *KeyPair {
+	return &KeyPair{
+		KeyType:       models.KeyTypeEd25519,
+		Ed25519Key:    privateKey,
+		PrivateKeyHex: hex.EncodeToString(privateKey.Seed()),
+		PublicKeyHex:  hex.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+	}
+}
+
+// SignDigest signs a 32-byte digest with the key pair and returns the signature
+// as hex. ECDSA keys sign the digest directly; Ed25519 signs it as its message.
+func (kp *KeyPair) SignDigest(digest []byte) (string, error) {
+	if kp.Ed25519Key == nil {
+		return SignDigest(kp.PrivateKey, digest)
+	}
+	if len(digest) != 32 {
+		return "", errors.New("digest must be 32 bytes")
+	}
+	return hex.EncodeToString(ed25519.Sign(kp.Ed25519Key, digest)), nil
+}
+
+// KeyTypeOf reports the key type of a hex-encoded public key from its encoding
+func KeyTypeOf(publicKeyHex string) (models.KeyType, error) {
+	publicKeyBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(publicKeyBytes) == ed25519.PublicKeySize:
+		return models.KeyTypeEd25519, nil
+	case len(publicKeyBytes) == 33 && (publicKeyBytes[0] == 0x02 || publicKeyBytes[0] == 0x03):
+		return models.KeyTypeSecp256k1, nil
+	case len(publicKeyBytes) > 0 && publicKeyBytes[0] == 0x30:
+		return models.KeyTypeP256, nil
+	default:
+		return "", errors.New("unrecognized public key encoding")
+	}
+}
+
+// privateKeyFromScalar builds a private key from its secret scalar
+func privateKeyFromScalar(curve elliptic.Curve, d *big.Int) *ecdsa.PrivateKey {
+	privateKey := &ecdsa.PrivateKey{D: new(big.Int).Set(d)}
+	privateKey.PublicKey.Curve = curve
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+	return privateKey
+}
+
+// GenerateWalletID creates a wallet ID by hashing the public key and encoding
+// the hash as a checksummed Base58Check address for the active network
 func GenerateWalletID(publicKeyHex string) string {
-	// First SHA-256 hash
-	hash := sha256.Sum256([]byte(publicKeyHex))
-	
-	// Second SHA-256 hash (double hashing for extra security)
-	hash2 := sha256.Sum256(hash[:])
-	
-	// Take first 20 bytes and convert to hex (40 characters)
-	walletID := hex.EncodeToString(hash2[:20])
-	
-	return walletID
+	return EncodeAddress(ActiveAddressVersion(), PublicKeyHash(publicKeyHex))
 }
 
 // PrivateKeyFromHex reconstructs a private key from hex string
@@ -72,12 +162,12 @@ func PrivateKeyFromHex(privateKeyHex string) (*ecdsa.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	
+
 	privateKey, err := x509.ParseECPrivateKey(privateKeyBytes)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return privateKey, nil
 }
 
@@ -87,32 +177,58 @@ func PublicKeyFromHex(publicKeyHex string) (*ecdsa.PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	
+
 	publicKeyInterface, err := x509.ParsePKIXPublicKey(publicKeyBytes)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	publicKey, ok := publicKeyInterface.(*ecdsa.PublicKey)
 	if !ok {
 		return nil, errors.New("not an ECDSA public key")
 	}
-	
+
 	return publicKey, nil
 }
 
+// ValidatePublicKey checks that a hex public key is a valid key of one of the
+// supported key types
+func ValidatePublicKey(publicKeyHex string) error {
+	keyType, err := KeyTypeOf(publicKeyHex)
+	if err != nil {
+		return err
+	}
+
+	switch keyType {
+	case models.KeyTypeP256:
+		publicKey, err := PublicKeyFromHex(publicKeyHex)
+		if err != nil {
+			return err
+		}
+		if publicKey.Curve != elliptic.P256() {
+			return ErrUnsupportedCurve
+		}
+	case models.KeyTypeSecp256k1:
+		publicKeyBytes, _ := hex.DecodeString(publicKeyHex)
+		if _, err := secp256k1.ParsePubKey(publicKeyBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PrivateKeyToPEM converts private key to PEM format
 func PrivateKeyToPEM(privateKey *ecdsa.PrivateKey) (string, error) {
 	privateKeyBytes, err := x509.MarshalECPrivateKey(privateKey)
 	if err != nil {
 		return "", err
 	}
-	
+
 	pemBlock := &pem.Block{
 		Type:  "EC PRIVATE KEY",
 		Bytes: privateKeyBytes,
 	}
-	
+
 	return string(pem.EncodeToMemory(pemBlock)), nil
 }
 
@@ -122,11 +238,92 @@ func PublicKeyToPEM(publicKey *ecdsa.PublicKey) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	
+
 	pemBlock := &pem.Block{
 		Type:  "PUBLIC KEY",
 		Bytes: publicKeyBytes,
 	}
-	
+
 	return string(pem.EncodeToMemory(pemBlock)), nil
 }
+
+// ErrUnsupportedCurve is returned by ParsePrivateKey for keys that aren't on the P-256 curve
+var ErrUnsupportedCurve = errors.New("private key must use the P-256 curve")
+
+// ImportKey reads an externally generated private key of the given type:
+// P-256 keys as accepted by ParsePrivateKey, secp256k1 keys as a hex 32-byte
+// scalar, and Ed25519 keys as a hex 32-byte seed or a PKCS #8 PEM block. The
+// key has no chain code.
+func ImportKey(keyType models.KeyType, encoded string) (*ExtendedKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	switch keyType = keyType.OrDefault(); keyType {
+	case models.KeyTypeP256:
+		privateKey, err := ParsePrivateKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		return &ExtendedKey{KeyType: keyType, PrivateKey: privateKey}, nil
+	case models.KeyTypeEd25519:
+		if !strings.HasPrefix(encoded, "-----BEGIN") {
+			break
+		}
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil || block.Type != "PRIVATE KEY" {
+			return nil, errors.New("Ed25519 keys must be a hex seed or a PKCS #8 PEM block")
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, errors.New("unable to parse private key: " + err.Error())
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an Ed25519 private key")
+		}
+		return &ExtendedKey{KeyType: keyType, Ed25519Key: privateKey}, nil
+	}
+
+	return extendedKeyFromHex(keyType, strings.TrimPrefix(encoded, "0x"))
+}
+
+// ParsePrivateKey reads a private key given as a PEM block or as hex-encoded DER,
+// in either SEC 1 ("EC PRIVATE KEY") or PKCS #8 ("PRIVATE KEY") form. Only P-256
+// keys are accepted since wallet signatures use that curve.
+func ParsePrivateKey(encoded string) (*ecdsa.PrivateKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	var der []byte
+	if strings.HasPrefix(encoded, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, errors.New("invalid PEM block")
+		}
+		if block.Type != "EC PRIVATE KEY" && block.Type != "PRIVATE KEY" {
+			return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+		}
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+		if err != nil {
+			return nil, errors.New("private key must be PEM or hex-encoded DER")
+		}
+	}
+
+	privateKey, err := x509.ParseECPrivateKey(der)
+	if err != nil {
+		key, pkcs8Err := x509.ParsePKCS8PrivateKey(der)
+		if pkcs8Err != nil {
+			return nil, errors.New("unable to parse private key: " + err.Error())
+		}
+		var ok bool
+		if privateKey, ok = key.(*ecdsa.PrivateKey); !ok {
+			return nil, errors.New("not an ECDSA private key")
+		}
+	}
+
+	if privateKey.Curve != elliptic.P256() {
+		return nil, ErrUnsupportedCurve
+	}
+	return privateKey, nil
+}
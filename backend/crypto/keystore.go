@@ -0,0 +1,207 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Keystore files hold a wallet's keys encrypted under a passphrase chosen by the
+// user, so a backup can be stored or moved without exposing the private key.
+// The passphrase is stretched with scrypt and the keys are sealed with AES-256-GCM,
+// using the wallet ID as additional data so it can't be swapped without detection.
+
+// KeystoreVersion is the version written to new keystore files
+const KeystoreVersion = 1
+
+// Default scrypt cost parameters for new keystores (32 MiB of memory)
+const (
+	keystoreScryptN    = 1 << 15
+	keystoreScryptR    = 8
+	keystoreScryptP    = 1
+	keystoreKeyLength  = 32
+	keystoreSaltLength = 32
+	keystoreCipher     = "aes-256-gcm"
+	keystoreKDF        = "scrypt"
+)
+
+// Limits on the scrypt parameters of an uploaded keystore. scrypt needs
+// 128·N·r bytes of memory, and p multiplies the time it takes.
+const (
+	maxKeystoreScryptMemory = 64 << 20
+	maxKeystoreScryptP      = 16
+)
+
+var (
+	ErrKeystorePassphrase  = errors.New("incorrect keystore passphrase")
+	ErrUnsupportedKeystore = errors.New("unsupported keystore format")
+)
+
+// Keystore is the JSON document produced by EncryptKeystore
+type Keystore struct {
+	Version  int            `json:"version"`
+	ID       string         `json:"id"`
+	WalletID string         `json:"walletId"`
+	Crypto   KeystoreCrypto `json:"crypto"`
+}
+
+// KeystoreCrypto describes how the keystore's secret was encrypted
+type KeystoreCrypto struct {
+	Cipher       string               `json:"cipher"`
+	CipherText   string               `json:"ciphertext"`
+	CipherParams KeystoreCipherParams `json:"cipherparams"`
+	KDF          string               `json:"kdf"`
+	KDFParams    KeystoreKDFParams    `json:"kdfparams"`
+}
+
+// KeystoreCipherParams holds the AES-GCM nonce
+type KeystoreCipherParams struct {
+	Nonce string `json:"nonce"`
+}
+
+// KeystoreKDFParams holds the scrypt parameters used to derive the encryption key
+type KeystoreKDFParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// KeystoreKey is the plaintext sealed inside a keystore. The chain code is empty
+// for wallets that don't support HD derivation, and the key type is empty in
+// keystores written before other key types than P-256 existed.
+type KeystoreKey struct {
+	KeyType    models.KeyType `json:"keyType,omitempty"`
+	PrivateKey string         `json:"privateKey"`
+	ChainCode  string         `json:"chainCode,omitempty"`
+}
+
+// EncryptKeystore seals a wallet's private key and chain code under passphrase
+func EncryptKeystore(walletID string, key KeystoreKey, passphrase string) (*Keystore, error) {
+	salt := make([]byte, keystoreSaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	params := KeystoreKDFParams{
+		N:     keystoreScryptN,
+		R:     keystoreScryptR,
+		P:     keystoreScryptP,
+		DKLen: keystoreKeyLength,
+		Salt:  hex.EncodeToString(salt),
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newKeystoreGCM(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, []byte(walletID))
+
+	return &Keystore{
+		Version:  KeystoreVersion,
+		ID:       uuid.New().String(),
+		WalletID: walletID,
+		Crypto: KeystoreCrypto{
+			Cipher:       keystoreCipher,
+			CipherText:   hex.EncodeToString(ciphertext),
+			CipherParams: KeystoreCipherParams{Nonce: hex.EncodeToString(nonce)},
+			KDF:          keystoreKDF,
+			KDFParams:    params,
+		},
+	}, nil
+}
+
+// DecryptKeystore opens a keystore with passphrase and returns the key it holds
+func DecryptKeystore(ks *Keystore, passphrase string) (*KeystoreKey, error) {
+	if ks.Version != KeystoreVersion || ks.Crypto.Cipher != keystoreCipher || ks.Crypto.KDF != keystoreKDF {
+		return nil, ErrUnsupportedKeystore
+	}
+
+	params := ks.Crypto.KDFParams
+	if err := checkKeystoreKDFParams(params); err != nil {
+		return nil, err
+	}
+
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("%w: invalid salt", ErrUnsupportedKeystore)
+	}
+	nonce, err := hex.DecodeString(ks.Crypto.CipherParams.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrUnsupportedKeystore)
+	}
+	ciphertext, err := hex.DecodeString(ks.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ciphertext", ErrUnsupportedKeystore)
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, params.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newKeystoreGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrUnsupportedKeystore)
+	}
+
+	// A wrong passphrase and a tampered file both fail authentication
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(ks.WalletID))
+	if err != nil {
+		return nil, ErrKeystorePassphrase
+	}
+
+	var secret KeystoreKey
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload", ErrUnsupportedKeystore)
+	}
+	return &secret, nil
+}
+
+// checkKeystoreKDFParams bounds the cost of deriving a key with params, which
+// come from an uploaded file
+func checkKeystoreKDFParams(params KeystoreKDFParams) error {
+	if params.N <= 1 || params.N&(params.N-1) != 0 || params.R <= 0 ||
+		params.P <= 0 || params.P > maxKeystoreScryptP || params.DKLen != keystoreKeyLength {
+		return fmt.Errorf("%w: invalid scrypt parameters", ErrUnsupportedKeystore)
+	}
+	// Divide rather than multiply so large values can't overflow
+	if params.N > maxKeystoreScryptMemory/128 || params.R > maxKeystoreScryptMemory/128/params.N {
+		return fmt.Errorf("%w: scrypt parameters need more than %d MiB of memory", ErrUnsupportedKeystore, maxKeystoreScryptMemory>>20)
+	}
+	return nil
+}
+
+func newKeystoreGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
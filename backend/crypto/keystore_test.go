@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"errors"
+	"testing"
+)
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	key := KeystoreKey{
+		KeyType:    models.KeyTypeSecp256k1,
+		PrivateKey: "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		ChainCode:  "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+	}
+	ks, err := EncryptKeystore("wallet", key, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.Version != KeystoreVersion || ks.WalletID != "wallet" || ks.Crypto.KDFParams.N != keystoreScryptN {
+		t.Fatalf("unexpected keystore header %+v", ks)
+	}
+
+	got, err := DecryptKeystore(ks, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != key {
+		t.Fatalf("decrypted %+v, want %+v", *got, key)
+	}
+
+	if _, err := DecryptKeystore(ks, "wrong horse"); !errors.Is(err, ErrKeystorePassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want %v", err, ErrKeystorePassphrase)
+	}
+
+	// The wallet ID is authenticated, so a file can't be moved to another wallet
+	moved := *ks
+	moved.WalletID = "other"
+	if _, err := DecryptKeystore(&moved, "correct horse"); !errors.Is(err, ErrKeystorePassphrase) {
+		t.Errorf("changed wallet ID: err = %v, want %v", err, ErrKeystorePassphrase)
+	}
+
+	// Every keystore gets a fresh salt and nonce
+	again, err := EncryptKeystore("wallet", key, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Crypto.KDFParams.Salt == ks.Crypto.KDFParams.Salt || again.Crypto.CipherText == ks.Crypto.CipherText {
+		t.Error("two keystores of the same key share a salt or ciphertext")
+	}
+}
+
+func TestKeystoreKDFParamsLimits(t *testing.T) {
+	valid := KeystoreKDFParams{N: keystoreScryptN, R: keystoreScryptR, P: keystoreScryptP, DKLen: keystoreKeyLength}
+	tests := []struct {
+		name   string
+		modify func(p *KeystoreKDFParams)
+		ok     bool
+	}{
+		{"defaults", func(p *KeystoreKDFParams) {}, true},
+		{"exactly 64 MiB", func(p *KeystoreKDFParams) { p.N, p.R = 1<<16, 8 }, true},
+		{"largest N with r=1", func(p *KeystoreKDFParams) { p.N, p.R = 1<<19, 1 }, true},
+		{"largest p", func(p *KeystoreKDFParams) { p.P = maxKeystoreScryptP }, true},
+		{"8 GiB", func(p *KeystoreKDFParams) { p.N, p.R = 1<<20, 64 }, false},
+		{"just over 64 MiB", func(p *KeystoreKDFParams) { p.N, p.R = 1<<16, 9 }, false},
+		{"N alone over the limit", func(p *KeystoreKDFParams) { p.N, p.R = 1<<20, 1 }, false},
+		{"r that overflows 128·N·r", func(p *KeystoreKDFParams) { p.R = 1 << 62 }, false},
+		{"p over the limit", func(p *KeystoreKDFParams) { p.P = maxKeystoreScryptP + 1 }, false},
+		{"N not a power of two", func(p *KeystoreKDFParams) { p.N = 3 << 10 }, false},
+		{"N of one", func(p *KeystoreKDFParams) { p.N = 1 }, false},
+		{"zero r", func(p *KeystoreKDFParams) { p.R = 0 }, false},
+		{"zero p", func(p *KeystoreKDFParams) { p.P = 0 }, false},
+		{"short key", func(p *KeystoreKDFParams) { p.DKLen = 16 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			tt.modify(&params)
+			err := checkKeystoreKDFParams(params)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrUnsupportedKeystore) {
+				t.Fatalf("err = %v, want %v", err, ErrUnsupportedKeystore)
+			}
+		})
+	}
+}
+
+func TestDecryptKeystoreRejectsOversizedKDFParams(t *testing.T) {
+	// An uploaded file asking for N=2^20, r=64 would need 8 GiB; it must be
+	// refused before scrypt runs
+	ks := &Keystore{
+		Version:  KeystoreVersion,
+		WalletID: "wallet",
+		Crypto: KeystoreCrypto{
+			Cipher:       keystoreCipher,
+			CipherText:   "00",
+			CipherParams: KeystoreCipherParams{Nonce: "000000000000000000000000"},
+			KDF:          keystoreKDF,
+			KDFParams:    KeystoreKDFParams{N: 1 << 20, R: 64, P: 1, DKLen: keystoreKeyLength, Salt: "00"},
+		},
+	}
+	if _, err := DecryptKeystore(ks, "passphrase"); !errors.Is(err, ErrUnsupportedKeystore) {
+		t.Fatalf("err = %v, want %v", err, ErrUnsupportedKeystore)
+	}
+}
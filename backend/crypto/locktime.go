@@ -0,0 +1,62 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"errors"
+	"fmt"
+)
+
+const (
+	// LockTimeThreshold separates block heights from Unix times in lock values:
+	// smaller values are heights, larger ones are timestamps
+	LockTimeThreshold int64 = 500000000
+
+	// MaxRelativeLock is the longest relative lock an output can carry, in blocks
+	MaxRelativeLock int64 = 0xffff
+)
+
+// LockTimeReached reports whether a lock value allows inclusion in the block at
+// height mined at unixTime. Zero never locks.
+func LockTimeReached(lockTime, height, unixTime int64) bool {
+	if lockTime <= 0 {
+		return true
+	}
+	if lockTime < LockTimeThreshold {
+		return height >= lockTime
+	}
+	return unixTime >= lockTime
+}
+
+// IsFinalTransaction reports whether tx's lock time allows it into the block at
+// height mined at unixTime
+func IsFinalTransaction(tx *models.Transaction, height, unixTime int64) bool {
+	return LockTimeReached(tx.LockTime, height, unixTime)
+}
+
+// UTXOUnlocked reports whether an output's time locks allow spending it in the
+// block at height mined at unixTime. An output with a relative lock must have
+// been confirmed at least RelativeLock blocks earlier.
+func UTXOUnlocked(utxo *models.UTXO, height, unixTime int64) bool {
+	if !LockTimeReached(utxo.LockUntil, height, unixTime) {
+		return false
+	}
+	if utxo.RelativeLock > 0 {
+		return utxo.IsConfirmed && utxo.BlockHeight+utxo.RelativeLock <= height
+	}
+	return true
+}
+
+// ValidateTimeLocks checks the lock values of a payment before they are built
+// into a transaction
+func ValidateTimeLocks(lockTime, lockUntil, relativeLock int64) error {
+	if lockTime < 0 {
+		return errors.New("lock time must not be negative")
+	}
+	if lockUntil < 0 {
+		return errors.New("output lock must not be negative")
+	}
+	if relativeLock < 0 || relativeLock > MaxRelativeLock {
+		return fmt.Errorf("relative lock must be between 0 and %d blocks", MaxRelativeLock)
+	}
+	return nil
+}
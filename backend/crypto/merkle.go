@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// coinbaseCommitmentVersion is the first header version whose Merkle root
+// commits to the block's coinbase
+const coinbaseCommitmentVersion = 4
+
+// merkleLeaf hashes a transaction ID into a leaf of the block's Merkle tree
+func merkleLeaf(txID string) string {
+	hash := sha256.Sum256([]byte(txID))
+	return hex.EncodeToString(hash[:])
+}
+
+// merkleParent hashes two sibling nodes, given as hex, into their parent
+func merkleParent(left, right string) string {
+	hash := sha256.Sum256([]byte(left + right))
+	return hex.EncodeToString(hash[:])
+}
+
+// BlockMerkleLeaves returns the transaction IDs a block's Merkle root is built
+// from, in order. From coinbaseCommitmentVersion the coinbase comes first.
+func BlockMerkleLeaves(block *models.Block) []string {
+	txIDs := make([]string, 0, len(block.Transactions)+1)
+	if block.Coinbase != nil {
+		txIDs = append(txIDs, block.Coinbase.TransactionID)
+	}
+	for _, tx := range block.Transactions {
+		txIDs = append(txIDs, tx.TransactionID)
+	}
+	return txIDs
+}
+
+// CommitsToCoinbase reports whether a header's Merkle root must include a
+// coinbase
+func CommitsToCoinbase(header *models.BlockHeader) bool {
+	return header.Version >= coinbaseCommitmentVersion
+}
+
+// MerkleBranch builds the inclusion proof for txID in a block whose transaction
+// IDs are transactionIDs, in block order. The proof has the same shape as the
+// tree CalculateMerkleRoot builds: when a level has an odd number of nodes the
+// last one is paired with itself, so its branch entry is the node's own hash.
+func MerkleBranch(transactionIDs []string, txID string) (*models.MerkleProof, error) {
+	index := -1
+	level := make([]string, len(transactionIDs))
+	for i, id := range transactionIDs {
+		if id == txID && index < 0 {
+			index = i
+		}
+		level[i] = merkleLeaf(id)
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("transaction %s is not in the block", txID)
+	}
+
+	proof := &models.MerkleProof{
+		TransactionID: txID,
+		Index:         index,
+		LeafCount:     len(transactionIDs),
+		Branch:        []string{},
+	}
+
+	for position := index; len(level) > 1; position /= 2 {
+		sibling := position ^ 1
+		if sibling >= len(level) {
+			sibling = position
+		}
+		proof.Branch = append(proof.Branch, level[sibling])
+
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, merkleParent(level[i], right))
+		}
+		level = next
+	}
+
+	proof.MerkleRoot = level[0]
+	return proof, nil
+}
+
+// MerkleProofRoot folds a proof's branch into the root it commits to. It checks
+// that the branch has one entry per tree level for LeafCount leaves and that
+// every self-paired node repeats its own hash, so a proof has only one valid form.
+func MerkleProofRoot(proof *models.MerkleProof) (string, error) {
+	if proof.LeafCount < 1 || proof.Index < 0 || proof.Index >= proof.LeafCount {
+		return "", errors.New("leaf index out of range")
+	}
+
+	hash := merkleLeaf(proof.TransactionID)
+	position, width, depth := proof.Index, proof.LeafCount, 0
+	for ; width > 1; position, width, depth = position/2, (width+1)/2, depth+1 {
+		if depth >= len(proof.Branch) {
+			return "", errors.New("merkle branch is too short")
+		}
+		sibling := proof.Branch[depth]
+		if _, err := hex.DecodeString(sibling); err != nil || len(sibling) != 2*sha256.Size {
+			return "", fmt.Errorf("invalid branch hash at level %d", depth)
+		}
+
+		switch {
+		case position%2 == 1:
+			hash = merkleParent(sibling, hash)
+		case position == width-1:
+			if sibling != hash {
+				return "", fmt.Errorf("unpaired node at level %d must repeat its own hash", depth)
+			}
+			hash = merkleParent(hash, hash)
+		default:
+			hash = merkleParent(hash, sibling)
+		}
+	}
+	if depth != len(proof.Branch) {
+		return "", errors.New("merkle branch is too long")
+	}
+	return hash, nil
+}
+
+// VerifyMerkleProof checks that a proof places its transaction under header's
+// Merkle root. It needs nothing but the header, so a light client holding a
+// chain of headers can confirm a payment without downloading the block.
+// The header itself is checked as well: its hash must match its fields and meet
+// its target.
+func VerifyMerkleProof(header *models.BlockHeader, proof *models.MerkleProof) error {
+	hash, err := HashBlock(header)
+	if err != nil {
+		return err
+	}
+	if hash != header.Hash {
+		return errors.New("block header hash does not match its fields")
+	}
+	if err := CheckProofOfWork(header); err != nil {
+		return err
+	}
+
+	root, err := MerkleProofRoot(proof)
+	if err != nil {
+		return err
+	}
+	if root != header.MerkleRoot {
+		return errors.New("merkle proof does not lead to the block's merkle root")
+	}
+	return nil
+}
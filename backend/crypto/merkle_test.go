@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"fmt"
+	"testing"
+)
+
+func testTransactionIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("tx%d", i)
+	}
+	return ids
+}
+
+func TestMerkleBranchLeadsToRoot(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 9, 11, 16, 17} {
+		ids := testTransactionIDs(n)
+		root := CalculateMerkleRoot(ids)
+		for i, id := range ids {
+			proof, err := MerkleBranch(ids, id)
+			if err != nil {
+				t.Fatalf("%d leaves, index %d: %v", n, i, err)
+			}
+			if proof.Index != i || proof.LeafCount != n || proof.MerkleRoot != root {
+				t.Fatalf("%d leaves, index %d: proof %+v, want root %s", n, i, proof, root)
+			}
+
+			got, err := MerkleProofRoot(proof)
+			if err != nil {
+				t.Fatalf("%d leaves, index %d: %v", n, i, err)
+			}
+			if got != root {
+				t.Errorf("%d leaves, index %d: root %s, want %s", n, i, got, root)
+			}
+		}
+	}
+}
+
+func TestMerkleBranchUnknownTransaction(t *testing.T) {
+	if _, err := MerkleBranch(testTransactionIDs(3), "missing"); err == nil {
+		t.Fatal("built a proof for a transaction that is not in the block")
+	}
+}
+
+func TestMerkleProofRootRejectsMalformedProofs(t *testing.T) {
+	// With 5 leaves the last one is unpaired on the first two levels
+	ids := testTransactionIDs(5)
+	last, err := MerkleBranch(ids, ids[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := MerkleBranch(ids, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(proof *models.MerkleProof)
+		base   *models.MerkleProof
+	}{
+		{"unpaired node without its own hash", func(p *models.MerkleProof) { p.Branch[0] = first.Branch[0] }, last},
+		{"branch too short", func(p *models.MerkleProof) { p.Branch = p.Branch[:len(p.Branch)-1] }, first},
+		{"branch too long", func(p *models.MerkleProof) { p.Branch = append(p.Branch, p.Branch[0]) }, first},
+		{"non-hex sibling", func(p *models.MerkleProof) { p.Branch[1] = "zz" }, first},
+		{"index past the last leaf", func(p *models.MerkleProof) { p.Index = 5 }, first},
+		{"negative index", func(p *models.MerkleProof) { p.Index = -1 }, first},
+		{"no leaves", func(p *models.MerkleProof) { p.LeafCount = 0 }, first},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := *tt.base
+			proof.Branch = append([]string(nil), tt.base.Branch...)
+			tt.modify(&proof)
+			if root, err := MerkleProofRoot(&proof); err == nil {
+				t.Fatalf("accepted a malformed proof with root %s", root)
+			}
+		})
+	}
+}
+
+func TestMerkleProofRootDetectsTampering(t *testing.T) {
+	ids := testTransactionIDs(7)
+	root := CalculateMerkleRoot(ids)
+	proof, err := MerkleBranch(ids, ids[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another transaction, or the same one claimed at another position, leads elsewhere
+	swapped := *proof
+	swapped.TransactionID = ids[5]
+	if got, _ := MerkleProofRoot(&swapped); got == root {
+		t.Error("a different transaction produced the block's root")
+	}
+	moved := *proof
+	moved.Index = 5
+	if got, _ := MerkleProofRoot(&moved); got == root {
+		t.Error("a different position produced the block's root")
+	}
+}
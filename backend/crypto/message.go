@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// signedMessagePrefix starts every signed message preimage. Transaction input
+// signatures are made over the SHA-256 of a 64-character hex sighash, and the
+// prefix begins with a byte that is never hex, so a signed message can't be
+// replayed as a transaction signature.
+const signedMessagePrefix = "\x19CryptoWallet Signed Message:\n"
+
+// messagePreimage encodes a message for signing: the prefix followed by the
+// length-prefixed message
+func messagePreimage(message string) string {
+	var buf bytes.Buffer
+	buf.WriteString(signedMessagePrefix)
+	writeString(&buf, message)
+	return buf.String()
+}
+
+// MessageDigest returns the 32-byte digest a message signature is made over,
+// for signers that only see digests (see the signer package)
+func MessageDigest(message string) []byte {
+	hash := sha256.Sum256([]byte(messagePreimage(message)))
+	return hash[:]
+}
+
+// VerifyMessage verifies a signature made over MessageDigest
+func VerifyMessage(publicKeyHex, message, signatureHex string) (bool, error) {
+	return VerifySignature(publicKeyHex, messagePreimage(message), signatureHex)
+}
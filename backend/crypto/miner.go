@@ -0,0 +1,190 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto-wallet-backend/models"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// NoncesPerTemplate is how many nonces are tried against one header before
+	// the miner asks for a new one with the next extra nonce. Rolling the
+	// template regularly also keeps its timestamp current.
+	NoncesPerTemplate uint64 = 1 << 32
+
+	// hashBatch is how many hashes a worker tries between checks for
+	// cancellation or another worker's success
+	hashBatch = 4096
+)
+
+// HeaderTemplate returns the header to mine for an extra nonce. Templates
+// usually commit the extra nonce through the coinbase's CoinbaseData and
+// refresh the timestamp. It is called from one goroutine at a time, and a
+// solution always belongs to the most recent header it returned.
+type HeaderTemplate func(extraNonce uint64) (*models.BlockHeader, error)
+
+// MinerResult describes a finished mining run
+type MinerResult struct {
+	Header     models.BlockHeader // Solved header with Nonce and Hash set
+	ExtraNonce uint64             // Extra nonce of the winning template
+	Hashes     uint64             // Headers hashed across all templates and workers
+	Duration   time.Duration
+	Workers    int
+}
+
+// Hashrate returns the average hashes per second of the run
+func (r *MinerResult) Hashrate() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Hashes) / r.Duration.Seconds()
+}
+
+// Miner mines blocks on several goroutines. Its hash counter can be read while
+// Mine runs to report progress.
+type Miner struct {
+	Workers int // Goroutines to hash on; all CPUs when not positive
+
+	hashes uint64
+}
+
+// Hashes returns the number of headers hashed so far, across all of the
+// miner's runs
+func (m *Miner) Hashes() uint64 {
+	return atomic.LoadUint64(&m.hashes)
+}
+
+// MineBlock mines with a new Miner; see Miner.Mine
+func MineBlock(ctx context.Context, template HeaderTemplate, workers int) (*MinerResult, error) {
+	return (&Miner{Workers: workers}).Mine(ctx, template)
+}
+
+// Mine searches for a header whose double SHA-256 meets its target, splitting
+// each template's nonce range between the miner's workers. When a template's
+// range is exhausted the next extra nonce is requested, so mining only stops on
+// success, on a template error or when ctx is done. A cancelled run returns
+// ctx's error along with the work done so far.
+func (m *Miner) Mine(ctx context.Context, template HeaderTemplate) (*MinerResult, error) {
+	workers := m.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	result := &MinerResult{Workers: workers}
+	start := time.Now()
+	startHashes := m.Hashes()
+	defer func() {
+		result.Duration = time.Since(start)
+		result.Hashes = m.Hashes() - startHashes
+	}()
+
+	for extraNonce := uint64(0); ; extraNonce++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		header, err := template(extraNonce)
+		if err != nil {
+			return result, err
+		}
+		candidate := *header
+		candidate.Version = BlockHeaderVersion
+		candidate.Nonce = 0
+
+		nonce, hash, found, err := searchNonces(ctx, &candidate, workers, &m.hashes)
+		if err != nil {
+			return result, err
+		}
+		if found {
+			candidate.Nonce = int64(nonce)
+			candidate.Hash = hash
+			result.Header = candidate
+			result.ExtraNonce = extraNonce
+			return result, nil
+		}
+	}
+}
+
+// searchNonces tries every nonce below NoncesPerTemplate for header, worker i
+// taking nonces i, i+workers, i+2*workers and so on. Each worker serializes the
+// header once and rewrites only the trailing nonce bytes per attempt.
+func searchNonces(ctx context.Context, header *models.BlockHeader, workers int, hashes *uint64) (uint64, string, bool, error) {
+	target, err := HeaderTarget(header)
+	if err != nil {
+		return 0, "", false, err
+	}
+	data, err := SerializeBlockHeader(header)
+	if err != nil {
+		return 0, "", false, err
+	}
+	var targetBytes [sha256.Size]byte
+	target.FillBytes(targetBytes[:])
+
+	var (
+		wg        sync.WaitGroup
+		once      sync.Once
+		done      int32
+		winner    uint64
+		winnerHex string
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(first uint64) {
+			defer wg.Done()
+			buf := append([]byte(nil), data...)
+			nonceBytes := buf[len(buf)-8:]
+
+			// Hashes are counted in batches so progress can be read during the search
+			var tried, reported uint64
+			defer func() { atomic.AddUint64(hashes, tried-reported) }()
+
+			for nonce := first; nonce < NoncesPerTemplate; nonce += uint64(workers) {
+				if tried%hashBatch == 0 {
+					atomic.AddUint64(hashes, tried-reported)
+					reported = tried
+					if atomic.LoadInt32(&done) != 0 || ctx.Err() != nil {
+						return
+					}
+				}
+				tried++
+
+				binary.BigEndian.PutUint64(nonceBytes, nonce)
+				inner := sha256.Sum256(buf)
+				hash := sha256.Sum256(inner[:])
+				if bytes.Compare(hash[:], targetBytes[:]) <= 0 {
+					once.Do(func() {
+						winner = nonce
+						winnerHex = hex.EncodeToString(hash[:])
+						atomic.StoreInt32(&done, 1)
+					})
+					return
+				}
+			}
+		}(uint64(w))
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&done) != 0 {
+		return winner, winnerHex, true, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, "", false, err
+	}
+	return 0, "", false, nil
+}
+
+// CoinbaseData encodes the block height and extra nonce a coinbase commits to
+// as height u64 | extra nonce u64, in hex. The height makes every coinbase ID
+// unique; the extra nonce gives each mining template a distinct Merkle root.
+func CoinbaseData(height int64, extraNonce uint64) string {
+	var data [16]byte
+	binary.BigEndian.PutUint64(data[:8], uint64(height))
+	binary.BigEndian.PutUint64(data[8:], extraNonce)
+	return hex.EncodeToString(data[:])
+}
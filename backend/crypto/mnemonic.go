@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// BIP-39 parameters
+const (
+	mnemonicSeedIterations = 2048
+	mnemonicSeedLength     = 64
+	mnemonicBitsPerWord    = 11
+)
+
+//go:embed wordlists/english.txt
+var englishWordlist string
+
+var (
+	mnemonicWords     = strings.Fields(englishWordlist)
+	mnemonicWordIndex = buildWordIndex(mnemonicWords)
+)
+
+var (
+	ErrInvalidWordCount   = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	ErrInvalidMnemonic    = errors.New("invalid mnemonic phrase")
+	ErrMnemonicChecksum   = errors.New("mnemonic checksum mismatch")
+	ErrInvalidEntropySize = errors.New("entropy must be 128-256 bits in multiples of 32")
+)
+
+func buildWordIndex(words []string) map[string]int {
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		index[word] = i
+	}
+	return index
+}
+
+// GenerateMnemonic creates a new BIP-39 mnemonic phrase with the given number
+// of words (12, 15, 18, 21 or 24) from the English wordlist
+func GenerateMnemonic(wordCount int) (string, error) {
+	if wordCount%3 != 0 || wordCount < 12 || wordCount > 24 {
+		return "", ErrInvalidWordCount
+	}
+
+	// Each 3 words encode 32 bits of entropy plus 1 checksum bit
+	entropy := make([]byte, wordCount/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes entropy as a mnemonic phrase. The first
+// len(entropy)*8/32 bits of its SHA-256 hash are appended as a checksum and the
+// result is split into 11-bit word indexes.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if entropyBits < 128 || entropyBits > 256 || entropyBits%32 != 0 {
+		return "", ErrInvalidEntropySize
+	}
+	checksumBits := entropyBits / 32
+
+	hash := sha256.Sum256(entropy)
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, uint(checksumBits))
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	wordCount := (entropyBits + checksumBits) / mnemonicBitsPerWord
+	words := make([]string, wordCount)
+	mask := big.NewInt(1<<mnemonicBitsPerWord - 1)
+	index := new(big.Int)
+	for i := wordCount - 1; i >= 0; i-- {
+		index.And(value, mask)
+		words[i] = mnemonicWords[index.Int64()]
+		value.Rsh(value, mnemonicBitsPerWord)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes a mnemonic phrase back to its entropy and verifies the checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(normalizeMnemonic(mnemonic))
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, ErrInvalidWordCount
+	}
+
+	value := new(big.Int)
+	for _, word := range words {
+		index, ok := mnemonicWordIndex[word]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, word)
+		}
+		value.Lsh(value, mnemonicBitsPerWord)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	totalBits := len(words) * mnemonicBitsPerWord
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	checksum := new(big.Int).And(value, big.NewInt(1<<checksumBits-1))
+	value.Rsh(value, uint(checksumBits))
+
+	entropy := make([]byte, entropyBits/8)
+	value.FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>(8-checksumBits)) {
+		return nil, ErrMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// ValidateMnemonic checks that every word is in the wordlist and the checksum is correct
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed derives the 64-byte BIP-39 seed from a mnemonic and an optional
+// passphrase. A different passphrase yields a completely different wallet.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	password := []byte(normalizeMnemonic(mnemonic))
+	salt := []byte("mnemonic" + norm.NFKD.String(passphrase))
+	return pbkdf2.Key(password, salt, mnemonicSeedIterations, mnemonicSeedLength, sha512.New), nil
+}
+
+// normalizeMnemonic applies NFKD normalisation, lower-cases the words and
+// collapses whitespace so phrases typed by hand still match
+func normalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(norm.NFKD.String(mnemonic))), " ")
+}
@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto-wallet-backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An m-of-n multisig wallet is described by its redeem policy: the number of
+// signatures required and the participants' public keys. The policy is encoded as
+//
+//	domain str | threshold u8 | key count | public key str...
+//
+// with the keys sorted, so the same participants and threshold always give the
+// same address regardless of the order they were listed in. The address is the
+// Base58Check encoding of the first 20 bytes of SHA-256(SHA-256(policy)) under
+// the multisig version byte. Spending inputs carry the policy, so a verifier only
+// needs the address to check which keys may sign.
+
+// MaxMultisigKeys is the largest number of participants a multisig wallet may have
+const MaxMultisigKeys = 15
+
+const multisigPolicyDomain = "CryptoWallet/multisig/v1"
+
+var ErrInsufficientSignatures = errors.New("not enough valid multisig signatures")
+
+// MultisigPolicy is the redeem policy of a multisig wallet
+type MultisigPolicy struct {
+	Threshold  int
+	PublicKeys []string // Sorted, lowercase hex
+}
+
+// NewMultisigPolicy validates the participant keys and threshold and returns the
+// canonical policy
+func NewMultisigPolicy(threshold int, publicKeys []string) (*MultisigPolicy, error) {
+	if len(publicKeys) == 0 || len(publicKeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("a multisig wallet needs between 1 and %d public keys", MaxMultisigKeys)
+	}
+	if threshold < 1 || threshold > len(publicKeys) {
+		return nil, fmt.Errorf("threshold must be between 1 and %d", len(publicKeys))
+	}
+
+	keys := make([]string, len(publicKeys))
+	for i, key := range publicKeys {
+		keys[i] = strings.ToLower(strings.TrimSpace(key))
+		if err := ValidatePublicKey(keys[i]); err != nil {
+			return nil, fmt.Errorf("public key %d: %w", i, err)
+		}
+	}
+	sort.Strings(keys)
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			return nil, errors.New("duplicate public key in multisig policy")
+		}
+	}
+
+	return &MultisigPolicy{Threshold: threshold, PublicKeys: keys}, nil
+}
+
+// ParseMultisigPolicy decodes a hex redeem policy and checks it is canonical
+func ParseMultisigPolicy(policyHex string) (*MultisigPolicy, error) {
+	data, err := hex.DecodeString(policyHex)
+	if err != nil {
+		return nil, errors.New("invalid redeem policy encoding")
+	}
+
+	r := bytes.NewReader(data)
+	domain, err := readString(r)
+	if err != nil || domain != multisigPolicyDomain {
+		return nil, errors.New("not a multisig redeem policy")
+	}
+	threshold, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	count, err := readCount(r, minEncodedStringSize)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, count)
+	for i := range keys {
+		if keys[i], err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+	if r.Len() != 0 {
+		return nil, ErrTrailingBytes
+	}
+
+	policy, err := NewMultisigPolicy(int(threshold), keys)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Hex() != strings.ToLower(policyHex) {
+		return nil, errors.New("redeem policy is not in canonical form")
+	}
+	return policy, nil
+}
+
+// Serialize returns the binary encoding of the policy
+func (p *MultisigPolicy) Serialize() []byte {
+	var buf bytes.Buffer
+	writeString(&buf, multisigPolicyDomain)
+	buf.WriteByte(byte(p.Threshold))
+	writeUvarint(&buf, uint64(len(p.PublicKeys)))
+	for _, key := range p.PublicKeys {
+		writeString(&buf, key)
+	}
+	return buf.Bytes()
+}
+
+// Hex returns the hex encoding of the policy, as stored on wallets and inputs
+func (p *MultisigPolicy) Hex() string {
+	return hex.EncodeToString(p.Serialize())
+}
+
+// Address returns the multisig address the policy controls on the active network
+func (p *MultisigPolicy) Address() string {
+	first := sha256.Sum256(p.Serialize())
+	second := sha256.Sum256(first[:])
+	return EncodeAddress(ActiveMultisigAddressVersion(), second[:addressHashLength])
+}
+
+// HasKey reports whether publicKeyHex is one of the policy's participants
+func (p *MultisigPolicy) HasKey(publicKeyHex string) bool {
+	publicKeyHex = strings.ToLower(publicKeyHex)
+	i := sort.SearchStrings(p.PublicKeys, publicKeyHex)
+	return i < len(p.PublicKeys) && p.PublicKeys[i] == publicKeyHex
+}
+
+// VerifyMultisigInput checks that a multisig input's policy belongs to address
+// and that at least the policy's threshold of distinct participants signed the
+// input's sighash. Signatures by unknown keys, duplicates and invalid signatures
+// are rejected rather than ignored.
+func VerifyMultisigInput(address, policyHex string, cosignatures []models.CosignerSignature, inputs []InputData, outputs []OutputData, inputIndex int, hashType SigHashType) error {
+	policy, err := ParseMultisigPolicy(policyHex)
+	if err != nil {
+		return err
+	}
+	if !AddressesEqual(policy.Address(), address) {
+		return errors.New("redeem policy does not match the multisig address")
+	}
+
+	sigHash, err := CalculateSigHash(inputs, outputs, inputIndex, hashType)
+	if err != nil {
+		return err
+	}
+
+	signed := make(map[string]bool, len(cosignatures))
+	for _, cosignature := range cosignatures {
+		key := strings.ToLower(cosignature.PublicKey)
+		if !policy.HasKey(key) {
+			return errors.New("signature by a key outside the multisig policy")
+		}
+		if signed[key] {
+			return errors.New("duplicate signature from the same participant")
+		}
+
+		valid, err := VerifySignature(key, sigHash, cosignature.Signature)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return errors.New("invalid multisig signature")
+		}
+		signed[key] = true
+	}
+
+	if len(signed) < policy.Threshold {
+		return fmt.Errorf("%w: have %d, need %d", ErrInsufficientSignatures, len(signed), policy.Threshold)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Proof-of-work targets. A block hash, read as a big-endian 256-bit number,
+// must not exceed the block's target. Headers from compactTargetVersion on carry
+// the target in compact "bits" form; older headers carry Difficulty, a count of
+// leading zero hex digits, which is the target 16^(64-Difficulty) - 1.
+
+const (
+	compactTargetVersion = 3
+
+	// MaxRetargetFactor bounds how far one retarget can move the target
+	MaxRetargetFactor = 4
+)
+
+var (
+	// powLimit is the easiest target allowed, one leading zero hex digit
+	powLimit = LeadingZerosTarget(1)
+
+	// PowLimitBits is powLimit in compact form
+	PowLimitBits = TargetToCompact(powLimit)
+
+	two256 = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+// LeadingZerosTarget returns the largest hash with at least zeros leading zero
+// hex digits
+func LeadingZerosTarget(zeros int) *big.Int {
+	if zeros < 0 {
+		zeros = 0
+	}
+	if zeros > 64 {
+		zeros = 64
+	}
+	target := new(big.Int).Lsh(big.NewInt(1), uint(256-4*zeros))
+	return target.Sub(target, big.NewInt(1))
+}
+
+// CompactToTarget expands compact bits into a target. The top byte is the
+// target's length in bytes and the low 23 bits its most significant digits;
+// bit 23 is a sign bit, and negative or zero targets are rejected.
+func CompactToTarget(bits uint32) (*big.Int, error) {
+	size := bits >> 24
+	mantissa := bits & 0x007fffff
+	if mantissa == 0 {
+		return nil, errors.New("compact target is zero")
+	}
+	if bits&0x00800000 != 0 {
+		return nil, errors.New("compact target is negative")
+	}
+
+	var target *big.Int
+	if size <= 3 {
+		target = big.NewInt(int64(mantissa >> (8 * (3 - size))))
+	} else {
+		target = new(big.Int).Lsh(big.NewInt(int64(mantissa)), uint(8*(size-3)))
+	}
+	if target.Sign() == 0 {
+		return nil, errors.New("compact target is zero")
+	}
+	if target.BitLen() > 256 {
+		return nil, errors.New("compact target overflows 256 bits")
+	}
+	return target, nil
+}
+
+// TargetToCompact encodes a target in compact form, keeping its three most
+// significant bytes
+func TargetToCompact(target *big.Int) uint32 {
+	size := uint32((target.BitLen() + 7) / 8)
+	var mantissa uint32
+	if size <= 3 {
+		mantissa = uint32(target.Uint64() << (8 * (3 - size)))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, uint(8*(size-3))).Uint64())
+	}
+	// Keep the sign bit clear
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		size++
+	}
+	return size<<24 | mantissa
+}
+
+// HasCompactTarget reports whether a header's target is given by its bits,
+// which are then set by the retargeting rule, rather than by its difficulty
+func HasCompactTarget(header *models.BlockHeader) bool {
+	return header.Version >= compactTargetVersion
+}
+
+// HeaderBits returns the target of a header in compact form. Headers from
+// before compact targets get the compact form of their leading-zero target,
+// which may round it down slightly.
+func HeaderBits(header *models.BlockHeader) uint32 {
+	if header.Version >= compactTargetVersion || header.Bits != 0 {
+		return header.Bits
+	}
+	return TargetToCompact(LeadingZerosTarget(header.Difficulty))
+}
+
+// HeaderTarget returns the target a header's hash must meet
+func HeaderTarget(header *models.BlockHeader) (*big.Int, error) {
+	if header.Version < compactTargetVersion && header.Bits == 0 {
+		return LeadingZerosTarget(header.Difficulty), nil
+	}
+	target, err := CompactToTarget(header.Bits)
+	if err != nil {
+		return nil, err
+	}
+	if target.Cmp(powLimit) > 0 {
+		return nil, errors.New("target is above the proof-of-work limit")
+	}
+	return target, nil
+}
+
+// HashMeetsTarget reports whether a hex block hash is at most target
+func HashMeetsTarget(hash string, target *big.Int) bool {
+	hashBytes, err := hex.DecodeString(hash)
+	if err != nil || len(hashBytes) != 32 {
+		return false
+	}
+	return new(big.Int).SetBytes(hashBytes).Cmp(target) <= 0
+}
+
+// CheckProofOfWork checks that a header's hash meets its target. It does not
+// recompute the hash from the header's fields.
+func CheckProofOfWork(header *models.BlockHeader) error {
+	target, err := HeaderTarget(header)
+	if err != nil {
+		return err
+	}
+	if !HashMeetsTarget(header.Hash, target) {
+		return errors.New("block hash doesn't meet its target")
+	}
+	return nil
+}
+
+// ShareTarget returns the target of a mining pool share of difficulty, the
+// proof-of-work limit divided by difficulty. Difficulty 1 is the easiest
+// target a block may have.
+func ShareTarget(difficulty int64) *big.Int {
+	if difficulty < 1 {
+		difficulty = 1
+	}
+	return new(big.Int).Div(powLimit, big.NewInt(difficulty))
+}
+
+// TargetWork returns the expected number of hashes needed to meet target,
+// 2^256 / (target + 1)
+func TargetWork(target *big.Int) *big.Int {
+	return new(big.Int).Div(two256, new(big.Int).Add(target, big.NewInt(1)))
+}
+
+// HeaderWork returns the work a header's proof-of-work represents
+func HeaderWork(header *models.BlockHeader) (*big.Int, error) {
+	target, err := HeaderTarget(header)
+	if err != nil {
+		return nil, err
+	}
+	return TargetWork(target), nil
+}
+
+// FormatChainWork encodes accumulated work as 64 hex digits, so stored values
+// sort in the same order as the numbers
+func FormatChainWork(work *big.Int) string {
+	return fmt.Sprintf("%064x", work)
+}
+
+// ParseChainWork decodes a value written by FormatChainWork. An empty string is
+// zero work.
+func ParseChainWork(chainWork string) (*big.Int, error) {
+	if chainWork == "" {
+		return new(big.Int), nil
+	}
+	work, ok := new(big.Int).SetString(chainWork, 16)
+	if !ok || work.Sign() < 0 {
+		return nil, fmt.Errorf("invalid chain work %q", chainWork)
+	}
+	return work, nil
+}
+
+// RetargetBits scales a target by how long the last blocks actually took
+// compared with how long they should have taken. The ratio is clamped to
+// MaxRetargetFactor either way and the result to the proof-of-work limit.
+func RetargetBits(bits uint32, actual, expected time.Duration) (uint32, error) {
+	target, err := CompactToTarget(bits)
+	if err != nil {
+		return 0, err
+	}
+	if expected <= 0 {
+		return bits, nil
+	}
+	if actual < expected/MaxRetargetFactor {
+		actual = expected / MaxRetargetFactor
+	}
+	if actual > expected*MaxRetargetFactor {
+		actual = expected * MaxRetargetFactor
+	}
+
+	target.Mul(target, big.NewInt(int64(actual)))
+	target.Div(target, big.NewInt(int64(expected)))
+	if target.Sign() == 0 {
+		target.SetInt64(1)
+	}
+	if target.Cmp(powLimit) > 0 {
+		target.Set(powLimit)
+	}
+	return TargetToCompact(target), nil
+}
+
+// TargetLeadingZeros returns the number of leading zero hex digits every hash
+// meeting target has
+func TargetLeadingZeros(target *big.Int) int {
+	return 64 - (target.BitLen()+3)/4
+}
+
+// FormatTarget encodes a target as 64 hex digits, for comparison with hashes
+func FormatTarget(target *big.Int) string {
+	return fmt.Sprintf("%064x", target)
+}
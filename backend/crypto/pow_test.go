@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hexTarget(t *testing.T, s string) *big.Int {
+	t.Helper()
+	target, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex target %q", s)
+	}
+	return target
+}
+
+func TestCompactToTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		bits    uint32
+		want    string // Hex target; empty when an error is expected
+		wantErr bool
+	}{
+		{"bitcoin genesis", 0x1d00ffff, "ffff" + strings.Repeat("0", 52), false},
+		{"three byte size", 0x03123456, "123456", false},
+		{"two byte size drops a byte", 0x02123456, "1234", false},
+		{"one byte size drops two bytes", 0x01123456, "12", false},
+		{"largest 256-bit target", 0x207fffff, "7fffff" + strings.Repeat("0", 58), false},
+		{"size past 32 bytes that still fits", 0x22000001, "1" + strings.Repeat("0", 62), false},
+		{"zero mantissa", 0x04000000, "", true},
+		{"mantissa shifted out", 0x01003456, "", true},
+		{"sign bit", 0x04923456, "", true},
+		{"overflows 256 bits", 0x21010000, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := CompactToTarget(tt.bits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && target.Cmp(hexTarget(t, tt.want)) != 0 {
+				t.Fatalf("target = %x, want %s", target, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetToCompact(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   uint32
+	}{
+		{"three bytes", "123456", 0x03123456},
+		{"one byte", "12", 0x01120000},
+		{"two bytes", "1234", 0x02123400},
+		{"high bit moves to the next byte", "80", 0x02008000},
+		{"high bit in a large target", "ffff" + strings.Repeat("0", 52), 0x1d00ffff},
+		{"low bytes are truncated", "123456789a", 0x05123456},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TargetToCompact(hexTarget(t, tt.target)); got != tt.want {
+				t.Fatalf("TargetToCompact = %#08x, want %#08x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompactRoundTrip(t *testing.T) {
+	for _, bits := range []uint32{0x1d00ffff, 0x1f0fffff, 0x03123456, 0x02008000, 0x01120000, 0x207fffff, PowLimitBits} {
+		target, err := CompactToTarget(bits)
+		if err != nil {
+			t.Fatalf("%#08x: %v", bits, err)
+		}
+		if got := TargetToCompact(target); got != bits {
+			t.Errorf("%#08x round-tripped to %#08x", bits, got)
+		}
+	}
+
+	// The compact form of the proof-of-work limit rounds it down, never up
+	limit, err := CompactToTarget(PowLimitBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit.Cmp(powLimit) > 0 {
+		t.Errorf("PowLimitBits expands to %x, above the limit %x", limit, powLimit)
+	}
+}
+
+func TestRetargetBits(t *testing.T) {
+	const bits = 0x1e0fffff
+	base, err := CompactToTarget(bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled := func(num, den int64) uint32 {
+		target := new(big.Int).Mul(base, big.NewInt(num))
+		return TargetToCompact(target.Div(target, big.NewInt(den)))
+	}
+	expected := 10 * time.Minute
+
+	tests := []struct {
+		name    string
+		bits    uint32
+		actual  time.Duration
+		want    uint32
+		wantErr bool
+	}{
+		{"on schedule", bits, expected, bits, false},
+		{"twice as fast halves the target", bits, expected / 2, scaled(1, 2), false},
+		{"twice as slow doubles the target", bits, 2 * expected, scaled(2, 1), false},
+		{"much faster is clamped", bits, expected / 100, scaled(1, MaxRetargetFactor), false},
+		{"no time at all is clamped", bits, 0, scaled(1, MaxRetargetFactor), false},
+		{"negative time is clamped", bits, -expected, scaled(1, MaxRetargetFactor), false},
+		{"much slower is clamped", bits, 100 * expected, scaled(MaxRetargetFactor, 1), false},
+		{"never easier than the limit", PowLimitBits, 4 * expected, PowLimitBits, false},
+		{"never below a target of one", 0x01010000, expected / 4, 0x01010000, false},
+		{"invalid bits", 0x04923456, expected, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RetargetBits(tt.bits, tt.actual, expected)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("RetargetBits = %#08x, want %#08x", got, tt.want)
+			}
+		})
+	}
+
+	// Without an expected duration there is nothing to scale by
+	if got, err := RetargetBits(bits, expected, 0); err != nil || got != bits {
+		t.Errorf("zero expected duration: %#08x, %v", got, err)
+	}
+}
@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// Deterministic ECDSA (RFC 6979). The nonce k is derived from the private key and
+// the message digest with HMAC-DRBG instead of being drawn at random, so signing
+// the same digest twice gives the same signature and a weak random number
+// generator can't leak the key through reused nonces.
+
+// signDeterministic signs digest with an RFC 6979 nonce and returns a low-S signature
+func signDeterministic(privateKey *ecdsa.PrivateKey, digest []byte) (*big.Int, *big.Int, error) {
+	curve := privateKey.Curve
+	n := curve.Params().N
+
+	e := hashToInt(digest, n)
+	generate := rfc6979Nonces(privateKey.D, digest, n)
+	for {
+		k := generate()
+
+		x, _ := curve.ScalarBaseMult(k.FillBytes(make([]byte, (n.BitLen()+7)/8)))
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k⁻¹(e + r·d) mod n
+		kInv := new(big.Int).ModInverse(k, n)
+		if kInv == nil {
+			return nil, nil, errors.New("invalid nonce")
+		}
+		s := new(big.Int).Mul(r, privateKey.D)
+		s.Add(s, e)
+		s.Mul(s, kInv)
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return r, normalizeLowS(s, n), nil
+	}
+}
+
+// rfc6979Nonces returns a generator of candidate nonces for RFC 6979 section 3.2
+// using HMAC-SHA256. Each call yields the next candidate in [1, n-1].
+func rfc6979Nonces(d *big.Int, digest []byte, n *big.Int) func() *big.Int {
+	rolen := (n.BitLen() + 7) / 8
+
+	x := d.FillBytes(make([]byte, rolen))
+	h1 := new(big.Int).Mod(hashToInt(digest, n), n).FillBytes(make([]byte, rolen))
+
+	v := bytes.Repeat([]byte{0x01}, sha256.Size)
+	k := make([]byte, sha256.Size)
+
+	k = hmacSHA256(k, v, []byte{0x00}, x, h1)
+	v = hmacSHA256(k, v)
+	k = hmacSHA256(k, v, []byte{0x01}, x, h1)
+	v = hmacSHA256(k, v)
+
+	first := true
+	return func() *big.Int {
+		for {
+			if !first {
+				k = hmacSHA256(k, v, []byte{0x00})
+				v = hmacSHA256(k, v)
+			}
+			first = false
+
+			var t []byte
+			for len(t) < rolen {
+				v = hmacSHA256(k, v)
+				t = append(t, v...)
+			}
+
+			candidate := hashToInt(t, n)
+			if candidate.Sign() > 0 && candidate.Cmp(n) < 0 {
+				return candidate
+			}
+		}
+	}
+}
+
+// hashToInt converts a digest to an integer, keeping the leftmost bits when the
+// digest is longer than the group order (bits2int in RFC 6979)
+func hashToInt(digest []byte, n *big.Int) *big.Int {
+	orderBits := n.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(digest) > orderBytes {
+		digest = digest[:orderBytes]
+	}
+
+	value := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - orderBits; excess > 0 {
+		value.Rsh(value, uint(excess))
+	}
+	return value
+}
+
+// normalizeLowS replaces s with n - s when s is in the upper half of the order.
+// Both values verify, so only the low one is accepted to prevent malleability.
+func normalizeLowS(s, n *big.Int) *big.Int {
+	if isLowS(s, n) {
+		return s
+	}
+	return new(big.Int).Sub(n, s)
+}
+
+// isLowS reports whether s <= n/2
+func isLowS(s, n *big.Int) bool {
+	halfOrder := new(big.Int).Rsh(n, 1)
+	return s.Cmp(halfOrder) <= 0
+}
+
+func hmacSHA256(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
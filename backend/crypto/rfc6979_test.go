@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func hexInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	value, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("invalid hex integer %q", s)
+	}
+	return value
+}
+
+func TestRFC6979Vectors(t *testing.T) {
+	// P-256 vectors are from RFC 6979 appendix A.2.5 (SHA-256). The secp256k1
+	// vectors are the ones widely used by Bitcoin libraries. Signatures are
+	// listed as published; ours replace a high S with n - S.
+	tests := []struct {
+		name    string
+		curve   elliptic.Curve
+		key     string
+		message string
+		k, r, s string
+	}{
+		{
+			name:    "P-256 sample",
+			curve:   elliptic.P256(),
+			key:     "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+			message: "sample",
+			k:       "a6e3c57dd01abe90086538398355dd4c3b17aa873382b0f24d6129493d8aad60",
+			r:       "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716",
+			s:       "f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8",
+		},
+		{
+			name:    "P-256 test",
+			curve:   elliptic.P256(),
+			key:     "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+			message: "test",
+			k:       "d16b6ae827f17175e040871a1c7ec3500192c4c92677336ec2537acaee0008e0",
+			r:       "f1abb023518351cd71d881567b1ea663ed3efcf6c5132b354f28d3b0b7d38367",
+			s:       "019f4113742a2b14bd25926b49c649155f267e60d3814b4c0cc84250e46f0083",
+		},
+		{
+			name:    "secp256k1 key 1",
+			curve:   secp256k1.S256(),
+			key:     "1",
+			message: "Satoshi Nakamoto",
+			k:       "8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15",
+			r:       "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8",
+			s:       "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+		},
+		{
+			name:    "secp256k1 key 1, long message",
+			curve:   secp256k1.S256(),
+			key:     "1",
+			message: "All those moments will be lost in time, like tears in rain. Time to die...",
+			k:       "38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3",
+			r:       "8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b",
+			s:       "547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateKey := privateKeyFromScalar(tt.curve, hexInt(t, tt.key))
+			n := tt.curve.Params().N
+			digest := sha256.Sum256([]byte(tt.message))
+
+			if k := rfc6979Nonces(privateKey.D, digest[:], n)(); k.Cmp(hexInt(t, tt.k)) != 0 {
+				t.Errorf("k = %x, want %s", k, tt.k)
+			}
+
+			r, s, err := signDeterministic(privateKey, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantS := normalizeLowS(hexInt(t, tt.s), n)
+			if r.Cmp(hexInt(t, tt.r)) != 0 || s.Cmp(wantS) != 0 {
+				t.Fatalf("signature = (%x, %x), want (%s, %x)", r, s, tt.r, wantS)
+			}
+			if !isLowS(s, n) {
+				t.Error("signature has a high S value")
+			}
+			if !ecdsa.Verify(&privateKey.PublicKey, digest[:], r, s) {
+				t.Error("signature did not verify")
+			}
+
+			// Signing again gives the same signature
+			if r2, s2, err := signDeterministic(privateKey, digest[:]); err != nil || r2.Cmp(r) != 0 || s2.Cmp(s) != 0 {
+				t.Errorf("second signature = (%x, %x), %v", r2, s2, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,1108 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Outputs can be locked by a small stack-based script instead of a bare public
+// key. Spending such an output runs the input's unlocking script and then the
+// output's locking script on the same stack; the spend is valid when both run
+// without error and leave a true value on top of the stack.
+//
+// The language is a subset of Bitcoin Script. There are no loops or jumps, so
+// every script terminates, and the result depends only on the spending
+// transaction and the block height and time it is validated for. Differences
+// from Bitcoin:
+//   - OP_PUBKEYHASH hashes a public key the way addresses do (see PublicKeyHash)
+//   - signatures are the wallet signature encoding followed by a sighash byte
+//   - OP_CHECKMULTISIG does not consume an extra dummy element
+//   - unlocking scripts may only push data
+
+// Opcodes
+const (
+	Op0                   byte = 0x00 // Push an empty item (false)
+	OpPushData1           byte = 0x4c // Next byte is the push length
+	OpPushData2           byte = 0x4d // Next 2 bytes (little-endian) are the push length
+	Op1Negate             byte = 0x4f // Push -1
+	Op1                   byte = 0x51 // Push 1 (OP_2 to OP_16 follow)
+	Op16                  byte = 0x60
+	OpNop                 byte = 0x61
+	OpIf                  byte = 0x63
+	OpNotIf               byte = 0x64
+	OpElse                byte = 0x67
+	OpEndIf               byte = 0x68
+	OpVerify              byte = 0x69
+	OpReturn              byte = 0x6a
+	OpDrop                byte = 0x75
+	OpDup                 byte = 0x76
+	OpSwap                byte = 0x7c
+	OpSize                byte = 0x82
+	OpEqual               byte = 0x87
+	OpEqualVerify         byte = 0x88
+	OpSHA256              byte = 0xa8
+	OpPubKeyHash          byte = 0xa9
+	OpHash256             byte = 0xaa
+	OpCheckSig            byte = 0xac
+	OpCheckSigVerify      byte = 0xad
+	OpCheckMultisig       byte = 0xae
+	OpCheckMultisigVerify byte = 0xaf
+	OpCheckLockTimeVerify byte = 0xb1
+)
+
+// Script limits
+const (
+	MaxScriptSize        = 10000
+	MaxScriptElementSize = 520
+	maxScriptOps         = 201
+	maxScriptStackSize   = 1000
+	maxScriptNumLength   = 4
+	lockTimeNumLength    = 5
+)
+
+// ScriptClass names the standard form of a locking script
+type ScriptClass string
+
+const (
+	ScriptClassPubKeyHash  ScriptClass = "pubkeyhash"  // Spendable by the key behind an address
+	ScriptClassMultisig    ScriptClass = "multisig"    // m-of-n bare multisig
+	ScriptClassHashLock    ScriptClass = "hashlock"    // Preimage and signature
+	ScriptClassTimeLock    ScriptClass = "timelock"    // Signature after a height or time
+	ScriptClassHTLC        ScriptClass = "htlc"        // Preimage and recipient signature, or sender signature after expiry
+	ScriptClassNullData    ScriptClass = "nulldata"    // Provably unspendable
+	ScriptClassNonStandard ScriptClass = "nonstandard" // Any other valid script
+)
+
+var (
+	ErrScriptFalse         = errors.New("script evaluated to false")
+	ErrScriptNotPushOnly   = errors.New("unlocking script may only push data")
+	ErrLockTimeNotReached  = errors.New("script is time-locked")
+	ErrNoLockingScript     = errors.New("address has no standard locking script")
+	errScriptTruncated     = errors.New("script ends in the middle of a push")
+	errScriptStackUnderrun = errors.New("script stack underflow")
+)
+
+var opcodeNames = map[byte]string{
+	Op0:                   "OP_0",
+	OpPushData1:           "OP_PUSHDATA1",
+	OpPushData2:           "OP_PUSHDATA2",
+	Op1Negate:             "OP_1NEGATE",
+	OpNop:                 "OP_NOP",
+	OpIf:                  "OP_IF",
+	OpNotIf:               "OP_NOTIF",
+	OpElse:                "OP_ELSE",
+	OpEndIf:               "OP_ENDIF",
+	OpVerify:              "OP_VERIFY",
+	OpReturn:              "OP_RETURN",
+	OpDrop:                "OP_DROP",
+	OpDup:                 "OP_DUP",
+	OpSwap:                "OP_SWAP",
+	OpSize:                "OP_SIZE",
+	OpEqual:               "OP_EQUAL",
+	OpEqualVerify:         "OP_EQUALVERIFY",
+	OpSHA256:              "OP_SHA256",
+	OpPubKeyHash:          "OP_PUBKEYHASH",
+	OpHash256:             "OP_HASH256",
+	OpCheckSig:            "OP_CHECKSIG",
+	OpCheckSigVerify:      "OP_CHECKSIGVERIFY",
+	OpCheckMultisig:       "OP_CHECKMULTISIG",
+	OpCheckMultisigVerify: "OP_CHECKMULTISIGVERIFY",
+	OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY",
+}
+
+var opcodesByName = buildOpcodesByName()
+
+func buildOpcodesByName() map[string]byte {
+	byName := make(map[string]byte, len(opcodeNames)+16)
+	for op, name := range opcodeNames {
+		byName[name] = op
+	}
+	byName["OP_FALSE"] = Op0
+	byName["OP_TRUE"] = Op1
+	for n := byte(1); n <= 16; n++ {
+		byName["OP_"+strconv.Itoa(int(n))] = Op1 + n - 1
+	}
+	return byName
+}
+
+// Script is a serialized locking or unlocking script
+type Script []byte
+
+// scriptOp is one parsed instruction: an opcode and, for pushes, its data
+type scriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+// ParseScriptHex decodes a hex script and checks that it parses
+func ParseScriptHex(scriptHex string) (Script, error) {
+	data, err := hex.DecodeString(scriptHex)
+	if err != nil {
+		return nil, errors.New("invalid script encoding")
+	}
+	script := Script(data)
+	if _, err := script.parse(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Hex returns the hex encoding of the script, as stored on outputs and inputs
+func (s Script) Hex() string {
+	return hex.EncodeToString(s)
+}
+
+// parse splits the script into instructions
+func (s Script) parse() ([]scriptOp, error) {
+	if len(s) > MaxScriptSize {
+		return nil, fmt.Errorf("script is larger than %d bytes", MaxScriptSize)
+	}
+
+	var ops []scriptOp
+	for i := 0; i < len(s); {
+		opcode := s[i]
+		i++
+
+		var length int
+		switch {
+		case opcode > Op0 && opcode < OpPushData1:
+			length = int(opcode)
+		case opcode == OpPushData1:
+			if i+1 > len(s) {
+				return nil, errScriptTruncated
+			}
+			length = int(s[i])
+			i++
+		case opcode == OpPushData2:
+			if i+2 > len(s) {
+				return nil, errScriptTruncated
+			}
+			length = int(binary.LittleEndian.Uint16(s[i:]))
+			i += 2
+		default:
+			if _, known := opcodeNames[opcode]; !known && !isSmallIntOp(opcode) {
+				return nil, fmt.Errorf("unknown opcode 0x%02x", opcode)
+			}
+			ops = append(ops, scriptOp{opcode: opcode})
+			continue
+		}
+
+		if i+length > len(s) {
+			return nil, errScriptTruncated
+		}
+		if length > MaxScriptElementSize {
+			return nil, fmt.Errorf("script push is larger than %d bytes", MaxScriptElementSize)
+		}
+		ops = append(ops, scriptOp{opcode: opcode, data: s[i : i+length]})
+		i += length
+	}
+	return ops, nil
+}
+
+// IsPushOnly reports whether the script only pushes data
+func (s Script) IsPushOnly() bool {
+	ops, err := s.parse()
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		if op.opcode > Op16 {
+			return false
+		}
+	}
+	return true
+}
+
+// String disassembles the script. Numbers are written in decimal (OP_n for
+// 1 to 16), other data pushes as hex: the same form ParseScriptAsm reads.
+func (s Script) String() string {
+	ops, err := s.parse()
+	if err != nil {
+		return "[invalid script]"
+	}
+
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		switch {
+		case op.data != nil || (op.opcode > Op0 && op.opcode <= OpPushData2):
+			// Pushes that read back as the same number are shown in decimal
+			if n, err := parseScriptNum(op.data, maxScriptNumLength); err == nil && len(op.data) > 0 && (n < -1 || n > 16) {
+				parts[i] = strconv.FormatInt(n, 10)
+				continue
+			}
+			parts[i] = hex.EncodeToString(op.data)
+			if isDecimalToken(parts[i]) {
+				parts[i] = "0x" + parts[i]
+			}
+		case isSmallIntOp(op.opcode):
+			parts[i] = "OP_" + strconv.Itoa(int(op.opcode-Op1+1))
+		default:
+			parts[i] = opcodeNames[op.opcode]
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseScriptAsm assembles a script from space-separated tokens: opcode names
+// (OP_DUP, OP_2, ...), decimal numbers of up to 10 digits, and hex data. Prefix
+// data with 0x when it could be mistaken for a number.
+func ParseScriptAsm(asm string) (Script, error) {
+	b := NewScriptBuilder()
+	for _, token := range strings.Fields(asm) {
+		if op, ok := opcodesByName[strings.ToUpper(token)]; ok {
+			b.AddOp(op)
+			continue
+		}
+		if isDecimalToken(token) {
+			n, err := strconv.ParseInt(token, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", token)
+			}
+			b.AddInt64(n)
+			continue
+		}
+
+		data, err := hex.DecodeString(strings.TrimPrefix(token, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid script token %q", token)
+		}
+		b.AddData(data)
+	}
+	return b.Script()
+}
+
+func isDecimalToken(token string) bool {
+	digits := strings.TrimPrefix(token, "-")
+	if digits == "" || len(digits) > 10 {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isSmallIntOp(opcode byte) bool {
+	return opcode >= Op1 && opcode <= Op16
+}
+
+// ============================================================================
+// Building scripts
+// ============================================================================
+
+// ScriptBuilder assembles a script with minimal push encodings. The first
+// error is kept and returned by Script.
+type ScriptBuilder struct {
+	script []byte
+	err    error
+}
+
+// NewScriptBuilder returns an empty script builder
+func NewScriptBuilder() *ScriptBuilder {
+	return &ScriptBuilder{}
+}
+
+// AddOp appends an opcode
+func (b *ScriptBuilder) AddOp(opcode byte) *ScriptBuilder {
+	b.script = append(b.script, opcode)
+	return b
+}
+
+// AddData appends a push of data using the smallest encoding
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch {
+	case len(data) > MaxScriptElementSize:
+		if b.err == nil {
+			b.err = fmt.Errorf("script push is larger than %d bytes", MaxScriptElementSize)
+		}
+	case len(data) == 0:
+		b.script = append(b.script, Op0)
+	case len(data) < int(OpPushData1):
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OpPushData1, byte(len(data)))
+	default:
+		b.script = append(b.script, OpPushData2, byte(len(data)), byte(len(data)>>8))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt64 appends a number, using OP_0 to OP_16 and OP_1NEGATE where possible
+func (b *ScriptBuilder) AddInt64(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(Op0)
+	case n == -1:
+		return b.AddOp(Op1Negate)
+	case n >= 1 && n <= 16:
+		return b.AddOp(Op1 + byte(n-1))
+	}
+	return b.AddData(scriptNumBytes(n))
+}
+
+// Script returns the assembled script
+func (b *ScriptBuilder) Script() (Script, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.script) > MaxScriptSize {
+		return nil, fmt.Errorf("script is larger than %d bytes", MaxScriptSize)
+	}
+	return Script(b.script), nil
+}
+
+// PayToPubKeyHashScript locks an output to the key behind a 20-byte public key hash:
+//
+//	OP_DUP OP_PUBKEYHASH <hash> OP_EQUALVERIFY OP_CHECKSIG
+func PayToPubKeyHashScript(pubKeyHash []byte) Script {
+	script, _ := NewScriptBuilder().
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(pubKeyHash).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+	return script
+}
+
+// PayToAddressScript returns the pay-to-pubkey-hash script for a single-key
+// address. Multisig addresses have no locking script; their inputs carry the
+// redeem policy instead.
+func PayToAddressScript(address string) (Script, error) {
+	version, hash, err := addressHash(address)
+	if err != nil {
+		return nil, err
+	}
+	if version == ActiveMultisigAddressVersion() {
+		return nil, ErrNoLockingScript
+	}
+	return PayToPubKeyHashScript(hash), nil
+}
+
+// MultisigScript locks an output to threshold signatures from publicKeys, made
+// in the same order as the keys are listed:
+//
+//	OP_m <key 1> ... <key n> OP_n OP_CHECKMULTISIG
+func MultisigScript(threshold int, publicKeys []string) (Script, error) {
+	if len(publicKeys) == 0 || len(publicKeys) > MaxMultisigKeys {
+		return nil, fmt.Errorf("a multisig script needs between 1 and %d public keys", MaxMultisigKeys)
+	}
+	if threshold < 1 || threshold > len(publicKeys) {
+		return nil, fmt.Errorf("threshold must be between 1 and %d", len(publicKeys))
+	}
+
+	b := NewScriptBuilder().AddInt64(int64(threshold))
+	for _, key := range publicKeys {
+		if err := ValidatePublicKey(key); err != nil {
+			return nil, err
+		}
+		data, _ := hex.DecodeString(key)
+		b.AddData(data)
+	}
+	return b.AddInt64(int64(len(publicKeys))).AddOp(OpCheckMultisig).Script()
+}
+
+// HashLockScript locks an output to whoever reveals the SHA-256 preimage of hash
+// and signs with the key behind pubKeyHash:
+//
+//	OP_SHA256 <hash> OP_EQUALVERIFY OP_DUP OP_PUBKEYHASH <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func HashLockScript(hash, pubKeyHash []byte) (Script, error) {
+	if len(hash) != sha256.Size {
+		return nil, errors.New("hash lock needs a 32-byte SHA-256 hash")
+	}
+	return NewScriptBuilder().
+		AddOp(OpSHA256).AddData(hash).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(pubKeyHash).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+// TimeLockScript locks an output to the key behind pubKeyHash until a block
+// height (below LockTimeThreshold) or Unix time:
+//
+//	<lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_PUBKEYHASH <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG
+func TimeLockScript(lockTime int64, pubKeyHash []byte) (Script, error) {
+	if lockTime <= 0 || lockTime >= 1<<32 {
+		return nil, errors.New("lock time must be a positive block height or Unix time")
+	}
+	return NewScriptBuilder().
+		AddInt64(lockTime).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(pubKeyHash).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script()
+}
+
+// HTLC holds the terms of a hash time-locked contract script
+type HTLC struct {
+	HashLock         []byte // SHA-256 hash of the secret
+	RecipientKeyHash []byte // Claims with the secret
+	SenderKeyHash    []byte // Refunds after expiry
+	ExpiryHeight     int64  // First block height a refund can be included in
+}
+
+// HTLCScript locks an output to the recipient, who must reveal the SHA-256
+// preimage of the hash lock, or to the sender from block ExpiryHeight on:
+//
+//	OP_IF
+//	    OP_SHA256 <hash> OP_EQUALVERIFY OP_DUP OP_PUBKEYHASH <recipient pubKeyHash>
+//	OP_ELSE
+//	    <expiry> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_PUBKEYHASH <sender pubKeyHash>
+//	OP_ENDIF
+//	OP_EQUALVERIFY OP_CHECKSIG
+func HTLCScript(htlc *HTLC) (Script, error) {
+	if len(htlc.HashLock) != sha256.Size {
+		return nil, errors.New("hash lock needs a 32-byte SHA-256 hash")
+	}
+	if len(htlc.RecipientKeyHash) != addressHashLength || len(htlc.SenderKeyHash) != addressHashLength {
+		return nil, errors.New("invalid public key hash")
+	}
+	if htlc.ExpiryHeight <= 0 || htlc.ExpiryHeight >= LockTimeThreshold {
+		return nil, errors.New("expiry must be a positive block height")
+	}
+	return NewScriptBuilder().
+		AddOp(OpIf).
+		AddOp(OpSHA256).AddData(htlc.HashLock).AddOp(OpEqualVerify).
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(htlc.RecipientKeyHash).
+		AddOp(OpElse).
+		AddInt64(htlc.ExpiryHeight).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(htlc.SenderKeyHash).
+		AddOp(OpEndIf).
+		AddOp(OpEqualVerify).AddOp(OpCheckSig).
+		Script()
+}
+
+// ExtractHTLC returns the terms of a script built by HTLCScript
+func ExtractHTLC(script Script) (*HTLC, bool) {
+	ops, err := script.parse()
+	if err != nil || !isHTLC(ops) {
+		return nil, false
+	}
+	var expiry int64
+	if isSmallIntOp(ops[8].opcode) {
+		expiry = int64(ops[8].opcode-Op1) + 1
+	} else if expiry, err = parseScriptNum(ops[8].data, lockTimeNumLength); err != nil {
+		return nil, false
+	}
+	return &HTLC{
+		HashLock:         ops[2].data,
+		RecipientKeyHash: ops[6].data,
+		SenderKeyHash:    ops[13].data,
+		ExpiryHeight:     expiry,
+	}, true
+}
+
+// HTLCClaimScript builds the unlocking script <signature> <public key> <preimage> OP_1
+// that claims an HTLC output
+func HTLCClaimScript(signatureHex string, hashType SigHashType, publicKeyHex string, preimage []byte) (Script, error) {
+	signature, err := ScriptSignature(signatureHex, hashType)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, errors.New("invalid public key encoding")
+	}
+	return NewScriptBuilder().AddData(signature).AddData(publicKey).AddData(preimage).AddOp(Op1).Script()
+}
+
+// HTLCRefundScript builds the unlocking script <signature> <public key> OP_0
+// that refunds an expired HTLC output
+func HTLCRefundScript(signatureHex string, hashType SigHashType, publicKeyHex string) (Script, error) {
+	signature, err := ScriptSignature(signatureHex, hashType)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, errors.New("invalid public key encoding")
+	}
+	return NewScriptBuilder().AddData(signature).AddData(publicKey).AddOp(Op0).Script()
+}
+
+// ScriptSignature encodes a signature for use in an unlocking script: the
+// signature bytes followed by the sighash type they were made with
+func ScriptSignature(signatureHex string, hashType SigHashType) ([]byte, error) {
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || len(signature) == 0 {
+		return nil, errors.New("invalid signature encoding")
+	}
+	return append(signature, byte(hashType)), nil
+}
+
+// PayToPubKeyHashUnlockingScript builds the unlocking script <signature> <public key>
+// that spends a pay-to-pubkey-hash output
+func PayToPubKeyHashUnlockingScript(signatureHex string, hashType SigHashType, publicKeyHex string) (Script, error) {
+	signature, err := ScriptSignature(signatureHex, hashType)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, errors.New("invalid public key encoding")
+	}
+	return NewScriptBuilder().AddData(signature).AddData(publicKey).Script()
+}
+
+// ClassifyScript returns the standard form of a locking script
+func ClassifyScript(script Script) ScriptClass {
+	ops, err := script.parse()
+	if err != nil {
+		return ScriptClassNonStandard
+	}
+
+	switch {
+	case len(ops) > 0 && ops[0].opcode == OpReturn:
+		return ScriptClassNullData
+	case isPayToPubKeyHash(ops):
+		return ScriptClassPubKeyHash
+	case isMultisig(ops):
+		return ScriptClassMultisig
+	case len(ops) == 8 && ops[0].opcode == OpSHA256 && len(ops[1].data) == sha256.Size &&
+		ops[2].opcode == OpEqualVerify && isPayToPubKeyHash(ops[3:]):
+		return ScriptClassHashLock
+	case len(ops) == 8 && ops[1].opcode == OpCheckLockTimeVerify && ops[2].opcode == OpDrop &&
+		isPayToPubKeyHash(ops[3:]):
+		return ScriptClassTimeLock
+	case isHTLC(ops):
+		return ScriptClassHTLC
+	}
+	return ScriptClassNonStandard
+}
+
+// ClassifyScriptHex classifies a hex locking script. Outputs without a script
+// (locked to a bare public key) have an empty class.
+func ClassifyScriptHex(scriptHex string) ScriptClass {
+	if scriptHex == "" {
+		return ""
+	}
+	script, err := ParseScriptHex(scriptHex)
+	if err != nil {
+		return ScriptClassNonStandard
+	}
+	return ClassifyScript(script)
+}
+
+// ExtractPubKeyHash returns the public key hash a pay-to-pubkey-hash script pays to
+func ExtractPubKeyHash(script Script) ([]byte, bool) {
+	ops, err := script.parse()
+	if err != nil || !isPayToPubKeyHash(ops) {
+		return nil, false
+	}
+	return ops[2].data, true
+}
+
+func isPayToPubKeyHash(ops []scriptOp) bool {
+	return len(ops) == 5 &&
+		ops[0].opcode == OpDup &&
+		ops[1].opcode == OpPubKeyHash &&
+		len(ops[2].data) == addressHashLength &&
+		ops[3].opcode == OpEqualVerify &&
+		ops[4].opcode == OpCheckSig
+}
+
+func isHTLC(ops []scriptOp) bool {
+	return len(ops) == 17 &&
+		ops[0].opcode == OpIf &&
+		ops[1].opcode == OpSHA256 && len(ops[2].data) == sha256.Size && ops[3].opcode == OpEqualVerify &&
+		ops[4].opcode == OpDup && ops[5].opcode == OpPubKeyHash && len(ops[6].data) == addressHashLength &&
+		ops[7].opcode == OpElse &&
+		ops[8].opcode <= Op16 && ops[9].opcode == OpCheckLockTimeVerify && ops[10].opcode == OpDrop &&
+		ops[11].opcode == OpDup && ops[12].opcode == OpPubKeyHash && len(ops[13].data) == addressHashLength &&
+		ops[14].opcode == OpEndIf && ops[15].opcode == OpEqualVerify && ops[16].opcode == OpCheckSig
+}
+
+func isMultisig(ops []scriptOp) bool {
+	if len(ops) < 4 || ops[len(ops)-1].opcode != OpCheckMultisig {
+		return false
+	}
+	m, n := ops[0].opcode, ops[len(ops)-2].opcode
+	if !isSmallIntOp(m) || !isSmallIntOp(n) || m > n {
+		return false
+	}
+	keys := ops[1 : len(ops)-2]
+	if len(keys) != int(n-Op1+1) {
+		return false
+	}
+	for _, key := range keys {
+		if len(key.data) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ============================================================================
+// Interpreter
+// ============================================================================
+
+// ScriptContext is what an input's scripts are evaluated against: the spending
+// transaction, for signature checks, and the chain position, for time locks
+type ScriptContext struct {
+	Inputs      []InputData
+	Outputs     []OutputData
+	InputIndex  int
+	BlockHeight int64 // Height of the block the spend would be included in
+	BlockTime   int64 // Unix time the spend is validated at
+}
+
+// VerifyInputScript parses an input's unlocking script and the locking script of
+// the output it spends, and executes them
+func VerifyInputScript(unlockingHex, lockingHex string, ctx *ScriptContext) error {
+	unlocking, err := ParseScriptHex(unlockingHex)
+	if err != nil {
+		return fmt.Errorf("unlocking script: %w", err)
+	}
+	locking, err := ParseScriptHex(lockingHex)
+	if err != nil {
+		return fmt.Errorf("locking script: %w", err)
+	}
+	return ExecuteScript(unlocking, locking, ctx)
+}
+
+// ExecuteScript runs unlocking then locking on a shared stack and succeeds if
+// the top of the stack is true at the end
+func ExecuteScript(unlocking, locking Script, ctx *ScriptContext) error {
+	if !unlocking.IsPushOnly() {
+		return ErrScriptNotPushOnly
+	}
+
+	vm := &scriptVM{ctx: ctx}
+	if err := vm.run(unlocking); err != nil {
+		return err
+	}
+	if err := vm.run(locking); err != nil {
+		return err
+	}
+
+	if len(vm.stack) == 0 || !asBool(vm.stack[len(vm.stack)-1]) {
+		return ErrScriptFalse
+	}
+	return nil
+}
+
+type scriptVM struct {
+	ctx   *ScriptContext
+	stack [][]byte
+}
+
+// run executes one script. Conditionals must be closed within the script.
+func (vm *scriptVM) run(script Script) error {
+	ops, err := script.parse()
+	if err != nil {
+		return err
+	}
+
+	var conditions []bool // One entry per open OP_IF; false while skipping a branch
+	opCount := 0
+	for _, op := range ops {
+		executing := true
+		for _, condition := range conditions {
+			executing = executing && condition
+		}
+
+		if op.opcode > Op16 {
+			opCount++
+			if opCount > maxScriptOps {
+				return fmt.Errorf("script has more than %d operations", maxScriptOps)
+			}
+		}
+
+		// Conditionals are tracked even inside a skipped branch
+		switch op.opcode {
+		case OpIf, OpNotIf:
+			condition := false
+			if executing {
+				item, err := vm.pop()
+				if err != nil {
+					return err
+				}
+				condition = asBool(item)
+				if op.opcode == OpNotIf {
+					condition = !condition
+				}
+			}
+			conditions = append(conditions, condition)
+			continue
+		case OpElse:
+			if len(conditions) == 0 {
+				return errors.New("OP_ELSE without OP_IF")
+			}
+			conditions[len(conditions)-1] = !conditions[len(conditions)-1]
+			continue
+		case OpEndIf:
+			if len(conditions) == 0 {
+				return errors.New("OP_ENDIF without OP_IF")
+			}
+			conditions = conditions[:len(conditions)-1]
+			continue
+		}
+
+		if !executing {
+			continue
+		}
+		if err := vm.step(op); err != nil {
+			return err
+		}
+		if len(vm.stack) > maxScriptStackSize {
+			return fmt.Errorf("script stack is larger than %d items", maxScriptStackSize)
+		}
+	}
+
+	if len(conditions) != 0 {
+		return errors.New("unbalanced conditional in script")
+	}
+	return nil
+}
+
+// step executes a single non-conditional instruction
+func (vm *scriptVM) step(op scriptOp) error {
+	switch {
+	case op.opcode == Op0:
+		vm.push(nil)
+		return nil
+	case op.opcode <= OpPushData2:
+		vm.push(op.data)
+		return nil
+	case op.opcode == Op1Negate:
+		vm.push(scriptNumBytes(-1))
+		return nil
+	case isSmallIntOp(op.opcode):
+		vm.push(scriptNumBytes(int64(op.opcode - Op1 + 1)))
+		return nil
+	}
+
+	switch op.opcode {
+	case OpNop:
+		return nil
+
+	case OpVerify:
+		item, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(item) {
+			return errors.New("OP_VERIFY failed")
+		}
+		return nil
+
+	case OpReturn:
+		return errors.New("OP_RETURN executed")
+
+	case OpDrop:
+		_, err := vm.pop()
+		return err
+
+	case OpDup:
+		item, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(item)
+		return nil
+
+	case OpSwap:
+		if len(vm.stack) < 2 {
+			return errScriptStackUnderrun
+		}
+		n := len(vm.stack)
+		vm.stack[n-1], vm.stack[n-2] = vm.stack[n-2], vm.stack[n-1]
+		return nil
+
+	case OpSize:
+		item, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		vm.push(scriptNumBytes(int64(len(item))))
+		return nil
+
+	case OpEqual, OpEqualVerify:
+		a, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		b, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		equal := bytes.Equal(a, b)
+		if op.opcode == OpEqualVerify {
+			if !equal {
+				return errors.New("OP_EQUALVERIFY failed")
+			}
+			return nil
+		}
+		vm.push(boolBytes(equal))
+		return nil
+
+	case OpSHA256, OpHash256, OpPubKeyHash:
+		item, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		switch op.opcode {
+		case OpSHA256:
+			hash := sha256.Sum256(item)
+			vm.push(hash[:])
+		case OpHash256:
+			first := sha256.Sum256(item)
+			second := sha256.Sum256(first[:])
+			vm.push(second[:])
+		default:
+			vm.push(PublicKeyHash(hex.EncodeToString(item)))
+		}
+		return nil
+
+	case OpCheckSig, OpCheckSigVerify:
+		publicKey, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := vm.pop()
+		if err != nil {
+			return err
+		}
+		valid, err := vm.checkSignature(signature, publicKey)
+		if err != nil {
+			return err
+		}
+		if op.opcode == OpCheckSigVerify {
+			if !valid {
+				return errors.New("OP_CHECKSIGVERIFY failed")
+			}
+			return nil
+		}
+		vm.push(boolBytes(valid))
+		return nil
+
+	case OpCheckMultisig, OpCheckMultisigVerify:
+		valid, err := vm.checkMultisig()
+		if err != nil {
+			return err
+		}
+		if op.opcode == OpCheckMultisigVerify {
+			if !valid {
+				return errors.New("OP_CHECKMULTISIGVERIFY failed")
+			}
+			return nil
+		}
+		vm.push(boolBytes(valid))
+		return nil
+
+	case OpCheckLockTimeVerify:
+		item, err := vm.peek(0)
+		if err != nil {
+			return err
+		}
+		lockTime, err := parseScriptNum(item, lockTimeNumLength)
+		if err != nil {
+			return err
+		}
+		return vm.checkLockTime(lockTime)
+	}
+
+	return fmt.Errorf("unknown opcode 0x%02x", op.opcode)
+}
+
+// checkSignature verifies a script signature against the input's sighash. An
+// empty signature is a valid "no" so scripts can branch on a failed check.
+func (vm *scriptVM) checkSignature(signature, publicKey []byte) (bool, error) {
+	if len(signature) == 0 {
+		return false, nil
+	}
+	if vm.ctx == nil {
+		return false, errors.New("signature check without a transaction")
+	}
+
+	hashType := SigHashType(signature[len(signature)-1])
+	sigHash, err := CalculateSigHash(vm.ctx.Inputs, vm.ctx.Outputs, vm.ctx.InputIndex, hashType)
+	if err != nil {
+		return false, err
+	}
+
+	valid, err := VerifySignature(hex.EncodeToString(publicKey), sigHash, hex.EncodeToString(signature[:len(signature)-1]))
+	if err != nil {
+		return false, fmt.Errorf("malformed signature or public key: %w", err)
+	}
+	return valid, nil
+}
+
+// checkMultisig pops n, n public keys, m and m signatures, and checks that the
+// signatures match a subsequence of the keys in order
+func (vm *scriptVM) checkMultisig() (bool, error) {
+	n, err := vm.popInt(maxScriptNumLength)
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > MaxMultisigKeys {
+		return false, errors.New("invalid multisig key count")
+	}
+	publicKeys := make([][]byte, n)
+	for i := range publicKeys {
+		if publicKeys[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := vm.popInt(maxScriptNumLength)
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, errors.New("invalid multisig signature count")
+	}
+	signatures := make([][]byte, m)
+	for i := range signatures {
+		if signatures[i], err = vm.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	// Items were popped in reverse order; walk both lists from their last entry
+	key := 0
+	for _, signature := range signatures {
+		matched := false
+		for key < len(publicKeys) && !matched {
+			valid, err := vm.checkSignature(signature, publicKeys[key])
+			if err != nil {
+				return false, err
+			}
+			matched = valid
+			key++
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// checkLockTime fails unless the chain has reached lockTime, a block height
+// below LockTimeThreshold or a Unix time at or above it
+func (vm *scriptVM) checkLockTime(lockTime int64) error {
+	if lockTime < 0 {
+		return errors.New("negative lock time")
+	}
+	if vm.ctx == nil {
+		return ErrLockTimeNotReached
+	}
+	if lockTime < LockTimeThreshold {
+		if vm.ctx.BlockHeight < lockTime {
+			return fmt.Errorf("%w until block %d", ErrLockTimeNotReached, lockTime)
+		}
+		return nil
+	}
+	if vm.ctx.BlockTime < lockTime {
+		return fmt.Errorf("%w until Unix time %d", ErrLockTimeNotReached, lockTime)
+	}
+	return nil
+}
+
+func (vm *scriptVM) push(item []byte) {
+	vm.stack = append(vm.stack, item)
+}
+
+func (vm *scriptVM) pop() ([]byte, error) {
+	if len(vm.stack) == 0 {
+		return nil, errScriptStackUnderrun
+	}
+	item := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return item, nil
+}
+
+func (vm *scriptVM) peek(depth int) ([]byte, error) {
+	if depth >= len(vm.stack) {
+		return nil, errScriptStackUnderrun
+	}
+	return vm.stack[len(vm.stack)-1-depth], nil
+}
+
+func (vm *scriptVM) popInt(maxLength int) (int64, error) {
+	item, err := vm.pop()
+	if err != nil {
+		return 0, err
+	}
+	return parseScriptNum(item, maxLength)
+}
+
+// ============================================================================
+// Stack values
+// ============================================================================
+
+// asBool interprets a stack item: false is empty or all zeros (including negative zero)
+func asBool(item []byte) bool {
+	for i, b := range item {
+		if b != 0 {
+			return !(i == len(item)-1 && b == 0x80)
+		}
+	}
+	return false
+}
+
+func boolBytes(value bool) []byte {
+	if value {
+		return []byte{1}
+	}
+	return nil
+}
+
+// scriptNumBytes encodes n as a minimal little-endian sign-magnitude number
+func scriptNumBytes(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	magnitude := uint64(n)
+	if negative {
+		magnitude = uint64(-n)
+	}
+
+	var result []byte
+	for magnitude > 0 {
+		result = append(result, byte(magnitude&0xff))
+		magnitude >>= 8
+	}
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// parseScriptNum decodes a minimally encoded number of at most maxLength bytes
+func parseScriptNum(item []byte, maxLength int) (int64, error) {
+	if len(item) > maxLength {
+		return 0, fmt.Errorf("script number is longer than %d bytes", maxLength)
+	}
+	if len(item) == 0 {
+		return 0, nil
+	}
+	// The top byte may only be 0x00 or 0x80 if it is needed for the sign bit
+	last := item[len(item)-1]
+	if last&0x7f == 0 && (len(item) == 1 || item[len(item)-2]&0x80 == 0) {
+		return 0, errors.New("script number is not minimally encoded")
+	}
+
+	var result int64
+	for i, b := range item {
+		result |= int64(b) << (8 * i)
+	}
+	if last&0x80 != 0 {
+		result &^= int64(0x80) << (8 * (len(item) - 1))
+		return -result, nil
+	}
+	return result, nil
+}
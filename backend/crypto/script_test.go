@@ -0,0 +1,282 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto-wallet-backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func testKeyPair(t *testing.T, keyType models.KeyType) *KeyPair {
+	t.Helper()
+	masterKey, err := GenerateMasterKey(keyType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := masterKey.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyPair
+}
+
+func testScriptContext(height int64) *ScriptContext {
+	return &ScriptContext{
+		Inputs:      []InputData{{TransactionID: "source", OutputIndex: 0, Amount: 150}},
+		Outputs:     []OutputData{{WalletID: "recipient", Amount: 100}, {WalletID: "change", Amount: 50}},
+		BlockHeight: height,
+		BlockTime:   1700000000,
+	}
+}
+
+// testScriptSignature signs the context's input and encodes it for a script
+func testScriptSignature(t *testing.T, keyPair *KeyPair, ctx *ScriptContext) []byte {
+	t.Helper()
+	signatureHex, err := SignInput(keyPair.KeyType, keyPair.PrivateKeyHex, ctx.Inputs, ctx.Outputs, ctx.InputIndex, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ScriptSignature(signatureHex, SigHashAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+// mustScript panics if a script failed to build, like regexp.MustCompile
+func mustScript(script Script, err error) Script {
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// pushes builds a push-only script, pushing nil items as OP_0
+func pushes(items ...[]byte) Script {
+	b := NewScriptBuilder()
+	for _, item := range items {
+		if item == nil {
+			b.AddOp(Op0)
+		} else {
+			b.AddData(item)
+		}
+	}
+	return mustScript(b.Script())
+}
+
+func publicKeyBytes(keyPair *KeyPair) []byte {
+	data, _ := hex.DecodeString(keyPair.PublicKeyHex)
+	return data
+}
+
+func TestExecuteScript(t *testing.T) {
+	alice := testKeyPair(t, models.KeyTypeP256)
+	bob := testKeyPair(t, models.KeyTypeSecp256k1)
+	carol := testKeyPair(t, models.KeyTypeEd25519)
+
+	ctx := testScriptContext(100)
+	aliceSig := testScriptSignature(t, alice, ctx)
+	bobSig := testScriptSignature(t, bob, ctx)
+	carolSig := testScriptSignature(t, carol, ctx)
+
+	// A signature over different outputs
+	other := testScriptContext(100)
+	other.Outputs[0].Amount = 101
+	staleSig := testScriptSignature(t, alice, other)
+
+	p2pkh := PayToPubKeyHashScript(PublicKeyHash(alice.PublicKeyHex))
+	multisig := mustScript(MultisigScript(2, []string{alice.PublicKeyHex, bob.PublicKeyHex, carol.PublicKeyHex}))
+
+	preimage := []byte("open sesame")
+	hash := sha256.Sum256(preimage)
+	hashLock := mustScript(HashLockScript(hash[:], PublicKeyHash(alice.PublicKeyHex)))
+	heightLock := mustScript(TimeLockScript(100, PublicKeyHash(alice.PublicKeyHex)))
+	timeLock := mustScript(TimeLockScript(ctx.BlockTime, PublicKeyHash(alice.PublicKeyHex)))
+
+	htlc := mustScript(HTLCScript(&HTLC{
+		HashLock:         hash[:],
+		RecipientKeyHash: PublicKeyHash(bob.PublicKeyHex),
+		SenderKeyHash:    PublicKeyHash(alice.PublicKeyHex),
+		ExpiryHeight:     100,
+	}))
+	claim := pushes(bobSig, publicKeyBytes(bob), preimage, []byte{1})
+	refund := pushes(aliceSig, publicKeyBytes(alice), nil)
+
+	tests := []struct {
+		name      string
+		unlocking Script
+		locking   Script
+		height    int64
+		wantErr   bool
+		want      error // Checked with errors.Is when set
+	}{
+		{"p2pkh", pushes(aliceSig, publicKeyBytes(alice)), p2pkh, 100, false, nil},
+		{"p2pkh wrong key", pushes(bobSig, publicKeyBytes(bob)), p2pkh, 100, true, nil},
+		{"p2pkh stale signature", pushes(staleSig, publicKeyBytes(alice)), p2pkh, 100, true, ErrScriptFalse},
+		{"p2pkh no signature", pushes(nil, publicKeyBytes(alice)), p2pkh, 100, true, ErrScriptFalse},
+
+		{"multisig in key order", pushes(aliceSig, carolSig), multisig, 100, false, nil},
+		{"multisig adjacent keys", pushes(bobSig, carolSig), multisig, 100, false, nil},
+		{"multisig out of key order", pushes(carolSig, aliceSig), multisig, 100, true, ErrScriptFalse},
+		{"multisig same signature twice", pushes(aliceSig, aliceSig), multisig, 100, true, ErrScriptFalse},
+		{"multisig below threshold", pushes(aliceSig), multisig, 100, true, nil},
+
+		{"hashlock", pushes(aliceSig, publicKeyBytes(alice), preimage), hashLock, 100, false, nil},
+		{"hashlock wrong preimage", pushes(aliceSig, publicKeyBytes(alice), []byte("guess")), hashLock, 100, true, nil},
+
+		{"cltv height reached", pushes(aliceSig, publicKeyBytes(alice)), heightLock, 100, false, nil},
+		{"cltv height not reached", pushes(aliceSig, publicKeyBytes(alice)), heightLock, 99, true, ErrLockTimeNotReached},
+		{"cltv time reached", pushes(aliceSig, publicKeyBytes(alice)), timeLock, 1, false, nil},
+
+		{"htlc claim", claim, htlc, 1, false, nil},
+		{"htlc claim wrong preimage", pushes(bobSig, publicKeyBytes(bob), []byte("guess"), []byte{1}), htlc, 1, true, nil},
+		{"htlc claim by sender", pushes(aliceSig, publicKeyBytes(alice), preimage, []byte{1}), htlc, 1, true, nil},
+		{"htlc refund after expiry", refund, htlc, 100, false, nil},
+		{"htlc refund before expiry", refund, htlc, 99, true, ErrLockTimeNotReached},
+		{"htlc refund by recipient", pushes(bobSig, publicKeyBytes(bob), nil), htlc, 100, true, nil},
+
+		{"unlocking script with opcodes", mustScript(NewScriptBuilder().AddData(aliceSig).AddData(publicKeyBytes(alice)).AddOp(OpDup).AddOp(OpDrop).Script()), p2pkh, 100, true, ErrScriptNotPushOnly},
+		{"unlocking script that returns", mustScript(NewScriptBuilder().AddOp(OpReturn).Script()), p2pkh, 100, true, ErrScriptNotPushOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testScriptContext(tt.height)
+			err := ExecuteScript(tt.unlocking, tt.locking, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+
+			// The hex entry point behaves the same
+			hexErr := VerifyInputScript(tt.unlocking.Hex(), tt.locking.Hex(), ctx)
+			if (hexErr != nil) != tt.wantErr {
+				t.Fatalf("VerifyInputScript err = %v, want error %v", hexErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTLCUnlockingScripts(t *testing.T) {
+	recipient := testKeyPair(t, models.KeyTypeP256)
+	sender := testKeyPair(t, models.KeyTypeP256)
+
+	preimage := []byte("secret")
+	hash := sha256.Sum256(preimage)
+	terms := &HTLC{
+		HashLock:         hash[:],
+		RecipientKeyHash: PublicKeyHash(recipient.PublicKeyHex),
+		SenderKeyHash:    PublicKeyHash(sender.PublicKeyHex),
+		ExpiryHeight:     500,
+	}
+	locking := mustScript(HTLCScript(terms))
+
+	extracted, ok := ExtractHTLC(locking)
+	if !ok || !bytes.Equal(extracted.HashLock, terms.HashLock) || extracted.ExpiryHeight != terms.ExpiryHeight ||
+		!bytes.Equal(extracted.RecipientKeyHash, terms.RecipientKeyHash) || !bytes.Equal(extracted.SenderKeyHash, terms.SenderKeyHash) {
+		t.Fatalf("ExtractHTLC = %+v, %v", extracted, ok)
+	}
+	if class := ClassifyScript(locking); class != ScriptClassHTLC {
+		t.Fatalf("class = %q, want %q", class, ScriptClassHTLC)
+	}
+
+	ctx := testScriptContext(499)
+	sign := func(keyPair *KeyPair) string {
+		signature, err := SignInput(keyPair.KeyType, keyPair.PrivateKeyHex, ctx.Inputs, ctx.Outputs, 0, SigHashAll)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+
+	claim := mustScript(HTLCClaimScript(sign(recipient), SigHashAll, recipient.PublicKeyHex, preimage))
+	if err := ExecuteScript(claim, locking, ctx); err != nil {
+		t.Errorf("claim before expiry: %v", err)
+	}
+
+	refund := mustScript(HTLCRefundScript(sign(sender), SigHashAll, sender.PublicKeyHex))
+	if err := ExecuteScript(refund, locking, ctx); !errors.Is(err, ErrLockTimeNotReached) {
+		t.Errorf("refund before expiry: err = %v, want %v", err, ErrLockTimeNotReached)
+	}
+	ctx.BlockHeight = 500
+	if err := ExecuteScript(refund, locking, ctx); err != nil {
+		t.Errorf("refund at expiry: %v", err)
+	}
+}
+
+func TestScriptNumbers(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    []byte
+		want    int64
+		wantErr bool
+	}{
+		{"zero", nil, 0, false},
+		{"one", []byte{0x01}, 1, false},
+		{"minus one", []byte{0x81}, -1, false},
+		{"127", []byte{0x7f}, 127, false},
+		{"128 needs a sign byte", []byte{0x80, 0x00}, 128, false},
+		{"minus 128", []byte{0x80, 0x80}, -128, false},
+		{"255", []byte{0xff, 0x00}, 255, false},
+		{"256", []byte{0x00, 0x01}, 256, false},
+		{"max four bytes", []byte{0xff, 0xff, 0xff, 0x7f}, 1<<31 - 1, false},
+		{"zero with a byte", []byte{0x00}, 0, true},
+		{"negative zero", []byte{0x80}, 0, true},
+		{"padded one", []byte{0x01, 0x00}, 0, true},
+		{"padded minus one", []byte{0x01, 0x80}, 0, true},
+		{"padded 127", []byte{0x7f, 0x00, 0x00}, 0, true},
+		{"too long", []byte{0x01, 0x02, 0x03, 0x04, 0x05}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScriptNum(tt.item, maxScriptNumLength)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("parseScriptNum = %d, want %d", got, tt.want)
+			}
+			if encoded := scriptNumBytes(got); !bytes.Equal(encoded, tt.item) {
+				t.Fatalf("scriptNumBytes(%d) = %x, want %x", got, encoded, tt.item)
+			}
+		})
+	}
+}
+
+func TestNonMinimalNumbersInScripts(t *testing.T) {
+	keyPair := testKeyPair(t, models.KeyTypeP256)
+	ctx := testScriptContext(1000)
+	signature := testScriptSignature(t, keyPair, ctx)
+	unlocking := pushes(signature, publicKeyBytes(keyPair))
+	pubKeyHash := PublicKeyHash(keyPair.PublicKeyHex)
+
+	// Lock height 100 pushed as 0x64 0x00 instead of 0x64
+	padded := mustScript(NewScriptBuilder().
+		AddData([]byte{0x64, 0x00}).AddOp(OpCheckLockTimeVerify).AddOp(OpDrop).
+		AddOp(OpDup).AddOp(OpPubKeyHash).AddData(pubKeyHash).AddOp(OpEqualVerify).
+		AddOp(OpCheckSig).
+		Script())
+	if err := ExecuteScript(unlocking, padded, ctx); err == nil {
+		t.Error("accepted a non-minimally encoded lock time")
+	}
+
+	minimal := mustScript(TimeLockScript(100, pubKeyHash))
+	if err := ExecuteScript(unlocking, minimal, ctx); err != nil {
+		t.Errorf("minimal lock time: %v", err)
+	}
+
+	// A multisig key count of 0x01 0x00 instead of OP_1
+	multisig := mustScript(NewScriptBuilder().
+		AddInt64(1).AddData(publicKeyBytes(keyPair)).AddData([]byte{0x01, 0x00}).AddOp(OpCheckMultisig).
+		Script())
+	if err := ExecuteScript(pushes(signature), multisig, ctx); err == nil {
+		t.Error("accepted a non-minimally encoded key count")
+	}
+}
@@ -0,0 +1,607 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto-wallet-backend/models"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Encoding versions. Bump these whenever the binary layout changes so that
+// transaction IDs and block hashes produced by older code can still be recognised.
+const (
+	TransactionEncodingVersion uint32 = 4 // Version 1 has no locking scripts, version 2 no time locks, version 3 no coinbase data
+	BlockHeaderVersion         int    = 4 // Version 0/1 blocks used the legacy string hash, version 2 a leading-zero difficulty, version 3 no coinbase commitment
+)
+
+// Witness flag written after the transaction version
+const (
+	txFlagNoWitness           byte = 0x00
+	txFlagWithWitness         byte = 0x01
+	txFlagWithMultisigWitness byte = 0x02 // Witness including redeem policies and cosignatures
+	txFlagWithScriptWitness   byte = 0x03 // Multisig witness followed by unlocking scripts
+)
+
+// Upper bounds used while decoding to reject corrupt or hostile input early
+const (
+	maxEncodedStringLength = 1 << 16
+	maxEncodedItemCount    = 1 << 16
+)
+
+// Smallest encodings of repeated items, which bound how many the rest of the
+// input can hold before anything is allocated for them
+const (
+	minEncodedInputSize       = 1 + 4 + 8 // Empty transaction ID, output index, amount
+	minEncodedOutputSize      = 1 + 8 + 1 // Empty wallet ID, amount, empty public key
+	minEncodedCosignatureSize = 1 + 1     // Empty public key and signature
+	minEncodedStringSize      = 1         // Length of an empty string
+)
+
+var ErrTrailingBytes = errors.New("unexpected trailing bytes after encoded value")
+
+// ErrNonCanonicalEncoding is returned for a transaction encoded with a higher
+// version or witness flag than its contents need, which would re-encode to
+// different bytes and a different ID
+var ErrNonCanonicalEncoding = errors.New("transaction is not canonically encoded")
+
+// ============================================================================
+// Transactions
+// ============================================================================
+
+// SerializeTransaction encodes a transaction in the canonical binary format:
+//
+//	version u32 | flag u8 | type str | sender str | timestamp i64 | message str |
+//	input count | inputs... | output count | outputs...
+//
+// Strings and counts are prefixed with an unsigned varint length, integers are
+// big-endian and amounts are signed 64-bit base units. Each input is
+//
+//	source txId str | output index u32 | amount i64 [| public key str | signature str | sighash u8]
+//
+// where the bracketed witness part is only present when withWitness is true.
+// If any input spends from a multisig address the flag is 0x02 and every input's
+// witness continues with
+//
+//	redeem policy str | cosignature count | (public key str | signature str)...
+//
+// If any input has an unlocking script the flag is 0x03 and every input's witness
+// carries the multisig fields followed by its unlocking script str.
+//
+// Each output is wallet ID str | amount i64 | public key str, followed by its
+// locking script str from version 2 and by lock until i64 | relative lock i64 in
+// version 3, which also writes the transaction's lock time i64 after the message.
+// Version 4 follows the lock time with the coinbase data str of a coinbase.
+// Transactions are encoded with the oldest version that can represent them so
+// the IDs of existing transactions don't change.
+// Timestamps are encoded with second precision.
+func SerializeTransaction(tx *models.Transaction, withWitness bool) []byte {
+	var buf bytes.Buffer
+	buf.Grow(TransactionSize(tx, withWitness))
+
+	version := transactionEncodingVersion(tx)
+	flag := witnessFlag(tx, withWitness)
+	writeUint32(&buf, version)
+	buf.WriteByte(flag)
+	writeString(&buf, string(tx.Type))
+	writeString(&buf, tx.SenderWallet)
+	writeInt64(&buf, tx.Timestamp.Unix())
+	writeString(&buf, tx.Message)
+	if version >= 3 {
+		writeInt64(&buf, tx.LockTime)
+	}
+	if version >= 4 {
+		writeString(&buf, tx.CoinbaseData)
+	}
+
+	writeUvarint(&buf, uint64(len(tx.Inputs)))
+	for _, input := range tx.Inputs {
+		writeString(&buf, input.TransactionID)
+		writeUint32(&buf, uint32(input.OutputIndex))
+		writeInt64(&buf, int64(input.Amount))
+		if withWitness {
+			writeString(&buf, input.PublicKey)
+			writeString(&buf, input.Signature)
+			buf.WriteByte(input.SigHashType)
+		}
+		if flag >= txFlagWithMultisigWitness {
+			writeString(&buf, input.RedeemPolicy)
+			writeUvarint(&buf, uint64(len(input.Cosignatures)))
+			for _, cosignature := range input.Cosignatures {
+				writeString(&buf, cosignature.PublicKey)
+				writeString(&buf, cosignature.Signature)
+			}
+		}
+		if flag == txFlagWithScriptWitness {
+			writeString(&buf, input.UnlockingScript)
+		}
+	}
+
+	writeUvarint(&buf, uint64(len(tx.Outputs)))
+	for _, output := range tx.Outputs {
+		writeString(&buf, output.WalletID)
+		writeInt64(&buf, int64(output.Amount))
+		writeString(&buf, output.PublicKey)
+		if version >= 2 {
+			writeString(&buf, output.LockingScript)
+		}
+		if version >= 3 {
+			writeInt64(&buf, output.LockUntil)
+			writeInt64(&buf, output.RelativeLock)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// DeserializeTransaction decodes a transaction produced by SerializeTransaction.
+// Totals and fee are recomputed from the inputs and outputs. Encodings other
+// than the one SerializeTransaction would produce are rejected.
+func DeserializeTransaction(data []byte) (*models.Transaction, error) {
+	r := bytes.NewReader(data)
+
+	version, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if version < 1 || version > TransactionEncodingVersion {
+		return nil, fmt.Errorf("unsupported transaction encoding version %d", version)
+	}
+
+	flag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if flag > txFlagWithScriptWitness {
+		return nil, fmt.Errorf("invalid transaction flag 0x%02x", flag)
+	}
+	withWitness := flag != txFlagNoWitness
+
+	tx := &models.Transaction{}
+
+	txType, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	tx.Type = models.TransactionType(txType)
+
+	if tx.SenderWallet, err = readString(r); err != nil {
+		return nil, err
+	}
+
+	unixTime, err := readInt64(r)
+	if err != nil {
+		return nil, err
+	}
+	tx.Timestamp = time.Unix(unixTime, 0)
+
+	if tx.Message, err = readString(r); err != nil {
+		return nil, err
+	}
+	if version >= 3 {
+		if tx.LockTime, err = readInt64(r); err != nil {
+			return nil, err
+		}
+	}
+	if version >= 4 {
+		if tx.CoinbaseData, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	inputCount, err := readCount(r, minEncodedInputSize)
+	if err != nil {
+		return nil, err
+	}
+	tx.Inputs = make([]models.SignedInput, inputCount)
+	for i := range tx.Inputs {
+		input := &tx.Inputs[i]
+		if input.TransactionID, err = readString(r); err != nil {
+			return nil, err
+		}
+		outputIndex, err := readUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		input.OutputIndex = int(outputIndex)
+		inputAmount, err := readInt64(r)
+		if err != nil {
+			return nil, err
+		}
+		input.Amount = models.Amount(inputAmount)
+		if withWitness {
+			if input.PublicKey, err = readString(r); err != nil {
+				return nil, err
+			}
+			if input.Signature, err = readString(r); err != nil {
+				return nil, err
+			}
+			if input.SigHashType, err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		if flag >= txFlagWithMultisigWitness {
+			if input.RedeemPolicy, err = readString(r); err != nil {
+				return nil, err
+			}
+			cosignatureCount, err := readCount(r, minEncodedCosignatureSize)
+			if err != nil {
+				return nil, err
+			}
+			if cosignatureCount > 0 {
+				input.Cosignatures = make([]models.CosignerSignature, cosignatureCount)
+			}
+			for j := range input.Cosignatures {
+				if input.Cosignatures[j].PublicKey, err = readString(r); err != nil {
+					return nil, err
+				}
+				if input.Cosignatures[j].Signature, err = readString(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if flag == txFlagWithScriptWitness {
+			if input.UnlockingScript, err = readString(r); err != nil {
+				return nil, err
+			}
+		}
+		tx.TotalInput += input.Amount
+	}
+
+	outputCount, err := readCount(r, minEncodedOutputSize)
+	if err != nil {
+		return nil, err
+	}
+	tx.Outputs = make([]models.TransactionOutput, outputCount)
+	for i := range tx.Outputs {
+		output := &tx.Outputs[i]
+		if output.WalletID, err = readString(r); err != nil {
+			return nil, err
+		}
+		outputAmount, err := readInt64(r)
+		if err != nil {
+			return nil, err
+		}
+		output.Amount = models.Amount(outputAmount)
+		if output.PublicKey, err = readString(r); err != nil {
+			return nil, err
+		}
+		if version >= 2 {
+			if output.LockingScript, err = readString(r); err != nil {
+				return nil, err
+			}
+		}
+		if version >= 3 {
+			if output.LockUntil, err = readInt64(r); err != nil {
+				return nil, err
+			}
+			if output.RelativeLock, err = readInt64(r); err != nil {
+				return nil, err
+			}
+		}
+		tx.TotalOutput += output.Amount
+	}
+
+	if r.Len() != 0 {
+		return nil, ErrTrailingBytes
+	}
+	if version != transactionEncodingVersion(tx) || (withWitness && flag != witnessFlag(tx, true)) {
+		return nil, ErrNonCanonicalEncoding
+	}
+
+	if tx.Type != models.TxTypeCoinbase {
+		tx.Fee = tx.TotalInput - tx.TotalOutput
+	}
+
+	return tx, nil
+}
+
+// TransactionSize returns the length of SerializeTransaction's output without encoding it
+func TransactionSize(tx *models.Transaction, withWitness bool) int {
+	size := 4 + 1 // version + flag
+	size += stringSize(string(tx.Type))
+	size += stringSize(tx.SenderWallet)
+	size += 8 // timestamp
+	size += stringSize(tx.Message)
+
+	version := transactionEncodingVersion(tx)
+	if version >= 3 {
+		size += 8 // lock time
+	}
+	if version >= 4 {
+		size += stringSize(tx.CoinbaseData)
+	}
+
+	flag := witnessFlag(tx, withWitness)
+	size += uvarintSize(uint64(len(tx.Inputs)))
+	for _, input := range tx.Inputs {
+		size += stringSize(input.TransactionID) + 4 + 8
+		if withWitness {
+			size += stringSize(input.PublicKey) + stringSize(input.Signature) + 1
+		}
+		if flag >= txFlagWithMultisigWitness {
+			size += stringSize(input.RedeemPolicy) + uvarintSize(uint64(len(input.Cosignatures)))
+			for _, cosignature := range input.Cosignatures {
+				size += stringSize(cosignature.PublicKey) + stringSize(cosignature.Signature)
+			}
+		}
+		if flag == txFlagWithScriptWitness {
+			size += stringSize(input.UnlockingScript)
+		}
+	}
+
+	size += uvarintSize(uint64(len(tx.Outputs)))
+	for _, output := range tx.Outputs {
+		size += stringSize(output.WalletID) + 8 + stringSize(output.PublicKey)
+		if version >= 2 {
+			size += stringSize(output.LockingScript)
+		}
+		if version >= 3 {
+			size += 8 + 8 // lock until + relative lock
+		}
+	}
+
+	return size
+}
+
+// witnessFlag returns the flag byte for a transaction's encoding. The multisig
+// and script witness layouts are only used when needed, so other transactions
+// encode as before.
+func witnessFlag(tx *models.Transaction, withWitness bool) byte {
+	if !withWitness {
+		return txFlagNoWitness
+	}
+	flag := txFlagWithWitness
+	for _, input := range tx.Inputs {
+		if input.UnlockingScript != "" {
+			return txFlagWithScriptWitness
+		}
+		if input.RedeemPolicy != "" || len(input.Cosignatures) > 0 {
+			flag = txFlagWithMultisigWitness
+		}
+	}
+	return flag
+}
+
+// transactionEncodingVersion returns the oldest encoding version that can
+// represent the transaction
+func transactionEncodingVersion(tx *models.Transaction) uint32 {
+	version := uint32(1)
+	if tx.CoinbaseData != "" {
+		return 4
+	}
+	if tx.LockTime != 0 {
+		return 3
+	}
+	for _, output := range tx.Outputs {
+		if output.LockUntil != 0 || output.RelativeLock != 0 {
+			return 3
+		}
+		if output.LockingScript != "" {
+			version = 2
+		}
+	}
+	return version
+}
+
+// ============================================================================
+// Block headers
+// ============================================================================
+
+// SerializeBlockHeader encodes the fields covered by proof-of-work:
+//
+//	version u32 | index u64 | previous hash bytes | timestamp i64 | merkle root bytes | bits u32 | nonce u64
+//
+// Version 2 headers carry the leading-zero difficulty in place of the compact
+// target bits. Hashes are hex-decoded and length-prefixed. The nonce is always the last
+// 8 bytes so miners can update it in place.
+func SerializeBlockHeader(header *models.BlockHeader) ([]byte, error) {
+	previousHash, err := hex.DecodeString(header.PreviousHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid previous hash: %v", err)
+	}
+	merkleRoot, err := hex.DecodeString(header.MerkleRoot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merkle root: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writeUint32(&buf, uint32(header.Version))
+	writeUint64(&buf, uint64(header.Index))
+	writeBytes(&buf, previousHash)
+	writeInt64(&buf, header.Timestamp.Unix())
+	writeBytes(&buf, merkleRoot)
+	if header.Version >= compactTargetVersion {
+		writeUint32(&buf, header.Bits)
+	} else {
+		writeUint32(&buf, uint32(header.Difficulty))
+	}
+	writeUint64(&buf, uint64(header.Nonce))
+
+	return buf.Bytes(), nil
+}
+
+// DeserializeBlockHeader decodes a header produced by SerializeBlockHeader.
+// The returned header's Hash is left empty.
+func DeserializeBlockHeader(data []byte) (*models.BlockHeader, error) {
+	r := bytes.NewReader(data)
+	header := &models.BlockHeader{}
+
+	version, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	header.Version = int(version)
+
+	index, err := readUint64(r)
+	if err != nil {
+		return nil, err
+	}
+	header.Index = int64(index)
+
+	previousHash, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	header.PreviousHash = hex.EncodeToString(previousHash)
+
+	unixTime, err := readInt64(r)
+	if err != nil {
+		return nil, err
+	}
+	header.Timestamp = time.Unix(unixTime, 0)
+
+	merkleRoot, err := readBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	header.MerkleRoot = hex.EncodeToString(merkleRoot)
+
+	target, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version >= compactTargetVersion {
+		header.Bits = target
+	} else {
+		header.Difficulty = int(target)
+	}
+
+	nonce, err := readUint64(r)
+	if err != nil {
+		return nil, err
+	}
+	header.Nonce = int64(nonce)
+
+	if r.Len() != 0 {
+		return nil, ErrTrailingBytes
+	}
+
+	return header, nil
+}
+
+// BlockSize returns the serialized size of a block: its header followed by a
+// count-prefixed list of transactions including their witness data
+func BlockSize(header *models.BlockHeader, transactions []models.Transaction) (int64, error) {
+	headerBytes, err := SerializeBlockHeader(header)
+	if err != nil {
+		return 0, err
+	}
+
+	size := len(headerBytes) + uvarintSize(uint64(len(transactions)))
+	for i := range transactions {
+		size += TransactionSize(&transactions[i], true)
+	}
+
+	return int64(size), nil
+}
+
+// ============================================================================
+// Primitive helpers
+// ============================================================================
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	buf.Write(b[:])
+}
+
+func writeInt64(buf *bytes.Buffer, v int64) {
+	writeUint64(buf, uint64(v))
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	buf.Write(b[:n])
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	writeUvarint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeUvarint(buf, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func uvarintSize(v uint64) int {
+	size := 1
+	for v >= 0x80 {
+		v >>= 7
+		size++
+	}
+	return size
+}
+
+func stringSize(s string) int {
+	return uvarintSize(uint64(len(s))) + len(s)
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+func readUint64(r *bytes.Reader) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func readInt64(r *bytes.Reader) (int64, error) {
+	v, err := readUint64(r)
+	return int64(v), err
+}
+
+// readCount reads the number of items that follow, each encoded in at least
+// minItemSize bytes. Counts the remaining input can't hold are rejected, so a
+// short input can't make the caller allocate room for many items.
+func readCount(r *bytes.Reader, minItemSize int) (int, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > maxEncodedItemCount {
+		return 0, fmt.Errorf("item count %d exceeds limit", n)
+	}
+	if n*uint64(minItemSize) > uint64(r.Len()) {
+		return 0, fmt.Errorf("item count %d exceeds available data", n)
+	}
+	return int(n), nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxEncodedStringLength || n > uint64(r.Len()) {
+		return nil, fmt.Errorf("encoded length %d exceeds available data", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	data, err := readBytes(r)
+	return string(data), err
+}
@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto-wallet-backend/models"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTransfer() *models.Transaction {
+	return &models.Transaction{
+		TransactionID: "ignored",
+		Type:          models.TxTypeTransfer,
+		SenderWallet:  "sender",
+		Timestamp:     time.Unix(1700000000, 0),
+		Message:       "rent",
+		Inputs: []models.SignedInput{{
+			TransactionID: "source",
+			OutputIndex:   1,
+			Amount:        150,
+			PublicKey:     "04ab",
+			Signature:     "3045",
+			SigHashType:   uint8(SigHashAll),
+		}},
+		Outputs: []models.TransactionOutput{
+			{WalletID: "recipient", Amount: 100, PublicKey: "04cd"},
+			{WalletID: "sender", Amount: 50, PublicKey: "04ab"},
+		},
+	}
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint32
+		modify  func(tx *models.Transaction)
+	}{
+		{"plain", 1, func(tx *models.Transaction) {}},
+		{"locking script", 2, func(tx *models.Transaction) {
+			tx.Outputs[0].LockingScript = "76a914"
+		}},
+		{"lock time", 3, func(tx *models.Transaction) {
+			tx.LockTime = 42
+		}},
+		{"output time locks", 3, func(tx *models.Transaction) {
+			tx.Outputs[0].LockUntil = 1800000000
+			tx.Outputs[1].RelativeLock = 6
+		}},
+		{"coinbase", 4, func(tx *models.Transaction) {
+			tx.Type = models.TxTypeCoinbase
+			tx.Inputs = nil
+			tx.CoinbaseData = CoinbaseData(7, 3)
+		}},
+		{"multisig witness", 1, func(tx *models.Transaction) {
+			tx.Inputs[0].PublicKey = ""
+			tx.Inputs[0].Signature = ""
+			tx.Inputs[0].RedeemPolicy = "0201"
+			tx.Inputs[0].Cosignatures = []models.CosignerSignature{{PublicKey: "04aa", Signature: "30"}, {PublicKey: "04bb", Signature: "31"}}
+		}},
+		{"script witness", 2, func(tx *models.Transaction) {
+			tx.Outputs[1].LockingScript = "76a914"
+			tx.Inputs[0].UnlockingScript = "4730"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testTransfer()
+			tt.modify(tx)
+			if got := transactionEncodingVersion(tx); got != tt.version {
+				t.Fatalf("encoding version = %d, want %d", got, tt.version)
+			}
+
+			for _, withWitness := range []bool{false, true} {
+				data := SerializeTransaction(tx, withWitness)
+				if len(data) != TransactionSize(tx, withWitness) {
+					t.Errorf("witness %v: size %d, TransactionSize %d", withWitness, len(data), TransactionSize(tx, withWitness))
+				}
+
+				decoded, err := DeserializeTransaction(data)
+				if err != nil {
+					t.Fatalf("witness %v: decode: %v", withWitness, err)
+				}
+				if again := SerializeTransaction(decoded, withWitness); !bytes.Equal(again, data) {
+					t.Errorf("witness %v: re-encoding differs", withWitness)
+				}
+				if GenerateTransactionID(decoded) != GenerateTransactionID(tx) {
+					t.Errorf("witness %v: ID changed after round trip", withWitness)
+				}
+				if decoded.TotalInput != 150 && tx.Type != models.TxTypeCoinbase {
+					t.Errorf("witness %v: total input %s, want 150", withWitness, decoded.TotalInput)
+				}
+			}
+		})
+	}
+}
+
+func TestTransactionIDIgnoresWitness(t *testing.T) {
+	tx := testTransfer()
+	id := GenerateTransactionID(tx)
+	tx.Inputs[0].Signature = "3046"
+	tx.Inputs[0].UnlockingScript = "4730"
+	if GenerateTransactionID(tx) != id {
+		t.Fatal("signatures changed the transaction ID")
+	}
+}
+
+func TestDeserializeTransactionRejectsNonCanonical(t *testing.T) {
+	// Without outputs the version 1 and 2 layouts only differ in the version field
+	noOutputs := testTransfer()
+	noOutputs.Outputs = nil
+	v1 := SerializeTransaction(noOutputs, false)
+	v2 := append([]byte(nil), v1...)
+	v2[3] = 2
+
+	// A version 4 encoding with empty coinbase data after the lock time
+	locked := testTransfer()
+	locked.Outputs = nil
+	locked.LockTime = 5
+	v3 := SerializeTransaction(locked, false)
+	prefix := 4 + 1 + stringSize(string(locked.Type)) + stringSize(locked.SenderWallet) + 8 + stringSize(locked.Message) + 8
+	v4 := append(append(append([]byte(nil), v3[:prefix]...), 0), v3[prefix:]...)
+	v4[3] = 4
+
+	// A multisig witness flag with an empty policy and no cosignatures
+	witness := SerializeTransaction(noOutputs, true)
+	multisig := append(append([]byte(nil), witness[:len(witness)-1]...), 0, 0, 0)
+	multisig[4] = txFlagWithMultisigWitness
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"canonical version 1", v1, nil},
+		{"canonical version 3", v3, nil},
+		{"canonical witness", witness, nil},
+		{"version 2 without locking scripts", v2, ErrNonCanonicalEncoding},
+		{"version 4 without coinbase data", v4, ErrNonCanonicalEncoding},
+		{"multisig flag without multisig inputs", multisig, ErrNonCanonicalEncoding},
+		{"trailing bytes", append(append([]byte(nil), v1...), 0), ErrTrailingBytes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DeserializeTransaction(tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeserializeTransactionRejectsOversizedCounts(t *testing.T) {
+	empty := testTransfer()
+	empty.Inputs = nil
+	empty.Outputs = nil
+	data := SerializeTransaction(empty, false)
+	header := data[:len(data)-2] // Drop the input and output counts
+
+	manyInputs := binary.AppendUvarint(append([]byte(nil), header...), maxEncodedItemCount)
+	manyOutputs := binary.AppendUvarint(append(append([]byte(nil), header...), 0), maxEncodedItemCount)
+	// One input with an empty ID, public key, signature and redeem policy
+	multisig := append(append([]byte(nil), header...), 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	multisig[4] = txFlagWithMultisigWitness
+	multisig = binary.AppendUvarint(multisig, maxEncodedItemCount)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"inputs", manyInputs},
+		{"outputs", manyOutputs},
+		{"cosignatures", multisig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			_, err := DeserializeTransaction(tt.data)
+			runtime.ReadMemStats(&after)
+			if err == nil || !strings.Contains(err.Error(), "exceeds available data") {
+				t.Fatalf("err = %v, want a count the input can't hold", err)
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<16 {
+				t.Fatalf("allocated %d bytes for %d bytes of input", allocated, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestBlockHeaderRoundTrip(t *testing.T) {
+	headers := []models.BlockHeader{
+		{Version: 2, Index: 5, PreviousHash: "00ff", Timestamp: time.Unix(1700000000, 0), MerkleRoot: "abcd", Nonce: 99, Difficulty: 4},
+		{Version: BlockHeaderVersion, Index: 6, PreviousHash: "00ff", Timestamp: time.Unix(1700000030, 0), MerkleRoot: "abcd", Nonce: 1 << 40, Bits: 0x1f00ffff},
+	}
+	for _, header := range headers {
+		data, err := SerializeBlockHeader(&header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DeserializeBlockHeader(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *decoded != header {
+			t.Errorf("version %d: decoded %+v, want %+v", header.Version, *decoded, header)
+		}
+	}
+
+	if _, err := SerializeBlockHeader(&models.BlockHeader{PreviousHash: "zz"}); err == nil {
+		t.Error("serialized a header with a non-hex previous hash")
+	}
+}
@@ -0,0 +1,205 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto-wallet-backend/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// SigHashType selects which parts of a transaction an input signature commits to
+type SigHashType uint8
+
+const (
+	SigHashAll          SigHashType = 0x01 // Sign all inputs and all outputs
+	SigHashNone         SigHashType = 0x02 // Sign all inputs, no outputs
+	SigHashSingle       SigHashType = 0x03 // Sign all inputs and the output with the same index
+	SigHashAnyoneCanPay SigHashType = 0x80 // Modifier: sign only this input
+
+	sigHashBaseMask SigHashType = 0x1f
+)
+
+// sigHashDomain prefixes every sighash preimage so it can't collide with other signed data.
+// The v2 preimage also commits to locking scripts; it is used whenever the input
+// being signed or an output the signature covers has one. The v3 preimage adds
+// the time locks of the covered outputs and is used when any of them has one.
+// The v4 preimage adds the transaction's lock time to v3 and is used when it is
+// set, so signatures of transactions without one are unchanged.
+const (
+	sigHashDomain         = "CryptoWallet/sighash/v1"
+	scriptSigHashDomain   = "CryptoWallet/sighash/v2"
+	timeLockSigHashDomain = "CryptoWallet/sighash/v3"
+	lockTimeSigHashDomain = "CryptoWallet/sighash/v4"
+)
+
+// ParseSigHashType validates a sighash byte coming from a request or a stored input.
+// Zero is treated as SigHashAll so clients that omit the field get the safest mode.
+func ParseSigHashType(value uint8) (SigHashType, error) {
+	hashType := SigHashType(value)
+	if hashType == 0 {
+		return SigHashAll, nil
+	}
+
+	if hashType&^(sigHashBaseMask|SigHashAnyoneCanPay) != 0 {
+		return 0, fmt.Errorf("invalid sighash type: 0x%02x", value)
+	}
+
+	switch hashType & sigHashBaseMask {
+	case SigHashAll, SigHashNone, SigHashSingle:
+		return hashType, nil
+	default:
+		return 0, fmt.Errorf("invalid sighash type: 0x%02x", value)
+	}
+}
+
+// String returns a readable name such as "ALL" or "SINGLE|ANYONECANPAY"
+func (t SigHashType) String() string {
+	var name string
+	switch t & sigHashBaseMask {
+	case SigHashAll:
+		name = "ALL"
+	case SigHashNone:
+		name = "NONE"
+	case SigHashSingle:
+		name = "SINGLE"
+	default:
+		name = fmt.Sprintf("UNKNOWN(0x%02x)", uint8(t))
+	}
+
+	if t&SigHashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// CalculateSigHash creates the digest an input signature must cover.
+// The digest always commits to the hash type and the input being signed; which
+// other inputs and outputs are included depends on the hash type:
+//   - ALL:    every output
+//   - NONE:   no outputs (anyone may redirect the funds)
+//   - SINGLE: only the output at the same index as the input
+//   - ANYONECANPAY may be combined with any of the above to commit to this input only
+//
+// When scripts are involved the digest also commits to the locking script of the
+// output being spent and to the locking script of every covered output, and when
+// covered outputs are time-locked, to their locks. A transaction lock time is
+// committed to whenever it is set, whatever the hash type.
+func CalculateSigHash(inputs []InputData, outputs []OutputData, inputIndex int, hashType SigHashType) (string, error) {
+	hashType, err := ParseSigHashType(uint8(hashType))
+	if err != nil {
+		return "", err
+	}
+
+	if inputIndex < 0 || inputIndex >= len(inputs) {
+		return "", errors.New("input index out of range")
+	}
+
+	base := hashType & sigHashBaseMask
+	if base == SigHashSingle && inputIndex >= len(outputs) {
+		return "", errors.New("SIGHASH_SINGLE requires an output with the same index as the input")
+	}
+
+	// Outputs the signature covers
+	var covered []OutputData
+	switch base {
+	case SigHashAll:
+		covered = outputs
+	case SigHashSingle:
+		covered = outputs[inputIndex : inputIndex+1]
+	}
+
+	lockTime := inputs[inputIndex].LockTime
+	scripted := inputs[inputIndex].LockingScript != ""
+	timeLocked := lockTime != 0
+	for _, output := range covered {
+		scripted = scripted || output.LockingScript != ""
+		timeLocked = timeLocked || output.LockUntil != 0 || output.RelativeLock != 0
+	}
+	scripted = scripted || timeLocked
+
+	var buf bytes.Buffer
+	switch {
+	case lockTime != 0:
+		buf.WriteString(lockTimeSigHashDomain)
+	case timeLocked:
+		buf.WriteString(timeLockSigHashDomain)
+	case scripted:
+		buf.WriteString(scriptSigHashDomain)
+	default:
+		buf.WriteString(sigHashDomain)
+	}
+	buf.WriteByte(byte(hashType))
+	if scripted {
+		writeString(&buf, inputs[inputIndex].LockingScript)
+	}
+	if lockTime != 0 {
+		writeInt64(&buf, lockTime)
+	}
+
+	// Inputs
+	if hashType&SigHashAnyoneCanPay != 0 {
+		writeUvarint(&buf, 1)
+		writeInputData(&buf, inputs[inputIndex])
+	} else {
+		writeUint32(&buf, uint32(inputIndex))
+		writeUvarint(&buf, uint64(len(inputs)))
+		for _, input := range inputs {
+			writeInputData(&buf, input)
+		}
+	}
+
+	// Outputs
+	writeUvarint(&buf, uint64(len(covered)))
+	if base == SigHashSingle {
+		writeUint32(&buf, uint32(inputIndex))
+	}
+	for _, output := range covered {
+		writeOutputData(&buf, output)
+		if scripted {
+			writeString(&buf, output.LockingScript)
+		}
+		if timeLocked {
+			writeInt64(&buf, output.LockUntil)
+			writeInt64(&buf, output.RelativeLock)
+		}
+	}
+
+	hash := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// SignInput signs the sighash of a single transaction input with a private key
+// of the given type, as SignData does
+func SignInput(keyType models.KeyType, privateKeyHex string, inputs []InputData, outputs []OutputData, inputIndex int, hashType SigHashType) (string, error) {
+	sigHash, err := CalculateSigHash(inputs, outputs, inputIndex, hashType)
+	if err != nil {
+		return "", err
+	}
+
+	return SignData(keyType, privateKeyHex, sigHash)
+}
+
+// SigHashDigest returns the 32-byte message digest an input signature is made
+// over, for signers that only see digests (see the signer package)
+func SigHashDigest(inputs []InputData, outputs []OutputData, inputIndex int, hashType SigHashType) ([]byte, error) {
+	sigHash, err := CalculateSigHash(inputs, outputs, inputIndex, hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(sigHash))
+	return digest[:], nil
+}
+
+// VerifyInputSignature verifies an input signature against the sighash recomputed
+// from the transaction's actual inputs and outputs
+func VerifyInputSignature(publicKeyHex string, inputs []InputData, outputs []OutputData, inputIndex int, hashType SigHashType, signatureHex string) (bool, error) {
+	sigHash, err := CalculateSigHash(inputs, outputs, inputIndex, hashType)
+	if err != nil {
+		return false, err
+	}
+
+	return VerifySignature(publicKeyHex, sigHash, signatureHex)
+}
@@ -1,19 +1,30 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto-wallet-backend/models"
 	"crypto/ecdsa"
-	"crypto/rand"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/asn1"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
 )
 
-// SignData signs data using the private key and returns the signature as hex
-func SignData(privateKeyHex string, data string) (string, error) {
+// SignData signs data with a private key in the hex encoding of its key type
+// (P-256 when keyType is empty) and returns the signature as hex
+func SignData(keyType models.KeyType, privateKeyHex string, data string) (string, error) {
 	// Parse the private key
-	privateKey, err := PrivateKeyFromHex(privateKeyHex)
+	key, err := extendedKeyFromHex(keyType.OrDefault(), privateKeyHex)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse private key: %v", err)
+	}
+	keyPair, err := key.KeyPair()
 	if err != nil {
 		return "", fmt.Errorf("failed to parse private key: %v", err)
 	}
@@ -21,31 +32,36 @@ func SignData(privateKeyHex string, data string) (string, error) {
 	// Hash the data
 	hash := sha256.Sum256([]byte(data))
 
+	return keyPair.SignDigest(hash[:])
+}
+
+// SignDigest signs an already hashed message and returns the signature as hex.
+// Signatures are deterministic (RFC 6979) and always have a low S value.
+func SignDigest(privateKey *ecdsa.PrivateKey, digest []byte) (string, error) {
+	if len(digest) != sha256.Size {
+		return "", errors.New("digest must be 32 bytes")
+	}
+
 	// Sign the hash
-	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+	r, s, err := signDeterministic(privateKey, digest)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign data: %v", err)
 	}
 
-	// Encode r and s as hex (each 32 bytes)
-	rBytes := r.Bytes()
-	sBytes := s.Bytes()
-
-	// Pad to 32 bytes if necessary
-	rPadded := make([]byte, 32)
-	sPadded := make([]byte, 32)
-	copy(rPadded[32-len(rBytes):], rBytes)
-	copy(sPadded[32-len(sBytes):], sBytes)
-
-	// Concatenate r and s
-	signature := append(rPadded, sPadded...)
+	// Encode r and s as hex (each 32 bytes), concatenated
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
 	return hex.EncodeToString(signature), nil
 }
 
-// VerifySignature verifies a signature using the public key
+// VerifySignature verifies a signature using the public key, whose encoding
+// determines the key type. ECDSA signatures may be raw r||s (64 bytes) or ASN.1
+// DER, as produced by most ECDSA libraries; either way S must be in the lower
+// half of the curve order. Ed25519 signatures are the 64-byte signature of the
+// SHA-256 digest of data.
 func VerifySignature(publicKeyHex string, data string, signatureHex string) (bool, error) {
-	// Parse the public key
-	publicKey, err := PublicKeyFromHex(publicKeyHex)
+	keyType, err := KeyTypeOf(publicKeyHex)
 	if err != nil {
 		return false, fmt.Errorf("failed to parse public key: %v", err)
 	}
@@ -56,46 +72,145 @@ func VerifySignature(publicKeyHex string, data string, signatureHex string) (boo
 		return false, fmt.Errorf("failed to decode signature: %v", err)
 	}
 
-	if len(signatureBytes) != 64 {
-		return false, errors.New("invalid signature length")
+	// Hash the data
+	hash := sha256.Sum256([]byte(data))
+
+	if keyType == models.KeyTypeEd25519 {
+		publicKeyBytes, _ := hex.DecodeString(publicKeyHex)
+		if len(signatureBytes) != ed25519.SignatureSize {
+			return false, errors.New("invalid signature length")
+		}
+		return ed25519.Verify(publicKeyBytes, hash[:], signatureBytes), nil
 	}
 
-	// Extract r and s from signature
-	r := new(big.Int).SetBytes(signatureBytes[:32])
-	s := new(big.Int).SetBytes(signatureBytes[32:])
+	r, s, err := parseSignature(signatureBytes)
+	if err != nil {
+		return false, err
+	}
 
-	// Hash the data
-	hash := sha256.Sum256([]byte(data))
+	if keyType == models.KeyTypeSecp256k1 {
+		return verifySecp256k1(publicKeyHex, hash[:], r, s)
+	}
+
+	// Parse the public key
+	publicKey, err := PublicKeyFromHex(publicKeyHex)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	if err := checkSignatureRange(r, s, publicKey.Curve.Params().N); err != nil {
+		return false, err
+	}
 
 	// Verify the signature
 	valid := ecdsa.Verify(publicKey, hash[:], r, s)
 	return valid, nil
 }
 
+// verifySecp256k1 verifies an ECDSA signature over digest with a compressed
+// secp256k1 public key
+func verifySecp256k1(publicKeyHex string, digest []byte, r, s *big.Int) (bool, error) {
+	publicKeyBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	publicKey, err := secp256k1.ParsePubKey(publicKeyBytes)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse public key: %v", err)
+	}
+	if err := checkSignatureRange(r, s, secp256k1.S256().Params().N); err != nil {
+		return false, err
+	}
+
+	var rScalar, sScalar secp256k1.ModNScalar
+	rScalar.SetByteSlice(r.Bytes())
+	sScalar.SetByteSlice(s.Bytes())
+	return secp256k1ecdsa.NewSignature(&rScalar, &sScalar).Verify(digest, publicKey), nil
+}
+
+// checkSignatureRange rejects r and s outside [1, n-1] and high S values
+func checkSignatureRange(r, s, n *big.Int) error {
+	if r.Sign() <= 0 || s.Sign() <= 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return errors.New("signature values out of range")
+	}
+	if !isLowS(s, n) {
+		return errors.New("signature has a high S value")
+	}
+	return nil
+}
+
+// EncodeSignatureDER converts a raw r||s signature (hex) to ASN.1 DER (hex)
+func EncodeSignatureDER(signatureHex string) (string, error) {
+	signatureBytes, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode signature: %v", err)
+	}
+
+	r, s, err := parseSignature(signatureBytes)
+	if err != nil {
+		return "", err
+	}
+
+	der, err := asn1.Marshal(derSignature{R: r, S: s})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(der), nil
+}
+
+// derSignature is the ASN.1 structure of an ECDSA signature
+type derSignature struct {
+	R, S *big.Int
+}
+
+// parseSignature reads a raw 64-byte r||s signature or a strictly encoded DER one.
+// Non-canonical DER (padding, long-form lengths, trailing data) is rejected so a
+// signature has exactly one accepted encoding per form.
+func parseSignature(signature []byte) (*big.Int, *big.Int, error) {
+	if len(signature) == 64 {
+		return new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:]), nil
+	}
+
+	if len(signature) == 0 || signature[0] != 0x30 {
+		return nil, nil, errors.New("invalid signature length")
+	}
+
+	var sig derSignature
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, nil, errors.New("invalid DER signature")
+	}
+	canonical, err := asn1.Marshal(sig)
+	if err != nil || !bytes.Equal(canonical, signature) {
+		return nil, nil, errors.New("non-canonical DER signature")
+	}
+
+	return sig.R, sig.S, nil
+}
+
 // HashTransaction creates a hash of transaction data for signing
-func HashTransaction(txID string, inputIndex int, amount float64, recipientWallet string) string {
-	data := fmt.Sprintf("%s:%d:%.8f:%s", txID, inputIndex, amount, recipientWallet)
+func HashTransaction(txID string, inputIndex int, amount models.Amount, recipientWallet string) string {
+	data := fmt.Sprintf("%s:%d:%d:%s", txID, inputIndex, int64(amount), recipientWallet)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
 
 // HashTransactionData creates a comprehensive hash of all transaction data
 func HashTransactionData(inputs []InputData, outputs []OutputData) string {
-	data := ""
-	
+	var buf bytes.Buffer
+
 	// Add all inputs
+	writeUvarint(&buf, uint64(len(inputs)))
 	for _, input := range inputs {
-		data += fmt.Sprintf("%s:%d:%.8f|", input.TransactionID, input.OutputIndex, input.Amount)
+		writeInputData(&buf, input)
 	}
-	
-	data += "->"
-	
+
 	// Add all outputs
+	writeUvarint(&buf, uint64(len(outputs)))
 	for _, output := range outputs {
-		data += fmt.Sprintf("%s:%.8f|", output.WalletID, output.Amount)
+		writeOutputData(&buf, output)
 	}
-	
-	hash := sha256.Sum256([]byte(data))
+
+	hash := sha256.Sum256(buf.Bytes())
 	return hex.EncodeToString(hash[:])
 }
 
@@ -103,32 +218,35 @@ func HashTransactionData(inputs []InputData, outputs []OutputData) string {
 type InputData struct {
 	TransactionID string
 	OutputIndex   int
-	Amount        float64
+	Amount        models.Amount
+	LockingScript string // Hex locking script of the output being spent, if any
+	LockTime      int64  // Lock time of the spending transaction, the same for every input
 }
 
 // OutputData represents output data for hashing
 type OutputData struct {
-	WalletID string
-	Amount   float64
+	WalletID      string
+	Amount        models.Amount
+	LockingScript string // Hex locking script, if any
+	LockUntil     int64  // Absolute time lock, if any
+	RelativeLock  int64  // Relative time lock in blocks, if any
 }
 
-// GenerateTransactionID creates a unique transaction ID from transaction data
-func GenerateTransactionID(senderWallet string, inputs []InputData, outputs []OutputData, timestamp int64) string {
-	data := fmt.Sprintf("%s:%d:", senderWallet, timestamp)
-	
-	for _, input := range inputs {
-		data += fmt.Sprintf("%s:%d:%.8f|", input.TransactionID, input.OutputIndex, input.Amount)
-	}
-	
-	for _, output := range outputs {
-		data += fmt.Sprintf("%s:%.8f|", output.WalletID, output.Amount)
-	}
-	
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:])
+func writeInputData(buf *bytes.Buffer, input InputData) {
+	writeString(buf, input.TransactionID)
+	writeUint32(buf, uint32(input.OutputIndex))
+	writeInt64(buf, int64(input.Amount))
+}
+
+func writeOutputData(buf *bytes.Buffer, output OutputData) {
+	writeString(buf, output.WalletID)
+	writeInt64(buf, int64(output.Amount))
 }
 
-// CreateInputSignatureData creates the data string that needs to be signed for an input
-func CreateInputSignatureData(txID string, inputTxID string, inputIndex int, amount float64) string {
-	return fmt.Sprintf("%s:%s:%d:%.8f", txID, inputTxID, inputIndex, amount)
+// GenerateTransactionID creates a transaction ID by hashing the canonical
+// encoding of the transaction without its signatures, so the ID is known
+// before the inputs are signed
+func GenerateTransactionID(tx *models.Transaction) string {
+	hash := sha256.Sum256(SerializeTransaction(tx, false))
+	return hex.EncodeToString(hash[:])
 }
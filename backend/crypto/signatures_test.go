@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto-wallet-backend/models"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestSignDataKeyTypes(t *testing.T) {
+	inputs := []InputData{{TransactionID: "source", OutputIndex: 0, Amount: 150}}
+	outputs := []OutputData{{WalletID: "recipient", Amount: 100}, {WalletID: "change", Amount: 50}}
+
+	for _, keyType := range []models.KeyType{"", models.KeyTypeP256, models.KeyTypeSecp256k1, models.KeyTypeEd25519} {
+		t.Run(string(keyType.OrDefault()), func(t *testing.T) {
+			masterKey, err := GenerateMasterKey(keyType.OrDefault())
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyPair, err := masterKey.KeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signature, err := SignData(keyType, keyPair.PrivateKeyHex, "hello")
+			if err != nil {
+				t.Fatalf("SignData: %v", err)
+			}
+			if ok, err := VerifySignature(keyPair.PublicKeyHex, "hello", signature); err != nil || !ok {
+				t.Errorf("signature did not verify: %v", err)
+			}
+			if ok, _ := VerifySignature(keyPair.PublicKeyHex, "goodbye", signature); ok {
+				t.Error("signature verified for different data")
+			}
+
+			signature, err = SignInput(keyType, keyPair.PrivateKeyHex, inputs, outputs, 0, SigHashAll)
+			if err != nil {
+				t.Fatalf("SignInput: %v", err)
+			}
+			if ok, err := VerifyInputSignature(keyPair.PublicKeyHex, inputs, outputs, 0, SigHashAll, signature); err != nil || !ok {
+				t.Errorf("input signature did not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignDataRejectsMismatchedKeyType(t *testing.T) {
+	masterKey, err := GenerateMasterKey(models.KeyTypeP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := masterKey.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A P-256 key is DER encoded, which is not a 32-byte secp256k1 scalar
+	if _, err := SignData(models.KeyTypeSecp256k1, keyPair.PrivateKeyHex, "hello"); err == nil {
+		t.Error("signed with a P-256 key parsed as secp256k1")
+	}
+	if _, err := SignData("rsa", keyPair.PrivateKeyHex, "hello"); err == nil {
+		t.Error("signed with an unsupported key type")
+	}
+}
+
+func TestInputSignatureCommitsToLockTime(t *testing.T) {
+	masterKey, err := GenerateMasterKey(models.KeyTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := masterKey.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := []OutputData{{WalletID: "recipient", Amount: 100}, {WalletID: "change", Amount: 50}}
+	withLockTime := func(lockTime int64) []InputData {
+		return []InputData{{TransactionID: "source", OutputIndex: 1, Amount: 150, LockTime: lockTime}}
+	}
+
+	// Transactions without a lock time keep the sighashes they had before it
+	// was committed to, so their signatures still verify
+	for hashType, want := range map[SigHashType]string{
+		SigHashAll:                        "3a875bfa81bdc617c9c10574157d9da0fb4fc81392e521791a77a6602e5cddd6",
+		SigHashNone | SigHashAnyoneCanPay: "cc5ead355c8e9cde38816bcf81ae95c107914799a453d409d9a4a67ba72c3b6e",
+	} {
+		if got, err := CalculateSigHash(withLockTime(0), outputs, 0, hashType); err != nil || got != want {
+			t.Errorf("%s sighash without a lock time = %s, %v, want %s", hashType, got, err, want)
+		}
+	}
+
+	for _, hashType := range []SigHashType{SigHashAll, SigHashNone | SigHashAnyoneCanPay} {
+		signature, err := SignInput(keyPair.KeyType, keyPair.PrivateKeyHex, withLockTime(800000), outputs, 0, hashType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := VerifyInputSignature(keyPair.PublicKeyHex, withLockTime(800000), outputs, 0, hashType, signature); err != nil || !ok {
+			t.Fatalf("%s: signature did not verify: %v", hashType, err)
+		}
+		for _, lockTime := range []int64{0, 800001, LockTimeThreshold} {
+			if ok, _ := VerifyInputSignature(keyPair.PublicKeyHex, withLockTime(lockTime), outputs, 0, hashType, signature); ok {
+				t.Errorf("%s: signature for lock time 800000 verified with lock time %d", hashType, lockTime)
+			}
+		}
+	}
+}
+
+func TestVerifySignatureRejectsHighS(t *testing.T) {
+	for _, keyType := range []models.KeyType{models.KeyTypeP256, models.KeyTypeSecp256k1} {
+		t.Run(string(keyType), func(t *testing.T) {
+			masterKey, err := GenerateMasterKey(keyType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keyPair, err := masterKey.KeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := SignData(keyType, keyPair.PrivateKeyHex, "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// n - s verifies as plain ECDSA, but is a second encoding of the
+			// same signature
+			raw, _ := hex.DecodeString(signature)
+			n := keyPair.PrivateKey.Curve.Params().N
+			highS := new(big.Int).Sub(n, new(big.Int).SetBytes(raw[32:]))
+			highS.FillBytes(raw[32:])
+			if ok, err := VerifySignature(keyPair.PublicKeyHex, "hello", hex.EncodeToString(raw)); ok || err == nil || !strings.Contains(err.Error(), "high S") {
+				t.Errorf("high-S signature: ok = %v, err = %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestSignatureDERRoundTrip(t *testing.T) {
+	masterKey, err := GenerateMasterKey(models.KeyTypeSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := masterKey.KeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := SignData(keyPair.KeyType, keyPair.PrivateKeyHex, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derHex, err := EncodeSignatureDER(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := hex.DecodeString(derHex)
+	r, s, err := parseSignature(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	s.FillBytes(raw[32:])
+	if hex.EncodeToString(raw) != signature {
+		t.Fatalf("DER %s decodes to %x, want %s", derHex, raw, signature)
+	}
+	if ok, err := VerifySignature(keyPair.PublicKeyHex, "hello", derHex); err != nil || !ok {
+		t.Fatalf("DER signature did not verify: %v", err)
+	}
+
+	// Each signature has one accepted DER encoding
+	padded := append([]byte{0x30, der[1] + 1, 0x02, der[3] + 1, 0x00}, der[4:]...)
+	longForm := append([]byte{0x30, 0x81, der[1]}, der[2:]...)
+	tests := map[string][]byte{
+		"trailing data":           append(append([]byte(nil), der...), 0x00),
+		"padded integer":          padded,
+		"long-form length":        longForm,
+		"truncated":               der[:len(der)-1],
+		"not a sequence":          append([]byte{0x31}, der[1:]...),
+		"raw signature too short": raw[:63],
+	}
+	for name, encoded := range tests {
+		if _, _, err := parseSignature(encoded); err == nil {
+			t.Errorf("%s: parsed %x", name, encoded)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"crypto-wallet-backend/config"
+	"crypto-wallet-backend/controllers"
+	"crypto-wallet-backend/crypto"
 	"crypto-wallet-backend/database"
 	"crypto-wallet-backend/routes"
+	"crypto-wallet-backend/signer"
 	"log"
 	"os"
 
@@ -17,6 +20,19 @@ func main() {
 		log.Println("Warning: .env file not found, using system environment variables")
 	}
 
+	// Pick the signing backend (in-process or the signing daemon)
+	if err := signer.Setup(); err != nil {
+		log.Fatal("Invalid signer configuration:", err)
+	}
+
+	// Wallet keys are encrypted under WALLET_MASTER_KEY; refuse to start without a
+	// valid one unless the signing daemon holds it
+	if !signer.IsRemote() {
+		if _, err := crypto.ActiveMasterKeyID(); err != nil {
+			log.Fatal("Invalid wallet master key configuration:", err)
+		}
+	}
+
 	// Initialize database connection
 	if err := database.Connect(); err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -48,6 +64,16 @@ func main() {
 	routes.SetupZakatRoutes(router)
 	routes.SetupLogRoutes(router)
 	routes.SetupAdminRoutes(router)
+	routes.SetupMultisigRoutes(router)
+	routes.SetupHTLCRoutes(router)
+
+	// Start the mining pool's Stratum server if POOL_ADDRESS is set, e.g. ":3333"
+	if poolAddress := os.Getenv("POOL_ADDRESS"); poolAddress != "" {
+		if err := controllers.StartMiningPool(poolAddress); err != nil {
+			log.Fatal("Failed to start mining pool:", err)
+		}
+		log.Printf("⛏️  Mining pool listening on %s", poolAddress)
+	}
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -59,7 +85,7 @@ func main() {
 	// address := ":" + port
 	log.Printf("🚀 Server starting on %s", address)
 	log.Printf("📡 Listening on port %s", port)
-	
+
 	// Bind to 0.0.0.0 for Render/Docker compatibility
 	if err := router.Run(address); err != nil {
 		log.Fatal("Failed to start server:", err)
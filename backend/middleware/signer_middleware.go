@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto-wallet-backend/signer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalKeysRequired refuses keystore import and export when keys are held by
+// the signing daemon (SIGNER_BACKEND=remote). Both decrypt a wallet's key in the
+// API server, which then has no master key to do it with.
+func LocalKeysRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if signer.IsRemote() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Wallet keys are managed by the signing daemon and cannot be exported or imported as keystore files through this server"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// Amount is a quantity of coins expressed in indivisible base units
+// (UnitsPerCoin units make one coin, like satoshis in Bitcoin).
+// It is stored in MongoDB as an int64 and sent over JSON as an exact decimal
+// string such as "12.50000000".
+type Amount int64
+
+const (
+	// AmountDecimals is the number of decimal places a coin can be divided into
+	AmountDecimals = 8
+
+	// UnitsPerCoin is the number of base units in one coin
+	UnitsPerCoin Amount = 100_000_000
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// AmountFromCoins converts a floating-point coin value to base units, rounding
+// to the nearest unit. Only use it for legacy data and hand-written constants.
+func AmountFromCoins(coins float64) Amount {
+	return Amount(math.Round(coins * float64(UnitsPerCoin)))
+}
+
+// ParseAmount parses an exact decimal coin value such as "12.5" or "-0.00000001".
+// More than AmountDecimals fractional digits is an error rather than being rounded.
+func ParseAmount(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	if len(frac) > AmountDecimals {
+		return 0, fmt.Errorf("%w: more than %d decimal places", ErrInvalidAmount, AmountDecimals)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	var wholeUnits, fracUnits int64
+	var err error
+	if whole != "" {
+		wholeUnits, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || wholeUnits > math.MaxInt64/int64(UnitsPerCoin) {
+			return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+		}
+	}
+	if frac != "" {
+		frac += strings.Repeat("0", AmountDecimals-len(frac))
+		fracUnits, err = strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	units := wholeUnits*int64(UnitsPerCoin) + fracUnits
+	if units < 0 {
+		return 0, fmt.Errorf("%w: out of range", ErrInvalidAmount)
+	}
+	if negative {
+		units = -units
+	}
+
+	return Amount(units), nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount as a decimal coin value with all AmountDecimals places
+func (a Amount) String() string {
+	sign := ""
+	units := uint64(a)
+	if a < 0 {
+		sign = "-"
+		units = uint64(-a)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/uint64(UnitsPerCoin), AmountDecimals, units%uint64(UnitsPerCoin))
+}
+
+// Coins returns the amount as a floating-point coin value, for display only
+func (a Amount) Coins() float64 {
+	return float64(a) / float64(UnitsPerCoin)
+}
+
+// MulRate multiplies the amount by a rate such as a 2.5% zakat rate, rounding
+// down to a whole base unit. The arithmetic is exact.
+func (a Amount) MulRate(rate float64) Amount {
+	product := new(big.Rat).SetInt64(int64(a))
+	product.Mul(product, new(big.Rat).SetFloat64(rate))
+
+	quotient := new(big.Int).Quo(product.Num(), product.Denom())
+	return Amount(quotient.Int64())
+}
+
+// MarshalJSON encodes the amount as a decimal string so no precision is lost in JavaScript
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts either a decimal string or a JSON number, parsing the
+// literal text exactly instead of going through float64
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(text, "eE") {
+		// Exponent notation can't be parsed digit by digit
+		coins, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return ErrInvalidAmount
+		}
+		*a = AmountFromCoins(coins)
+		return nil
+	}
+
+	parsed, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// UnmarshalBSONValue reads base units stored as integers. Documents written
+// before amounts became integers hold floating-point coin values; those are
+// converted so they keep working until the migration has run.
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Int64:
+		*a = Amount(value.Int64())
+	case bsontype.Int32:
+		*a = Amount(value.Int32())
+	case bsontype.Double:
+		*a = AmountFromCoins(value.Double())
+	case bsontype.Null, bsontype.Undefined:
+		*a = 0
+	default:
+		return fmt.Errorf("cannot decode BSON %s into Amount", t)
+	}
+	return nil
+}
@@ -9,22 +9,28 @@ import (
 // Block represents a block in the blockchain
 type Block struct {
 	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Index            int64              `json:"index" bson:"index"`                       // Block height/number
-	Hash             string             `json:"hash" bson:"hash"`                         // Hash of this block
-	PreviousHash     string             `json:"previousHash" bson:"previousHash"`         // Hash of previous block
-	Timestamp        time.Time          `json:"timestamp" bson:"timestamp"`               // When block was mined
-	Transactions     []Transaction      `json:"transactions" bson:"transactions"`         // Transactions in this block
-	TransactionCount int                `json:"transactionCount" bson:"transactionCount"` // Number of transactions
-	MerkleRoot       string             `json:"merkleRoot" bson:"merkleRoot"`             // Merkle root of transactions
-	Nonce            int64              `json:"nonce" bson:"nonce"`                       // Proof-of-work nonce
-	Difficulty       int                `json:"difficulty" bson:"difficulty"`             // Mining difficulty (number of leading zeros)
-	MinerWalletID    string             `json:"minerWalletId" bson:"minerWalletId"`       // Wallet that mined this block
-	MiningReward     float64            `json:"miningReward" bson:"miningReward"`         // Reward for mining this block
-	Size             int64              `json:"size" bson:"size"`                         // Block size in bytes (approximate)
+	Version          int                `json:"version" bson:"version"`                       // Header encoding version
+	Index            int64              `json:"index" bson:"index"`                           // Block height/number
+	Hash             string             `json:"hash" bson:"hash"`                             // Hash of this block
+	PreviousHash     string             `json:"previousHash" bson:"previousHash"`             // Hash of previous block
+	Timestamp        time.Time          `json:"timestamp" bson:"timestamp"`                   // When block was mined
+	Transactions     []Transaction      `json:"transactions" bson:"transactions"`             // Transactions in this block
+	Coinbase         *Transaction       `json:"coinbase,omitempty" bson:"coinbase,omitempty"` // Mining reward, the first Merkle leaf from header version 4
+	TransactionCount int                `json:"transactionCount" bson:"transactionCount"`     // Number of transactions
+	MerkleRoot       string             `json:"merkleRoot" bson:"merkleRoot"`                 // Merkle root of transactions
+	Nonce            int64              `json:"nonce" bson:"nonce"`                           // Proof-of-work nonce
+	Difficulty       int                `json:"difficulty" bson:"difficulty"`                 // Leading zero hex digits; the proof-of-work rule before compact targets, informational after
+	Bits             uint32             `json:"bits" bson:"bits"`                             // Compact proof-of-work target
+	ChainWork        string             `json:"chainWork" bson:"chainWork"`                   // Total work of the chain up to this block, 64 hex digits
+	MinerWalletID    string             `json:"minerWalletId" bson:"minerWalletId"`           // Wallet that mined this block
+	MiningReward     Amount             `json:"miningReward" bson:"miningReward"`             // Reward for mining this block
+	Size             int64              `json:"size" bson:"size"`                             // Serialized block size in bytes
+	Stale            bool               `json:"stale,omitempty" bson:"stale,omitempty"`       // On a side branch rather than the main chain
 }
 
 // BlockHeader contains just the header info for lighter queries
 type BlockHeader struct {
+	Version      int       `json:"version"`
 	Index        int64     `json:"index"`
 	Hash         string    `json:"hash"`
 	PreviousHash string    `json:"previousHash"`
@@ -32,6 +38,35 @@ type BlockHeader struct {
 	MerkleRoot   string    `json:"merkleRoot"`
 	Nonce        int64     `json:"nonce"`
 	Difficulty   int       `json:"difficulty"`
+	Bits         uint32    `json:"bits"`
+}
+
+// Header returns the fields of the block covered by its hash
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{
+		Version:      b.Version,
+		Index:        b.Index,
+		Hash:         b.Hash,
+		PreviousHash: b.PreviousHash,
+		Timestamp:    b.Timestamp,
+		MerkleRoot:   b.MerkleRoot,
+		Nonce:        b.Nonce,
+		Difficulty:   b.Difficulty,
+		Bits:         b.Bits,
+	}
+}
+
+// MerkleProof shows that a transaction is one of the leaves under a block's
+// Merkle root. Leaves are the SHA-256 of the transaction ID string and each
+// parent is the SHA-256 of its two children's hex hashes, left then right.
+// When a level has an odd number of nodes the last one is paired with itself,
+// so its entry in Branch is a copy of the hash computed so far.
+type MerkleProof struct {
+	TransactionID string   `json:"transactionId"`
+	Index         int      `json:"index"`      // Leaf position; bit i is set when the node at level i is a right child
+	LeafCount     int      `json:"leafCount"`  // Transactions in the block
+	Branch        []string `json:"branch"`     // Sibling hashes from the leaves up to just below the root
+	MerkleRoot    string   `json:"merkleRoot"` // Root the branch leads to
 }
 
 // GenesisBlock creates the first block in the chain
@@ -40,24 +75,91 @@ type GenesisBlockInfo struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-// MiningJob represents a job for mining a new block
+// MiningJobStatus is the state of a background mining job
+type MiningJobStatus string
+
+const (
+	MiningJobQueued    MiningJobStatus = "queued"    // Accepted, not started yet
+	MiningJobRunning   MiningJobStatus = "running"   // Searching for a block
+	MiningJobSucceeded MiningJobStatus = "succeeded" // A block was mined and saved
+	MiningJobFailed    MiningJobStatus = "failed"    // Stopped by an error or the time limit
+	MiningJobCancelled MiningJobStatus = "cancelled" // Stopped at the user's request
+)
+
+// MiningJob is a background attempt to mine the next block for a user. The
+// block fields describe the template being mined and change if another block
+// becomes the tip and mining restarts on top of it.
 type MiningJob struct {
-	BlockIndex       int64         `json:"blockIndex"`
-	PreviousHash     string        `json:"previousHash"`
-	Transactions     []Transaction `json:"transactions"`
-	MerkleRoot       string        `json:"merkleRoot"`
-	Difficulty       int           `json:"difficulty"`
-	MiningReward     float64       `json:"miningReward"`
-	TargetPrefix     string        `json:"targetPrefix"` // The hash must start with this
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID           primitive.ObjectID `json:"userId" bson:"userId"`
+	MinerWalletID    string             `json:"minerWalletId" bson:"minerWalletId"`
+	Status           MiningJobStatus    `json:"status" bson:"status"`
+	BlockIndex       int64              `json:"blockIndex" bson:"blockIndex"`
+	PreviousHash     string             `json:"previousHash" bson:"previousHash"`
+	TransactionCount int                `json:"transactionCount" bson:"transactionCount"`
+	MerkleRoot       string             `json:"merkleRoot" bson:"merkleRoot"`
+	Difficulty       int                `json:"difficulty" bson:"difficulty"`
+	Bits             uint32             `json:"bits" bson:"bits"`
+	MiningReward     Amount             `json:"miningReward" bson:"miningReward"`
+	TargetPrefix     string             `json:"targetPrefix" bson:"targetPrefix"` // The hash must start with this
+	Hashes           int64              `json:"hashes" bson:"hashes"`             // Headers hashed so far
+	Hashrate         float64            `json:"hashrate" bson:"hashrate"`         // Average hashes per second
+	CancelRequested  bool               `json:"cancelRequested,omitempty" bson:"cancelRequested,omitempty"`
+	Result           *MiningResult      `json:"result,omitempty" bson:"result,omitempty"` // Set once the job finishes
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	StartedAt        *time.Time         `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updatedAt"` // Refreshed by the running job as a heartbeat
+	FinishedAt       *time.Time         `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
 }
 
-// MiningResult is returned when a block is successfully mined
+// IsFinished reports whether the job has stopped for good
+func (j *MiningJob) IsFinished() bool {
+	return j.Status == MiningJobSucceeded || j.Status == MiningJobFailed || j.Status == MiningJobCancelled
+}
+
+// BlockTemplate is what an external miner needs to mine the next block. Header
+// is the serialized header with a zero nonce in its last 8 bytes; a miner
+// rewrites those bytes and looks for a double SHA-256 at most Target. To vary
+// the Merkle root, ask for another template with a different extraNonce.
+type BlockTemplate struct {
+	Version        int           `json:"version"`
+	Index          int64         `json:"index"`
+	PreviousHash   string        `json:"previousHash"`
+	Timestamp      int64         `json:"timestamp"`    // Unix seconds
+	MinTimestamp   int64         `json:"minTimestamp"` // Earliest timestamp a submission may use
+	MaxTimestamp   int64         `json:"maxTimestamp"` // Latest timestamp a submission may use
+	Bits           uint32        `json:"bits"`
+	Target         string        `json:"target"` // 64 hex digits
+	Difficulty     int           `json:"difficulty"`
+	ExtraNonce     uint64        `json:"extraNonce"`
+	MerkleRoot     string        `json:"merkleRoot"`
+	Coinbase       Transaction   `json:"coinbase"`       // Pays MiningReward to the caller's wallet
+	Transactions   []Transaction `json:"transactions"`   // Selected pending transactions, in block order
+	TransactionIDs []string      `json:"transactionIds"` // Their IDs, to echo back in the submission
+	MiningReward   Amount        `json:"miningReward"`
+	Header         string        `json:"header"` // Hex serialized header with nonce 0
+}
+
+// SubmitBlockRequest submits a block mined from a template. The coinbase is
+// rebuilt from the caller's wallet, the height, extraNonce and timestamp, so
+// only these fields and the nonce are needed.
+type SubmitBlockRequest struct {
+	PreviousHash   string   `json:"previousHash" binding:"required,hexadecimal"`
+	Timestamp      int64    `json:"timestamp" binding:"required"`
+	ExtraNonce     uint64   `json:"extraNonce"`
+	Nonce          int64    `json:"nonce"`
+	TransactionIDs []string `json:"transactionIds"`
+}
+
+// MiningResult is the outcome of a mining job
 type MiningResult struct {
-	Success bool   `json:"success"`
-	Block   *Block `json:"block,omitempty"`
-	Message string `json:"message"`
-	Nonce   int64  `json:"nonce,omitempty"`
-	Hash    string `json:"hash,omitempty"`
+	Success    bool    `json:"success" bson:"success"`
+	Block      *Block  `json:"block,omitempty" bson:"block,omitempty"`
+	Message    string  `json:"message" bson:"message"`
+	Nonce      int64   `json:"nonce,omitempty" bson:"nonce,omitempty"`
+	Hash       string  `json:"hash,omitempty" bson:"hash,omitempty"`
+	ExtraNonce uint64  `json:"extraNonce,omitempty" bson:"extraNonce,omitempty"`
+	MiningTime float64 `json:"miningTime" bson:"miningTime"` // Seconds spent mining
 }
 
 // BlockchainStats provides statistics about the blockchain
@@ -67,32 +169,56 @@ type BlockchainStats struct {
 	CurrentDifficulty   int     `json:"currentDifficulty"`
 	LastBlockHash       string  `json:"lastBlockHash"`
 	LastBlockTime       string  `json:"lastBlockTime"`
-	AverageBlockTime    float64 `json:"averageBlockTime"`    // in seconds
-	TotalMiningRewards  float64 `json:"totalMiningRewards"`
+	CurrentBits         uint32  `json:"currentBits"`
+	ChainWork           string  `json:"chainWork"`
+	AverageBlockTime    float64 `json:"averageBlockTime"` // in seconds
+	TotalMiningRewards  Amount  `json:"totalMiningRewards"`
 	PendingTransactions int     `json:"pendingTransactions"`
 }
 
-// ChainValidation represents the result of validating the blockchain
+// ChainValidation represents the result of validating the blockchain by
+// replaying it from the genesis block
 type ChainValidation struct {
-	IsValid       bool     `json:"isValid"`
-	BlocksChecked int64    `json:"blocksChecked"`
-	Errors        []string `json:"errors,omitempty"`
+	IsValid             bool                   `json:"isValid"`
+	BlocksChecked       int64                  `json:"blocksChecked"`
+	TransactionsChecked int64                  `json:"transactionsChecked"`
+	UTXOCount           int64                  `json:"utxoCount"`   // Unspent outputs left by the replay
+	TotalSupply         Amount                 `json:"totalSupply"` // Sum of those outputs
+	ErrorCount          int                    `json:"errorCount"`
+	Errors              []ChainValidationError `json:"errors,omitempty"` // The first errors found
+}
+
+// ChainValidationError is a rule a main chain block or one of its transactions
+// breaks
+type ChainValidationError struct {
+	BlockIndex    int64  `json:"blockIndex"`
+	BlockHash     string `json:"blockHash"`
+	TransactionID string `json:"transactionId,omitempty"`
+	Message       string `json:"message"`
 }
 
 // BlockchainConfig holds configuration for the blockchain
 type BlockchainConfig struct {
-	InitialDifficulty     int     `json:"initialDifficulty"`     // Starting difficulty
-	BlockReward           float64 `json:"blockReward"`           // Mining reward
-	DifficultyAdjustment  int     `json:"difficultyAdjustment"`  // Adjust every N blocks
-	TargetBlockTime       int     `json:"targetBlockTime"`       // Target time in seconds
-	MaxTransactionsPerBlock int   `json:"maxTransactionsPerBlock"`
+	InitialDifficulty       int    `json:"initialDifficulty"`    // Starting difficulty
+	BlockReward             Amount `json:"blockReward"`          // Mining reward
+	DifficultyAdjustment    int    `json:"difficultyAdjustment"` // Adjust every N blocks
+	TargetBlockTime         int    `json:"targetBlockTime"`      // Target time in seconds
+	MaxTransactionsPerBlock int    `json:"maxTransactionsPerBlock"`
+	MaxMiningJobsPerUser    int    `json:"maxMiningJobsPerUser"` // Mining jobs a user may run at once
+	MiningJobTimeout        int    `json:"miningJobTimeout"`     // Seconds a mining job runs before giving up
+	PoolShareDifficulty     int64  `json:"poolShareDifficulty"`  // Difficulty of mining pool shares
+	PoolPPLNSWindow         int    `json:"poolPplnsWindow"`      // Last N pool shares a block reward is split over
 }
 
 // Default blockchain configuration
 var DefaultBlockchainConfig = BlockchainConfig{
-	InitialDifficulty:       4,     // 4 leading zeros
-	BlockReward:             50.0,  // 50 coins per block
-	DifficultyAdjustment:    10,    // Adjust every 10 blocks
-	TargetBlockTime:         30,    // 30 seconds target
-	MaxTransactionsPerBlock: 100,   // Max 100 transactions per block
+	InitialDifficulty:       4,                 // 4 leading zeros
+	BlockReward:             50 * UnitsPerCoin, // 50 coins per block
+	DifficultyAdjustment:    10,                // Adjust every 10 blocks
+	TargetBlockTime:         30,                // 30 seconds target
+	MaxTransactionsPerBlock: 100,               // Max 100 transactions per block
+	MaxMiningJobsPerUser:    1,                 // One mining job per user at a time
+	MiningJobTimeout:        600,               // Give up after 10 minutes
+	PoolShareDifficulty:     16,                // 1/16 of the easiest block target
+	PoolPPLNSWindow:         1000,              // Split rewards over the last 1000 shares
 }
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HTLCStatus is the state of a hash time-locked contract
+type HTLCStatus string
+
+const (
+	HTLCStatusPending  HTLCStatus = "pending"  // Funded and waiting to be claimed or refunded
+	HTLCStatusClaimed  HTLCStatus = "claimed"  // The recipient revealed the preimage and took the funds
+	HTLCStatusRefunded HTLCStatus = "refunded" // The sender took the funds back after expiry
+)
+
+// HTLC is a hash time-locked contract: an output the recipient can claim by
+// revealing the SHA-256 preimage of HashLock before ExpiryHeight, and that the
+// sender can take back from ExpiryHeight on. Two contracts with the same hash
+// lock on different chains make an atomic swap.
+type HTLC struct {
+	ID                primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TransactionID     string             `json:"transactionId" bson:"transactionId"` // Transaction that funded the contract
+	OutputIndex       int                `json:"outputIndex" bson:"outputIndex"`
+	SenderWalletID    string             `json:"senderWalletId" bson:"senderWalletId"`
+	RecipientWalletID string             `json:"recipientWalletId" bson:"recipientWalletId"`
+	Amount            Amount             `json:"amount" bson:"amount"`
+	HashLock          string             `json:"hashLock" bson:"hashLock"`           // Hex SHA-256 hash of the secret
+	ExpiryHeight      int64              `json:"expiryHeight" bson:"expiryHeight"`   // First block a refund can be included in
+	LockingScript     string             `json:"lockingScript" bson:"lockingScript"` // Hex HTLC script of the output
+	Status            HTLCStatus         `json:"status" bson:"status"`
+	Preimage          string             `json:"preimage,omitempty" bson:"preimage,omitempty"`             // Hex secret, revealed by the claim
+	SettlementTxID    string             `json:"settlementTxId,omitempty" bson:"settlementTxId,omitempty"` // Claim or refund transaction
+	CreatedAt         time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt         time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CreateHTLCRequest locks an amount in a hash time-locked contract
+type CreateHTLCRequest struct {
+	RecipientWalletID string `json:"recipientWalletId" binding:"required"`
+	Amount            Amount `json:"amount" binding:"required,gt=0"`
+	HashLock          string `json:"hashLock" binding:"required,len=64,hexadecimal"` // Hex SHA-256 hash of the secret
+	ExpiryHeight      int64  `json:"expiryHeight" binding:"required,gt=0"`           // Block height from which the sender can refund
+	Message           string `json:"message"`
+}
+
+// ClaimHTLCRequest reveals the secret that unlocks a contract
+type ClaimHTLCRequest struct {
+	Preimage string `json:"preimage" binding:"required,hexadecimal"` // Hex secret whose SHA-256 hash is the hash lock
+}
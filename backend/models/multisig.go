@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MultisigWallet is a wallet whose funds can only be spent with signatures from
+// Threshold of its participants. Its wallet ID is derived from the redeem policy
+// (the threshold and the sorted participant keys), so anyone holding the policy
+// can check which address it controls.
+type MultisigWallet struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WalletID     string             `json:"walletId" bson:"walletId"`         // Multisig address
+	Threshold    int                `json:"threshold" bson:"threshold"`       // Signatures required (m)
+	PublicKeys   []string           `json:"publicKeys" bson:"publicKeys"`     // Participant public keys, sorted (n)
+	RedeemPolicy string             `json:"redeemPolicy" bson:"redeemPolicy"` // Hex encoding of the policy the address commits to
+	CreatedBy    primitive.ObjectID `json:"createdBy" bson:"createdBy"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// CosignerSignature is one participant's signature on a multisig input
+type CosignerSignature struct {
+	PublicKey string `json:"publicKey" bson:"publicKey"`
+	Signature string `json:"signature" bson:"signature"`
+}
+
+// MultisigSpendStatus is the state of a proposed multisig spend
+type MultisigSpendStatus string
+
+const (
+	MultisigSpendPending   MultisigSpendStatus = "pending"   // Collecting signatures
+	MultisigSpendBroadcast MultisigSpendStatus = "broadcast" // Enough signatures; the transaction was broadcast
+	MultisigSpendFailed    MultisigSpendStatus = "failed"    // Its signatures were invalid or its inputs were spent by another transaction
+)
+
+// MultisigSpend is a transaction from a multisig wallet that is waiting for its
+// participants' signatures. It is broadcast once every input has Threshold
+// valid signatures.
+type MultisigSpend struct {
+	ID               primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	MultisigWalletID string              `json:"multisigWalletId" bson:"multisigWalletId"`
+	Threshold        int                 `json:"threshold" bson:"threshold"`
+	Transaction      Transaction         `json:"transaction" bson:"transaction"` // Inputs collect cosignatures as participants sign
+	DataToSign       []string            `json:"dataToSign" bson:"dataToSign"`   // Sighash of each input
+	Signers          []string            `json:"signers" bson:"signers"`         // Public keys that have signed
+	ProposedBy       string              `json:"proposedBy" bson:"proposedBy"`   // Wallet ID of the participant who proposed it
+	Status           MultisigSpendStatus `json:"status" bson:"status"`
+	Error            string              `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt        time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CreateMultisigWalletRequest creates an m-of-n multisig wallet
+type CreateMultisigWalletRequest struct {
+	PublicKeys []string `json:"publicKeys" binding:"required,min=1"` // Participant public keys (hex)
+	Threshold  int      `json:"threshold" binding:"required,min=1"`  // Signatures required to spend
+}
+
+// ProposeMultisigSpendRequest starts a spend from a multisig wallet
+type ProposeMultisigSpendRequest struct {
+	MultisigWalletID  string `json:"multisigWalletId" binding:"required"`
+	RecipientWalletID string `json:"recipientWalletId" binding:"required"`
+	Amount            Amount `json:"amount" binding:"required,gt=0"`
+	Message           string `json:"message"`
+}
+
+// SignMultisigSpendRequest adds the caller's signatures to a pending spend. When
+// Signatures is empty the server signs with the caller's wallet key; otherwise
+// each input's signature is made by the client over DataToSign.
+type SignMultisigSpendRequest struct {
+	Signatures []InputSignature `json:"signatures"`
+}
+
+// MultisigWalletResponse is a multisig wallet with its current balance
+type MultisigWalletResponse struct {
+	MultisigWallet
+	Balance Amount `json:"balance"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PoolShare is a proof of work submitted to the mining pool: a hash meeting the
+// share target for a pool job. Shares are the weights block rewards are split
+// by.
+type PoolShare struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WorkerName string             `json:"workerName" bson:"workerName"` // Stratum username, "walletId" or "walletId.rig"
+	WalletID   string             `json:"walletId" bson:"walletId"`     // Wallet the worker's rewards are paid to
+	JobID      string             `json:"jobId" bson:"jobId"`
+	BlockIndex int64              `json:"blockIndex" bson:"blockIndex"` // Height of the block the job was for
+	Difficulty int64              `json:"difficulty" bson:"difficulty"` // Share difficulty, the share's weight
+	Hash       string             `json:"hash" bson:"hash"`
+	BlockHash  string             `json:"blockHash,omitempty" bson:"blockHash,omitempty"` // Set when the share also solved the block
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// PoolPayout is a wallet's part of the PPLNS window and of the reward it would
+// get if the pool found a block now
+type PoolPayout struct {
+	WalletID string  `json:"walletId"`
+	Shares   int     `json:"shares"`
+	Weight   int64   `json:"weight"`  // Sum of the wallet's share difficulties
+	Percent  float64 `json:"percent"` // Share of the window's weight
+	Amount   Amount  `json:"amount"`
+}
+
+// PoolJob describes the work the pool is handing out
+type PoolJob struct {
+	ID               string    `json:"id"`
+	BlockIndex       int64     `json:"blockIndex"`
+	PreviousHash     string    `json:"previousHash"`
+	TransactionCount int       `json:"transactionCount"`
+	Bits             uint32    `json:"bits"`
+	Difficulty       int       `json:"difficulty"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// PoolStats summarizes the mining pool
+type PoolStats struct {
+	Enabled          bool         `json:"enabled"`
+	ConnectedWorkers int          `json:"connectedWorkers"`
+	ShareDifficulty  int64        `json:"shareDifficulty"`
+	ShareTarget      string       `json:"shareTarget"` // 64 hex digits
+	PPLNSWindow      int          `json:"pplnsWindow"`
+	Hashrate         float64      `json:"hashrate"`     // Estimated from recent shares, in hashes per second
+	RecentShares     int64        `json:"recentShares"` // Shares accepted in the hashrate window
+	BlocksFound      int64        `json:"blocksFound"`
+	CurrentJob       *PoolJob     `json:"currentJob,omitempty"`
+	Payouts          []PoolPayout `json:"payouts"` // Split of the next block's reward
+}
+
+// PoolWorkerStats describes one worker of the mining pool
+type PoolWorkerStats struct {
+	WorkerName     string     `json:"workerName"`
+	WalletID       string     `json:"walletId"`
+	Online         bool       `json:"online"`
+	AcceptedShares int64      `json:"acceptedShares"` // In the hashrate window
+	RejectedShares int64      `json:"rejectedShares"` // Since the worker connected
+	Hashrate       float64    `json:"hashrate"`
+	LastShareAt    *time.Time `json:"lastShareAt,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// ScriptLockRequest pays an amount from the caller's wallet to an output locked
+// by a custom script
+type ScriptLockRequest struct {
+	LockingScript string `json:"lockingScript" binding:"required"` // Script assembly, e.g. "OP_2 <key> <key> <key> OP_3 OP_CHECKMULTISIG"
+	Amount        Amount `json:"amount" binding:"required,gt=0"`
+	WalletID      string `json:"walletId"` // Address the output is listed under; defaults to the caller's wallet
+	Message       string `json:"message"`
+}
+
+// ScriptSpendInput references a script-locked output and the script that unlocks it
+type ScriptSpendInput struct {
+	TransactionID string `json:"transactionId" binding:"required"`
+	OutputIndex   int    `json:"outputIndex"`
+	// Script assembly. SIG is replaced by the caller's wallet signature over the
+	// input (SIGHASH_ALL) and PUBKEY by the caller's public key.
+	UnlockingScript string `json:"unlockingScript"`
+}
+
+// ScriptSpendRequest spends script-locked outputs to a single recipient
+type ScriptSpendRequest struct {
+	Inputs            []ScriptSpendInput `json:"inputs" binding:"required,min=1,dive"`
+	RecipientWalletID string             `json:"recipientWalletId" binding:"required"`
+	Message           string             `json:"message"`
+	Timestamp         int64              `json:"timestamp"` // From the preview; needed when unlocking scripts carry client signatures
+}
+
+// DecodeScriptRequest converts a script between assembly and hex
+type DecodeScriptRequest struct {
+	Script string `json:"script" binding:"required"` // Assembly or hex
+}
@@ -26,18 +26,35 @@ const (
 
 // SignedInput represents a UTXO input with its signature
 type SignedInput struct {
-	TransactionID string  `json:"transactionId" bson:"transactionId"` // Reference to source UTXO's transaction
-	OutputIndex   int     `json:"outputIndex" bson:"outputIndex"`     // Index in the source transaction
-	Amount        float64 `json:"amount" bson:"amount"`               // Amount from this input
-	PublicKey     string  `json:"publicKey" bson:"publicKey"`         // Sender's public key
-	Signature     string  `json:"signature" bson:"signature"`         // Digital signature proving ownership
+	TransactionID string  `json:"transactionId" bson:"transactionId"`         // Reference to source UTXO's transaction
+	OutputIndex   int     `json:"outputIndex" bson:"outputIndex"`             // Index in the source transaction
+	Amount        Amount  `json:"amount" bson:"amount"`                       // Amount from this input
+	PublicKey     string  `json:"publicKey" bson:"publicKey"`                 // Sender's public key
+	Signature     string  `json:"signature" bson:"signature"`                 // Digital signature proving ownership
+	SigHashType   uint8   `json:"sigHashType" bson:"sigHashType"`             // Which inputs/outputs the signature commits to
+	KeyType       KeyType `json:"keyType,omitempty" bson:"keyType,omitempty"` // Signature scheme of PublicKey (empty means P-256)
+
+	// Hex script run before the spent output's locking script. Empty when the
+	// output predates scripts and is locked to a bare public key.
+	UnlockingScript string `json:"unlockingScript,omitempty" bson:"unlockingScript,omitempty"`
+
+	// Inputs spending from a multisig address carry the redeem policy the address
+	// commits to and the participants' signatures instead of PublicKey and Signature
+	RedeemPolicy string              `json:"redeemPolicy,omitempty" bson:"redeemPolicy,omitempty"`
+	Cosignatures []CosignerSignature `json:"cosignatures,omitempty" bson:"cosignatures,omitempty"`
 }
 
 // TransactionOutput represents an output in a transaction
 type TransactionOutput struct {
-	WalletID  string  `json:"walletId" bson:"walletId"`   // Recipient's wallet ID
-	Amount    float64 `json:"amount" bson:"amount"`       // Amount to send
-	PublicKey string  `json:"publicKey" bson:"publicKey"` // Recipient's public key
+	WalletID      string `json:"walletId" bson:"walletId"`                               // Recipient's wallet ID
+	Amount        Amount `json:"amount" bson:"amount"`                                   // Amount to send
+	PublicKey     string `json:"publicKey" bson:"publicKey"`                             // Recipient's public key
+	LockingScript string `json:"lockingScript,omitempty" bson:"lockingScript,omitempty"` // Hex script that must succeed to spend the output
+
+	// Time locks on spending the output. LockUntil is a block height (below
+	// 500,000,000) or Unix time; RelativeLock counts blocks after confirmation.
+	LockUntil    int64 `json:"lockUntil,omitempty" bson:"lockUntil,omitempty"`
+	RelativeLock int64 `json:"relativeLock,omitempty" bson:"relativeLock,omitempty"`
 }
 
 // Transaction represents a complete blockchain transaction
@@ -47,35 +64,49 @@ type Transaction struct {
 	Type          TransactionType     `json:"type" bson:"type"`
 	Inputs        []SignedInput       `json:"inputs" bson:"inputs"`
 	Outputs       []TransactionOutput `json:"outputs" bson:"outputs"`
-	TotalInput    float64             `json:"totalInput" bson:"totalInput"`
-	TotalOutput   float64             `json:"totalOutput" bson:"totalOutput"`
-	Fee           float64             `json:"fee" bson:"fee"` // TotalInput - TotalOutput (goes to miners)
+	TotalInput    Amount              `json:"totalInput" bson:"totalInput"`
+	TotalOutput   Amount              `json:"totalOutput" bson:"totalOutput"`
+	Fee           Amount              `json:"fee" bson:"fee"` // TotalInput - TotalOutput (goes to miners)
 	SenderWallet  string              `json:"senderWallet" bson:"senderWallet"`
 	Status        TransactionStatus   `json:"status" bson:"status"`
 	BlockHash     string              `json:"blockHash,omitempty" bson:"blockHash"`
 	BlockHeight   int64               `json:"blockHeight,omitempty" bson:"blockHeight"`
 	Timestamp     time.Time           `json:"timestamp" bson:"timestamp"`
 	ConfirmedAt   *time.Time          `json:"confirmedAt,omitempty" bson:"confirmedAt"`
-	Message       string              `json:"message,omitempty" bson:"message"` // Optional memo
+	Message       string              `json:"message,omitempty" bson:"message"`                     // Optional memo
+	LockTime      int64               `json:"lockTime,omitempty" bson:"lockTime,omitempty"`         // Earliest block height or Unix time the transaction can be mined at
+	CoinbaseData  string              `json:"coinbaseData,omitempty" bson:"coinbaseData,omitempty"` // Hex block height and extra nonce of a coinbase, varied while mining
+}
+
+// PaymentLocks are the optional time locks of a payment. Lock values below
+// 500,000,000 are block heights, larger ones Unix times.
+type PaymentLocks struct {
+	LockTime     int64 `json:"lockTime" binding:"min=0"`     // Earliest block the transaction can be mined in
+	LockUntil    int64 `json:"lockUntil" binding:"min=0"`    // Earliest block the recipient can spend the payment in
+	RelativeLock int64 `json:"relativeLock" binding:"min=0"` // Blocks the recipient must wait after the payment is confirmed
 }
 
 // CreateTransactionRequest is used when creating a new transaction
 type CreateTransactionRequest struct {
-	RecipientWalletID string  `json:"recipientWalletId" binding:"required"`
-	Amount            float64 `json:"amount" binding:"required,gt=0"`
-	Message           string  `json:"message"`
+	RecipientWalletID string `json:"recipientWalletId" binding:"required"`
+	Amount            Amount `json:"amount" binding:"required,gt=0"`
+	Message           string `json:"message"`
+	SigHashType       uint8  `json:"sigHashType"` // Optional, defaults to SIGHASH_ALL
+	PaymentLocks
 }
 
 // SignTransactionRequest contains the data to sign for a transaction
 type SignTransactionRequest struct {
-	TransactionID string        `json:"transactionId" binding:"required"`
+	TransactionID string           `json:"transactionId" binding:"required"`
 	Signatures    []InputSignature `json:"signatures" binding:"required"`
 }
 
 // InputSignature pairs an input index with its signature
 type InputSignature struct {
-	InputIndex int    `json:"inputIndex"`
-	Signature  string `json:"signature"`
+	InputIndex  int     `json:"inputIndex"`
+	Signature   string  `json:"signature"`
+	SigHashType uint8   `json:"sigHashType"`       // Optional, defaults to SIGHASH_ALL
+	KeyType     KeyType `json:"keyType,omitempty"` // Optional, must match the input's key when given
 }
 
 // TransactionPreview shows what a transaction will look like before signing
@@ -83,13 +114,17 @@ type TransactionPreview struct {
 	TransactionID     string              `json:"transactionId"`
 	Inputs            []SignedInput       `json:"inputs"`
 	Outputs           []TransactionOutput `json:"outputs"`
-	TotalInput        float64             `json:"totalInput"`
-	TotalOutput       float64             `json:"totalOutput"`
-	Fee               float64             `json:"fee"`
+	TotalInput        Amount              `json:"totalInput"`
+	TotalOutput       Amount              `json:"totalOutput"`
+	Fee               Amount              `json:"fee"`
 	DataToSign        []string            `json:"dataToSign"` // Hash of each input to sign
+	KeyPaths          []string            `json:"keyPaths"`   // Derivation path of the key that signs each input ("m" is the master key)
 	RecipientWalletID string              `json:"recipientWalletId"`
-	Amount            float64             `json:"amount"`
-	Change            float64             `json:"change"`
+	Amount            Amount              `json:"amount"`
+	Change            Amount              `json:"change"`
+	ChangeAddress     string              `json:"changeAddress,omitempty"` // Echo it back when broadcasting
+	Timestamp         int64               `json:"timestamp"`               // Unix time covered by the transaction ID; echo it back when broadcasting
+	LockTime          int64               `json:"lockTime,omitempty"`
 }
 
 // TransactionResponse is returned after transaction operations
@@ -103,8 +138,8 @@ type TransactionHistoryItem struct {
 	TransactionID string            `json:"transactionId"`
 	Type          TransactionType   `json:"type"`
 	Direction     string            `json:"direction"` // "sent" or "received"
-	Amount        float64           `json:"amount"`
-	Fee           float64           `json:"fee"`
+	Amount        Amount            `json:"amount"`
+	Fee           Amount            `json:"fee"`
 	Counterparty  string            `json:"counterparty"` // Other party's wallet ID
 	Status        TransactionStatus `json:"status"`
 	Timestamp     time.Time         `json:"timestamp"`
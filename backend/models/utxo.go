@@ -9,55 +9,61 @@ import (
 // UTXO represents an Unspent Transaction Output
 // This is the fundamental building block for tracking balances in a UTXO-based system
 type UTXO struct {
-	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	TransactionID   string             `json:"transactionId" bson:"transactionId"`     // ID of the transaction that created this UTXO
-	OutputIndex     int                `json:"outputIndex" bson:"outputIndex"`         // Index of this output in the transaction
-	WalletID        string             `json:"walletId" bson:"walletId"`               // Owner's wallet ID
-	Amount          float64            `json:"amount" bson:"amount"`                   // Amount in this UTXO
-	PublicKey       string             `json:"publicKey" bson:"publicKey"`             // Owner's public key for verification
-	IsSpent         bool               `json:"isSpent" bson:"isSpent"`                 // Whether this UTXO has been spent
-	SpentInTx       string             `json:"spentInTx,omitempty" bson:"spentInTx"`   // Transaction ID that spent this UTXO
-	BlockHash       string             `json:"blockHash,omitempty" bson:"blockHash"`   // Block hash where this UTXO was confirmed
-	IsConfirmed     bool               `json:"isConfirmed" bson:"isConfirmed"`         // Whether this UTXO is confirmed in a block
-	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
-	SpentAt         *time.Time         `json:"spentAt,omitempty" bson:"spentAt"`
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TransactionID string             `json:"transactionId" bson:"transactionId"`                     // ID of the transaction that created this UTXO
+	OutputIndex   int                `json:"outputIndex" bson:"outputIndex"`                         // Index of this output in the transaction
+	WalletID      string             `json:"walletId" bson:"walletId"`                               // Owner's wallet ID
+	Amount        Amount             `json:"amount" bson:"amount"`                                   // Amount in this UTXO
+	PublicKey     string             `json:"publicKey" bson:"publicKey"`                             // Owner's public key for verification
+	LockingScript string             `json:"lockingScript,omitempty" bson:"lockingScript,omitempty"` // Hex script that must succeed to spend it
+	ScriptClass   string             `json:"scriptClass,omitempty" bson:"scriptClass,omitempty"`     // Standard form of LockingScript, e.g. "pubkeyhash"
+	IsSpent       bool               `json:"isSpent" bson:"isSpent"`                                 // Whether this UTXO has been spent
+	SpentInTx     string             `json:"spentInTx,omitempty" bson:"spentInTx"`                   // Transaction ID that spent this UTXO
+	BlockHash     string             `json:"blockHash,omitempty" bson:"blockHash"`                   // Block hash where this UTXO was confirmed
+	IsConfirmed   bool               `json:"isConfirmed" bson:"isConfirmed"`                         // Whether this UTXO is confirmed in a block
+	BlockHeight   int64              `json:"blockHeight,omitempty" bson:"blockHeight,omitempty"`     // Height of the block that confirmed it
+	LockUntil     int64              `json:"lockUntil,omitempty" bson:"lockUntil,omitempty"`         // Block height or Unix time before which it can't be spent
+	RelativeLock  int64              `json:"relativeLock,omitempty" bson:"relativeLock,omitempty"`   // Blocks it must wait after confirmation before it can be spent
+	Locked        bool               `json:"locked,omitempty" bson:"-"`                              // Set in responses while a time lock prevents spending
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	SpentAt       *time.Time         `json:"spentAt,omitempty" bson:"spentAt"`
 }
 
 // UTXOInput represents a reference to a UTXO being spent in a transaction
 type UTXOInput struct {
-	TransactionID string  `json:"transactionId" bson:"transactionId"` // Reference to the UTXO's transaction
-	OutputIndex   int     `json:"outputIndex" bson:"outputIndex"`     // Index of the output being spent
-	Amount        float64 `json:"amount" bson:"amount"`               // Amount being spent
-	Signature     string  `json:"signature" bson:"signature"`         // Digital signature proving ownership
+	TransactionID string `json:"transactionId" bson:"transactionId"` // Reference to the UTXO's transaction
+	OutputIndex   int    `json:"outputIndex" bson:"outputIndex"`     // Index of the output being spent
+	Amount        Amount `json:"amount" bson:"amount"`               // Amount being spent
+	Signature     string `json:"signature" bson:"signature"`         // Digital signature proving ownership
 }
 
 // UTXOOutput represents a new output being created in a transaction
 type UTXOOutput struct {
-	WalletID  string  `json:"walletId" bson:"walletId"`   // Recipient's wallet ID
-	Amount    float64 `json:"amount" bson:"amount"`       // Amount being sent
-	PublicKey string  `json:"publicKey" bson:"publicKey"` // Recipient's public key
+	WalletID  string `json:"walletId" bson:"walletId"`   // Recipient's wallet ID
+	Amount    Amount `json:"amount" bson:"amount"`       // Amount being sent
+	PublicKey string `json:"publicKey" bson:"publicKey"` // Recipient's public key
 }
 
 // UTXOSet represents a collection of UTXOs for balance calculation
 type UTXOSet struct {
-	WalletID     string  `json:"walletId"`
-	TotalBalance float64 `json:"totalBalance"`
-	UTXOs        []UTXO  `json:"utxos"`
-	UTXOCount    int     `json:"utxoCount"`
+	WalletID     string `json:"walletId"`
+	TotalBalance Amount `json:"totalBalance"`
+	UTXOs        []UTXO `json:"utxos"`
+	UTXOCount    int    `json:"utxoCount"`
 }
 
 // BalanceResponse is returned when querying a wallet's balance
 type BalanceResponse struct {
-	WalletID         string  `json:"walletId"`
-	Balance          float64 `json:"balance"`
-	ConfirmedBalance float64 `json:"confirmedBalance"`
-	PendingBalance   float64 `json:"pendingBalance"`
-	UTXOCount        int     `json:"utxoCount"`
+	WalletID         string `json:"walletId"`
+	Balance          Amount `json:"balance"`
+	ConfirmedBalance Amount `json:"confirmedBalance"`
+	PendingBalance   Amount `json:"pendingBalance"`
+	UTXOCount        int    `json:"utxoCount"`
 }
 
 // CoinbaseUTXO creates a new UTXO for mining rewards or initial distribution
 type CoinbaseRequest struct {
-	WalletID string  `json:"walletId" binding:"required"`
-	Amount   float64 `json:"amount" binding:"required"`
-	Reason   string  `json:"reason"` // "mining_reward", "initial_distribution", etc.
+	WalletID string `json:"walletId" binding:"required"`
+	Amount   Amount `json:"amount" binding:"required"`
+	Reason   string `json:"reason"` // "mining_reward", "initial_distribution", etc.
 }
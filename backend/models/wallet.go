@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -8,14 +9,61 @@ import (
 
 // Wallet represents a user's cryptocurrency wallet
 type Wallet struct {
-	ID              primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	UserID          primitive.ObjectID `json:"userId" bson:"userId"`
-	WalletID        string             `json:"walletId" bson:"walletId"`          // Unique wallet address (hash of public key)
-	PublicKey       string             `json:"publicKey" bson:"publicKey"`        // Public key in hex format
-	PrivateKey      string             `json:"-" bson:"privateKey"`               // Encrypted private key (never sent to client)
-	Balance         float64            `json:"balance" bson:"balance"`            // Cached balance (calculated from UTXOs)
-	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID           primitive.ObjectID `json:"userId" bson:"userId"`
+	WalletID         string             `json:"walletId" bson:"walletId"`         // Unique wallet address (hash of public key)
+	PublicKey        string             `json:"publicKey" bson:"publicKey"`       // Public key in hex format
+	PrivateKey       string             `json:"-" bson:"privateKey"`              // Encrypted private key (never sent to client)
+	Balance          Amount             `json:"balance" bson:"balance"`           // Cached balance (calculated from UTXOs)
+	KeyType          KeyType            `json:"keyType" bson:"keyType,omitempty"` // Signature scheme of the wallet's keys (empty means P-256)
+	ChainCode        string             `json:"-" bson:"chainCode,omitempty"`     // Encrypted HD chain code (empty for wallets created before HD support)
+	NextReceiveIndex uint32             `json:"-" bson:"nextReceiveIndex"`        // Next unused receive address index
+	NextChangeIndex  uint32             `json:"-" bson:"nextChangeIndex"`         // Next unused change address index
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// KeyType identifies the signature scheme a wallet's keys use
+type KeyType string
+
+const (
+	KeyTypeP256      KeyType = "p256"      // ECDSA on NIST P-256, the default
+	KeyTypeSecp256k1 KeyType = "secp256k1" // ECDSA on secp256k1, as used by Bitcoin and Ethereum
+	KeyTypeEd25519   KeyType = "ed25519"   // EdDSA on Curve25519
+)
+
+// OrDefault returns the key type, treating an empty tag (wallets and inputs
+// created before key types existed) as P-256
+func (t KeyType) OrDefault() KeyType {
+	if t == "" {
+		return KeyTypeP256
+	}
+	return t
+}
+
+// IsHD reports whether the wallet can derive child addresses
+func (w *Wallet) IsHD() bool {
+	return w.ChainCode != ""
+}
+
+// Address chains used for derived addresses
+const (
+	AddressChainReceive uint32 = 0 // Addresses handed out to receive payments
+	AddressChainChange  uint32 = 1 // Internal addresses that receive transaction change
+)
+
+// WalletAddress is an address derived from a wallet's HD master key.
+// Funds sent to any of a wallet's addresses belong to that wallet.
+type WalletAddress struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	WalletID  string             `json:"walletId" bson:"walletId"`   // Primary wallet ID the address belongs to
+	Address   string             `json:"address" bson:"address"`     // Derived wallet ID that can receive funds
+	PublicKey string             `json:"publicKey" bson:"publicKey"` // Derived public key in hex format
+	Chain     uint32             `json:"chain" bson:"chain"`         // 0 = receive, 1 = change
+	Index     uint32             `json:"index" bson:"index"`         // Child index on the chain
+	Path      string             `json:"path" bson:"path"`           // Derivation path, e.g. m/0/3
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
 }
 
 // WalletResponse is used when sending wallet info to client (no private key)
@@ -24,10 +72,59 @@ type WalletResponse struct {
 	UserID    primitive.ObjectID `json:"userId"`
 	WalletID  string             `json:"walletId"`
 	PublicKey string             `json:"publicKey"`
-	Balance   float64            `json:"balance"`
+	Balance   Amount             `json:"balance"`
+	KeyType   KeyType            `json:"keyType"`
+	IsHD      bool               `json:"isHD"`
 	CreatedAt time.Time          `json:"createdAt"`
 }
 
+// GenerateWalletRequest holds the optional settings for generating a wallet
+type GenerateWalletRequest struct {
+	UseMnemonic bool    `json:"useMnemonic"`                                              // Derive the key from a new mnemonic phrase
+	WordCount   int     `json:"wordCount"`                                                // 12 or 24 words (default 12)
+	Passphrase  string  `json:"passphrase"`                                               // Optional BIP-39 passphrase
+	KeyType     KeyType `json:"keyType" binding:"omitempty,oneof=p256 secp256k1 ed25519"` // Default p256
+}
+
+// RestoreWalletRequest is used to rebuild a wallet from its mnemonic phrase
+type RestoreWalletRequest struct {
+	Mnemonic   string  `json:"mnemonic" binding:"required"`
+	Passphrase string  `json:"passphrase"`
+	KeyType    KeyType `json:"keyType" binding:"omitempty,oneof=p256 secp256k1 ed25519"` // Must match the type the wallet was created with
+}
+
+// ExportKeystoreRequest is used to export the wallet as an encrypted keystore file
+type ExportKeystoreRequest struct {
+	Password   string `json:"password" binding:"required"`         // Account password, re-entered to confirm the export
+	Passphrase string `json:"passphrase" binding:"required,min=8"` // Protects the keystore file
+}
+
+// ImportKeystoreRequest is used to create or restore a wallet from a keystore file
+type ImportKeystoreRequest struct {
+	Keystore   json.RawMessage `json:"keystore" binding:"required"`
+	Passphrase string          `json:"passphrase" binding:"required"`
+}
+
+// ImportPrivateKeyRequest is used to create a wallet from an existing private key
+type ImportPrivateKeyRequest struct {
+	PrivateKey string  `json:"privateKey" binding:"required"` // P-256: PEM or hex DER; secp256k1: hex scalar; Ed25519: hex seed or PEM
+	KeyType    KeyType `json:"keyType" binding:"omitempty,oneof=p256 secp256k1 ed25519"`
+}
+
+// SignMessageRequest asks for a signature over a message with one of the
+// caller's keys, to prove control of an address
+type SignMessageRequest struct {
+	Message string `json:"message" binding:"required,max=4096"`
+	Address string `json:"address"` // Primary wallet ID or derived address; defaults to the primary wallet ID
+}
+
+// VerifyMessageRequest checks a signed message against the key behind an address
+type VerifyMessageRequest struct {
+	WalletID  string `json:"walletId" binding:"required"` // Address that signed the message
+	Message   string `json:"message" binding:"required,max=4096"`
+	Signature string `json:"signature" binding:"required,hexadecimal"`
+}
+
 // Beneficiary represents a saved wallet address for quick transfers
 type Beneficiary struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
@@ -16,8 +16,11 @@ func SetupBlockchainRoutes(router *gin.Engine) {
 		blockchain.GET("/blocks", controllers.GetBlocks)
 		blockchain.GET("/block/:identifier", controllers.GetBlock)
 		blockchain.GET("/latest", controllers.GetLatestBlock)
+		blockchain.GET("/proof/:txId", controllers.GetMerkleProof)
 		blockchain.GET("/validate", controllers.ValidateBlockchain)
 		blockchain.GET("/mining-status", controllers.GetMiningStatus)
+		blockchain.GET("/pool/stats", controllers.GetPoolStats)
+		blockchain.GET("/pool/workers", controllers.GetPoolWorkers)
 
 		// Protected endpoints (require authentication)
 		protected := blockchain.Group("/")
@@ -25,6 +28,11 @@ func SetupBlockchainRoutes(router *gin.Engine) {
 		{
 			protected.POST("/genesis", controllers.CreateGenesisBlock)
 			protected.POST("/mine", controllers.MineBlock)
+			protected.GET("/template", controllers.GetBlockTemplate)
+			protected.POST("/submit", controllers.SubmitBlock)
+			protected.GET("/mining-jobs", controllers.GetMyMiningJobs)
+			protected.GET("/mining-jobs/:id", controllers.GetMiningJob)
+			protected.POST("/mining-jobs/:id/cancel", controllers.CancelMiningJob)
 			protected.GET("/my-blocks", controllers.GetMyMinedBlocks)
 		}
 	}
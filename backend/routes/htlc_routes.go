@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"crypto-wallet-backend/controllers"
+	"crypto-wallet-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupHTLCRoutes configures hash time-locked contract routes
+func SetupHTLCRoutes(router *gin.Engine) {
+	htlc := router.Group("/api/htlc")
+	htlc.Use(middleware.AuthRequired())
+	{
+		htlc.POST("", controllers.CreateHTLC)
+		htlc.GET("", controllers.GetMyHTLCs)
+		htlc.GET("/:id", controllers.GetHTLC)
+		htlc.POST("/:id/claim", controllers.ClaimHTLC)
+		htlc.POST("/:id/refund", controllers.RefundHTLC)
+	}
+}
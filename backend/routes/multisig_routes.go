@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"crypto-wallet-backend/controllers"
+	"crypto-wallet-backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMultisigRoutes configures multisig wallet routes
+func SetupMultisigRoutes(router *gin.Engine) {
+	multisig := router.Group("/api/multisig")
+	multisig.Use(middleware.AuthRequired())
+	{
+		multisig.POST("/wallets", controllers.CreateMultisigWallet)
+		multisig.GET("/wallets", controllers.GetMyMultisigWallets)
+		multisig.POST("/spends", controllers.ProposeMultisigSpend)
+		multisig.GET("/spends", controllers.GetMyMultisigSpends)
+		multisig.GET("/spends/:id", controllers.GetMultisigSpend)
+		multisig.POST("/spends/:id/sign", controllers.SignMultisigSpend)
+	}
+}
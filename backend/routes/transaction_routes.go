@@ -27,6 +27,12 @@ func SetupTransactionRoutes(router *gin.Engine) {
 		// Get transaction stats
 		tx.GET("/stats", controllers.GetTransactionStats)
 
+		// Lock funds to a custom script and spend script-locked outputs
+		tx.POST("/script/lock", controllers.LockToScript)
+		tx.POST("/script/preview", controllers.PreviewScriptSpend)
+		tx.POST("/script/spend", controllers.SpendScriptOutputs)
+		tx.POST("/script/decode", controllers.DecodeScript)
+
 		// Get a specific transaction by ID
 		tx.GET("/:txId", controllers.GetTransaction)
 	}
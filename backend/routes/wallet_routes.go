@@ -13,11 +13,18 @@ func SetupWalletRoutes(router *gin.Engine) {
 		// Public routes
 		wallet.GET("/validate/:walletId", controllers.ValidateWalletID)
 		wallet.GET("/info/:walletId", controllers.GetWalletByID)
+		wallet.POST("/verify-message", controllers.VerifyMessage)
 
 		// Protected routes
 		wallet.POST("/generate", middleware.AuthRequired(), controllers.GenerateWallet)
+		wallet.POST("/restore", middleware.AuthRequired(), controllers.RestoreWallet)
+		wallet.POST("/import-key", middleware.AuthRequired(), controllers.ImportPrivateKey)
 		wallet.GET("/my-wallet", middleware.AuthRequired(), controllers.GetWallet)
-		wallet.GET("/export-key", middleware.AuthRequired(), controllers.ExportPrivateKey)
+		wallet.POST("/export-keystore", middleware.AuthRequired(), middleware.LocalKeysRequired(), controllers.ExportKeystore)
+		wallet.POST("/import-keystore", middleware.AuthRequired(), middleware.LocalKeysRequired(), controllers.ImportKeystore)
+		wallet.GET("/addresses", middleware.AuthRequired(), controllers.GetMyAddresses)
+		wallet.POST("/addresses", middleware.AuthRequired(), controllers.NewReceiveAddress)
+		wallet.POST("/sign-message", middleware.AuthRequired(), controllers.SignMessage)
 
 		// Beneficiary routes
 		wallet.GET("/beneficiaries", middleware.AuthRequired(), controllers.GetBeneficiaries)
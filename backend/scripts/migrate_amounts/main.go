@@ -0,0 +1,129 @@
+// Command migrate_amounts rewrites amounts stored as floating-point coin values
+// into integer base units (see models.Amount). It is safe to run more than once:
+// documents that already hold integers are left untouched.
+//
+// Run it from the backend/scripts/migrate_amounts directory after deploying the
+// integer amount change:
+//
+//	go run .
+package main
+
+import (
+	"context"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// amountFields lists the fields holding amounts in each collection. Dotted
+// paths are searched inside arrays of embedded documents as well.
+var amountFields = map[string][]string{
+	"utxos":              {"amount"},
+	"transactions":       {"totalInput", "totalOutput", "fee", "inputs.amount", "outputs.amount"},
+	"blocks":             {"miningReward", "transactions.totalInput", "transactions.totalOutput", "transactions.fee", "transactions.inputs.amount", "transactions.outputs.amount"},
+	"wallets":            {"balance"},
+	"zakat_calculations": {"totalBalance", "nisabThreshold", "zakatAmount"},
+	"zakat_payments":     {"amount"},
+}
+
+// Collections are migrated in a fixed order so the log output is predictable
+var collectionOrder = []string{"utxos", "transactions", "blocks", "wallets", "zakat_calculations", "zakat_payments"}
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load("../../.env"); err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	// Connect to database
+	if err := database.Connect(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.Disconnect()
+
+	fmt.Println("=== Migrate Amounts To Base Units ===")
+
+	for _, name := range collectionOrder {
+		updated, err := migrateCollection(name, amountFields[name])
+		if err != nil {
+			log.Fatalf("Failed to migrate %s: %v", name, err)
+		}
+		fmt.Printf("✅ %s: %d documents updated\n", name, updated)
+	}
+}
+
+// migrateCollection converts every double found at the given paths and writes back
+// the documents that changed
+func migrateCollection(name string, paths []string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	collection := database.GetCollection(name)
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return updated, err
+		}
+
+		changed := false
+		for _, path := range paths {
+			if convertPath(doc, strings.Split(path, ".")) {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		if _, err := collection.ReplaceOne(ctx, bson.M{"_id": doc["_id"]}, doc); err != nil {
+			return updated, fmt.Errorf("document %v: %w", doc["_id"], err)
+		}
+		updated++
+	}
+
+	return updated, cursor.Err()
+}
+
+// convertPath replaces floating-point coin values at path with int64 base units.
+// It reports whether anything was changed.
+func convertPath(doc bson.M, path []string) bool {
+	value, ok := doc[path[0]]
+	if !ok {
+		return false
+	}
+
+	if len(path) == 1 {
+		coins, ok := value.(float64)
+		if !ok {
+			return false
+		}
+		doc[path[0]] = int64(models.AmountFromCoins(coins))
+		return true
+	}
+
+	changed := false
+	switch nested := value.(type) {
+	case bson.M:
+		changed = convertPath(nested, path[1:])
+	case bson.A:
+		for _, item := range nested {
+			if itemDoc, ok := item.(bson.M); ok && convertPath(itemDoc, path[1:]) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
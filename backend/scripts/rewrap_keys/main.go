@@ -0,0 +1,118 @@
+// Command rewrap_keys re-wraps every wallet's encrypted secrets under the active
+// master key (WALLET_MASTER_KEY). Only the wrapped data keys change, so it is
+// quick and safe to run while the server is up. Values still in the older
+// JWT_SECRET format are converted to envelopes on the way.
+//
+// To rotate the master key without downtime:
+//
+//  1. Restart the servers with WALLET_MASTER_KEY set to the new key and the old
+//     key added to WALLET_PREVIOUS_MASTER_KEYS, so both can be read.
+//  2. Run this command with the same environment: go run . from the
+//     backend/scripts/rewrap_keys directory.
+//  3. Once it reports nothing left to re-wrap, remove the old key from
+//     WALLET_PREVIOUS_MASTER_KEYS.
+//
+// Running it again is harmless: values already under the active key are skipped.
+package main
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// encryptedFields lists the wallet fields holding envelope-encrypted secrets
+var encryptedFields = []string{"privateKey", "chainCode"}
+
+func main() {
+	// Load environment variables
+	if err := godotenv.Load("../../.env"); err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	activeID, err := crypto.ActiveMasterKeyID()
+	if err != nil {
+		log.Fatal("Invalid master key configuration:", err)
+	}
+
+	// Connect to database
+	if err := database.Connect(); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.Disconnect()
+
+	fmt.Println("=== Re-wrap Wallet Keys ===")
+	fmt.Printf("Active master key: %s\n", activeID)
+
+	rewrapped, skipped, err := rewrapWallets()
+	if err != nil {
+		log.Fatal("Failed to re-wrap wallets:", err)
+	}
+	fmt.Printf("✅ wallets: %d re-wrapped\n", rewrapped)
+	if skipped > 0 {
+		fmt.Printf("⚠️  %d wallets changed during the run; run the command again to finish them\n", skipped)
+	}
+}
+
+// rewrapWallets re-wraps every wallet whose secrets aren't under the active master
+// key. A wallet updated concurrently (e.g. restored by its owner) is skipped and
+// counted, since the server already wrote it under the active key.
+func rewrapWallets() (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	collection := database.GetCollection("wallets")
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	rewrapped, skipped := 0, 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return rewrapped, skipped, err
+		}
+
+		// Only update if the secrets are still the ones we read
+		filter := bson.M{"_id": doc["_id"]}
+		update := bson.M{}
+		for _, field := range encryptedFields {
+			value, ok := doc[field].(string)
+			if !ok || value == "" {
+				continue
+			}
+			filter[field] = value
+
+			newValue, changed, err := crypto.RewrapPrivateKey(value)
+			if err != nil {
+				return rewrapped, skipped, fmt.Errorf("wallet %v %s: %w", doc["_id"], field, err)
+			}
+			if changed {
+				update[field] = newValue
+			}
+		}
+		if len(update) == 0 {
+			continue
+		}
+
+		result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": update})
+		if err != nil {
+			return rewrapped, skipped, fmt.Errorf("wallet %v: %w", doc["_id"], err)
+		}
+		if result.MatchedCount == 0 {
+			skipped++
+			continue
+		}
+		rewrapped++
+	}
+
+	return rewrapped, skipped, cursor.Err()
+}
@@ -0,0 +1,182 @@
+package signer
+
+import (
+	"context"
+	"crypto-wallet-backend/crypto"
+	"crypto-wallet-backend/database"
+	"crypto-wallet-backend/models"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LocalSigner signs in the current process with keys decrypted from the wallets
+// collection. It is used by the API server by default and by the signing daemon.
+type LocalSigner struct{}
+
+// NewLocalSigner returns a signer that reads keys from the database
+func NewLocalSigner() *LocalSigner {
+	return &LocalSigner{}
+}
+
+// PublicKey returns the public key (hex) at path
+func (s *LocalSigner) PublicKey(ctx context.Context, walletID, path string) (string, error) {
+	keyPair, err := s.keyPair(ctx, walletID, path)
+	if err != nil {
+		return "", err
+	}
+	return keyPair.PublicKeyHex, nil
+}
+
+// SignDigest signs digest with the key at path
+func (s *LocalSigner) SignDigest(ctx context.Context, walletID, path string, digest []byte) (string, error) {
+	keyPair, err := s.keyPair(ctx, walletID, path)
+	if err != nil {
+		return "", err
+	}
+	return keyPair.SignDigest(digest)
+}
+
+// keyPair decrypts the wallet's master key and derives the key at path
+func (s *LocalSigner) keyPair(ctx context.Context, walletID, path string) (*crypto.KeyPair, error) {
+	var wallet models.Wallet
+	err := database.GetCollection("wallets").FindOne(ctx, bson.M{"walletId": walletID}).Decode(&wallet)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWallet, walletID)
+		}
+		return nil, err
+	}
+
+	privateKeyHex, err := crypto.DecryptPrivateKey(wallet.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	var chainCodeHex string
+	if wallet.IsHD() {
+		chainCodeHex, err = crypto.DecryptPrivateKey(wallet.ChainCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt chain code: %w", err)
+		}
+	}
+
+	// Wallets without a chain code only have their own key, at path "m"
+	masterKey, err := crypto.NewExtendedKey(wallet.KeyType, privateKeyHex, chainCodeHex)
+	if err != nil {
+		return nil, err
+	}
+	key, err := masterKey.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.KeyPair()
+}
+
+// DescribeKey returns the public details of the key spec recovers or imports
+func (s *LocalSigner) DescribeKey(ctx context.Context, spec KeySpec) (*KeyInfo, error) {
+	if spec.generates() {
+		return nil, fmt.Errorf("%w: no key to describe", ErrInvalidKey)
+	}
+	key, _, err := makeKey(spec)
+	if err != nil {
+		return nil, err
+	}
+	return keyInfo(key, "")
+}
+
+// StoreKey makes the key spec describes and saves it on the wallet document
+func (s *LocalSigner) StoreKey(ctx context.Context, walletDocID primitive.ObjectID, spec KeySpec) (*KeyInfo, error) {
+	key, mnemonic, err := makeKey(spec)
+	if err != nil {
+		return nil, err
+	}
+	info, err := keyInfo(key, mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	keyPair, err := key.KeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedPrivateKey, err := crypto.EncryptPrivateKey(keyPair.PrivateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	update := bson.M{
+		"privateKey": encryptedPrivateKey,
+		"keyType":    keyPair.KeyType,
+	}
+	// A plain key never downgrades an HD wallet
+	if len(key.ChainCode) > 0 {
+		encryptedChainCode, err := crypto.EncryptPrivateKey(key.ChainCodeHex())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt chain code: %w", err)
+		}
+		update["chainCode"] = encryptedChainCode
+	}
+
+	_, err = database.GetCollection("wallets").UpdateOne(ctx,
+		bson.M{"_id": walletDocID},
+		bson.M{"$set": update},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// makeKey builds the key spec describes, returning the mnemonic it generated
+// if any. Unusable input is reported as ErrInvalidKey.
+func makeKey(spec KeySpec) (*crypto.ExtendedKey, string, error) {
+	var key *crypto.ExtendedKey
+	var mnemonic string
+	var err error
+	switch {
+	case spec.Mnemonic != "":
+		key, err = keyFromMnemonic(spec.Mnemonic, spec.Passphrase, spec.KeyType)
+	case spec.PrivateKey != "":
+		key, err = crypto.ImportKey(spec.KeyType, spec.PrivateKey)
+	case spec.PrivateKeyHex != "":
+		key, err = crypto.NewExtendedKey(spec.KeyType, spec.PrivateKeyHex, spec.ChainCodeHex)
+	case spec.WordCount > 0:
+		if mnemonic, err = crypto.GenerateMnemonic(spec.WordCount); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		key, err = keyFromMnemonic(mnemonic, spec.Passphrase, spec.KeyType)
+	default:
+		// Failing to generate a key isn't the caller's fault
+		key, err = crypto.GenerateMasterKey(spec.KeyType)
+		return key, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return key, mnemonic, nil
+}
+
+func keyFromMnemonic(mnemonic, passphrase string, keyType models.KeyType) (*crypto.ExtendedKey, error) {
+	seed, err := crypto.MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewMasterKey(seed, keyType)
+}
+
+// keyInfo returns the public details of key
+func keyInfo(key *crypto.ExtendedKey, mnemonic string) (*KeyInfo, error) {
+	keyPair, err := key.KeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return &KeyInfo{
+		KeyType:   keyPair.KeyType,
+		PublicKey: keyPair.PublicKeyHex,
+		HD:        len(key.ChainCode) > 0,
+		Mnemonic:  mnemonic,
+	}, nil
+}
@@ -0,0 +1,268 @@
+package signer
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The API server and the signing daemon talk net/rpc over a Unix socket or a
+// loopback TCP port. Each request carries the shared SIGNER_TOKEN, if one is set.
+
+// rpcServiceName is the name the daemon registers its service under
+const rpcServiceName = "Signer"
+
+// requestTimeout bounds a request on the daemon side
+const requestTimeout = 10 * time.Second
+
+var errUnauthorized = errors.New("signer: invalid token")
+
+// remoteErrors are the errors callers test for, which net/rpc only carries as
+// text and RemoteSigner turns back into errors that wrap them
+var remoteErrors = []error{ErrUnknownWallet, ErrInvalidKey}
+
+// PublicKeyArgs is the request for Signer.PublicKey
+type PublicKeyArgs struct {
+	Token    string
+	WalletID string
+	Path     string
+}
+
+// PublicKeyReply is the response to Signer.PublicKey
+type PublicKeyReply struct {
+	PublicKey string
+}
+
+// SignDigestArgs is the request for Signer.SignDigest
+type SignDigestArgs struct {
+	Token    string
+	WalletID string
+	Path     string
+	Digest   []byte
+}
+
+// SignDigestReply is the response to Signer.SignDigest
+type SignDigestReply struct {
+	Signature string
+}
+
+// DescribeKeyArgs is the request for Signer.DescribeKey
+type DescribeKeyArgs struct {
+	Token string
+	Spec  KeySpec
+}
+
+// StoreKeyArgs is the request for Signer.StoreKey
+type StoreKeyArgs struct {
+	Token       string
+	WalletDocID primitive.ObjectID
+	Spec        KeySpec
+}
+
+// RemoteSigner forwards requests to the signing daemon
+type RemoteSigner struct {
+	network string
+	address string
+	token   string
+}
+
+// NewRemoteSigner returns a signer that calls the daemon at address
+func NewRemoteSigner(address, token string) (*RemoteSigner, error) {
+	network, addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteSigner{network: network, address: addr, token: token}, nil
+}
+
+// PublicKey returns the public key (hex) at path
+func (s *RemoteSigner) PublicKey(ctx context.Context, walletID, path string) (string, error) {
+	var reply PublicKeyReply
+	args := PublicKeyArgs{Token: s.token, WalletID: walletID, Path: path}
+	if err := s.call(ctx, "PublicKey", args, &reply); err != nil {
+		return "", err
+	}
+	return reply.PublicKey, nil
+}
+
+// SignDigest signs digest with the key at path
+func (s *RemoteSigner) SignDigest(ctx context.Context, walletID, path string, digest []byte) (string, error) {
+	var reply SignDigestReply
+	args := SignDigestArgs{Token: s.token, WalletID: walletID, Path: path, Digest: digest}
+	if err := s.call(ctx, "SignDigest", args, &reply); err != nil {
+		return "", err
+	}
+	return reply.Signature, nil
+}
+
+// DescribeKey returns the public details of the key spec recovers or imports
+func (s *RemoteSigner) DescribeKey(ctx context.Context, spec KeySpec) (*KeyInfo, error) {
+	var reply KeyInfo
+	args := DescribeKeyArgs{Token: s.token, Spec: spec}
+	if err := s.call(ctx, "DescribeKey", args, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// StoreKey has the daemon make the key spec describes and save it on the
+// wallet document
+func (s *RemoteSigner) StoreKey(ctx context.Context, walletDocID primitive.ObjectID, spec KeySpec) (*KeyInfo, error) {
+	var reply KeyInfo
+	args := StoreKeyArgs{Token: s.token, WalletDocID: walletDocID, Spec: spec}
+	if err := s.call(ctx, "StoreKey", args, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
+// call makes a single request on a new connection, honouring the context deadline
+func (s *RemoteSigner) call(ctx context.Context, method string, args, reply interface{}) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	err = client.Call(rpcServiceName+"."+method, args, reply)
+	if serverErr, ok := err.(rpc.ServerError); ok {
+		for _, known := range remoteErrors {
+			if detail, found := strings.CutPrefix(string(serverErr), known.Error()); found {
+				return fmt.Errorf("%w%s", known, detail)
+			}
+		}
+	}
+	return err
+}
+
+// Service exposes a Signer over net/rpc
+type Service struct {
+	signer Signer
+	token  string
+}
+
+// PublicKey handles Signer.PublicKey requests
+func (s *Service) PublicKey(args PublicKeyArgs, reply *PublicKeyReply) error {
+	if !s.authorized(args.Token) {
+		return errUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	publicKey, err := s.signer.PublicKey(ctx, args.WalletID, args.Path)
+	if err != nil {
+		return err
+	}
+	reply.PublicKey = publicKey
+	return nil
+}
+
+// SignDigest handles Signer.SignDigest requests
+func (s *Service) SignDigest(args SignDigestArgs, reply *SignDigestReply) error {
+	if !s.authorized(args.Token) {
+		return errUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	signature, err := s.signer.SignDigest(ctx, args.WalletID, args.Path, args.Digest)
+	if err != nil {
+		return err
+	}
+	reply.Signature = signature
+	return nil
+}
+
+// DescribeKey handles Signer.DescribeKey requests
+func (s *Service) DescribeKey(args DescribeKeyArgs, reply *KeyInfo) error {
+	if !s.authorized(args.Token) {
+		return errUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	info, err := s.signer.DescribeKey(ctx, args.Spec)
+	if err != nil {
+		return err
+	}
+	*reply = *info
+	return nil
+}
+
+// StoreKey handles Signer.StoreKey requests. Only the key's public details
+// are sent back.
+func (s *Service) StoreKey(args StoreKeyArgs, reply *KeyInfo) error {
+	if !s.authorized(args.Token) {
+		return errUnauthorized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	info, err := s.signer.StoreKey(ctx, args.WalletDocID, args.Spec)
+	if err != nil {
+		return err
+	}
+	*reply = *info
+	return nil
+}
+
+func (s *Service) authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// Listen opens the daemon's listener. A stale Unix socket is replaced and the
+// new one is made accessible to the owning user only.
+func Listen(address string) (net.Listener, error) {
+	network, addr, err := parseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "unix" {
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "unix" {
+		if err := os.Chmod(addr, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
+
+// Serve answers requests on listener with signer until the listener is closed
+func Serve(listener net.Listener, signer Signer, token string) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(rpcServiceName, &Service{signer: signer, token: token}); err != nil {
+		return err
+	}
+
+	server.Accept(listener)
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package signer produces signatures with wallet keys. The API server signs
+// through the Signer interface, which is backed either by the in-process
+// LocalSigner or by a RemoteSigner that forwards requests to the signing daemon
+// (cmd/signerd), so decrypted keys can be kept out of the API process.
+package signer
+
+import (
+	"context"
+	"crypto-wallet-backend/models"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Signer creates wallet keys, and signs digests with and reports the public
+// keys of the keys of a wallet. Keys are identified by the wallet's primary
+// wallet ID and an HD derivation path; "m" is the wallet's own key.
+type Signer interface {
+	// PublicKey returns the public key (hex) at path
+	PublicKey(ctx context.Context, walletID, path string) (string, error)
+
+	// SignDigest signs a 32-byte digest with the key at path and returns the
+	// signature as hex
+	SignDigest(ctx context.Context, walletID, path string, digest []byte) (string, error)
+
+	// DescribeKey returns the public details of the key spec recovers or
+	// imports, without storing it
+	DescribeKey(ctx context.Context, spec KeySpec) (*KeyInfo, error)
+
+	// StoreKey makes the key spec describes and saves it, encrypted, on the
+	// wallet document with ID walletDocID, creating the document if there is
+	// none. A stored chain code is kept when the new key has none.
+	StoreKey(ctx context.Context, walletDocID primitive.ObjectID, spec KeySpec) (*KeyInfo, error)
+}
+
+// KeySpec describes the key StoreKey makes: recovered from a mnemonic,
+// imported, restored from its stored form, or otherwise freshly generated
+type KeySpec struct {
+	KeyType    models.KeyType
+	Mnemonic   string // BIP-39 phrase to recover the key from
+	Passphrase string // BIP-39 passphrase, with Mnemonic or WordCount
+	WordCount  int    // Words of a new mnemonic to generate the key from; zero generates a key without one
+
+	PrivateKey string // Key to import, in any encoding crypto.ImportKey accepts
+
+	// Key in its stored hex form, as kept in keystore files
+	PrivateKeyHex string
+	ChainCodeHex  string
+}
+
+// generates reports whether spec asks for a new key
+func (spec KeySpec) generates() bool {
+	return spec.Mnemonic == "" && spec.PrivateKey == "" && spec.PrivateKeyHex == ""
+}
+
+// KeyInfo is what the signer reveals about a key it made: only public details,
+// and the mnemonic of a generated key so it can be shown to the user once
+type KeyInfo struct {
+	KeyType   models.KeyType
+	PublicKey string // Hex public key of the wallet's own key
+	HD        bool   // Has a chain code, so addresses can be derived
+	Mnemonic  string // Set only when StoreKey generated a mnemonic
+}
+
+// Backends selectable with SIGNER_BACKEND
+const (
+	BackendLocal  = "local"
+	BackendRemote = "remote"
+)
+
+var (
+	ErrUnknownWallet = errors.New("unknown wallet")
+	ErrInvalidKey    = errors.New("invalid key")
+)
+
+var defaultSigner Signer = NewLocalSigner()
+
+// remote records whether Setup chose the signing daemon
+var remote bool
+
+// Setup selects the signing backend from the environment. SIGNER_BACKEND is
+// "local" (default) or "remote"; the remote backend dials SIGNER_ADDRESS and
+// sends SIGNER_TOKEN with each request.
+func Setup() error {
+	switch backend := os.Getenv("SIGNER_BACKEND"); backend {
+	case "", BackendLocal:
+		defaultSigner = NewLocalSigner()
+		remote = false
+	case BackendRemote:
+		address := os.Getenv("SIGNER_ADDRESS")
+		if address == "" {
+			return errors.New("SIGNER_ADDRESS is required for the remote signer")
+		}
+		remoteSigner, err := NewRemoteSigner(address, os.Getenv("SIGNER_TOKEN"))
+		if err != nil {
+			return err
+		}
+		defaultSigner = remoteSigner
+		remote = true
+	default:
+		return fmt.Errorf("unknown SIGNER_BACKEND %q", backend)
+	}
+	return nil
+}
+
+// Default returns the signer chosen by Setup
+func Default() Signer {
+	return defaultSigner
+}
+
+// IsRemote reports whether keys are held by the signing daemon. The API server
+// then can't decrypt keys itself.
+func IsRemote() bool {
+	return remote
+}
+
+// parseAddress splits a signer address into a network and address. Addresses are
+// either "unix:/path/to/socket" or a loopback "host:port"; the daemon must not
+// be reachable from other machines.
+func parseAddress(address string) (string, string, error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		path = strings.TrimPrefix(path, "//")
+		if path == "" {
+			return "", "", errors.New("signer socket path is empty")
+		}
+		return "unix", path, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid signer address %q: %w", address, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", "", fmt.Errorf("signer address %q must be a unix socket or a loopback address", address)
+		}
+	}
+	return "tcp", address, nil
+}
@@ -0,0 +1,106 @@
+package signer
+
+import (
+	"context"
+	"crypto-wallet-backend/models"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// testRemoteSigner serves a LocalSigner on a loopback port and returns a
+// RemoteSigner that calls it with token
+func testRemoteSigner(t *testing.T, token string) *RemoteSigner {
+	t.Helper()
+	listener, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go Serve(listener, NewLocalSigner(), "secret")
+
+	remote, err := NewRemoteSigner(listener.Addr().String(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote
+}
+
+func TestMakeKeyGeneratesRecoverableMnemonic(t *testing.T) {
+	spec := KeySpec{KeyType: models.KeyTypeSecp256k1, WordCount: 24, Passphrase: "extra"}
+	key, mnemonic, err := makeKey(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings.Fields(mnemonic)) != 24 {
+		t.Fatalf("generated %q, want 24 words", mnemonic)
+	}
+	generated, err := keyInfo(key, mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := NewLocalSigner().DescribeKey(context.Background(), KeySpec{
+		KeyType:    models.KeyTypeSecp256k1,
+		Mnemonic:   mnemonic,
+		Passphrase: "extra",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.PublicKey != generated.PublicKey || !recovered.HD || recovered.Mnemonic != "" {
+		t.Fatalf("recovered %+v, want the public key of %+v without a mnemonic", recovered, generated)
+	}
+}
+
+func TestDescribeKeyRejectsUnusableSpecs(t *testing.T) {
+	tests := []struct {
+		name string
+		spec KeySpec
+	}{
+		{"nothing to describe", KeySpec{}},
+		{"bad mnemonic checksum", KeySpec{Mnemonic: strings.Replace(testMnemonic, "about", "abandon", 1)}},
+		{"bad private key", KeySpec{KeyType: models.KeyTypeSecp256k1, PrivateKey: "zz"}},
+		{"chain code on an Ed25519 key", KeySpec{
+			KeyType:       models.KeyTypeEd25519,
+			PrivateKeyHex: strings.Repeat("11", 32),
+			ChainCodeHex:  strings.Repeat("22", 32),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLocalSigner().DescribeKey(context.Background(), tt.spec); !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("err = %v, want %v", err, ErrInvalidKey)
+			}
+		})
+	}
+}
+
+func TestRemoteDescribeKey(t *testing.T) {
+	ctx := context.Background()
+	spec := KeySpec{Mnemonic: testMnemonic}
+	want, err := NewLocalSigner().DescribeKey(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := testRemoteSigner(t, "secret")
+	got, err := remote.DescribeKey(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Fatalf("remote described %+v, want %+v", *got, *want)
+	}
+
+	// Errors callers test for survive the trip
+	if _, err := remote.DescribeKey(ctx, KeySpec{Mnemonic: "not a mnemonic"}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("invalid mnemonic: err = %v, want %v", err, ErrInvalidKey)
+	}
+
+	if _, err := testRemoteSigner(t, "wrong").DescribeKey(ctx, spec); err == nil || !strings.Contains(err.Error(), errUnauthorized.Error()) {
+		t.Errorf("wrong token: err = %v, want %v", err, errUnauthorized)
+	}
+}
@@ -177,7 +177,7 @@ const Admin = () => {
             <div className="grid grid-cols-1 md:grid-cols-3 gap-6">
               <div className="glass-card rounded-2xl p-6">
                 <h3 className="text-lg font-semibold text-gray-800 mb-4">💰 System Balance</h3>
-                <p className="text-3xl font-bold gradient-text">{stats.totalBalance?.toFixed(2) || '0.00'}</p>
+                <p className="text-3xl font-bold gradient-text">{Number(stats.totalBalance || 0).toFixed(2)}</p>
                 <p className="text-sm text-gray-500">Total coins in circulation</p>
               </div>
 
@@ -300,7 +300,7 @@ const Admin = () => {
                           {tx.type}
                         </span>
                       </td>
-                      <td className="py-3 px-4 text-sm font-medium text-gray-900">{tx.totalOutput?.toFixed(2) || '0.00'}</td>
+                      <td className="py-3 px-4 text-sm font-medium text-gray-900">{Number(tx.totalOutput || 0).toFixed(2)}</td>
                       <td className="py-3 px-4 text-sm text-gray-500 font-mono truncate max-w-[100px]">
                         {tx.senderWallet?.substring(0, 12) || 'System'}...
                       </td>
@@ -353,7 +353,7 @@ const Admin = () => {
                       <td className="py-3 px-4 text-sm text-gray-500 font-mono truncate max-w-[100px]">
                         {block.minerWalletId?.substring(0, 12) || 'Genesis'}...
                       </td>
-                      <td className="py-3 px-4 text-sm font-medium text-amber-600">{block.miningReward?.toFixed(2) || '0.00'}</td>
+                      <td className="py-3 px-4 text-sm font-medium text-amber-600">{Number(block.miningReward || 0).toFixed(2)}</td>
                       <td className="py-3 px-4 text-sm text-gray-600">{block.transactionCount || 0}</td>
                       <td className="py-3 px-4 text-sm text-gray-500">{formatDate(block.timestamp)}</td>
                     </tr>
@@ -25,7 +25,7 @@ const Dashboard = () => {
   const fetchZakatStatus = useCallback(async () => {
     try {
       const res = await api.zakat.getSummary(token);
-      setZakatDue(res.data.summary?.zakatDue || 0);
+      setZakatDue(Number(res.data.summary?.zakatDue) || 0);
     } catch (err) {
       console.log('Zakat info not available');
     }
@@ -281,7 +281,7 @@ const Dashboard = () => {
                       <td className={`py-4 font-semibold ${
                         tx.direction === 'sent' ? 'text-red-600' : 'text-green-600'
                       }`}>
-                        {tx.direction === 'sent' ? '-' : '+'}{tx.amount?.toFixed(2) || '0.00'}
+                        {tx.direction === 'sent' ? '-' : '+'}{Number(tx.amount || 0).toFixed(2)}
                       </td>
                       <td className="py-4 hidden sm:table-cell">
                         <span className={`px-3 py-1 rounded-full text-xs font-medium ${
@@ -61,7 +61,7 @@ const Mining = () => {
     try {
       await api.utxo.createCoinbase({
         walletId: wallet?.walletId,
-        amount: parseFloat(coinbaseAmount),
+        amount: String(coinbaseAmount).trim(),
         reason: 'initial_distribution'
       });
       setShowCoinbaseModal(false);
@@ -99,13 +99,25 @@ const Mining = () => {
     setError('');
 
     try {
+      // Mining runs as a background job; poll it until it finishes
       const response = await api.blockchain.mine(token);
+      const jobId = response.data.jobId;
+      let job = response.data.job;
+      while (!['succeeded', 'failed', 'cancelled'].includes(job.status)) {
+        await new Promise((resolve) => setTimeout(resolve, 1000));
+        job = (await api.blockchain.getMiningJob(jobId, token)).data.job;
+      }
+      if (job.status !== 'succeeded') {
+        setError(job.result?.message || 'Mining failed');
+        return;
+      }
       setMiningResult({
         type: 'mined',
-        message: response.data.message,
-        block: response.data.block,
-        reward: response.data.miningReward,
-        nonce: response.data.nonce
+        message: job.result.message,
+        block: job.result.block,
+        reward: job.miningReward,
+        nonce: job.result.nonce,
+        hashrate: job.hashrate
       });
       fetchData();
     } catch (err) {
@@ -120,9 +132,13 @@ const Mining = () => {
       const response = await api.blockchain.validate();
       const validation = response.data.validation;
       if (validation.isValid) {
-        alert(`✅ Blockchain is valid! ${validation.blocksChecked} blocks verified.`);
+        alert(`✅ Blockchain is valid! ${validation.blocksChecked} blocks and ${validation.transactionsChecked} transactions verified.`);
       } else {
-        alert(`❌ Blockchain validation failed!\nErrors: ${validation.errors.join('\n')}`);
+        const errors = validation.errors.map((e) =>
+          `Block ${e.blockIndex}${e.transactionId ? ` (tx ${e.transactionId.substring(0, 12)}...)` : ''}: ${e.message}`
+        );
+        const more = validation.errorCount - errors.length;
+        alert(`❌ Blockchain validation failed!\nErrors:\n${errors.join('\n')}${more > 0 ? `\n...and ${more} more` : ''}`);
       }
     } catch (err) {
       setError('Failed to validate blockchain');
@@ -182,6 +198,9 @@ const Mining = () => {
                 {miningResult.nonce && (
                   <p className="text-sm text-green-300/60 mt-1">Nonce: {miningResult.nonce}</p>
                 )}
+                {miningResult.hashrate > 0 && (
+                  <p className="text-sm text-green-300/60 mt-1">Hashrate: {Math.round(miningResult.hashrate).toLocaleString()} H/s</p>
+                )}
               </div>
             </div>
           </div>
@@ -362,7 +381,7 @@ const Mining = () => {
               <div className="bg-gray-50 rounded-xl p-4 text-center">
                 <p className="text-gray-600 text-sm">Total Earned</p>
                 <p className="text-2xl font-bold text-green-600 mt-1">
-                  {myBlocks.reduce((sum, b) => sum + (b.miningReward || 0), 0).toFixed(0)}
+                  {myBlocks.reduce((sum, b) => sum + (Number(b.miningReward) || 0), 0).toFixed(0)}
                 </p>
               </div>
               <div className="bg-gray-50 rounded-xl p-4 text-center">
@@ -38,10 +38,10 @@ const Reports = () => {
       let sent = 0, received = 0, zakat = 0;
       txList.forEach(tx => {
         if (tx.direction === 'sent') {
-          sent += tx.amount || 0;
-          if (tx.type === 'zakat') zakat += tx.amount || 0;
+          sent += Number(tx.amount) || 0;
+          if (tx.type === 'zakat') zakat += Number(tx.amount) || 0;
         } else if (tx.direction === 'received') {
-          received += tx.amount || 0;
+          received += Number(tx.amount) || 0;
         }
       });
 
@@ -410,7 +410,7 @@ const Reports = () => {
                     </div>
                     <div className="flex-1 min-w-0">
                       <p className="text-sm font-medium text-gray-900">
-                        {tx.type === 'coinbase' ? 'Mining Reward' : tx.direction === 'sent' ? 'Sent' : 'Received'} {tx.amount?.toFixed(2)} coins
+                        {tx.type === 'coinbase' ? 'Mining Reward' : tx.direction === 'sent' ? 'Sent' : 'Received'} {Number(tx.amount || 0).toFixed(2)} coins
                       </p>
                       <p className="text-xs text-gray-500 truncate">
                         {tx.type === 'coinbase' ? 'Block Reward' : tx.direction === 'sent' ? `To: ${tx.counterparty}` : `From: ${tx.counterparty}`}
@@ -495,7 +495,7 @@ const Reports = () => {
                           {tx.type === 'coinbase' ? 'Mining' : tx.type === 'zakat' ? 'Zakat' : tx.direction === 'sent' ? 'Sent' : 'Received'}
                         </span>
                       </td>
-                      <td className="py-3 px-4 text-sm font-medium text-gray-900">{tx.amount?.toFixed(2)} coins</td>
+                      <td className="py-3 px-4 text-sm font-medium text-gray-900">{Number(tx.amount || 0).toFixed(2)} coins</td>
                       <td className="py-3 px-4 text-sm text-gray-500 font-mono truncate max-w-xs">
                         {tx.type === 'coinbase' ? 'Block Reward' : tx.counterparty || '-'}
                       </td>
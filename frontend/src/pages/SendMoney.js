@@ -56,7 +56,7 @@ const SendMoney = () => {
     try {
       await api.transaction.send({
         recipientWalletId: formData.recipientWalletId,
-        amount: parseFloat(formData.amount),
+        amount: String(formData.amount).trim(),
         message: formData.message,
       });
       
@@ -317,7 +317,7 @@ const SendMoney = () => {
                             <p className={`font-semibold ${
                               tx.direction === 'sent' ? 'text-red-400' : 'text-green-400'
                             }`}>
-                              {tx.direction === 'sent' ? '-' : '+'}{tx.amount?.toFixed(4)}
+                              {tx.direction === 'sent' ? '-' : '+'}{Number(tx.amount || 0).toFixed(4)}
                             </p>
                             <span className={`text-xs px-2 py-0.5 rounded-full ${
                               tx.status === 'confirmed'
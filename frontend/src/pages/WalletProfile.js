@@ -56,12 +56,24 @@ const WalletProfile = () => {
   };
 
   const handleExportKey = async () => {
+    const password = window.prompt('Re-enter your account password to export your wallet');
+    if (!password) return;
+    const passphrase = window.prompt('Choose a passphrase (at least 8 characters) to encrypt the keystore file');
+    if (!passphrase) return;
+
+    setError('');
     try {
-      const res = await api.wallet.exportPrivateKey();
-      setPrivateKey(res.data.privateKey);
-      setShowPrivateKey(true);
+      const res = await api.wallet.exportKeystore({ password, passphrase });
+      const keystore = res.data.keystore;
+      const blob = new Blob([JSON.stringify(keystore, null, 2)], { type: 'application/json' });
+      const url = URL.createObjectURL(blob);
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = `keystore-${keystore.walletId}.json`;
+      link.click();
+      URL.revokeObjectURL(url);
     } catch (err) {
-      setError(err.response?.data?.error || 'Failed to export private key');
+      setError(err.response?.data?.error || 'Failed to export keystore');
     }
   };
 
@@ -248,10 +260,9 @@ const WalletProfile = () => {
                   className="w-full px-4 py-3 rounded-xl bg-gray-100 hover:bg-gray-200 text-gray-700 font-medium transition-colors flex items-center justify-center gap-2"
                 >
                   <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                    <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M15 12a3 3 0 11-6 0 3 3 0 016 0z" />
-                    <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M2.458 12C3.732 7.943 7.523 5 12 5c4.478 0 8.268 2.943 9.542 7-1.274 4.057-5.064 7-9.542 7-4.477 0-8.268-2.943-9.542-7z" />
+                    <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 16v1a3 3 0 003 3h10a3 3 0 003-3v-1m-4-4l-4 4m0 0l-4-4m4 4V4" />
                   </svg>
-                  Reveal Private Key
+                  Download Encrypted Keystore
                 </button>
               )}
             </div>
@@ -275,7 +286,7 @@ const WalletProfile = () => {
                         <p className="text-gray-500 text-xs">Output #{utxo.outputIndex}</p>
                       </div>
                       <div className="text-right">
-                        <p className="text-green-600 font-semibold">{utxo.amount?.toFixed(4)}</p>
+                        <p className="text-green-600 font-semibold">{Number(utxo.amount || 0).toFixed(4)}</p>
                         <p className={`text-xs ${utxo.isConfirmed ? 'text-green-600' : 'text-yellow-600'}`}>
                           {utxo.isConfirmed ? 'Confirmed' : 'Pending'}
                         </p>
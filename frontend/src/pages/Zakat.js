@@ -82,7 +82,7 @@ const Zakat = () => {
   };
 
   const isEligible = summary?.currentBalance >= (settings?.nisabThreshold || 1000);
-  const zakatDue = summary?.zakatDue || 0;
+  const zakatDue = Number(summary?.zakatDue) || 0;
 
   if (loading) {
     return (
@@ -181,7 +181,7 @@ const Zakat = () => {
             <div className="grid grid-cols-2 lg:grid-cols-4 gap-4">
               <div className="glass-card rounded-2xl p-6">
                 <p className="text-gray-600 text-sm mb-1">Current Balance</p>
-                <p className="text-2xl font-bold text-gray-800">{summary?.currentBalance?.toFixed(2) || '0.00'}</p>
+                <p className="text-2xl font-bold text-gray-800">{Number(summary?.currentBalance || 0).toFixed(2)}</p>
               </div>
               <div className="glass-card rounded-2xl p-6">
                 <p className="text-gray-600 text-sm mb-1">Nisab Threshold</p>
@@ -193,7 +193,7 @@ const Zakat = () => {
               </div>
               <div className="glass-card rounded-2xl p-6">
                 <p className="text-gray-600 text-sm mb-1">Total Paid</p>
-                <p className="text-2xl font-bold text-green-600">{summary?.totalPaid?.toFixed(2) || '0.00'}</p>
+                <p className="text-2xl font-bold text-green-600">{Number(summary?.totalPaid || 0).toFixed(2)}</p>
               </div>
             </div>
 
@@ -262,7 +262,7 @@ const Zakat = () => {
                 <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
                   <div>
                     <p className="text-gray-600 text-sm mb-1">Your Balance</p>
-                    <p className="text-3xl font-bold text-gray-800">{summary?.currentBalance?.toFixed(4) || '0.00'}</p>
+                    <p className="text-3xl font-bold text-gray-800">{Number(summary?.currentBalance || 0).toFixed(4)}</p>
                   </div>
                   <div>
                     <p className="text-gray-600 text-sm mb-1">Nisab (Minimum)</p>
@@ -330,7 +330,7 @@ const Zakat = () => {
                             {new Date(payment.paidAt).toLocaleDateString()}
                           </td>
                           <td className="py-4 text-green-400 font-semibold">
-                            {payment.amount?.toFixed(2)}
+                            {Number(payment.amount || 0).toFixed(2)}
                           </td>
                           <td className="py-4">
                             <span className={`px-3 py-1 rounded-full text-xs font-medium ${
@@ -367,7 +367,7 @@ const Zakat = () => {
                     <div key={index} className="bg-gray-50 rounded-xl p-4 flex items-center justify-between">
                       <div>
                         <p className="text-gray-800 font-medium">
-                          Balance: {calc.eligibleBalance?.toFixed(2)} → Zakat: {calc.zakatAmount?.toFixed(2)}
+                          Balance: {Number(calc.eligibleBalance || 0).toFixed(2)} → Zakat: {Number(calc.zakatAmount || 0).toFixed(2)}
                         </p>
                         <p className="text-gray-600 text-sm">
                           {new Date(calc.calculatedAt).toLocaleString()}
@@ -34,11 +34,18 @@ export const authAPI = {
 
 // Wallet API
 export const walletAPI = {
-  generateWallet: () => api.post('/wallet/generate'),
+  generateWallet: (options) => api.post('/wallet/generate', options),
+  restoreWallet: (data) => api.post('/wallet/restore', data),
+  importPrivateKey: (privateKey, keyType) => api.post('/wallet/import-key', { privateKey, keyType }),
   getMyWallet: () => api.get('/wallet/my-wallet'),
   validateWalletId: (walletId) => api.get(`/wallet/validate/${walletId}`),
   getWalletInfo: (walletId) => api.get(`/wallet/info/${walletId}`),
-  exportPrivateKey: () => api.get('/wallet/export-key'),
+  exportKeystore: (data) => api.post('/wallet/export-keystore', data),
+  importKeystore: (data) => api.post('/wallet/import-keystore', data),
+  getAddresses: (includeChange = false) => api.get(`/wallet/addresses?includeChange=${includeChange}`),
+  newReceiveAddress: () => api.post('/wallet/addresses'),
+  signMessage: (message, address) => api.post('/wallet/sign-message', { message, address }),
+  verifyMessage: (data) => api.post('/wallet/verify-message', data),
   getBeneficiaries: () => api.get('/wallet/beneficiaries'),
   addBeneficiary: (data) => api.post('/wallet/beneficiaries', data),
   deleteBeneficiary: (id) => api.delete(`/wallet/beneficiaries/${id}`),
@@ -62,6 +69,29 @@ export const transactionAPI = {
   getMyTransactions: () => api.get('/transaction/my-transactions'),
   getTransaction: (txId) => api.get(`/transaction/${txId}`),
   getStats: () => api.get('/transaction/stats'),
+  lockToScript: (data) => api.post('/transaction/script/lock', data),
+  previewScriptSpend: (data) => api.post('/transaction/script/preview', data),
+  spendScriptOutputs: (data) => api.post('/transaction/script/spend', data),
+  decodeScript: (script) => api.post('/transaction/script/decode', { script }),
+};
+
+// Multisig API
+export const multisigAPI = {
+  createWallet: (data) => api.post('/multisig/wallets', data),
+  getMyWallets: () => api.get('/multisig/wallets'),
+  proposeSpend: (data) => api.post('/multisig/spends', data),
+  getSpends: (status = '') => api.get(`/multisig/spends${status ? `?status=${status}` : ''}`),
+  getSpend: (id) => api.get(`/multisig/spends/${id}`),
+  signSpend: (id, signatures = []) => api.post(`/multisig/spends/${id}/sign`, { signatures }),
+};
+
+// HTLC API
+export const htlcAPI = {
+  create: (data) => api.post('/htlc', data),
+  getMine: (status = '') => api.get(`/htlc${status ? `?status=${status}` : ''}`),
+  get: (id) => api.get(`/htlc/${id}`),
+  claim: (id, preimage) => api.post(`/htlc/${id}/claim`, { preimage }),
+  refund: (id) => api.post(`/htlc/${id}/refund`),
 };
 
 // Blockchain API
@@ -70,6 +100,7 @@ export const blockchainAPI = {
   getBlocks: (page = 1, limit = 10) => api.get(`/blockchain/blocks?page=${page}&limit=${limit}`),
   getBlock: (identifier) => api.get(`/blockchain/block/${identifier}`),
   getLatestBlock: () => api.get('/blockchain/latest'),
+  getMerkleProof: (txId) => api.get(`/blockchain/proof/${txId}`),
   getMiningStatus: () => api.get('/blockchain/mining-status'),
   validate: () => api.get('/blockchain/validate'),
   createGenesis: (token) => api.post('/blockchain/genesis', {}, {
@@ -78,6 +109,24 @@ export const blockchainAPI = {
   mine: (token) => api.post('/blockchain/mine', {}, {
     headers: { Authorization: `Bearer ${token}` }
   }),
+  getMiningJobs: (token) => api.get('/blockchain/mining-jobs', {
+    headers: { Authorization: `Bearer ${token}` }
+  }),
+  getMiningJob: (jobId, token) => api.get(`/blockchain/mining-jobs/${jobId}`, {
+    headers: { Authorization: `Bearer ${token}` }
+  }),
+  cancelMiningJob: (jobId, token) => api.post(`/blockchain/mining-jobs/${jobId}/cancel`, {}, {
+    headers: { Authorization: `Bearer ${token}` }
+  }),
+  getPoolStats: () => api.get('/blockchain/pool/stats'),
+  getPoolWorkers: () => api.get('/blockchain/pool/workers'),
+  getBlockTemplate: (extraNonce, token) => api.get('/blockchain/template', {
+    params: { extraNonce },
+    headers: { Authorization: `Bearer ${token}` }
+  }),
+  submitBlock: (data, token) => api.post('/blockchain/submit', data, {
+    headers: { Authorization: `Bearer ${token}` }
+  }),
   getMyBlocks: (token) => api.get('/blockchain/my-blocks', {
     headers: { Authorization: `Bearer ${token}` }
   }),
@@ -156,6 +205,8 @@ const apiService = {
   wallet: walletAPI,
   utxo: utxoAPI,
   transaction: transactionAPI,
+  multisig: multisigAPI,
+  htlc: htlcAPI,
   blockchain: blockchainAPI,
   zakat: zakatAPI,
   logs: logsAPI,